@@ -0,0 +1,78 @@
+// Package authz decides what users may do, from the permissions granted to
+// their role.
+package authz
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// refreshInterval bounds how long a grant changed by another instance goes
+// unnoticed. Changes made through this instance apply at once.
+const refreshInterval = time.Minute
+
+// Permission returns the name of the permission for action on resource,
+// e.g. location:delete.
+func Permission(resource, action string) string {
+	return resource + ":" + action
+}
+
+// Policy answers permission checks from the grants stored in the
+// database, which it caches.
+type Policy struct {
+	db *db.DB
+
+	mu       sync.RWMutex
+	grants   map[string]map[string]bool
+	loadedAt time.Time
+}
+
+// NewPolicy returns a policy reading grants from dbConn. They are loaded
+// on first use.
+func NewPolicy(dbConn *db.DB) *Policy {
+	return &Policy{db: dbConn}
+}
+
+// Allows reports whether users with role may take action on resource. The
+// admin role may do anything, so that admins cannot lock themselves out.
+func (p *Policy) Allows(role, resource, action string) (bool, error) {
+	if role == models.UserRoleAdmin {
+		return true, nil
+	}
+
+	p.mu.RLock()
+	grants, fresh := p.grants, time.Since(p.loadedAt) < refreshInterval
+	p.mu.RUnlock()
+	if !fresh {
+		if err := p.Reload(); err != nil {
+			return false, err
+		}
+		p.mu.RLock()
+		grants = p.grants
+		p.mu.RUnlock()
+	}
+	return grants[role][Permission(resource, action)], nil
+}
+
+// Reload reads the grants again, as after they were changed.
+func (p *Policy) Reload() error {
+	roles, err := p.db.GetRoleGrants()
+	if err != nil {
+		return err
+	}
+	grants := make(map[string]map[string]bool, len(roles))
+	for role, permissions := range roles {
+		grants[role] = make(map[string]bool, len(permissions))
+		for _, permission := range permissions {
+			grants[role][permission] = true
+		}
+	}
+
+	p.mu.Lock()
+	p.grants, p.loadedAt = grants, time.Now()
+	p.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,33 @@
+package config
+
+// CaptchaProvider describes a supported CAPTCHA service: where tokens are
+// verified, and how the signup page renders its widget.
+type CaptchaProvider struct {
+	VerifyURL   string
+	ScriptURL   string
+	WidgetClass string
+}
+
+// CaptchaProviders lists the accepted CAPTCHA_PROVIDER values.
+var CaptchaProviders = map[string]CaptchaProvider{
+	"recaptcha": {
+		VerifyURL:   "https://www.google.com/recaptcha/api/siteverify",
+		ScriptURL:   "https://www.google.com/recaptcha/api.js",
+		WidgetClass: "g-recaptcha",
+	},
+	"hcaptcha": {
+		VerifyURL:   "https://api.hcaptcha.com/siteverify",
+		ScriptURL:   "https://js.hcaptcha.com/1/api.js",
+		WidgetClass: "h-captcha",
+	},
+	"turnstile": {
+		VerifyURL:   "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+		ScriptURL:   "https://challenges.cloudflare.com/turnstile/v0/api.js",
+		WidgetClass: "cf-turnstile",
+	},
+}
+
+// CaptchaEnabled reports whether signups must pass a CAPTCHA check.
+func (c *Config) CaptchaEnabled() bool {
+	return c.CaptchaProvider != "" && c.CaptchaSecret != ""
+}
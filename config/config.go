@@ -1,32 +1,352 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/vikash-parashar/asset-locator/logger"
 )
 
+// MaxLabelRows and MaxLabelCols bound the label sheet layout so that every
+// label stays large enough to hold a scannable QR code.
+const (
+	MaxLabelRows = 20
+	MaxLabelCols = 6
+)
+
+// DefaultJWTSecret is the publicly known fallback JWT secret. It is only
+// acceptable outside of release mode.
+const DefaultJWTSecret = "go-server-secret"
+
 // Config struct to hold configuration parameters
 type Config struct {
-	DBHost         string
-	DBPort         string
-	DBUser         string
-	DBPassword     string
-	DBName         string
-	Port           string
-	JWTSecret      string
-	EmailPassword  string
-	EmailUsername  string
-	UseHTTPS       bool
-	CertFile       string
-	KeyFile        string
-	ExternalServer string
-	ExternalPort   int
-	ExternalUser   string
-	ExternalPass   string
-	Env            string // New field to store the environment name
+	DBHost        string
+	DBPort        string
+	DBUser        string
+	DBPassword    string
+	DBName        string
+	DBSSLMode     string
+	DBSSLRootCert string
+	Port          string
+
+	// DBReadAttempts is how often a read query is tried when it fails with a
+	// transient error, waiting DBReadRetryBackoff, doubled on each retry,
+	// in between. Writes are never retried.
+	DBReadAttempts     int
+	DBReadRetryBackoff time.Duration
+
+	// AutoMigrate applies pending migrations at startup, so a development
+	// database needs no separate -migrate up run. Off by default so that
+	// production schema changes stay deliberate.
+	AutoMigrate bool
+
+	JWTSecret     string
+	EmailPassword string
+	EmailUsername string
+	UseHTTPS      bool
+	CertFile      string
+	KeyFile       string
+
+	// TLSMinVersion is the lowest TLS version accepted over HTTPS, "1.2" or
+	// "1.3". TLSCipherSuites optionally restricts the TLS 1.2 cipher suites.
+	TLSMinVersion   string
+	TLSCipherSuites []string
+	ExternalServer  string
+	ExternalPort    int
+	ExternalUser    string
+	ExternalPass    string
+	Env             string // New field to store the environment name
+	GinMode         string
+
+	// JWTSecretFallback decides what happens when the default JWT secret is
+	// used in release mode: "refuse" to start, or "ephemeral" to generate a
+	// random secret that invalidates all tokens on restart.
+	JWTSecretFallback string
+
+	// JWTSecretMinBytes is the shortest JWT secret accepted in release mode;
+	// a shorter one only logs a warning in development. Tokens are signed
+	// with HS256, whose key should be at least as long as its 32 byte hash.
+	// Zero disables the check.
+	JWTSecretMinBytes int
+
+	// WebAuthMode and APIAuthMode select how the browser pages and the API
+	// accept the JWT: "cookie", "bearer", or "any" for either.
+	WebAuthMode string
+	APIAuthMode string
+
+	// PasswordMaxAgeDays is the maximum age of a password before it must be
+	// rotated. Zero disables the policy.
+	PasswordMaxAgeDays int
+
+	// LoginLockoutThreshold consecutive failed password logins lock an
+	// account for LoginLockoutDuration, doubled for every further failure
+	// up to LoginLockoutMaxDuration. Zero disables the lockout.
+	LoginLockoutThreshold   int
+	LoginLockoutDuration    time.Duration
+	LoginLockoutMaxDuration time.Duration
+
+	// New passwords need PasswordMinLength characters and one of each of
+	// PasswordRequiredClasses (lower, upper, digit, symbol). Common
+	// passwords and those listed in PasswordBannedFile, one per line, are
+	// refused, as are passwords whose zxcvbn score is below
+	// PasswordMinScore; zero skips the score.
+	PasswordMinLength       int
+	PasswordRequiredClasses []string
+	PasswordBannedFile      string
+	PasswordMinScore        int
+
+	// PasswordHistorySize is how many of a user's latest passwords, the
+	// current one included, a reset or change may not reuse. Zero only
+	// refuses the current password.
+	PasswordHistorySize int
+
+	// ResetRequestsPerWindow caps the password reset requests for one account
+	// within ResetRequestWindow.
+	ResetRequestsPerWindow int
+	ResetRequestWindow     time.Duration
+
+	// ResetSessionTTL is how long the form opened by a reset link may be
+	// submitted; the reset token itself is kept on the server meanwhile.
+	ResetSessionTTL time.Duration
+
+	// MagicLinkTTL is how long an emailed one-time login link stays valid.
+	// Magic links count towards ResetRequestsPerWindow like reset emails.
+	MagicLinkTTL time.Duration
+
+	// WebAuthnRPID is the domain passkeys are bound to and WebAuthnRPName
+	// the name authenticators show for it. WebAuthnOrigins lists the
+	// origins the login pages are served from. PasskeyChallengeTTL is how
+	// long a passkey registration or login may take, and at most
+	// PasskeyMaxChallenges may be in progress at once. Each client IP may
+	// start PasskeyLoginsPerMinute logins, with bursts of
+	// PasskeyLoginsBurst.
+	WebAuthnRPID           string
+	WebAuthnRPName         string
+	WebAuthnOrigins        []string
+	PasskeyChallengeTTL    time.Duration
+	PasskeyMaxChallenges   int
+	PasskeyLoginsPerMinute float64
+	PasskeyLoginsBurst     int
+
+	// Google and GitHub social login are enabled by setting the client id
+	// and secret of an OAuth app. OAuthCallbackBaseURL is the public URL
+	// the providers redirect back to, e.g. https://assets.example.com; the
+	// SAML endpoints are served below it too.
+	GoogleClientID       string
+	GoogleClientSecret   string
+	GitHubClientID       string
+	GitHubClientSecret   string
+	OAuthCallbackBaseURL string
+
+	// Single sign-on with an OpenID Connect provider such as Keycloak, Okta
+	// or Azure AD is enabled by setting OIDCIssuer and the client. When
+	// OIDCRoleClaim names a claim, users registered by their first login
+	// become admins if it lists one of OIDCAdminRoles and general users
+	// otherwise.
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCDisplayName  string
+	OIDCScopes       []string
+	OIDCRoleClaim    string
+	OIDCAdminRoles   []string
+
+	// Single sign-on with a SAML 2.0 identity provider is enabled by
+	// setting the IdP metadata, by URL or file. SAMLCertFile and
+	// SAMLKeyFile optionally hold the key pair that signs requests and
+	// decrypts assertions. When SAMLRoleAttribute names an attribute, users
+	// registered by their first login become admins if it lists one of
+	// SAMLAdminRoles and general users otherwise.
+	SAMLIDPMetadataURL  string
+	SAMLIDPMetadataFile string
+	SAMLEntityID        string
+	SAMLCertFile        string
+	SAMLKeyFile         string
+	SAMLDisplayName     string
+	SAMLEmailAttribute  string
+	SAMLRoleAttribute   string
+	SAMLAdminRoles      []string
+
+	// EmailRatePerMinute limits outgoing emails to protect SMTP sending
+	// quotas, allowing bursts of up to EmailBurst. Zero disables the limit.
+	EmailRatePerMinute float64
+	EmailBurst         int
+
+	// SessionDuration is the lifetime of a normal login session, and
+	// RememberMeDuration that of a login with "remember me" checked. Neither
+	// may exceed SessionMaxLifetime.
+	SessionDuration    time.Duration
+	RememberMeDuration time.Duration
+	SessionMaxLifetime time.Duration
+
+	// AccessTokenDuration is the lifetime of the access token issued with a
+	// refresh token, and RefreshTokenDuration that of each refresh token.
+	// A chain of refreshes never outlives SessionMaxLifetime.
+	AccessTokenDuration  time.Duration
+	RefreshTokenDuration time.Duration
+
+	// APIKeyDefaultTTL is the lifetime of an API key created without an
+	// expiry, and APIKeyMaxTTL the longest lifetime a key may be given.
+	APIKeyDefaultTTL time.Duration
+	APIKeyMaxTTL     time.Duration
+
+	// RequestTimeout bounds every request; ExportRequestTimeout replaces it
+	// on the PDF, Excel and CSV export routes. Zero disables the limit.
+	RequestTimeout       time.Duration
+	ExportRequestTimeout time.Duration
+
+	// ShutdownTimeout is how long in-flight requests may take to finish
+	// after SIGINT or SIGTERM before the server stops anyway.
+	ShutdownTimeout time.Duration
+
+	// EmailDrainTimeout is how long queued emails may take to be delivered
+	// at shutdown, after the requests have finished.
+	EmailDrainTimeout time.Duration
+
+	// MaxEventStreams caps the device event streams open at once, and
+	// EventHeartbeat is how often an idle stream sends a keep-alive comment.
+	MaxEventStreams int
+	EventHeartbeat  time.Duration
+
+	// EventBatchWindow coalesces the device events of a stream arriving
+	// within it into one batch, flushed early once EventBatchMax devices
+	// changed. Zero sends every event on its own.
+	EventBatchWindow time.Duration
+	EventBatchMax    int
+
+	// HealthCheckTimeout bounds each dependency check of the detailed health
+	// endpoint. HealthCheckSMTP adds a connection test to the SMTP server,
+	// and DetailedHealthAdminOnly limits the endpoint to admins.
+	HealthCheckTimeout      time.Duration
+	HealthCheckSMTP         bool
+	DetailedHealthAdminOnly bool
+
+	// MaxConcurrentPerIP caps the requests a single client IP may have in
+	// flight at once. Zero disables the limit.
+	MaxConcurrentPerIP int
+
+	// GzipLevel is the compression level of textual responses, 1 (fastest)
+	// to 9 (smallest). Zero disables compression.
+	GzipLevel int
+
+	// RequestIDHeader carries the id correlating a request across services;
+	// it is accepted from clients, echoed in responses and forwarded on
+	// outbound calls.
+	RequestIDHeader string
+
+	// StrictJSON rejects JSON request bodies with fields the handler does not
+	// expect, instead of silently ignoring them.
+	StrictJSON bool
+
+	// MaxUploadBytes limits the body of form uploads creating device records.
+	MaxUploadBytes int64
+
+	// MaxImportBytes limits the size of a CSV user import.
+	MaxImportBytes int64
+
+	// AvatarDir is where avatar thumbnails are stored, and MaxAvatarBytes
+	// limits the size of an avatar upload.
+	AvatarDir      string
+	MaxAvatarBytes int64
+
+	// TrustedProxies lists the proxies, as addresses or CIDR ranges, whose
+	// X-Forwarded-For header gives the client IP. Empty trusts no proxy and
+	// uses the address of the connection.
+	TrustedProxies []string
+
+	// AdminAllowedIPs and AdminDeniedIPs restrict the admin routes by client
+	// IP. An address in the allowlist is always let through; otherwise it is
+	// refused if the allowlist is not empty or the address is in the
+	// denylist. Both empty means no restriction.
+	AdminAllowedIPs []string
+	AdminDeniedIPs  []string
+
+	// AllowedEmailDomains restricts self-registration to these email domains.
+	// Entries such as "*.example.com" match any subdomain. Empty allows all.
+	AllowedEmailDomains []string
+
+	// UniquePhones rejects a user whose phone number, once normalized,
+	// already belongs to another user.
+	UniquePhones bool
+
+	// AvailabilityChecksPerMinute limits how often a client IP may ask
+	// whether an email is taken, with bursts of AvailabilityChecksBurst, to
+	// slow down enumeration of accounts.
+	AvailabilityChecksPerMinute float64
+	AvailabilityChecksBurst     int
+
+	// MinAccountAge is how old a non-admin account must be before it may
+	// delete records or upsert devices through the API. Zero disables it.
+	MinAccountAge time.Duration
+
+	// FirstUserIsAdmin gives the admin role to the user who signs up while
+	// there are no users yet, to bootstrap a new installation.
+	FirstUserIsAdmin bool
+
+	// UsersBatchMaxIDs caps the number of ids accepted by GET /api/v1/users.
+	UsersBatchMaxIDs int
+
+	// TrailingSlashMode is either "redirect" (301/307 to the canonical path)
+	// or "rewrite" (serve the canonical route without a redirect).
+	TrailingSlashMode string
+
+	// SPAMode serves SPAIndex for unknown non-API paths so a single-page app
+	// served from ./static can handle deep links with client-side routing.
+	SPAMode  bool
+	SPAIndex string
+
+	// BarcodeWidth and BarcodeHeight are the default pixel dimensions of
+	// generated device barcodes.
+	BarcodeWidth  int
+	BarcodeHeight int
+
+	// CaptchaProvider enables CAPTCHA verification of signups with one of
+	// CaptchaProviders. CaptchaVerifyURL defaults to the provider's endpoint,
+	// and CaptchaSiteKey renders the widget on the signup page.
+	CaptchaProvider  string
+	CaptchaSecret    string
+	CaptchaSiteKey   string
+	CaptchaVerifyURL string
+
+	// LabelRows and LabelCols are the default layout of a printed label
+	// sheet, and MaxLabelsPerRequest caps the labels in one sheet request.
+	LabelRows           int
+	LabelCols           int
+	MaxLabelsPerRequest int
+
+	// StocktakeIntervalDays is how often every device should be physically
+	// verified; devices not audited within it are reported as overdue.
+	StocktakeIntervalDays int
+
+	// CustomFields lists the custom fields devices may have, as name:type
+	// with a type of string, number or boolean. Empty disables them.
+	CustomFields []string
+
+	// RequireDeviceApproval keeps new and moved devices out of the listings
+	// until an admin approves them.
+	RequireDeviceApproval bool
+
+	// LogBodies logs request and response bodies, with sensitive fields
+	// redacted and truncated to LogBodyMaxBytes. Development only.
+	LogBodies       bool
+	LogBodyMaxBytes int
+
+	// StorageStatsInterval is how often table sizes are collected for
+	// /api/v1/admin/stats/storage. Zero disables the collector.
+	StorageStatsInterval time.Duration
+
+	// GraphQLEnabled exposes the read-only /api/v1/graphql endpoint.
+	GraphQLEnabled bool
 }
 
 // LoadConfig loads configuration from environment variables and a specific config file
@@ -45,48 +365,755 @@ func LoadConfig() *Config {
 		}
 	}
 
-	return &Config{
-		DBHost:         getEnv("DB_HOST", "localhost"),
-		DBPort:         getEnv("DB_PORT", "5432"),
-		DBUser:         getEnv("DB_USER", "postgres"),
-		DBPassword:     getEnv("DB_PASSWORD", "postgres"),
-		DBName:         getEnv("DB_NAME", "asset-locator"),
-		Port:           getEnv("PORT", "8080"),
-		JWTSecret:      getEnv("JWT_SECRET", "go-server-secret"),
-		EmailPassword:  getEnv("EMAIL_PASSWORD", ""),
-		EmailUsername:  getEnv("EMAIL_USERNAME", ""),
-		UseHTTPS:       getEnvAsBool("USE_HTTPS", false),
-		CertFile:       getEnv("CERT_FILE", ""),
-		KeyFile:        getEnv("KEY_FILE", ""),
-		ExternalServer: getEnv("S_SERVER", ""),
-		ExternalPort:   getEnvAsInt("S_PORT", 0),
-		ExternalUser:   getEnv("S_USER", ""),
-		ExternalPass:   getEnv("S_PASS", ""),
-		Env:            env,
+	cfg := &Config{
+		DBHost:        getEnv("DB_HOST", "localhost"),
+		DBPort:        getEnv("DB_PORT", "5432"),
+		DBUser:        getEnv("DB_USER", "postgres"),
+		DBPassword:    getEnv("DB_PASSWORD", "postgres"),
+		DBName:        getEnv("DB_NAME", "asset-locator"),
+		DBSSLMode:     getEnv("DB_SSLMODE", "disable"),
+		DBSSLRootCert: getEnv("DB_SSLROOTCERT", ""),
+		Port:          canonicalPort(getEnv("PORT", "8080")),
+
+		DBReadAttempts:     getEnvAsInt("DB_READ_ATTEMPTS", 3),
+		DBReadRetryBackoff: getEnvAsDuration("DB_READ_RETRY_BACKOFF", 100*time.Millisecond),
+		AutoMigrate:        getEnvAsBool("AUTO_MIGRATE", false),
+
+		JWTSecret:     getEnv("JWT_SECRET", DefaultJWTSecret),
+		EmailPassword: getEnv("EMAIL_PASSWORD", ""),
+		EmailUsername: getEnv("EMAIL_USERNAME", ""),
+		UseHTTPS:      getEnvAsBool("USE_HTTPS", false),
+		CertFile:      getEnv("CERT_FILE", ""),
+		KeyFile:       getEnv("KEY_FILE", ""),
+
+		TLSMinVersion:   getEnv("TLS_MIN_VERSION", "1.2"),
+		TLSCipherSuites: getEnvAsList("TLS_CIPHER_SUITES"),
+		ExternalServer:  getEnv("S_SERVER", ""),
+		ExternalPort:    getEnvAsInt("S_PORT", 0),
+		ExternalUser:    getEnv("S_USER", ""),
+		ExternalPass:    getEnv("S_PASS", ""),
+		Env:             env,
+		GinMode:         getEnv("GIN_MODE", "debug"),
+
+		JWTSecretFallback: getEnv("JWT_SECRET_FALLBACK", "refuse"),
+		JWTSecretMinBytes: getEnvAsInt("JWT_SECRET_MIN_BYTES", 32),
+		WebAuthMode:       getEnv("WEB_AUTH_MODE", "any"),
+		APIAuthMode:       getEnv("API_AUTH_MODE", "any"),
+
+		PasswordMaxAgeDays: getEnvAsInt("PASSWORD_MAX_AGE_DAYS", 0),
+		GraphQLEnabled:     getEnvAsBool("GRAPHQL_ENABLED", false),
+		LogBodies:          getEnvAsBool("LOG_BODIES", false),
+		LogBodyMaxBytes:    getEnvAsInt("LOG_BODY_MAX_BYTES", 4096),
+		BarcodeWidth:       getEnvAsInt("BARCODE_WIDTH", 300),
+		BarcodeHeight:      getEnvAsInt("BARCODE_HEIGHT", 100),
+
+		LoginLockoutThreshold:   getEnvAsInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+		LoginLockoutDuration:    getEnvAsDuration("LOGIN_LOCKOUT_DURATION", time.Minute),
+		LoginLockoutMaxDuration: getEnvAsDuration("LOGIN_LOCKOUT_MAX_DURATION", time.Hour),
+
+		PasswordMinLength:       getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordRequiredClasses: getEnvAsList("PASSWORD_REQUIRED_CLASSES"),
+		PasswordBannedFile:      getEnv("PASSWORD_BANNED_FILE", ""),
+		PasswordMinScore:        getEnvAsInt("PASSWORD_MIN_SCORE", 0),
+
+		PasswordHistorySize: getEnvAsInt("PASSWORD_HISTORY_SIZE", 5),
+
+		EmailRatePerMinute: float64(getEnvAsInt("EMAIL_RATE_PER_MINUTE", 30)),
+		EmailBurst:         getEnvAsInt("EMAIL_BURST", 5),
+
+		ResetRequestsPerWindow: getEnvAsInt("RESET_REQUESTS_PER_WINDOW", 3),
+		ResetRequestWindow:     getEnvAsDuration("RESET_REQUEST_WINDOW", time.Hour),
+		ResetSessionTTL:        getEnvAsDuration("RESET_SESSION_TTL", 10*time.Minute),
+		MagicLinkTTL:           getEnvAsDuration("MAGIC_LINK_TTL", 15*time.Minute),
+
+		WebAuthnRPID:           getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:         getEnv("WEBAUTHN_RP_NAME", "Asset Locator"),
+		WebAuthnOrigins:        getEnvAsList("WEBAUTHN_ORIGINS"),
+		PasskeyChallengeTTL:    getEnvAsDuration("PASSKEY_CHALLENGE_TTL", 5*time.Minute),
+		PasskeyMaxChallenges:   getEnvAsInt("PASSKEY_MAX_CHALLENGES", 10000),
+		PasskeyLoginsPerMinute: float64(getEnvAsInt("PASSKEY_LOGINS_PER_MINUTE", 10)),
+		PasskeyLoginsBurst:     getEnvAsInt("PASSKEY_LOGINS_BURST", 5),
+
+		GoogleClientID:       getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:   getEnv("GOOGLE_CLIENT_SECRET", ""),
+		GitHubClientID:       getEnv("GITHUB_CLIENT_ID", ""),
+		GitHubClientSecret:   getEnv("GITHUB_CLIENT_SECRET", ""),
+		OAuthCallbackBaseURL: strings.TrimSuffix(getEnv("OAUTH_CALLBACK_BASE_URL", ""), "/"),
+
+		OIDCIssuer:       getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCDisplayName:  getEnv("OIDC_DISPLAY_NAME", "SSO"),
+		OIDCScopes:       getEnvAsList("OIDC_SCOPES"),
+		OIDCRoleClaim:    getEnv("OIDC_ROLE_CLAIM", ""),
+		OIDCAdminRoles:   getEnvAsList("OIDC_ADMIN_ROLES"),
+
+		SAMLIDPMetadataURL:  getEnv("SAML_IDP_METADATA_URL", ""),
+		SAMLIDPMetadataFile: getEnv("SAML_IDP_METADATA_FILE", ""),
+		SAMLEntityID:        getEnv("SAML_ENTITY_ID", ""),
+		SAMLCertFile:        getEnv("SAML_CERT_FILE", ""),
+		SAMLKeyFile:         getEnv("SAML_KEY_FILE", ""),
+		SAMLDisplayName:     getEnv("SAML_DISPLAY_NAME", "SSO"),
+		SAMLEmailAttribute:  getEnv("SAML_EMAIL_ATTRIBUTE", ""),
+		SAMLRoleAttribute:   getEnv("SAML_ROLE_ATTRIBUTE", ""),
+		SAMLAdminRoles:      getEnvAsList("SAML_ADMIN_ROLES"),
+
+		StocktakeIntervalDays: getEnvAsInt("STOCKTAKE_INTERVAL_DAYS", 90),
+		StorageStatsInterval:  getEnvAsDuration("STORAGE_STATS_INTERVAL", 5*time.Minute),
+		RequireDeviceApproval: getEnvAsBool("REQUIRE_DEVICE_APPROVAL", false),
+		CustomFields:          getEnvAsList("CUSTOM_FIELDS"),
+
+		CaptchaProvider:  strings.ToLower(getEnv("CAPTCHA_PROVIDER", "")),
+		CaptchaSecret:    getEnv("CAPTCHA_SECRET", ""),
+		CaptchaSiteKey:   getEnv("CAPTCHA_SITE_KEY", ""),
+		CaptchaVerifyURL: getEnv("CAPTCHA_VERIFY_URL", ""),
+
+		LabelRows:           getEnvAsInt("LABEL_ROWS", 8),
+		LabelCols:           getEnvAsInt("LABEL_COLS", 3),
+		MaxLabelsPerRequest: getEnvAsInt("MAX_LABELS_PER_REQUEST", 500),
+
+		SessionDuration:    getEnvAsDuration("SESSION_DURATION", time.Hour),
+		RememberMeDuration: getEnvAsDuration("REMEMBER_ME_DURATION", 30*24*time.Hour),
+		SessionMaxLifetime: getEnvAsDuration("SESSION_MAX_LIFETIME", 30*24*time.Hour),
+
+		AccessTokenDuration:  getEnvAsDuration("ACCESS_TOKEN_DURATION", 15*time.Minute),
+		RefreshTokenDuration: getEnvAsDuration("REFRESH_TOKEN_DURATION", 30*24*time.Hour),
+
+		APIKeyDefaultTTL: getEnvAsDuration("API_KEY_DEFAULT_TTL", 90*24*time.Hour),
+		APIKeyMaxTTL:     getEnvAsDuration("API_KEY_MAX_TTL", 365*24*time.Hour),
+
+		RequestTimeout:       getEnvAsDuration("REQUEST_TIMEOUT", 30*time.Second),
+		ExportRequestTimeout: getEnvAsDuration("EXPORT_REQUEST_TIMEOUT", 5*time.Minute),
+		ShutdownTimeout:      getEnvAsDuration("SHUTDOWN_TIMEOUT", 15*time.Second),
+		EmailDrainTimeout:    getEnvAsDuration("EMAIL_DRAIN_TIMEOUT", 5*time.Second),
+
+		MaxUploadBytes: int64(getEnvAsInt("MAX_UPLOAD_BYTES", 10<<20)),
+		MaxImportBytes: int64(getEnvAsInt("MAX_IMPORT_BYTES", 1<<20)),
+
+		MaxConcurrentPerIP: getEnvAsInt("MAX_CONCURRENT_PER_IP", 20),
+		GzipLevel:          getEnvAsInt("GZIP_LEVEL", 5),
+		StrictJSON:         getEnvAsBool("STRICT_JSON", false),
+		RequestIDHeader:    http.CanonicalHeaderKey(strings.TrimSpace(getEnv("REQUEST_ID_HEADER", "X-Request-ID"))),
+
+		AvatarDir:      getEnv("AVATAR_DIR", "./uploads/avatars"),
+		MaxAvatarBytes: int64(getEnvAsInt("MAX_AVATAR_BYTES", 2<<20)),
+
+		AllowedEmailDomains: getEnvAsList("ALLOWED_EMAIL_DOMAINS"),
+		UniquePhones:        getEnvAsBool("UNIQUE_PHONES", false),
+		FirstUserIsAdmin:    getEnvAsBool("FIRST_USER_IS_ADMIN", false),
+		UsersBatchMaxIDs:    getEnvAsInt("USERS_BATCH_MAX_IDS", 100),
+		TrailingSlashMode:   getEnv("TRAILING_SLASH_MODE", "redirect"),
+		SPAMode:             getEnvAsBool("SPA_MODE", false),
+		SPAIndex:            getEnv("SPA_INDEX", "./static/index.html"),
+
+		AvailabilityChecksPerMinute: float64(getEnvAsInt("AVAILABILITY_CHECKS_PER_MINUTE", 10)),
+		AvailabilityChecksBurst:     getEnvAsInt("AVAILABILITY_CHECKS_BURST", 5),
+
+		MaxEventStreams: getEnvAsInt("MAX_EVENT_STREAMS", 100),
+		EventHeartbeat:  getEnvAsDuration("EVENT_HEARTBEAT", 30*time.Second),
+
+		EventBatchWindow: getEnvAsDuration("EVENT_BATCH_WINDOW", 0),
+		EventBatchMax:    getEnvAsInt("EVENT_BATCH_MAX", 100),
+
+		HealthCheckTimeout:      getEnvAsDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		HealthCheckSMTP:         getEnvAsBool("HEALTH_CHECK_SMTP", false),
+		DetailedHealthAdminOnly: getEnvAsBool("DETAILED_HEALTH_ADMIN_ONLY", true),
+
+		MinAccountAge: getEnvAsDuration("MIN_ACCOUNT_AGE", 0),
+
+		TrustedProxies:  getEnvAsList("TRUSTED_PROXIES"),
+		AdminAllowedIPs: getEnvAsList("ADMIN_ALLOWED_IPS"),
+		AdminDeniedIPs:  getEnvAsList("ADMIN_DENIED_IPS"),
+	}
+
+	if provider, ok := CaptchaProviders[cfg.CaptchaProvider]; ok && cfg.CaptchaVerifyURL == "" {
+		cfg.CaptchaVerifyURL = provider.VerifyURL
+	}
+
+	if len(cfg.WebAuthnOrigins) == 0 {
+		cfg.WebAuthnOrigins = []string{"http://localhost:" + cfg.Port}
+	}
+	if cfg.OAuthCallbackBaseURL == "" {
+		cfg.OAuthCallbackBaseURL = "http://localhost:" + cfg.Port
+	}
+	if len(cfg.OIDCScopes) == 0 {
+		cfg.OIDCScopes = []string{"openid", "email", "profile"}
+	}
+	if cfg.SAMLEntityID == "" {
+		cfg.SAMLEntityID = cfg.OAuthCallbackBaseURL + "/saml/metadata"
+	}
+
+	if cfg.IsRelease() && cfg.JWTSecret == DefaultJWTSecret && cfg.JWTSecretFallback == "ephemeral" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			logger.ErrorLogger.Fatalf("Failed to generate an ephemeral JWT secret: %v", err)
+		}
+		cfg.JWTSecret = hex.EncodeToString(secret)
+		configSources["JWT_SECRET"] = "ephemeral"
+		logger.WarningLogger.Println("JWT_SECRET is not set in release mode: using a random ephemeral secret, all tokens will be invalidated on restart")
+	}
+
+	// A single DATABASE_URL, as supplied by most PaaS providers, takes
+	// precedence over the discrete DB_* variables.
+	if databaseURL := getEnv("DATABASE_URL", ""); databaseURL != "" {
+		if err := cfg.applyDatabaseURL(databaseURL); err != nil {
+			logger.ErrorLogger.Fatalf("Invalid DATABASE_URL: %v", err)
+		}
+	}
+
+	return cfg
+}
+
+// canonicalPort trims surrounding whitespace and a leading colon, so that
+// "8080", " 8080 " and ":8080" all mean port 8080.
+func canonicalPort(port string) string {
+	return strings.TrimPrefix(strings.TrimSpace(port), ":")
+}
+
+// applyDatabaseURL populates the DB_* fields from a postgres:// connection URL.
+func (c *Config) applyDatabaseURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return fmt.Errorf("unsupported scheme %q, expected postgres", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return errors.New("missing host")
+	}
+	dbName := strings.TrimPrefix(u.Path, "/")
+	if dbName == "" {
+		return errors.New("missing database name")
+	}
+
+	c.DBHost = u.Hostname()
+	c.DBPort = "5432"
+	if port := u.Port(); port != "" {
+		c.DBPort = port
+	}
+	c.DBName = dbName
+	if u.User != nil {
+		c.DBUser = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			c.DBPassword = password
+		}
+	}
+	if sslMode := u.Query().Get("sslmode"); sslMode != "" {
+		c.DBSSLMode = sslMode
+	}
+
+	for _, key := range []string{"DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME"} {
+		configSources[key] = "DATABASE_URL"
 	}
+	if u.Query().Get("sslmode") != "" {
+		configSources["DB_SSLMODE"] = "DATABASE_URL"
+	}
+	return nil
+}
+
+// sslModes lists the sslmode values understood by lib/pq.
+var sslModes = map[string]bool{
+	"disable":     true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// authModes lists the ways a route group may accept the JWT.
+var authModes = map[string]bool{
+	"any":    true,
+	"cookie": true,
+	"bearer": true,
 }
 
+// Validate checks combinations of settings that cannot be caught while
+// reading individual values. It is called once at startup.
+func (c *Config) Validate() error {
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid PORT %q, expected a number between 1 and 65535", c.Port)
+	}
+	if c.JWTSecretFallback != "refuse" && c.JWTSecretFallback != "ephemeral" {
+		return fmt.Errorf("invalid JWT_SECRET_FALLBACK %q, expected refuse or ephemeral", c.JWTSecretFallback)
+	}
+	if c.IsRelease() && c.JWTSecret == DefaultJWTSecret {
+		return errors.New("JWT_SECRET must be set in release mode, the default secret is publicly known")
+	}
+	if c.JWTSecretMinBytes < 0 {
+		return errors.New("JWT_SECRET_MIN_BYTES must not be negative")
+	}
+	if len(c.JWTSecret) < c.JWTSecretMinBytes {
+		if c.IsRelease() {
+			return fmt.Errorf("JWT_SECRET is %d bytes long, at least %d are required in release mode", len(c.JWTSecret), c.JWTSecretMinBytes)
+		}
+		logger.WarningLogger.Printf("JWT_SECRET is only %d bytes long, use at least %d in production", len(c.JWTSecret), c.JWTSecretMinBytes)
+	}
+	if !authModes[c.WebAuthMode] || !authModes[c.APIAuthMode] {
+		return fmt.Errorf("invalid WEB_AUTH_MODE %q or API_AUTH_MODE %q, expected cookie, bearer or any", c.WebAuthMode, c.APIAuthMode)
+	}
+	if !sslModes[c.DBSSLMode] {
+		return fmt.Errorf("invalid DB_SSLMODE %q, expected one of disable, require, verify-ca, verify-full", c.DBSSLMode)
+	}
+	if c.DBSSLMode == "verify-ca" || c.DBSSLMode == "verify-full" {
+		if c.DBSSLRootCert == "" {
+			return fmt.Errorf("DB_SSLROOTCERT is required when DB_SSLMODE is %s", c.DBSSLMode)
+		}
+		if _, err := os.Stat(c.DBSSLRootCert); err != nil {
+			return fmt.Errorf("DB_SSLROOTCERT is not readable: %v", err)
+		}
+	}
+	if c.DBReadAttempts < 1 || c.DBReadRetryBackoff < 0 {
+		return errors.New("DB_READ_ATTEMPTS must be at least 1 and DB_READ_RETRY_BACKOFF must not be negative")
+	}
+	if c.SPAMode {
+		if _, err := os.Stat(c.SPAIndex); err != nil {
+			return fmt.Errorf("SPA_INDEX is not readable: %v", err)
+		}
+	}
+	if c.SessionDuration <= 0 || c.RememberMeDuration <= 0 || c.SessionMaxLifetime <= 0 {
+		return errors.New("SESSION_DURATION, REMEMBER_ME_DURATION and SESSION_MAX_LIFETIME must be positive")
+	}
+	if c.AccessTokenDuration <= 0 || c.RefreshTokenDuration <= 0 {
+		return errors.New("ACCESS_TOKEN_DURATION and REFRESH_TOKEN_DURATION must be positive")
+	}
+	if c.LoginLockoutThreshold < 0 {
+		return errors.New("LOGIN_LOCKOUT_THRESHOLD must not be negative")
+	}
+	if c.LoginLockoutThreshold > 0 && (c.LoginLockoutDuration <= 0 || c.LoginLockoutMaxDuration < c.LoginLockoutDuration) {
+		return errors.New("LOGIN_LOCKOUT_DURATION must be positive and at most LOGIN_LOCKOUT_MAX_DURATION")
+	}
+	if c.PasswordMaxAgeDays < 0 {
+		return errors.New("PASSWORD_MAX_AGE_DAYS must not be negative")
+	}
+	if c.PasswordMinLength < 1 || c.PasswordMinLength > 72 {
+		return errors.New("PASSWORD_MIN_LENGTH must be between 1 and 72")
+	}
+	for _, class := range c.PasswordRequiredClasses {
+		if !passwordClasses[class] {
+			return fmt.Errorf("invalid PASSWORD_REQUIRED_CLASSES entry %q, expected lower, upper, digit or symbol", class)
+		}
+	}
+	if c.PasswordMinScore < 0 || c.PasswordMinScore > 4 {
+		return errors.New("PASSWORD_MIN_SCORE must be between 0 and 4")
+	}
+	if _, err := c.BannedPasswords(); err != nil {
+		return err
+	}
+	if c.PasswordHistorySize < 0 {
+		return errors.New("PASSWORD_HISTORY_SIZE must not be negative")
+	}
+	if c.APIKeyDefaultTTL <= 0 || c.APIKeyMaxTTL < c.APIKeyDefaultTTL {
+		return errors.New("API_KEY_DEFAULT_TTL must be positive and at most API_KEY_MAX_TTL")
+	}
+	if c.TrailingSlashMode != "redirect" && c.TrailingSlashMode != "rewrite" {
+		return fmt.Errorf("invalid TRAILING_SLASH_MODE %q, expected redirect or rewrite", c.TrailingSlashMode)
+	}
+	if c.StocktakeIntervalDays <= 0 {
+		return errors.New("STOCKTAKE_INTERVAL_DAYS must be positive")
+	}
+	if c.EmailRatePerMinute < 0 || c.EmailBurst <= 0 {
+		return errors.New("EMAIL_RATE_PER_MINUTE must not be negative and EMAIL_BURST must be positive")
+	}
+	if c.ResetRequestsPerWindow <= 0 || c.ResetRequestWindow <= 0 {
+		return errors.New("RESET_REQUESTS_PER_WINDOW and RESET_REQUEST_WINDOW must be positive")
+	}
+	if c.ResetSessionTTL <= 0 || c.MagicLinkTTL <= 0 {
+		return errors.New("RESET_SESSION_TTL and MAGIC_LINK_TTL must be positive")
+	}
+	if c.WebAuthnRPID == "" || c.PasskeyChallengeTTL <= 0 {
+		return errors.New("WEBAUTHN_RP_ID must be set and PASSKEY_CHALLENGE_TTL must be positive")
+	}
+	if c.PasskeyMaxChallenges <= 0 || c.PasskeyLoginsPerMinute <= 0 || c.PasskeyLoginsBurst <= 0 {
+		return errors.New("PASSKEY_MAX_CHALLENGES, PASSKEY_LOGINS_PER_MINUTE and PASSKEY_LOGINS_BURST must be positive")
+	}
+	for _, origin := range c.WebAuthnOrigins {
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.Path != "" {
+			return fmt.Errorf("invalid WEBAUTHN_ORIGINS entry %q, expected scheme://host[:port]", origin)
+		}
+	}
+	if (c.GoogleClientID == "") != (c.GoogleClientSecret == "") {
+		return errors.New("GOOGLE_CLIENT_ID and GOOGLE_CLIENT_SECRET must be set together")
+	}
+	if (c.GitHubClientID == "") != (c.GitHubClientSecret == "") {
+		return errors.New("GITHUB_CLIENT_ID and GITHUB_CLIENT_SECRET must be set together")
+	}
+	if u, err := url.Parse(c.OAuthCallbackBaseURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid OAUTH_CALLBACK_BASE_URL %q", c.OAuthCallbackBaseURL)
+	}
+	if c.OIDCIssuer != "" {
+		// Plain http is only good enough for a local test issuer
+		u, err := url.Parse(c.OIDCIssuer)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && (u.Scheme != "http" || c.IsRelease())) {
+			return fmt.Errorf("invalid OIDC_ISSUER %q, expected an https URL", c.OIDCIssuer)
+		}
+		if c.OIDCClientID == "" {
+			return errors.New("OIDC_CLIENT_ID must be set when OIDC_ISSUER is")
+		}
+		if !slices.Contains(c.OIDCScopes, "openid") {
+			return errors.New("OIDC_SCOPES must include openid")
+		}
+		if c.OIDCRoleClaim != "" && len(c.OIDCAdminRoles) == 0 {
+			return errors.New("OIDC_ADMIN_ROLES must be set when OIDC_ROLE_CLAIM is")
+		}
+	}
+	if c.SAMLIDPMetadataURL != "" && c.SAMLIDPMetadataFile != "" {
+		return errors.New("only one of SAML_IDP_METADATA_URL and SAML_IDP_METADATA_FILE may be set")
+	}
+	if c.SAMLIDPMetadataURL != "" {
+		u, err := url.Parse(c.SAMLIDPMetadataURL)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && (u.Scheme != "http" || c.IsRelease())) {
+			return fmt.Errorf("invalid SAML_IDP_METADATA_URL %q, expected an https URL", c.SAMLIDPMetadataURL)
+		}
+	}
+	if c.SAMLEnabled() {
+		if _, err := c.SAMLIDPMetadata(); err != nil {
+			return err
+		}
+		if _, _, err := c.SAMLKeyPair(); err != nil {
+			return err
+		}
+		if c.SAMLRoleAttribute != "" && len(c.SAMLAdminRoles) == 0 {
+			return errors.New("SAML_ADMIN_ROLES must be set when SAML_ROLE_ATTRIBUTE is")
+		}
+	}
+	if _, err := c.TLSConfig(); err != nil {
+		return err
+	}
+	if _, err := parseIPNets("TRUSTED_PROXIES", c.TrustedProxies); err != nil {
+		return err
+	}
+	if _, _, err := c.AdminIPNets(); err != nil {
+		return err
+	}
+	if _, err := c.CustomFieldSchema(); err != nil {
+		return err
+	}
+	if c.UseHTTPS && (c.CertFile == "" || c.KeyFile == "") {
+		return errors.New("CERT_FILE and KEY_FILE are required when USE_HTTPS is enabled")
+	}
+	if c.CaptchaProvider != "" {
+		if _, ok := CaptchaProviders[c.CaptchaProvider]; !ok {
+			return fmt.Errorf("invalid CAPTCHA_PROVIDER %q, expected recaptcha, hcaptcha or turnstile", c.CaptchaProvider)
+		}
+		if c.CaptchaSecret == "" {
+			return errors.New("CAPTCHA_SECRET is required when CAPTCHA_PROVIDER is set")
+		}
+	}
+	if c.LabelRows < 1 || c.LabelRows > MaxLabelRows || c.LabelCols < 1 || c.LabelCols > MaxLabelCols {
+		return fmt.Errorf("LABEL_ROWS must be between 1 and %d and LABEL_COLS between 1 and %d", MaxLabelRows, MaxLabelCols)
+	}
+	if c.MaxLabelsPerRequest <= 0 {
+		return errors.New("MAX_LABELS_PER_REQUEST must be positive")
+	}
+	if c.UsersBatchMaxIDs < 1 {
+		return errors.New("USERS_BATCH_MAX_IDS must be positive")
+	}
+	if c.RequestIDHeader == "" {
+		return errors.New("REQUEST_ID_HEADER must not be empty")
+	}
+	if c.GzipLevel < 0 || c.GzipLevel > 9 {
+		return fmt.Errorf("invalid GZIP_LEVEL %d, expected 1 to 9, or 0 to disable compression", c.GzipLevel)
+	}
+	if c.MaxConcurrentPerIP < 0 {
+		return errors.New("MAX_CONCURRENT_PER_IP must not be negative")
+	}
+	if c.LogBodies && c.IsRelease() {
+		return errors.New("LOG_BODIES must not be enabled in release mode")
+	}
+	if c.LogBodyMaxBytes <= 0 {
+		return errors.New("LOG_BODY_MAX_BYTES must be positive")
+	}
+	if c.MinAccountAge < 0 {
+		return errors.New("MIN_ACCOUNT_AGE must not be negative")
+	}
+	if c.AvailabilityChecksPerMinute <= 0 || c.AvailabilityChecksBurst <= 0 {
+		return errors.New("AVAILABILITY_CHECKS_PER_MINUTE and AVAILABILITY_CHECKS_BURST must be positive")
+	}
+	if c.ShutdownTimeout <= 0 || c.EmailDrainTimeout < 0 {
+		return errors.New("SHUTDOWN_TIMEOUT must be positive and EMAIL_DRAIN_TIMEOUT must not be negative")
+	}
+	if c.MaxEventStreams <= 0 || c.EventHeartbeat <= 0 {
+		return errors.New("MAX_EVENT_STREAMS and EVENT_HEARTBEAT must be positive")
+	}
+	if c.EventBatchWindow < 0 || c.EventBatchMax <= 0 {
+		return errors.New("EVENT_BATCH_WINDOW must not be negative and EVENT_BATCH_MAX must be positive")
+	}
+	if c.HealthCheckTimeout <= 0 {
+		return errors.New("HEALTH_CHECK_TIMEOUT must be positive")
+	}
+	if c.StorageStatsInterval < 0 {
+		return errors.New("STORAGE_STATS_INTERVAL must not be negative")
+	}
+	return nil
+}
+
+// Sources of a configuration value, recorded for startup logging.
+const (
+	sourceEnv     = "env"
+	sourceDefault = "default"
+	sourceInvalid = "default (invalid env value)"
+)
+
+// configSources maps each environment variable read by LoadConfig to where
+// its effective value came from.
+var configSources = map[string]string{}
+
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
+		configSources[key] = sourceEnv
 		return value
 	}
+	configSources[key] = sourceDefault
 	return fallback
 }
 
 func getEnvAsInt(key string, fallback int) int {
 	if value, ok := os.LookupEnv(key); ok {
 		if intValue, err := strconv.Atoi(value); err == nil {
+			configSources[key] = sourceEnv
 			return intValue
 		}
+		configSources[key] = sourceInvalid
+		return fallback
 	}
+	configSources[key] = sourceDefault
 	return fallback
 }
 
 func getEnvAsBool(key string, fallback bool) bool {
 	if value, ok := os.LookupEnv(key); ok {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
+			configSources[key] = sourceEnv
 			return boolValue
 		}
+		configSources[key] = sourceInvalid
+		return fallback
+	}
+	configSources[key] = sourceDefault
+	return fallback
+}
+
+// getEnvAsDuration reads a duration such as "90m" or "720h".
+func getEnvAsDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if duration, err := time.ParseDuration(value); err == nil {
+			configSources[key] = sourceEnv
+			return duration
+		}
+		configSources[key] = sourceInvalid
+		return fallback
 	}
+	configSources[key] = sourceDefault
 	return fallback
 }
+
+// getEnvAsList reads a comma separated list, trimming and lower-casing each
+// entry and dropping empty ones.
+func getEnvAsList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, ""), ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// maskSecret hides a secret value while still showing whether it is set.
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "********"
+}
+
+// configEntry is a single line of the effective configuration.
+type configEntry struct {
+	key    string
+	value  interface{}
+	secret bool
+}
+
+func (c *Config) entries() []configEntry {
+	return []configEntry{
+		{"APP_ENV", c.Env, false},
+		{"GIN_MODE", c.GinMode, false},
+		{"DB_HOST", c.DBHost, false},
+		{"DB_PORT", c.DBPort, false},
+		{"DB_USER", c.DBUser, false},
+		{"DB_PASSWORD", c.DBPassword, true},
+		{"DB_NAME", c.DBName, false},
+		{"DB_SSLMODE", c.DBSSLMode, false},
+		{"DB_SSLROOTCERT", c.DBSSLRootCert, false},
+		{"DB_READ_ATTEMPTS", c.DBReadAttempts, false},
+		{"DB_READ_RETRY_BACKOFF", c.DBReadRetryBackoff, false},
+		{"AUTO_MIGRATE", c.AutoMigrate, false},
+		{"PORT", c.Port, false},
+		{"JWT_SECRET", c.JWTSecret, true},
+		{"JWT_SECRET_FALLBACK", c.JWTSecretFallback, false},
+		{"JWT_SECRET_MIN_BYTES", c.JWTSecretMinBytes, false},
+		{"WEB_AUTH_MODE", c.WebAuthMode, false},
+		{"API_AUTH_MODE", c.APIAuthMode, false},
+		{"EMAIL_USERNAME", c.EmailUsername, false},
+		{"EMAIL_PASSWORD", c.EmailPassword, true},
+		{"EMAIL_RATE_PER_MINUTE", c.EmailRatePerMinute, false},
+		{"EMAIL_BURST", c.EmailBurst, false},
+		{"USE_HTTPS", c.UseHTTPS, false},
+		{"CERT_FILE", c.CertFile, false},
+		{"KEY_FILE", c.KeyFile, false},
+		{"TLS_MIN_VERSION", c.TLSMinVersion, false},
+		{"TLS_CIPHER_SUITES", strings.Join(c.TLSCipherSuites, ","), false},
+		{"S_SERVER", c.ExternalServer, false},
+		{"S_PORT", c.ExternalPort, false},
+		{"S_USER", c.ExternalUser, false},
+		{"S_PASS", c.ExternalPass, true},
+		{"PASSWORD_MAX_AGE_DAYS", c.PasswordMaxAgeDays, false},
+		{"LOGIN_LOCKOUT_THRESHOLD", c.LoginLockoutThreshold, false},
+		{"LOGIN_LOCKOUT_DURATION", c.LoginLockoutDuration, false},
+		{"LOGIN_LOCKOUT_MAX_DURATION", c.LoginLockoutMaxDuration, false},
+		{"PASSWORD_MIN_LENGTH", c.PasswordMinLength, false},
+		{"PASSWORD_REQUIRED_CLASSES", strings.Join(c.PasswordRequiredClasses, ","), false},
+		{"PASSWORD_BANNED_FILE", c.PasswordBannedFile, false},
+		{"PASSWORD_MIN_SCORE", c.PasswordMinScore, false},
+		{"PASSWORD_HISTORY_SIZE", c.PasswordHistorySize, false},
+		{"RESET_REQUESTS_PER_WINDOW", c.ResetRequestsPerWindow, false},
+		{"RESET_REQUEST_WINDOW", c.ResetRequestWindow, false},
+		{"RESET_SESSION_TTL", c.ResetSessionTTL, false},
+		{"MAGIC_LINK_TTL", c.MagicLinkTTL, false},
+		{"WEBAUTHN_RP_ID", c.WebAuthnRPID, false},
+		{"WEBAUTHN_RP_NAME", c.WebAuthnRPName, false},
+		{"WEBAUTHN_ORIGINS", strings.Join(c.WebAuthnOrigins, ","), false},
+		{"PASSKEY_CHALLENGE_TTL", c.PasskeyChallengeTTL, false},
+		{"PASSKEY_MAX_CHALLENGES", c.PasskeyMaxChallenges, false},
+		{"PASSKEY_LOGINS_PER_MINUTE", c.PasskeyLoginsPerMinute, false},
+		{"PASSKEY_LOGINS_BURST", c.PasskeyLoginsBurst, false},
+		{"GOOGLE_CLIENT_ID", c.GoogleClientID, false},
+		{"GOOGLE_CLIENT_SECRET", c.GoogleClientSecret, true},
+		{"GITHUB_CLIENT_ID", c.GitHubClientID, false},
+		{"GITHUB_CLIENT_SECRET", c.GitHubClientSecret, true},
+		{"OAUTH_CALLBACK_BASE_URL", c.OAuthCallbackBaseURL, false},
+		{"OIDC_ISSUER", c.OIDCIssuer, false},
+		{"OIDC_CLIENT_ID", c.OIDCClientID, false},
+		{"OIDC_CLIENT_SECRET", c.OIDCClientSecret, true},
+		{"OIDC_DISPLAY_NAME", c.OIDCDisplayName, false},
+		{"OIDC_SCOPES", strings.Join(c.OIDCScopes, ","), false},
+		{"OIDC_ROLE_CLAIM", c.OIDCRoleClaim, false},
+		{"OIDC_ADMIN_ROLES", strings.Join(c.OIDCAdminRoles, ","), false},
+		{"SAML_IDP_METADATA_URL", c.SAMLIDPMetadataURL, false},
+		{"SAML_IDP_METADATA_FILE", c.SAMLIDPMetadataFile, false},
+		{"SAML_ENTITY_ID", c.SAMLEntityID, false},
+		{"SAML_CERT_FILE", c.SAMLCertFile, false},
+		{"SAML_KEY_FILE", c.SAMLKeyFile, false},
+		{"SAML_DISPLAY_NAME", c.SAMLDisplayName, false},
+		{"SAML_EMAIL_ATTRIBUTE", c.SAMLEmailAttribute, false},
+		{"SAML_ROLE_ATTRIBUTE", c.SAMLRoleAttribute, false},
+		{"SAML_ADMIN_ROLES", strings.Join(c.SAMLAdminRoles, ","), false},
+		{"SESSION_DURATION", c.SessionDuration, false},
+		{"REMEMBER_ME_DURATION", c.RememberMeDuration, false},
+		{"SESSION_MAX_LIFETIME", c.SessionMaxLifetime, false},
+		{"ACCESS_TOKEN_DURATION", c.AccessTokenDuration, false},
+		{"REFRESH_TOKEN_DURATION", c.RefreshTokenDuration, false},
+		{"API_KEY_DEFAULT_TTL", c.APIKeyDefaultTTL, false},
+		{"API_KEY_MAX_TTL", c.APIKeyMaxTTL, false},
+		{"REQUEST_TIMEOUT", c.RequestTimeout, false},
+		{"EXPORT_REQUEST_TIMEOUT", c.ExportRequestTimeout, false},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout, false},
+		{"EMAIL_DRAIN_TIMEOUT", c.EmailDrainTimeout, false},
+		{"MAX_EVENT_STREAMS", c.MaxEventStreams, false},
+		{"EVENT_HEARTBEAT", c.EventHeartbeat, false},
+		{"EVENT_BATCH_WINDOW", c.EventBatchWindow, false},
+		{"EVENT_BATCH_MAX", c.EventBatchMax, false},
+		{"HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout, false},
+		{"HEALTH_CHECK_SMTP", c.HealthCheckSMTP, false},
+		{"DETAILED_HEALTH_ADMIN_ONLY", c.DetailedHealthAdminOnly, false},
+		{"MAX_UPLOAD_BYTES", c.MaxUploadBytes, false},
+		{"MAX_IMPORT_BYTES", c.MaxImportBytes, false},
+		{"MAX_CONCURRENT_PER_IP", c.MaxConcurrentPerIP, false},
+		{"GZIP_LEVEL", c.GzipLevel, false},
+		{"STRICT_JSON", c.StrictJSON, false},
+		{"REQUEST_ID_HEADER", c.RequestIDHeader, false},
+		{"AVATAR_DIR", c.AvatarDir, false},
+		{"MAX_AVATAR_BYTES", c.MaxAvatarBytes, false},
+		{"TRUSTED_PROXIES", strings.Join(c.TrustedProxies, ","), false},
+		{"ADMIN_ALLOWED_IPS", strings.Join(c.AdminAllowedIPs, ","), false},
+		{"ADMIN_DENIED_IPS", strings.Join(c.AdminDeniedIPs, ","), false},
+		{"ALLOWED_EMAIL_DOMAINS", strings.Join(c.AllowedEmailDomains, ","), false},
+		{"UNIQUE_PHONES", c.UniquePhones, false},
+		{"AVAILABILITY_CHECKS_PER_MINUTE", c.AvailabilityChecksPerMinute, false},
+		{"AVAILABILITY_CHECKS_BURST", c.AvailabilityChecksBurst, false},
+		{"FIRST_USER_IS_ADMIN", c.FirstUserIsAdmin, false},
+		{"MIN_ACCOUNT_AGE", c.MinAccountAge, false},
+		{"USERS_BATCH_MAX_IDS", c.UsersBatchMaxIDs, false},
+		{"TRAILING_SLASH_MODE", c.TrailingSlashMode, false},
+		{"SPA_MODE", c.SPAMode, false},
+		{"SPA_INDEX", c.SPAIndex, false},
+		{"GRAPHQL_ENABLED", c.GraphQLEnabled, false},
+		{"BARCODE_WIDTH", c.BarcodeWidth, false},
+		{"BARCODE_HEIGHT", c.BarcodeHeight, false},
+		{"STOCKTAKE_INTERVAL_DAYS", c.StocktakeIntervalDays, false},
+		{"REQUIRE_DEVICE_APPROVAL", c.RequireDeviceApproval, false},
+		{"CUSTOM_FIELDS", strings.Join(c.CustomFields, ","), false},
+		{"STORAGE_STATS_INTERVAL", c.StorageStatsInterval, false},
+		{"CAPTCHA_PROVIDER", c.CaptchaProvider, false},
+		{"CAPTCHA_SECRET", c.CaptchaSecret, true},
+		{"CAPTCHA_SITE_KEY", c.CaptchaSiteKey, false},
+		{"CAPTCHA_VERIFY_URL", c.CaptchaVerifyURL, false},
+		{"LABEL_ROWS", c.LabelRows, false},
+		{"LABEL_COLS", c.LabelCols, false},
+		{"MAX_LABELS_PER_REQUEST", c.MaxLabelsPerRequest, false},
+		{"LOG_BODIES", c.LogBodies, false},
+		{"LOG_BODY_MAX_BYTES", c.LogBodyMaxBytes, false},
+	}
+}
+
+// String renders the effective configuration with secrets masked, one
+// KEY=value line per setting along with where the value came from.
+func (c *Config) String() string {
+	var b strings.Builder
+	for _, e := range c.entries() {
+		value := fmt.Sprint(e.value)
+		if e.secret {
+			value = maskSecret(value)
+		}
+		source, ok := configSources[e.key]
+		if !ok {
+			source = sourceDefault
+		}
+		fmt.Fprintf(&b, "%s=%s (%s)\n", e.key, value, source)
+	}
+	return b.String()
+}
+
+// LogEffective writes the effective configuration to the info log so that
+// misspelled or missing environment variables are easy to spot.
+func (c *Config) LogEffective() {
+	logger.InfoLogger.Printf("Effective configuration:\n%s", c.String())
+}
+
+// IsRelease reports whether the server runs in gin release mode.
+func (c *Config) IsRelease() bool {
+	return c.GinMode == "release"
+}
+
+// LoginSessionDuration returns how long a login session lasts, capped at the
+// absolute maximum session lifetime.
+func (c *Config) LoginSessionDuration(remember bool) time.Duration {
+	duration := c.SessionDuration
+	if remember {
+		duration = c.RememberMeDuration
+	}
+	if duration > c.SessionMaxLifetime {
+		duration = c.SessionMaxLifetime
+	}
+	return duration
+}
+
+// RefreshTokenExpiry returns when a refresh token issued now at login
+// expires: after RefreshTokenDuration, but no later than SessionMaxLifetime.
+func (c *Config) RefreshTokenExpiry() time.Time {
+	duration := c.RefreshTokenDuration
+	if duration > c.SessionMaxLifetime {
+		duration = c.SessionMaxLifetime
+	}
+	return time.Now().Add(duration)
+}
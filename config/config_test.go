@@ -0,0 +1,311 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadConfig returns the configuration read from env on top of the
+// defaults.
+func loadConfig(t *testing.T, env map[string]string) *Config {
+	t.Helper()
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	return LoadConfig()
+}
+
+func TestStringMasksSecrets(t *testing.T) {
+	cfg := loadConfig(t, map[string]string{
+		"DB_PASSWORD": "hunter2",
+		"DB_NAME":     "assets",
+		"S_PORT":      "lots",
+	})
+	out := cfg.String()
+
+	if strings.Contains(out, "hunter2") {
+		t.Errorf("String() shows the DB password:\n%s", out)
+	}
+	for _, want := range []string{
+		"DB_PASSWORD=******** (env)",
+		"DB_NAME=assets (env)",
+		"DB_HOST=localhost (default)",
+		"S_PORT=0 (default (invalid env value))",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("String() does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+// validate loads the configuration from env and validates it.
+func validate(t *testing.T, env map[string]string) error {
+	t.Helper()
+	return loadConfig(t, env).Validate()
+}
+
+// validateTest is a case of a table of Validate tests. wantErr is part of
+// the expected error, or empty if the configuration is valid.
+type validateTest struct {
+	name    string
+	env     map[string]string
+	wantErr string
+}
+
+func runValidateTests(t *testing.T, tests []validateTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(t, tt.env)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSSLMode(t *testing.T) {
+	rootCert := filepath.Join(t.TempDir(), "root.crt")
+	if err := os.WriteFile(rootCert, []byte("certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	runValidateTests(t, []validateTest{
+		{"default", nil, ""},
+		{"require", map[string]string{"DB_SSLMODE": "require"}, ""},
+		{"unknown mode", map[string]string{"DB_SSLMODE": "prefer"}, "invalid DB_SSLMODE"},
+		{"verify-full without root certificate", map[string]string{"DB_SSLMODE": "verify-full"}, "DB_SSLROOTCERT is required"},
+		{"verify-ca with missing root certificate", map[string]string{"DB_SSLMODE": "verify-ca", "DB_SSLROOTCERT": rootCert + ".missing"}, "DB_SSLROOTCERT is not readable"},
+		{"verify-full with root certificate", map[string]string{"DB_SSLMODE": "verify-full", "DB_SSLROOTCERT": rootCert}, ""},
+	})
+}
+
+func TestValidateTrailingSlashMode(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"redirect", map[string]string{"TRAILING_SLASH_MODE": "redirect"}, ""},
+		{"rewrite", map[string]string{"TRAILING_SLASH_MODE": "rewrite"}, ""},
+		{"unknown", map[string]string{"TRAILING_SLASH_MODE": "strip"}, "invalid TRAILING_SLASH_MODE"},
+	})
+}
+
+func TestLoginSessionDuration(t *testing.T) {
+	cfg := &Config{SessionDuration: time.Hour, RememberMeDuration: 30 * 24 * time.Hour, SessionMaxLifetime: 7 * 24 * time.Hour}
+	if got := cfg.LoginSessionDuration(false); got != time.Hour {
+		t.Errorf("LoginSessionDuration(false) = %s, want 1h", got)
+	}
+	if got := cfg.LoginSessionDuration(true); got != 7*24*time.Hour {
+		t.Errorf("LoginSessionDuration(true) = %s, want it capped at SESSION_MAX_LIFETIME", got)
+	}
+}
+
+func TestValidateSessionDurations(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"custom", map[string]string{"SESSION_DURATION": "30m", "REMEMBER_ME_DURATION": "168h"}, ""},
+		{"zero", map[string]string{"SESSION_DURATION": "0s"}, "must be positive"},
+		{"negative", map[string]string{"SESSION_MAX_LIFETIME": "-1h"}, "must be positive"},
+	})
+}
+
+func TestValidateSPAIndex(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"disabled with missing index", map[string]string{"SPA_MODE": "false", "SPA_INDEX": "missing.html"}, ""},
+		{"enabled with missing index", map[string]string{"SPA_MODE": "true", "SPA_INDEX": "missing.html"}, "SPA_INDEX is not readable"},
+	})
+}
+
+func TestValidateJWTSecretInRelease(t *testing.T) {
+	secret := strings.Repeat("s", 32)
+	runValidateTests(t, []validateTest{
+		{"development default", nil, ""},
+		{"release default", map[string]string{"GIN_MODE": "release"}, "JWT_SECRET must be set in release mode"},
+		{"release explicit default", map[string]string{"GIN_MODE": "release", "JWT_SECRET": DefaultJWTSecret}, "JWT_SECRET must be set in release mode"},
+		{"release secret", map[string]string{"GIN_MODE": "release", "JWT_SECRET": secret}, ""},
+		{"release ephemeral", map[string]string{"GIN_MODE": "release", "JWT_SECRET_FALLBACK": "ephemeral"}, ""},
+		{"unknown fallback", map[string]string{"JWT_SECRET_FALLBACK": "default"}, "invalid JWT_SECRET_FALLBACK"},
+	})
+}
+
+func TestEphemeralJWTSecret(t *testing.T) {
+	first := loadConfig(t, map[string]string{"GIN_MODE": "release", "JWT_SECRET_FALLBACK": "ephemeral"})
+	second := LoadConfig()
+	if first.JWTSecret == DefaultJWTSecret || first.JWTSecret == second.JWTSecret {
+		t.Errorf("JWT secrets %q and %q, want distinct random secrets", first.JWTSecret, second.JWTSecret)
+	}
+	if !strings.Contains(first.String(), "JWT_SECRET=******** (ephemeral)") {
+		t.Errorf("String() does not report the ephemeral secret:\n%s", first)
+	}
+}
+
+func TestValidateStocktakeInterval(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"custom", map[string]string{"STOCKTAKE_INTERVAL_DAYS": "90"}, ""},
+		{"zero", map[string]string{"STOCKTAKE_INTERVAL_DAYS": "0"}, "STOCKTAKE_INTERVAL_DAYS must be positive"},
+	})
+}
+
+func TestValidateLabelLayout(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"custom", map[string]string{"LABEL_ROWS": "10", "LABEL_COLS": "4"}, ""},
+		{"no rows", map[string]string{"LABEL_ROWS": "0"}, "LABEL_ROWS must be between"},
+		{"too many columns", map[string]string{"LABEL_COLS": "1000"}, "LABEL_ROWS must be between"},
+		{"no labels", map[string]string{"MAX_LABELS_PER_REQUEST": "0"}, "MAX_LABELS_PER_REQUEST must be positive"},
+	})
+}
+
+func TestValidateCaptcha(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"turnstile", map[string]string{"CAPTCHA_PROVIDER": "turnstile", "CAPTCHA_SECRET": "secret"}, ""},
+		{"unknown provider", map[string]string{"CAPTCHA_PROVIDER": "mycaptcha", "CAPTCHA_SECRET": "secret"}, "invalid CAPTCHA_PROVIDER"},
+		{"no secret", map[string]string{"CAPTCHA_PROVIDER": "hcaptcha"}, "CAPTCHA_SECRET is required"},
+	})
+}
+
+func TestValidateLogBodies(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"debug", map[string]string{"LOG_BODIES": "true"}, ""},
+		{"release", map[string]string{"LOG_BODIES": "true", "GIN_MODE": "release", "JWT_SECRET": strings.Repeat("s", 32)}, "LOG_BODIES"},
+		{"no room", map[string]string{"LOG_BODY_MAX_BYTES": "0"}, "LOG_BODY_MAX_BYTES must be positive"},
+	})
+}
+
+func TestValidateMaxConcurrentPerIP(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"disabled", map[string]string{"MAX_CONCURRENT_PER_IP": "0"}, ""},
+		{"negative", map[string]string{"MAX_CONCURRENT_PER_IP": "-1"}, "MAX_CONCURRENT_PER_IP must not be negative"},
+	})
+}
+
+func TestValidateResetRequestLimit(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"defaults", nil, ""},
+		{"no requests", map[string]string{"RESET_REQUESTS_PER_WINDOW": "0"}, "RESET_REQUESTS_PER_WINDOW and RESET_REQUEST_WINDOW must be positive"},
+		{"no window", map[string]string{"RESET_REQUEST_WINDOW": "0s"}, "RESET_REQUESTS_PER_WINDOW and RESET_REQUEST_WINDOW must be positive"},
+	})
+}
+
+func TestValidateEmailRate(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"unlimited", map[string]string{"EMAIL_RATE_PER_MINUTE": "0"}, ""},
+		{"negative rate", map[string]string{"EMAIL_RATE_PER_MINUTE": "-1"}, "EMAIL_RATE_PER_MINUTE must not be negative"},
+		{"no burst", map[string]string{"EMAIL_BURST": "0"}, "EMAIL_BURST must be positive"},
+	})
+}
+
+func TestPortCanonicalized(t *testing.T) {
+	tests := map[string]string{
+		"8080":   "8080",
+		":9000":  "9000",
+		" 3000 ": "3000",
+	}
+	for env, want := range tests {
+		if cfg := loadConfig(t, map[string]string{"PORT": env}); cfg.Port != want {
+			t.Errorf("PORT %q gives Port %q, want %q", env, cfg.Port, want)
+		}
+	}
+}
+
+func TestValidatePort(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"colon", map[string]string{"PORT": ":443"}, ""},
+		{"not a number", map[string]string{"PORT": "http"}, `invalid PORT "http"`},
+		{"out of range", map[string]string{"PORT": "70000"}, `invalid PORT "70000"`},
+		{"zero", map[string]string{"PORT": "0"}, `invalid PORT "0"`},
+		{"empty", map[string]string{"PORT": " "}, `invalid PORT ""`},
+	})
+}
+
+func TestValidateGzipLevel(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"disabled", map[string]string{"GZIP_LEVEL": "0"}, ""},
+		{"best compression", map[string]string{"GZIP_LEVEL": "9"}, ""},
+		{"too high", map[string]string{"GZIP_LEVEL": "10"}, "invalid GZIP_LEVEL 10"},
+	})
+}
+
+func TestValidateStorageStatsInterval(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"disabled", map[string]string{"STORAGE_STATS_INTERVAL": "0s"}, ""},
+		{"negative", map[string]string{"STORAGE_STATS_INTERVAL": "-1m"}, "STORAGE_STATS_INTERVAL must not be negative"},
+	})
+}
+
+func TestValidateJWTSecretLength(t *testing.T) {
+	short := strings.Repeat("s", 31)
+	runValidateTests(t, []validateTest{
+		{"release short secret", map[string]string{"GIN_MODE": "release", "JWT_SECRET": short}, "JWT_SECRET is 31 bytes long, at least 32 are required in release mode"},
+		{"release lower minimum", map[string]string{"GIN_MODE": "release", "JWT_SECRET": short, "JWT_SECRET_MIN_BYTES": "16"}, ""},
+		{"development short secret", map[string]string{"JWT_SECRET": "short"}, ""},
+		{"negative minimum", map[string]string{"JWT_SECRET_MIN_BYTES": "-1"}, "JWT_SECRET_MIN_BYTES must not be negative"},
+	})
+}
+
+func TestValidateAvailabilityChecks(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"defaults", nil, ""},
+		{"no rate", map[string]string{"AVAILABILITY_CHECKS_PER_MINUTE": "0"}, "AVAILABILITY_CHECKS_PER_MINUTE and AVAILABILITY_CHECKS_BURST must be positive"},
+		{"no burst", map[string]string{"AVAILABILITY_CHECKS_BURST": "0"}, "AVAILABILITY_CHECKS_PER_MINUTE and AVAILABILITY_CHECKS_BURST must be positive"},
+	})
+}
+
+func TestValidateShutdownTimeouts(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"no email drain", map[string]string{"EMAIL_DRAIN_TIMEOUT": "0s"}, ""},
+		{"no shutdown timeout", map[string]string{"SHUTDOWN_TIMEOUT": "0s"}, "SHUTDOWN_TIMEOUT must be positive"},
+		{"negative email drain", map[string]string{"EMAIL_DRAIN_TIMEOUT": "-1s"}, "EMAIL_DRAIN_TIMEOUT must not be negative"},
+	})
+}
+
+func TestValidateAuthModes(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"split", map[string]string{"WEB_AUTH_MODE": "cookie", "API_AUTH_MODE": "bearer"}, ""},
+		{"unknown web mode", map[string]string{"WEB_AUTH_MODE": "session"}, `invalid WEB_AUTH_MODE "session"`},
+		{"unknown api mode", map[string]string{"API_AUTH_MODE": "token"}, `API_AUTH_MODE "token"`},
+	})
+}
+
+func TestValidateEventBatch(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"disabled", map[string]string{"EVENT_BATCH_WINDOW": "0s"}, ""},
+		{"window", map[string]string{"EVENT_BATCH_WINDOW": "500ms", "EVENT_BATCH_MAX": "10"}, ""},
+		{"negative window", map[string]string{"EVENT_BATCH_WINDOW": "-1s"}, "EVENT_BATCH_WINDOW must not be negative and EVENT_BATCH_MAX must be positive"},
+		{"zero max", map[string]string{"EVENT_BATCH_MAX": "0"}, "EVENT_BATCH_WINDOW must not be negative and EVENT_BATCH_MAX must be positive"},
+	})
+}
+
+func TestValidateMinAccountAge(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"disabled", map[string]string{"MIN_ACCOUNT_AGE": "0s"}, ""},
+		{"one day", map[string]string{"MIN_ACCOUNT_AGE": "24h"}, ""},
+		{"negative", map[string]string{"MIN_ACCOUNT_AGE": "-1h"}, "MIN_ACCOUNT_AGE must not be negative"},
+	})
+}
+
+func TestValidateCustomFields(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"schema", map[string]string{"CUSTOM_FIELDS": "cost_center:string,purchase_cost:number,under_warranty:boolean"}, ""},
+		{"unknown type", map[string]string{"CUSTOM_FIELDS": "cost_center:text"}, `invalid CUSTOM_FIELDS entry "cost_center:text", expected name:string, name:number or name:boolean`},
+		{"invalid name", map[string]string{"CUSTOM_FIELDS": "cost-center:string"}, `invalid CUSTOM_FIELDS entry "cost-center:string", expected name:string, name:number or name:boolean`},
+		{"duplicate", map[string]string{"CUSTOM_FIELDS": "cost_center:string,cost_center:number"}, `custom field "cost_center" is defined twice in CUSTOM_FIELDS`},
+	})
+}
+
+func TestValidatePasswordMaxAge(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"disabled", map[string]string{"PASSWORD_MAX_AGE_DAYS": "0"}, ""},
+		{"ninety days", map[string]string{"PASSWORD_MAX_AGE_DAYS": "90"}, ""},
+		{"negative", map[string]string{"PASSWORD_MAX_AGE_DAYS": "-1"}, "PASSWORD_MAX_AGE_DAYS must not be negative"},
+	})
+}
+
+func TestValidateUsersBatchMaxIDs(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"one", map[string]string{"USERS_BATCH_MAX_IDS": "1"}, ""},
+		{"zero", map[string]string{"USERS_BATCH_MAX_IDS": "0"}, "USERS_BATCH_MAX_IDS must be positive"},
+		{"negative", map[string]string{"USERS_BATCH_MAX_IDS": "-5"}, "USERS_BATCH_MAX_IDS must be positive"},
+	})
+}
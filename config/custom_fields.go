@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// customFieldTypes are the value types a custom field may have.
+var customFieldTypes = map[string]bool{"string": true, "number": true, "boolean": true}
+
+// customFieldKey restricts custom field names to lower-case identifiers.
+var customFieldKey = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// CustomFieldSchema parses CustomFields, entries such as
+// "cost_center:string", into the type of each allowed key.
+func (c *Config) CustomFieldSchema() (map[string]string, error) {
+	schema := make(map[string]string, len(c.CustomFields))
+	for _, entry := range c.CustomFields {
+		key, typ, ok := strings.Cut(entry, ":")
+		if !ok || !customFieldKey.MatchString(key) || !customFieldTypes[typ] {
+			return nil, fmt.Errorf("invalid CUSTOM_FIELDS entry %q, expected name:string, name:number or name:boolean", entry)
+		}
+		if _, dup := schema[key]; dup {
+			return nil, fmt.Errorf("custom field %q is defined twice in CUSTOM_FIELDS", key)
+		}
+		schema[key] = typ
+	}
+	return schema, nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// AdminIPNets parses AdminAllowedIPs and AdminDeniedIPs. An entry is either
+// a CIDR range such as 10.0.0.0/8 or a single address.
+func (c *Config) AdminIPNets() (allow, deny []*net.IPNet, err error) {
+	if allow, err = parseIPNets("ADMIN_ALLOWED_IPS", c.AdminAllowedIPs); err != nil {
+		return nil, nil, err
+	}
+	if deny, err = parseIPNets("ADMIN_DENIED_IPS", c.AdminDeniedIPs); err != nil {
+		return nil, nil, err
+	}
+	return allow, deny, nil
+}
+
+// parseIPNets parses the entries of the list read from key, turning single
+// addresses into networks containing only that address.
+func parseIPNets(key string, entries []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q in %s", entry, key)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q in %s", entry, key)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestAdminIPNets(t *testing.T) {
+	cfg := &Config{AdminAllowedIPs: []string{"10.0.0.0/8", "192.0.2.7", "2001:db8::1"}}
+	allow, deny, err := cfg.AdminIPNets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deny) != 0 {
+		t.Errorf("deny = %v, want none", deny)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.7/32", "2001:db8::1/128"}
+	if len(allow) != len(want) {
+		t.Fatalf("allow = %v, want %v", allow, want)
+	}
+	for i, ipNet := range allow {
+		if ipNet.String() != want[i] {
+			t.Errorf("allow[%d] = %s, want %s", i, ipNet, want[i])
+		}
+	}
+}
+
+func TestValidateAdminIPs(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"ranges", map[string]string{"ADMIN_ALLOWED_IPS": "10.0.0.0/8, 192.0.2.7", "ADMIN_DENIED_IPS": "203.0.113.0/24"}, ""},
+		{"bad address", map[string]string{"ADMIN_DENIED_IPS": "10.0.0.256"}, `invalid address "10.0.0.256" in ADMIN_DENIED_IPS`},
+		{"bad range", map[string]string{"ADMIN_ALLOWED_IPS": "10.0.0.0/33"}, `invalid CIDR range "10.0.0.0/33" in ADMIN_ALLOWED_IPS`},
+		{"bad proxy", map[string]string{"TRUSTED_PROXIES": "proxy.internal"}, `invalid address "proxy.internal" in TRUSTED_PROXIES`},
+	})
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// passwordClasses are the accepted PASSWORD_REQUIRED_CLASSES entries.
+var passwordClasses = map[string]bool{
+	"lower":  true,
+	"upper":  true,
+	"digit":  true,
+	"symbol": true,
+}
+
+// BannedPasswords reads PasswordBannedFile: one password per line, ignoring
+// blank lines and lines starting with #.
+func (c *Config) BannedPasswords() ([]string, error) {
+	if c.PasswordBannedFile == "" {
+		return nil, nil
+	}
+	file, err := os.Open(c.PasswordBannedFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_BANNED_FILE: %w", err)
+	}
+	defer file.Close()
+
+	var banned []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			banned = append(banned, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_BANNED_FILE: %w", err)
+	}
+	return banned, nil
+}
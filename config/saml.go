@@ -0,0 +1,53 @@
+package config
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// SAMLEnabled reports whether a SAML identity provider is configured.
+func (c *Config) SAMLEnabled() bool {
+	return c.SAMLIDPMetadataURL != "" || c.SAMLIDPMetadataFile != ""
+}
+
+// SAMLIDPMetadata returns the contents of SAMLIDPMetadataFile, or nil if the
+// metadata is fetched from SAMLIDPMetadataURL instead.
+func (c *Config) SAMLIDPMetadata() ([]byte, error) {
+	if c.SAMLIDPMetadataFile == "" {
+		return nil, nil
+	}
+	metadata, err := os.ReadFile(c.SAMLIDPMetadataFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML_IDP_METADATA_FILE: %w", err)
+	}
+	return metadata, nil
+}
+
+// SAMLKeyPair loads the service provider's key and certificate from
+// SAMLKeyFile and SAMLCertFile. Both are nil if neither file is set.
+func (c *Config) SAMLKeyPair() (crypto.Signer, *x509.Certificate, error) {
+	if c.SAMLCertFile == "" && c.SAMLKeyFile == "" {
+		return nil, nil, nil
+	}
+	if c.SAMLCertFile == "" || c.SAMLKeyFile == "" {
+		return nil, nil, errors.New("SAML_CERT_FILE and SAML_KEY_FILE must be set together")
+	}
+
+	pair, err := tls.LoadX509KeyPair(c.SAMLCertFile, c.SAMLKeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid SAML key pair: %w", err)
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("invalid SAML key pair: unsupported key type")
+	}
+	certificate, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid SAML key pair: %w", err)
+	}
+	return key, certificate, nil
+}
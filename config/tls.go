@@ -0,0 +1,46 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// tlsVersions maps the accepted TLS_MIN_VERSION values to their protocol
+// versions. TLS 1.0 and 1.1 are deliberately not offered.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig builds the TLS settings of the HTTPS server from TLSMinVersion
+// and TLSCipherSuites. Without configured cipher suites Go's defaults are
+// used; suites never apply to TLS 1.3, whose suites are not configurable.
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	minVersion, ok := tlsVersions[c.TLSMinVersion]
+	if !ok {
+		return nil, fmt.Errorf("invalid TLS_MIN_VERSION %q, expected 1.2 or 1.3", c.TLSMinVersion)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: minVersion}
+	for _, name := range c.TLSCipherSuites {
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS cipher suite %q in TLS_CIPHER_SUITES", name)
+		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+	}
+	return tlsConfig, nil
+}
+
+// cipherSuiteID looks up a cipher suite by its standard name, e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Only suites Go considers secure are
+// accepted.
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if strings.EqualFold(suite.Name, name) {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
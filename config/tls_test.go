@@ -0,0 +1,39 @@
+package config
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestTLSConfig(t *testing.T) {
+	cfg := &Config{TLSMinVersion: "1.2", TLSCipherSuites: []string{"tls_ecdhe_rsa_with_aes_128_gcm_sha256", "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}}
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("MinVersion = %x, want TLS 1.2", tlsConfig.MinVersion)
+	}
+	want := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}
+	if len(tlsConfig.CipherSuites) != len(want) || tlsConfig.CipherSuites[0] != want[0] || tlsConfig.CipherSuites[1] != want[1] {
+		t.Errorf("CipherSuites = %x, want %x", tlsConfig.CipherSuites, want)
+	}
+
+	for _, cfg := range []*Config{
+		{TLSMinVersion: "1.0"},
+		{TLSMinVersion: "1.3", TLSCipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+		{TLSMinVersion: "1.3", TLSCipherSuites: []string{"TLS_NO_SUCH_SUITE"}},
+	} {
+		if _, err := cfg.TLSConfig(); err == nil {
+			t.Errorf("TLSConfig() accepted TLS_MIN_VERSION %s and TLS_CIPHER_SUITES %v", cfg.TLSMinVersion, cfg.TLSCipherSuites)
+		}
+	}
+}
+
+func TestValidateHTTPS(t *testing.T) {
+	runValidateTests(t, []validateTest{
+		{"HTTPS with certificate", map[string]string{"USE_HTTPS": "true", "CERT_FILE": "server.crt", "KEY_FILE": "server.key"}, ""},
+		{"HTTPS without certificate", map[string]string{"USE_HTTPS": "true"}, "CERT_FILE and KEY_FILE are required"},
+		{"TLS 1.1", map[string]string{"TLS_MIN_VERSION": "1.1"}, "invalid TLS_MIN_VERSION"},
+	})
+}
@@ -0,0 +1,203 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// Errors returned by the API key controllers.
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrAPIKeyInvalid is returned by AuthenticateAPIKey for a key that is
+	// unknown, deleted or expired.
+	ErrAPIKeyInvalid = errors.New("API key is invalid or expired")
+)
+
+const apiKeyColumns = "id, user_id, name, prefix, scopes, expires_at, created_at, last_used_at"
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var key models.APIKey
+	var lastUsedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, pq.Array(&key.Scopes),
+		&key.ExpiresAt, &key.CreatedAt, &lastUsedAt)
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	return key, err
+}
+
+// CreateAPIKey stores a new API key of a user under the hash of the key.
+func (db *DB) CreateAPIKey(userID int, name, prefix, hash string, scopes []string, expiresAt time.Time) (models.APIKey, error) {
+	query := `
+        INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING ` + apiKeyColumns
+	key, err := scanAPIKey(db.QueryRow(query, userID, name, prefix, hash, pq.Array(scopes), expiresAt))
+	if err != nil {
+		logger.ErrorLogger.Printf("Error storing API key: %v", err)
+		return key, unavailable(err)
+	}
+	return key, nil
+}
+
+// GetAPIKeys returns the API keys of a user, expired ones included, oldest
+// first.
+func (db *DB) GetAPIKeys(userID int) ([]models.APIKey, error) {
+	rows, err := db.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error listing API keys: %v", err)
+		return nil, unavailable(err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, unavailable(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, unavailable(rows.Err())
+}
+
+// GetAPIKey returns one of a user's API keys.
+func (db *DB) GetAPIKey(userID, id int) (models.APIKey, error) {
+	key, err := scanAPIKey(db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1 AND user_id = $2", id, userID))
+	if err == sql.ErrNoRows {
+		return key, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error looking up API key: %v", err)
+		return key, unavailable(err)
+	}
+	return key, nil
+}
+
+// UpdateAPIKey renames one of a user's API keys and replaces its scopes.
+func (db *DB) UpdateAPIKey(userID, id int, name string, scopes []string) (models.APIKey, error) {
+	query := `
+        UPDATE api_keys
+        SET name = $3, scopes = $4
+        WHERE id = $1 AND user_id = $2
+        RETURNING ` + apiKeyColumns
+	key, err := scanAPIKey(db.QueryRow(query, id, userID, name, pq.Array(scopes)))
+	if err == sql.ErrNoRows {
+		return key, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error updating API key: %v", err)
+		return key, unavailable(err)
+	}
+	return key, nil
+}
+
+// DeleteAPIKey revokes one of a user's API keys.
+func (db *DB) DeleteAPIKey(userID, id int) error {
+	result, err := db.Exec("DELETE FROM api_keys WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error deleting API key: %v", err)
+		return unavailable(err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// RotateAPIKeys revokes every API key of a user and replaces each one that
+// had not expired with a new key of the same name and scopes, valid until
+// expiresAt. newKey returns the prefix and hash of each replacement. The
+// rotation is recorded in the audit log as done by actorID, in the same
+// transaction. It returns the replacements in the order of the keys they
+// replace; none when the user had no unexpired keys.
+func (db *DB) RotateAPIKeys(actorID, userID int, expiresAt time.Time, newKey func() (prefix, hash string, err error)) ([]models.APIKey, error) {
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error starting API key rotation: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "DELETE FROM api_keys WHERE user_id = $1 RETURNING id, name, scopes, expires_at > NOW()", userID)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error revoking API keys: %v", err)
+		return nil, unavailable(err)
+	}
+	revoked := 0
+	var live []models.APIKey
+	for rows.Next() {
+		var key models.APIKey
+		var unexpired bool
+		if err := rows.Scan(&key.ID, &key.Name, pq.Array(&key.Scopes), &unexpired); err != nil {
+			rows.Close()
+			return nil, unavailable(err)
+		}
+		revoked++
+		if unexpired {
+			live = append(live, key)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, unavailable(err)
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].ID < live[j].ID })
+
+	replacements := []models.APIKey{}
+	insert := `
+        INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING ` + apiKeyColumns
+	for _, old := range live {
+		prefix, hash, err := newKey()
+		if err != nil {
+			return nil, err
+		}
+		key, err := scanAPIKey(tx.QueryRowContext(ctx, insert, userID, old.Name, prefix, hash, pq.Array(old.Scopes), expiresAt))
+		if err != nil {
+			logger.ErrorLogger.Printf("Error storing rotated API key: %v", err)
+			return nil, unavailable(err)
+		}
+		replacements = append(replacements, key)
+	}
+
+	details := fmt.Sprintf("%d keys revoked, %d replaced", revoked, len(replacements))
+	if _, err := tx.ExecContext(ctx, insertAuditEntry, actorID, userID, models.AuditAPIKeysRotated, details); err != nil {
+		logger.ErrorLogger.Printf("Error adding audit entry %s: %v", models.AuditAPIKeysRotated, err)
+		return nil, unavailable(err)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.ErrorLogger.Printf("Error committing API key rotation: %v", err)
+		return nil, unavailable(err)
+	}
+	return replacements, nil
+}
+
+// AuthenticateAPIKey returns the unexpired API key stored under hash and
+// records that it was used.
+func (db *DB) AuthenticateAPIKey(hash string) (models.APIKey, error) {
+	query := `
+        UPDATE api_keys
+        SET last_used_at = NOW()
+        WHERE key_hash = $1 AND expires_at > NOW()
+        RETURNING ` + apiKeyColumns
+	key, err := scanAPIKey(db.QueryRow(query, hash))
+	if err == sql.ErrNoRows {
+		return key, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error authenticating API key: %v", err)
+		return key, unavailable(err)
+	}
+	return key, nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// ErrDeviceNotPending is returned when reviewing a device that is not
+// pending approval.
+var ErrDeviceNotPending = errors.New("device is not pending approval")
+
+// SetRequireDeviceApproval makes new devices, and devices that move to
+// another location, pending until an admin approves them. Pending and
+// rejected devices are left out of the device listings and exports.
+func (db *DB) SetRequireDeviceApproval(required bool) {
+	db.requireDeviceApproval = required
+}
+
+// newDeviceStatus returns the approval status of a newly created device.
+func (db *DB) newDeviceStatus() string {
+	if db.requireDeviceApproval {
+		return models.DevicePendingApproval
+	}
+	return models.DeviceApproved
+}
+
+// GetPendingDeviceLocationDetails returns the devices waiting for approval,
+// oldest first.
+func (db *DB) GetPendingDeviceLocationDetails() ([]models.DeviceLocationDetail, error) {
+	query := "SELECT " + deviceLocationColumns + " FROM device_location WHERE approval_status = $1 ORDER BY id"
+	rows, err := db.queryRead(query, models.DevicePendingApproval)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error querying pending devices: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]models.DeviceLocationDetail, 0)
+	for rows.Next() {
+		data, err := scanDeviceLocationDetail(rows)
+		if err != nil {
+			logger.ErrorLogger.Printf("Error scanning pending device: %v", err)
+			return nil, unavailable(err)
+		}
+		results = append(results, data)
+	}
+	return results, unavailable(rows.Err())
+}
+
+// ReviewDeviceLocationDetail approves or rejects the pending device with the
+// given id, recording the reviewer and time. It returns ErrDeviceNotFound if
+// there is no such device and ErrDeviceNotPending if it is not pending.
+func (db *DB) ReviewDeviceLocationDetail(id int, approve bool, reviewerID int) (models.DeviceLocationDetail, error) {
+	status := models.DeviceRejected
+	if approve {
+		status = models.DeviceApproved
+	}
+	query := `
+		UPDATE device_location
+		SET approval_status = $2, reviewed_by = $3, reviewed_at = NOW()
+		WHERE id = $1 AND approval_status = $4
+		RETURNING ` + deviceLocationColumns
+	device, err := scanDeviceLocationDetail(db.QueryRow(query, id, status, reviewerID, models.DevicePendingApproval))
+	if errors.Is(err, sql.ErrNoRows) {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM device_location WHERE id = $1)", id).Scan(&exists); err != nil {
+			return device, err
+		}
+		if !exists {
+			return device, ErrDeviceNotFound
+		}
+		return device, ErrDeviceNotPending
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error reviewing device %d: %v", id, err)
+		return device, err
+	}
+	logger.InfoLogger.Printf("Device %d %s by user %d", id, status, reviewerID)
+	utils.PublishDeviceEvent(utils.DeviceUpdated, device)
+	return device, nil
+}
@@ -0,0 +1,18 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+func TestNewDeviceStatus(t *testing.T) {
+	db := &DB{}
+	if status := db.newDeviceStatus(); status != models.DeviceApproved {
+		t.Errorf("status = %s without approval, want %s", status, models.DeviceApproved)
+	}
+	db.SetRequireDeviceApproval(true)
+	if status := db.newDeviceStatus(); status != models.DevicePendingApproval {
+		t.Errorf("status = %s with approval required, want %s", status, models.DevicePendingApproval)
+	}
+}
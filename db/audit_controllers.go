@@ -0,0 +1,22 @@
+package db
+
+import (
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+// insertAuditEntry records that actor_id performed action on the account
+// of user_id.
+const insertAuditEntry = `
+        INSERT INTO audit_log (actor_id, user_id, action, details)
+        VALUES ($1, $2, $3, $4)
+    `
+
+// AddAuditEntry records in the audit log that actorID performed action on
+// the account of userID.
+func (db *DB) AddAuditEntry(actorID, userID int, action, details string) error {
+	if _, err := db.Exec(insertAuditEntry, actorID, userID, action, details); err != nil {
+		logger.ErrorLogger.Printf("Error adding audit entry %s: %v", action, err)
+		return unavailable(err)
+	}
+	return nil
+}
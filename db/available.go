@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ErrDatabaseUnavailable is returned, possibly wrapped, by every DB method
+// when there is no usable database connection: the DB was never opened, has
+// been closed, or the server cannot be reached.
+var ErrDatabaseUnavailable = errors.New("database is unavailable")
+
+// unavailable wraps connection-level failures in ErrDatabaseUnavailable and
+// returns every other error unchanged.
+func unavailable(err error) error {
+	if err == nil || errors.Is(err, ErrDatabaseUnavailable) {
+		return err
+	}
+	var netErr net.Error
+	if errors.Is(err, sql.ErrConnDone) || errors.Is(err, driver.ErrBadConn) ||
+		errors.As(err, &netErr) || err.Error() == "sql: database is closed" {
+		return fmt.Errorf("%w: %v", ErrDatabaseUnavailable, err)
+	}
+	return err
+}
+
+// conn returns the underlying connection pool, or ErrDatabaseUnavailable for
+// a nil or unopened DB.
+func (db *DB) conn() (*sql.DB, error) {
+	if db == nil || db.DB == nil {
+		return nil, ErrDatabaseUnavailable
+	}
+	return db.DB, nil
+}
+
+// The methods below shadow those of the embedded *sql.DB so that every query
+// in this package goes through the availability checks.
+
+// Query runs a query that returns rows.
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext runs a query that returns rows, bound to ctx.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	conn, err := db.conn()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn.QueryContext(ctx, query, args...)
+	return rows, unavailable(err)
+}
+
+// QueryRow runs a query that returns at most one row. Errors, including an
+// unavailable database, are reported by Scan.
+func (db *DB) QueryRow(query string, args ...interface{}) rowScanner {
+	conn, err := db.conn()
+	if err != nil {
+		return errRow{err}
+	}
+	return checkedRow{conn.QueryRow(query, args...)}
+}
+
+// Exec runs a statement that returns no rows.
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	conn, err := db.conn()
+	if err != nil {
+		return nil, err
+	}
+	result, err := conn.Exec(query, args...)
+	return result, unavailable(err)
+}
+
+// BeginTx starts a transaction bound to ctx.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	conn, err := db.conn()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := conn.BeginTx(ctx, opts)
+	return tx, unavailable(err)
+}
+
+// Ping checks that the database can be reached.
+func (db *DB) Ping() error {
+	conn, err := db.conn()
+	if err != nil {
+		return err
+	}
+	return unavailable(conn.Ping())
+}
+
+// PingContext checks that the database can be reached before ctx is done.
+func (db *DB) PingContext(ctx context.Context) error {
+	conn, err := db.conn()
+	if err != nil {
+		return err
+	}
+	return unavailable(conn.PingContext(ctx))
+}
+
+// errRow is a row whose query could not be run at all.
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...interface{}) error {
+	return r.err
+}
+
+// checkedRow maps connection failures reported by a *sql.Row.
+type checkedRow struct {
+	row *sql.Row
+}
+
+func (r checkedRow) Scan(dest ...interface{}) error {
+	return unavailable(r.row.Scan(dest...))
+}
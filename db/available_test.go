@@ -0,0 +1,48 @@
+package db
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUnopenedDBIsUnavailable(t *testing.T) {
+	closed, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	dbs := map[string]*DB{
+		"nil":      nil,
+		"unopened": {},
+		"closed":   {DB: closed},
+	}
+	for name, db := range dbs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := db.GetUserByID(1); !errors.Is(err, ErrDatabaseUnavailable) {
+				t.Errorf("GetUserByID error = %v, want ErrDatabaseUnavailable", err)
+			}
+			if _, err := db.GetAllDeviceLocationDetail(); !errors.Is(err, ErrDatabaseUnavailable) {
+				t.Errorf("GetAllDeviceLocationDetail error = %v, want ErrDatabaseUnavailable", err)
+			}
+			if err := db.Ping(); !errors.Is(err, ErrDatabaseUnavailable) {
+				t.Errorf("Ping error = %v, want ErrDatabaseUnavailable", err)
+			}
+		})
+	}
+}
+
+func TestUnavailableWrapsConnectionErrors(t *testing.T) {
+	netErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	if err := unavailable(netErr); !errors.Is(err, ErrDatabaseUnavailable) {
+		t.Errorf("unavailable(%v) = %v, want ErrDatabaseUnavailable", netErr, err)
+	}
+
+	queryErr := errors.New(`pq: relation "users" does not exist`)
+	if err := unavailable(queryErr); err != queryErr {
+		t.Errorf("unavailable(%v) = %v, want it unchanged", queryErr, err)
+	}
+}
@@ -3,6 +3,8 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/vikash-parashar/asset-locator/logger"
@@ -11,11 +13,21 @@ import (
 // DB represents the PostgreSQL database.
 type DB struct {
 	*sql.DB
+
+	// readAttempts and readBackoff configure the retry of read queries;
+	// see SetReadRetry.
+	readAttempts int
+	readBackoff  time.Duration
+
+	// requireDeviceApproval makes new and moved devices pending; see
+	// SetRequireDeviceApproval.
+	requireDeviceApproval bool
 }
 
-// NewDB creates a new database connection.
-func NewDB(host, port, user, password, dbName string) (*DB, error) {
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbName)
+// NewDB creates a new database connection. sslRootCert is only used by the
+// verify-ca and verify-full SSL modes and may be empty otherwise.
+func NewDB(host, port, user, password, dbName, sslMode, sslRootCert string) (*DB, error) {
+	connStr := DSN(host, port, user, password, dbName, sslMode, sslRootCert)
 
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
@@ -30,12 +42,36 @@ func NewDB(host, port, user, password, dbName string) (*DB, error) {
 
 	logger.InfoLogger.Println("Connected to the database")
 
-	return &DB{db}, nil
+	return &DB{DB: db, readAttempts: 1}, nil
+}
+
+// DSN builds the lib/pq connection string for the given settings. Values are
+// quoted, so a password may contain spaces, quotes or backslashes.
+func DSN(host, port, user, password, dbName, sslMode, sslRootCert string) string {
+	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		quoteDSN(host), quoteDSN(port), quoteDSN(user), quoteDSN(password), quoteDSN(dbName), quoteDSN(sslMode))
+	if sslRootCert != "" {
+		connStr += " sslrootcert=" + quoteDSN(sslRootCert)
+	}
+	return connStr
+}
+
+// dsnEscaper escapes the characters that are special in a quoted value of a
+// connection string.
+var dsnEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// quoteDSN quotes value for a key=value connection string.
+func quoteDSN(value string) string {
+	return "'" + dsnEscaper.Replace(value) + "'"
 }
 
 // Close closes the database connection.
 func (db *DB) Close() {
-	if err := db.DB.Close(); err != nil {
+	conn, err := db.conn()
+	if err != nil {
+		return
+	}
+	if err := conn.Close(); err != nil {
 		logger.ErrorLogger.Printf("Error closing database connection: %v", err)
 	} else {
 		logger.InfoLogger.Println("Closed database connection")
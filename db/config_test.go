@@ -0,0 +1,46 @@
+package db
+
+import "testing"
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		name        string
+		password    string
+		sslRootCert string
+		want        string
+	}{
+		{
+			name:     "plain",
+			password: "secret",
+			want:     `host='db' port='5432' user='app' password='secret' dbname='assets' sslmode='require'`,
+		},
+		{
+			name:     "space",
+			password: "two words",
+			want:     `host='db' port='5432' user='app' password='two words' dbname='assets' sslmode='require'`,
+		},
+		{
+			name:     "quote and backslash",
+			password: `it's\here`,
+			want:     `host='db' port='5432' user='app' password='it\'s\\here' dbname='assets' sslmode='require'`,
+		},
+		{
+			name:     "empty",
+			password: "",
+			want:     `host='db' port='5432' user='app' password='' dbname='assets' sslmode='require'`,
+		},
+		{
+			name:        "root certificate",
+			password:    "secret",
+			sslRootCert: "/etc/ssl/my ca.pem",
+			want:        `host='db' port='5432' user='app' password='secret' dbname='assets' sslmode='require' sslrootcert='/etc/ssl/my ca.pem'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DSN("db", "5432", "app", tt.password, "assets", "require", tt.sslRootCert); got != tt.want {
+				t.Errorf("DSN() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
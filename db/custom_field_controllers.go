@@ -0,0 +1,67 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// SetDeviceCustomFields replaces the custom fields of the device with the
+// given id and returns the updated device, or ErrDeviceNotFound.
+func (db *DB) SetDeviceCustomFields(id int, fields models.CustomFields) (models.DeviceLocationDetail, error) {
+	query := "UPDATE device_location SET custom_fields = $2 WHERE id = $1 RETURNING " + deviceLocationColumns
+	device, err := scanDeviceLocationDetail(db.QueryRow(query, id, fields))
+	if err == sql.ErrNoRows {
+		return device, ErrDeviceNotFound
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error setting custom fields of device %d: %v", id, err)
+		return device, err
+	}
+	utils.PublishDeviceEvent(utils.DeviceUpdated, device)
+	return device, nil
+}
+
+// SearchDeviceLocationDetails returns up to limit approved devices whose
+// custom fields contain all of filter, ordered by the custom field sortKey
+// if it is set, devices without it last, and then by id.
+func (db *DB) SearchDeviceLocationDetails(filter models.CustomFields, sortKey string, descending bool, limit int) ([]models.DeviceLocationDetail, error) {
+	query := "SELECT " + deviceLocationColumns + " FROM device_location" + approvedOnly
+	var args []interface{}
+	if len(filter) > 0 {
+		args = append(args, filter)
+		query += fmt.Sprintf(" AND custom_fields @> $%d", len(args))
+	}
+	query += " ORDER BY "
+	if sortKey != "" {
+		direction := "ASC"
+		if descending {
+			direction = "DESC"
+		}
+		args = append(args, sortKey)
+		query += fmt.Sprintf("custom_fields -> $%d %s NULLS LAST, ", len(args), direction)
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf("id LIMIT $%d", len(args))
+
+	rows, err := db.queryRead(query, args...)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error searching DeviceLocationDetail: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.DeviceLocationDetail
+	for rows.Next() {
+		data, err := scanDeviceLocationDetail(rows)
+		if err != nil {
+			logger.ErrorLogger.Printf("Error scanning DeviceLocationDetail: %v", err)
+			return nil, err
+		}
+		results = append(results, data)
+	}
+	return results, rows.Err()
+}
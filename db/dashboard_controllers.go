@@ -0,0 +1,63 @@
+package db
+
+import (
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+// CountUsers returns the total number of registered users.
+func (db *DB) CountUsers() (int, error) {
+	var count int
+	if err := db.queryRowRead("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		logger.ErrorLogger.Printf("Error counting users: %v", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountUsersCreatedSince returns the number of users registered at or after since.
+func (db *DB) CountUsersCreatedSince(since time.Time) (int, error) {
+	var count int
+	if err := db.queryRowRead("SELECT COUNT(*) FROM users WHERE created_at >= $1", since).Scan(&count); err != nil {
+		logger.ErrorLogger.Printf("Error counting new users: %v", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountDevices returns the number of devices registered in device_location.
+func (db *DB) CountDevices() (int, error) {
+	var count int
+	if err := db.queryRowRead("SELECT COUNT(*) FROM device_location").Scan(&count); err != nil {
+		logger.ErrorLogger.Printf("Error counting devices: %v", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountDevicesByType returns the number of devices per device type.
+func (db *DB) CountDevicesByType() (map[string]int, error) {
+	rows, err := db.queryRead("SELECT COALESCE(device_type, ''), COUNT(*) FROM device_location GROUP BY 1")
+	if err != nil {
+		logger.ErrorLogger.Printf("Error counting devices by type: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var deviceType string
+		var count int
+		if err := rows.Scan(&deviceType, &count); err != nil {
+			logger.ErrorLogger.Printf("Error scanning device type counts: %v", err)
+			return nil, err
+		}
+		counts[deviceType] = count
+	}
+	if err := rows.Err(); err != nil {
+		logger.ErrorLogger.Printf("Error iterating over device type counts: %v", err)
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// GetDeviceTypeFacets returns every distinct device type with its number of
+// devices, ordered by type. Devices without a type are left out.
+func (db *DB) GetDeviceTypeFacets() ([]models.FacetCount, error) {
+	return db.deviceLocationFacets("device_type")
+}
+
+// GetDeviceLocationFacets returns every distinct device location with its
+// number of devices, ordered by location. Devices without a location are
+// left out.
+func (db *DB) GetDeviceLocationFacets() ([]models.FacetCount, error) {
+	return db.deviceLocationFacets("device_location")
+}
+
+// deviceLocationFacets counts the device_location rows per distinct value of
+// column, which must be a trusted column name.
+func (db *DB) deviceLocationFacets(column string) ([]models.FacetCount, error) {
+	query := "SELECT " + column + ", COUNT(*) FROM device_location WHERE COALESCE(" + column + ", '') <> '' GROUP BY 1 ORDER BY 1"
+	rows, err := db.queryRead(query)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error counting devices by %s: %v", column, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	facets := make([]models.FacetCount, 0)
+	for rows.Next() {
+		var facet models.FacetCount
+		if err := rows.Scan(&facet.Value, &facet.Count); err != nil {
+			logger.ErrorLogger.Printf("Error scanning %s counts: %v", column, err)
+			return nil, err
+		}
+		facets = append(facets, facet)
+	}
+	if err := rows.Err(); err != nil {
+		logger.ErrorLogger.Printf("Error iterating over %s counts: %v", column, err)
+		return nil, err
+	}
+	return facets, nil
+}
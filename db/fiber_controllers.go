@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/lib/pq"
 	"github.com/vikash-parashar/asset-locator/logger" // Import the logger package
 	"github.com/vikash-parashar/asset-locator/models"
 )
@@ -25,8 +26,8 @@ func (db *DB) CreateDeviceEthernetFiberDetail(data *models.DeviceEthernetFiberDe
 
 // GetAllDeviceEthernetFiberDetail retrieves all records from the DeviceEthernetFiberDetail table.
 func (db *DB) GetAllDeviceEthernetFiberDetail() ([]models.DeviceEthernetFiberDetail, error) {
-	query := "SELECT * FROM device_ethernet_fiber"
-	rows, err := db.Query(query)
+	query := "SELECT " + deviceEthernetFiberColumns + " FROM device_ethernet_fiber"
+	rows, err := db.queryRead(query)
 	if err != nil {
 		logger.ErrorLogger.Printf("Error querying DeviceEthernetFiberDetail: %v", err)
 		return nil, err
@@ -35,8 +36,7 @@ func (db *DB) GetAllDeviceEthernetFiberDetail() ([]models.DeviceEthernetFiberDet
 
 	var results []models.DeviceEthernetFiberDetail
 	for rows.Next() {
-		var data models.DeviceEthernetFiberDetail
-		err := rows.Scan(&data.Id, &data.SerialNumber, &data.DeviceMakeModel, &data.Model, &data.DeviceType, &data.DevicePhysicalPort, &data.DevicePortType, &data.DevicePortMACWWN, &data.ConnectedDevicePort)
+		data, err := scanDeviceEthernetFiberDetail(rows)
 		if err != nil {
 			logger.ErrorLogger.Printf("Error scanning DeviceEthernetFiberDetail: %v", err)
 			return nil, err
@@ -50,21 +50,8 @@ func (db *DB) GetAllDeviceEthernetFiberDetail() ([]models.DeviceEthernetFiberDet
 
 // Your GetFiberDetailByID function
 func (db *DB) GetFiberDetailByID(id int) (models.DeviceEthernetFiberDetail, error) {
-	var fiberDetail models.DeviceEthernetFiberDetail
-	query := `
-        SELECT * FROM device_ethernet_fiber WHERE id = $1
-    `
-	err := db.QueryRow(query, id).Scan(
-		&fiberDetail.Id,
-		&fiberDetail.SerialNumber,
-		&fiberDetail.DeviceMakeModel,
-		&fiberDetail.Model,
-		&fiberDetail.DeviceType,
-		&fiberDetail.DevicePhysicalPort,
-		&fiberDetail.DevicePortType,
-		&fiberDetail.DevicePortMACWWN,
-		&fiberDetail.ConnectedDevicePort,
-	)
+	query := "SELECT " + deviceEthernetFiberColumns + " FROM device_ethernet_fiber WHERE id = $1"
+	fiberDetail, err := scanDeviceEthernetFiberDetail(db.queryRowRead(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Return a custom error when the fiber detail is not found
@@ -105,3 +92,29 @@ func (db *DB) UpdateDeviceEthernetFiberDetail(id int, data *models.DeviceEtherne
 	logger.InfoLogger.Printf("Updated DeviceEthernetFiberDetail with ID %d successfully", id)
 	return nil
 }
+
+// GetDeviceEthernetFiberDetailsBySerials retrieves all device_ethernet_fiber records for the given serial numbers in a single query.
+func (db *DB) GetDeviceEthernetFiberDetailsBySerials(serials []string) ([]models.DeviceEthernetFiberDetail, error) {
+	query := "SELECT " + deviceEthernetFiberColumns + " FROM device_ethernet_fiber WHERE serial_number = ANY($1)"
+	rows, err := db.queryRead(query, pq.Array(serials))
+	if err != nil {
+		logger.ErrorLogger.Printf("Error querying DeviceEthernetFiberDetail by serials: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.DeviceEthernetFiberDetail
+	for rows.Next() {
+		data, err := scanDeviceEthernetFiberDetail(rows)
+		if err != nil {
+			logger.ErrorLogger.Printf("Error scanning DeviceEthernetFiberDetail: %v", err)
+			return nil, err
+		}
+		results = append(results, data)
+	}
+	if err := rows.Err(); err != nil {
+		logger.ErrorLogger.Printf("Error iterating over DeviceEthernetFiberDetail rows: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// Errors returned by SignInWithIdentity.
+var (
+	// ErrIdentityNotLinked is returned for an identity that is not linked
+	// to a user, when no user may be created for it.
+	ErrIdentityNotLinked = errors.New("identity is not linked to a user")
+	// ErrIdentityEmailUnverified is returned for an identity whose email
+	// belongs to an existing user but was not verified by the provider.
+	ErrIdentityEmailUnverified = errors.New("identity email is not verified")
+)
+
+// SignInWithIdentity returns the user linked to the given subject at an
+// OAuth provider. An identity seen for the first time is linked to the user
+// with the same email, compared case-insensitively, if emailVerified is set,
+// or, if provision is set, to newUser, which is then created. created
+// reports the latter.
+func (db *DB) SignInWithIdentity(ctx context.Context, provider, subject string, newUser *models.User, emailVerified, provision bool) (user *models.User, created bool, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error starting identity sign-in: %v", err)
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        SELECT ` + userColumns + `
+        FROM users
+        WHERE id = (SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2)
+    `
+	user, err = scanUser(tx.QueryRowContext(ctx, query, provider, subject))
+	if err == nil {
+		return user, false, nil
+	}
+	if err != sql.ErrNoRows {
+		logger.ErrorLogger.Printf("Error looking up identity: %v", err)
+		return nil, false, unavailable(err)
+	}
+
+	query = `
+        SELECT ` + userColumns + `
+        FROM users
+        WHERE LOWER(email) = LOWER($1)
+        FOR UPDATE
+    `
+	user, err = scanUser(tx.QueryRowContext(ctx, query, newUser.Email))
+	switch {
+	case err == sql.ErrNoRows && !provision:
+		return nil, false, ErrIdentityNotLinked
+	case err == sql.ErrNoRows:
+		query = `
+            INSERT INTO users (first_name, last_name, phone, email, password, role)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            RETURNING ` + userColumns
+		user, err = scanUser(tx.QueryRowContext(ctx, query, newUser.FirstName, newUser.LastName, newUser.Phone, newUser.Email, newUser.Password, newUser.Role))
+		if err != nil {
+			logger.ErrorLogger.Printf("Error creating user for identity: %v", err)
+			return nil, false, phoneTaken(err)
+		}
+		created = true
+	case err != nil:
+		logger.ErrorLogger.Printf("Error looking up user for identity: %v", err)
+		return nil, false, unavailable(err)
+	case !emailVerified:
+		// Whoever controls the identity has not shown they own the account
+		return nil, false, ErrIdentityEmailUnverified
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO user_identities (user_id, provider, subject) VALUES ($1, $2, $3)", user.ID, provider, subject); err != nil {
+		logger.ErrorLogger.Printf("Error linking identity: %v", err)
+		return nil, false, unavailable(err)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.ErrorLogger.Printf("Error committing identity sign-in: %v", err)
+		return nil, false, unavailable(err)
+	}
+	return user, created, nil
+}
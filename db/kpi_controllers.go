@@ -0,0 +1,52 @@
+package db
+
+import (
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+// CountAssetsByPeriod counts the distinct devices whose purchase order date
+// (added) and end of support life (retired) fall in [from, to), grouped by
+// period, one of day, week or month. Both maps are keyed by the start of the
+// period as 2006-01-02; periods without devices are absent.
+func (db *DB) CountAssetsByPeriod(period string, from, to time.Time) (added, retired map[string]int, err error) {
+	query := `
+        SELECT 'added', date_trunc($1, po_order_date)::date, COUNT(DISTINCT serial_number)
+        FROM device_amc_owner
+        WHERE po_order_date >= $2::date AND po_order_date < $3::date
+        GROUP BY 2
+        UNION ALL
+        SELECT 'retired', date_trunc($1, eosl_date)::date, COUNT(DISTINCT serial_number)
+        FROM device_amc_owner
+        WHERE eosl_date >= $2::date AND eosl_date < $3::date
+        GROUP BY 2
+    `
+	rows, err := db.queryRead(query, period, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		logger.ErrorLogger.Printf("Error counting assets by period: %v", err)
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	added, retired = make(map[string]int), make(map[string]int)
+	for rows.Next() {
+		var kind string
+		var start time.Time
+		var count int
+		if err := rows.Scan(&kind, &start, &count); err != nil {
+			logger.ErrorLogger.Printf("Error scanning asset counts: %v", err)
+			return nil, nil, err
+		}
+		if kind == "added" {
+			added[start.Format("2006-01-02")] = count
+		} else {
+			retired[start.Format("2006-01-02")] = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		logger.ErrorLogger.Printf("Error iterating over asset counts: %v", err)
+		return nil, nil, err
+	}
+	return added, retired, nil
+}
@@ -1,29 +1,41 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
 	"github.com/vikash-parashar/asset-locator/logger" // Import the logger package
 	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
 )
 
-// CreateDeviceLocationDetail creates a new record in the DeviceLocationDetail table.
+// approvedOnly restricts the device listings to approved devices.
+const approvedOnly = " WHERE approval_status = '" + models.DeviceApproved + "'"
+
+// CreateDeviceLocationDetail creates a new record in the DeviceLocationDetail
+// table, pending approval if that is required, and sets data.Id.
 func (db *DB) CreateDeviceLocationDetail(data *models.DeviceLocationDetail) error {
 	query := `
-		INSERT INTO device_location (serial_number, device_make_model, model, device_type, data_center, region, dc_location, device_location, device_row_number, device_rack_number, device_ru_number)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		INSERT INTO device_location (serial_number, device_make_model, model, device_type, data_center, region, dc_location, device_location, device_row_number, device_rack_number, device_ru_number, approval_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
 	`
-	_, err := db.Exec(query, data.SerialNumber, data.DeviceMakeModel, data.Model, data.DeviceType, data.DataCenter, data.Region, data.DCLocation, data.DeviceLocation, data.DeviceRowNumber, data.DeviceRackNumber, data.DeviceRUNumber)
+	data.ApprovalStatus = db.newDeviceStatus()
+	err := db.QueryRow(query, data.SerialNumber, data.DeviceMakeModel, data.Model, data.DeviceType, data.DataCenter, data.Region, data.DCLocation, data.DeviceLocation, data.DeviceRowNumber, data.DeviceRackNumber, data.DeviceRUNumber, data.ApprovalStatus).Scan(&data.Id)
 	if err != nil {
 		logger.ErrorLogger.Printf("Error creating DeviceLocationDetail: %v", err)
 		return err
 	}
 	logger.InfoLogger.Println("Created DeviceLocationDetail successfully")
+	utils.PublishDeviceEvent(utils.DeviceCreated, *data)
 	return nil
 }
 
-// GetAllDeviceLocationDetail retrieves all records from the DeviceLocationDetail table.
+// GetAllDeviceLocationDetail retrieves all approved records from the DeviceLocationDetail table.
 func (db *DB) GetAllDeviceLocationDetail() ([]models.DeviceLocationDetail, error) {
-	query := "SELECT * FROM device_location"
-	rows, err := db.Query(query)
+	query := "SELECT " + deviceLocationColumns + " FROM device_location" + approvedOnly
+	rows, err := db.queryRead(query)
 	if err != nil {
 		logger.ErrorLogger.Printf("Error querying DeviceLocationDetail: %v", err)
 		return nil, err
@@ -32,8 +44,7 @@ func (db *DB) GetAllDeviceLocationDetail() ([]models.DeviceLocationDetail, error
 
 	var results []models.DeviceLocationDetail
 	for rows.Next() {
-		var data models.DeviceLocationDetail
-		err := rows.Scan(&data.Id, &data.SerialNumber, &data.DeviceMakeModel, &data.Model, &data.DeviceType, &data.DataCenter, &data.Region, &data.DCLocation, &data.DeviceLocation, &data.DeviceRowNumber, &data.DeviceRackNumber, &data.DeviceRUNumber)
+		data, err := scanDeviceLocationDetail(rows)
 		if err != nil {
 			logger.ErrorLogger.Printf("Error scanning DeviceLocationDetail: %v", err)
 			return nil, err
@@ -45,22 +56,62 @@ func (db *DB) GetAllDeviceLocationDetail() ([]models.DeviceLocationDetail, error
 	return results, nil
 }
 
-// UpdateDeviceLocationDetail updates an existing record in the device_location table based on the ID.
+// UpdateDeviceLocationDetail updates an existing record in the device_location
+// table based on the ID and sets data.ApprovalStatus. When approval is
+// required, a device that moves is pending approval again. It returns
+// ErrDeviceNotFound if there is no such record.
 func (db *DB) UpdateDeviceLocationDetail(id int, data *models.DeviceLocationDetail) error {
 	query := `
+        WITH old AS (
+            SELECT ` + devicePlaceColumns + ` FROM device_location WHERE id = $1
+        )
         UPDATE device_location
-        SET serial_number = $2, device_make_model = $3, model = $4, device_type = $5, data_center = $6, region = $7, dc_location = $8, device_location = $9, device_row_number = $10, device_rack_number = $11, device_ru_number = $12
+        SET serial_number = $2, device_make_model = $3, model = $4, device_type = $5, data_center = $6, region = $7, dc_location = $8, device_location = $9, device_row_number = $10, device_rack_number = $11, device_ru_number = $12,
+            approval_status = CASE
+                WHEN $13 AND (data_center, region, dc_location, device_location, device_row_number, device_rack_number, device_ru_number) IS DISTINCT FROM ($6, $7, $8, $9, $10, $11, $12)
+                THEN '` + models.DevicePendingApproval + `'
+                ELSE approval_status
+            END
         WHERE id = $1
+        RETURNING approval_status, ` + movedFromOld + `
     `
-	_, err := db.Exec(query, id, data.SerialNumber, data.DeviceMakeModel, data.Model, data.DeviceType, data.DataCenter, data.Region, data.DCLocation, data.DeviceLocation, data.DeviceRowNumber, data.DeviceRackNumber, data.DeviceRUNumber)
+	var moved bool
+	err := db.QueryRow(query, id, data.SerialNumber, data.DeviceMakeModel, data.Model, data.DeviceType, data.DataCenter, data.Region, data.DCLocation, data.DeviceLocation, data.DeviceRowNumber, data.DeviceRackNumber, data.DeviceRUNumber, db.requireDeviceApproval).Scan(&data.ApprovalStatus, &moved)
+	if err == sql.ErrNoRows {
+		return ErrDeviceNotFound
+	}
 	if err != nil {
 		logger.ErrorLogger.Printf("Error updating DeviceLocationDetail: %v", err)
 		return err
 	}
 	logger.InfoLogger.Printf("Updated DeviceLocationDetail with ID %d successfully", id)
+	data.Id = id
+	publishDeviceChange(*data, moved)
 	return nil
 }
 
+// devicePlaceColumns are the columns giving where a device is; a change to
+// any of them moves it.
+const devicePlaceColumns = "data_center, region, dc_location, device_location, device_row_number, device_rack_number, device_ru_number"
+
+// movedFromOld compares, in the RETURNING clause of a statement starting
+// with a WITH old AS (SELECT devicePlaceColumns ...) query, the place of the
+// device before and after the statement. It is false for a new device.
+const movedFromOld = `EXISTS (
+            SELECT 1 FROM old
+            WHERE (old.data_center, old.region, old.dc_location, old.device_location, old.device_row_number, old.device_rack_number, old.device_ru_number)
+                IS DISTINCT FROM (device_location.data_center, device_location.region, device_location.dc_location, device_location.device_location, device_location.device_row_number, device_location.device_rack_number, device_location.device_ru_number)
+        ) AS moved`
+
+// publishDeviceChange publishes an update of device, as a move if it moved.
+func publishDeviceChange(device models.DeviceLocationDetail, moved bool) {
+	if moved {
+		utils.PublishDeviceEvent(utils.DeviceMoved, device)
+		return
+	}
+	utils.PublishDeviceEvent(utils.DeviceUpdated, device)
+}
+
 // DeleteDeviceLocationDetail deletes a record from the device_location table based on the ID.
 func (db *DB) DeleteDeviceLocationDetail(id int) error {
 	query := "DELETE FROM device_location WHERE id = $1"
@@ -75,8 +126,8 @@ func (db *DB) DeleteDeviceLocationDetail(id int) error {
 
 // FetchDataFromTable3 retrieves data from table 3.
 func (db *DB) FetchDataFromDeviceLocation() ([]*models.DeviceLocationDetail, error) {
-	query := "SELECT * FROM device_location"
-	rows, err := db.Query(query)
+	query := "SELECT " + deviceLocationColumns + " FROM device_location" + approvedOnly
+	rows, err := db.queryRead(query)
 	if err != nil {
 		logger.ErrorLogger.Printf("Error fetching data from table 3: %v", err)
 		return nil, err
@@ -85,8 +136,7 @@ func (db *DB) FetchDataFromDeviceLocation() ([]*models.DeviceLocationDetail, err
 
 	var results []*models.DeviceLocationDetail
 	for rows.Next() {
-		var data models.DeviceLocationDetail
-		err := rows.Scan(&data.Id, &data.SerialNumber, &data.DeviceMakeModel, &data.Model, &data.DeviceType, &data.DataCenter, &data.Region, &data.DCLocation, &data.DeviceLocation, &data.DeviceRowNumber, &data.DeviceRackNumber, &data.DeviceRUNumber)
+		data, err := scanDeviceLocationDetail(rows)
 		if err != nil {
 			logger.ErrorLogger.Printf("Error scanning data from table 3: %v", err)
 			return nil, err
@@ -97,3 +147,130 @@ func (db *DB) FetchDataFromDeviceLocation() ([]*models.DeviceLocationDetail, err
 	logger.InfoLogger.Println("Fetched data from device_location table successfully")
 	return results, nil
 }
+
+// GetDeviceLocationDetailsBySerials retrieves all device_location records for the given serial numbers in a single query.
+func (db *DB) GetDeviceLocationDetailsBySerials(serials []string) ([]models.DeviceLocationDetail, error) {
+	query := "SELECT " + deviceLocationColumns + " FROM device_location WHERE serial_number = ANY($1)"
+	rows, err := db.queryRead(query, pq.Array(serials))
+	if err != nil {
+		logger.ErrorLogger.Printf("Error querying DeviceLocationDetail by serials: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.DeviceLocationDetail
+	for rows.Next() {
+		data, err := scanDeviceLocationDetail(rows)
+		if err != nil {
+			logger.ErrorLogger.Printf("Error scanning DeviceLocationDetail: %v", err)
+			return nil, err
+		}
+		results = append(results, data)
+	}
+	if err := rows.Err(); err != nil {
+		logger.ErrorLogger.Printf("Error iterating over DeviceLocationDetail rows: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// UpsertDeviceLocationDetailBySerial inserts data, or updates the existing
+// record with the same serial number, in a single statement. It sets data.Id
+// and data.ApprovalStatus and reports whether a new record was created. When
+// approval is required, new devices and devices that move are pending.
+func (db *DB) UpsertDeviceLocationDetailBySerial(data *models.DeviceLocationDetail) (bool, error) {
+	query := `
+		WITH old AS (
+			SELECT ` + devicePlaceColumns + ` FROM device_location WHERE serial_number = $1
+		)
+		INSERT INTO device_location (serial_number, device_make_model, model, device_type, data_center, region, dc_location, device_location, device_row_number, device_rack_number, device_ru_number, approval_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (serial_number) DO UPDATE
+		SET device_make_model = EXCLUDED.device_make_model, model = EXCLUDED.model, device_type = EXCLUDED.device_type, data_center = EXCLUDED.data_center, region = EXCLUDED.region, dc_location = EXCLUDED.dc_location, device_location = EXCLUDED.device_location, device_row_number = EXCLUDED.device_row_number, device_rack_number = EXCLUDED.device_rack_number, device_ru_number = EXCLUDED.device_ru_number,
+			approval_status = CASE
+				WHEN $13 AND (device_location.data_center, device_location.region, device_location.dc_location, device_location.device_location, device_location.device_row_number, device_location.device_rack_number, device_location.device_ru_number)
+					IS DISTINCT FROM (EXCLUDED.data_center, EXCLUDED.region, EXCLUDED.dc_location, EXCLUDED.device_location, EXCLUDED.device_row_number, EXCLUDED.device_rack_number, EXCLUDED.device_ru_number)
+				THEN '` + models.DevicePendingApproval + `'
+				ELSE device_location.approval_status
+			END
+		RETURNING id, approval_status, (xmax = 0) AS created, ` + movedFromOld + `
+	`
+	var created, moved bool
+	err := db.QueryRow(query, data.SerialNumber, data.DeviceMakeModel, data.Model, data.DeviceType, data.DataCenter, data.Region, data.DCLocation, data.DeviceLocation, data.DeviceRowNumber, data.DeviceRackNumber, data.DeviceRUNumber, db.newDeviceStatus(), db.requireDeviceApproval).Scan(&data.Id, &data.ApprovalStatus, &created, &moved)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error upserting DeviceLocationDetail %s: %v", data.SerialNumber, err)
+		return false, err
+	}
+	if created {
+		logger.InfoLogger.Printf("Created DeviceLocationDetail %s with ID %d", data.SerialNumber, data.Id)
+		utils.PublishDeviceEvent(utils.DeviceCreated, *data)
+	} else {
+		logger.InfoLogger.Printf("Updated DeviceLocationDetail %s with ID %d", data.SerialNumber, data.Id)
+		publishDeviceChange(*data, moved)
+	}
+	return created, nil
+}
+
+// GetDeviceLocationDetailsByIDs retrieves the device_location records with the given ids in a single query.
+func (db *DB) GetDeviceLocationDetailsByIDs(ids []int) ([]models.DeviceLocationDetail, error) {
+	query := "SELECT " + deviceLocationColumns + " FROM device_location WHERE id = ANY($1)"
+	rows, err := db.queryRead(query, pq.Array(ids))
+	if err != nil {
+		logger.ErrorLogger.Printf("Error querying DeviceLocationDetail by ids: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.DeviceLocationDetail
+	for rows.Next() {
+		data, err := scanDeviceLocationDetail(rows)
+		if err != nil {
+			logger.ErrorLogger.Printf("Error scanning DeviceLocationDetail: %v", err)
+			return nil, err
+		}
+		results = append(results, data)
+	}
+	if err := rows.Err(); err != nil {
+		logger.ErrorLogger.Printf("Error iterating over DeviceLocationDetail rows: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// DeviceLocationCursor walks over device_location rows one at a time, for
+// exports that must not hold the whole table in memory.
+type DeviceLocationCursor struct {
+	rows *sql.Rows
+}
+
+// OpenDeviceLocationCursor queries every approved device location ordered by
+// id. The query is bound to ctx, and the caller must Close the cursor.
+func (db *DB) OpenDeviceLocationCursor(ctx context.Context) (*DeviceLocationCursor, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+deviceLocationColumns+" FROM device_location"+approvedOnly+" ORDER BY id")
+	if err != nil {
+		logger.ErrorLogger.Printf("Error querying DeviceLocationDetail: %v", err)
+		return nil, err
+	}
+	return &DeviceLocationCursor{rows: rows}, nil
+}
+
+// Next advances to the next row, returning false when there are no more rows
+// or an error occurred; check Err afterwards.
+func (c *DeviceLocationCursor) Next() bool {
+	return c.rows.Next()
+}
+
+// Detail scans the current row.
+func (c *DeviceLocationCursor) Detail() (models.DeviceLocationDetail, error) {
+	return scanDeviceLocationDetail(c.rows)
+}
+
+// Err returns the error, if any, that ended the iteration.
+func (c *DeviceLocationCursor) Err() error {
+	return c.rows.Err()
+}
+
+// Close releases the underlying connection.
+func (c *DeviceLocationCursor) Close() error {
+	return c.rows.Close()
+}
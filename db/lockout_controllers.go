@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// RecordFailedLogin counts a failed password login of a user. From the
+// threshold-th consecutive failure on, every failure locks the account for
+// lockout, doubled for each failure beyond the threshold up to maxLockout.
+// It returns until when the account is locked, or the zero time.
+func (db *DB) RecordFailedLogin(userID, threshold int, lockout, maxLockout time.Duration) (time.Time, error) {
+	query := `
+        UPDATE users
+        SET failed_logins = failed_logins + 1,
+            locked_until = CASE
+                WHEN failed_logins + 1 >= $2
+                THEN NOW() + make_interval(secs => LEAST($3 * power(2, LEAST(failed_logins + 1 - $2, 30)), $4))
+                ELSE locked_until
+            END
+        WHERE id = $1
+        RETURNING locked_until
+    `
+	var lockedUntil sql.NullTime
+	err := db.QueryRow(query, userID, threshold, lockout.Seconds(), maxLockout.Seconds()).Scan(&lockedUntil)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error recording failed login: %v", err)
+		return time.Time{}, unavailable(err)
+	}
+	return lockedUntil.Time, nil
+}
+
+// ResetFailedLogins clears the failed logins of a user after a successful
+// login.
+func (db *DB) ResetFailedLogins(userID int) error {
+	query := `
+        UPDATE users
+        SET failed_logins = 0, locked_until = NULL
+        WHERE id = $1 AND (failed_logins > 0 OR locked_until IS NOT NULL)
+    `
+	if _, err := db.Exec(query, userID); err != nil {
+		logger.ErrorLogger.Printf("Error resetting failed logins: %v", err)
+		return unavailable(err)
+	}
+	return nil
+}
+
+// GetUserSecurity returns the failed logins and lockout of a user, or
+// ErrUserNotFound for an unknown user.
+func (db *DB) GetUserSecurity(userID int) (*models.UserSecurity, error) {
+	query := `
+        SELECT failed_logins, locked_until, COALESCE(locked_until > NOW(), FALSE)
+        FROM users
+        WHERE id = $1
+    `
+	security := &models.UserSecurity{UserID: userID}
+	var lockedUntil sql.NullTime
+	err := db.queryRowRead(query, userID).Scan(&security.FailedLogins, &lockedUntil, &security.Locked)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error fetching the lockout of user %d: %v", userID, err)
+		return nil, unavailable(err)
+	}
+	if lockedUntil.Valid {
+		security.LockedUntil = &lockedUntil.Time
+	}
+	return security, nil
+}
+
+// UnlockUser lifts the login lockout of a user and clears their failed
+// logins, recording in the audit log that actorID did so. It returns
+// ErrUserNotFound for an unknown user.
+func (db *DB) UnlockUser(actorID, userID int) error {
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error beginning transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var failedLogins int
+	err = tx.QueryRowContext(ctx, "SELECT failed_logins FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&failedLogins)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error fetching failed logins: %v", err)
+		return unavailable(err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = $1", userID); err != nil {
+		logger.ErrorLogger.Printf("Error unlocking user: %v", err)
+		return unavailable(err)
+	}
+
+	details := fmt.Sprintf("%d failed logins cleared", failedLogins)
+	if _, err := tx.ExecContext(ctx, insertAuditEntry, actorID, userID, models.AuditUserUnlocked, details); err != nil {
+		logger.ErrorLogger.Printf("Error adding audit entry %s: %v", models.AuditUserUnlocked, err)
+		return unavailable(err)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.ErrorLogger.Printf("Error committing user unlock: %v", err)
+		return unavailable(err)
+	}
+	return nil
+}
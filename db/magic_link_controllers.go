@@ -0,0 +1,49 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// ErrMagicLinkInvalid is returned for a magic link token that is unknown,
+// expired or already used.
+var ErrMagicLinkInvalid = errors.New("magic link is invalid or has expired")
+
+// SetMagicLinkToken stores the hash of a user's one-time login token,
+// replacing any earlier one.
+func (db *DB) SetMagicLinkToken(userID int, tokenHash string, expiryTime time.Time) error {
+	query := `
+        UPDATE users
+        SET magic_token_hash = $1, magic_token_expiry = $2
+        WHERE id = $3
+    `
+	if _, err := db.Exec(query, tokenHash, expiryTime, userID); err != nil {
+		logger.ErrorLogger.Printf("Error setting magic link token: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ConsumeMagicLinkToken clears an unexpired one-time login token and
+// returns its user. Clearing and reading happen in one statement, so of two
+// concurrent uses of the same link only one succeeds.
+func (db *DB) ConsumeMagicLinkToken(tokenHash string) (*models.User, error) {
+	query := `
+        UPDATE users
+        SET magic_token_hash = NULL, magic_token_expiry = NULL
+        WHERE magic_token_hash = $1 AND magic_token_expiry > NOW()
+        RETURNING ` + userColumns
+	user, err := scanUser(db.QueryRow(query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMagicLinkInvalid
+		}
+		logger.ErrorLogger.Printf("Error consuming magic link token: %v", err)
+		return nil, unavailable(err)
+	}
+	return user, nil
+}
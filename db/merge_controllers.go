@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// ErrDeviceNotFound is returned, wrapped with the missing ids, when a device
+// to merge does not exist.
+var ErrDeviceNotFound = errors.New("device not found")
+
+// MergeDeviceLocationDetails merges the duplicate device_location records
+// into the primary one in a single transaction. Power, fiber and AMC/owner
+// records of the duplicates are reassigned to the primary's serial number,
+// the primary keeps the most recent audit time of all the records, and the
+// duplicates are deleted. It returns the merged primary record.
+func (db *DB) MergeDeviceLocationDetails(ctx context.Context, primaryID int, duplicateIDs []int) (models.DeviceLocationDetail, error) {
+	var primary models.DeviceLocationDetail
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error starting device merge: %v", err)
+		return primary, err
+	}
+	defer tx.Rollback()
+
+	ids := append([]int{primaryID}, duplicateIDs...)
+	rows, err := tx.QueryContext(ctx, "SELECT id, serial_number FROM device_location WHERE id = ANY($1) FOR UPDATE", pq.Array(ids))
+	if err != nil {
+		logger.ErrorLogger.Printf("Error locking devices to merge: %v", err)
+		return primary, unavailable(err)
+	}
+	serials := make(map[int]string, len(ids))
+	for rows.Next() {
+		var id int
+		var serial string
+		if err := rows.Scan(&id, &serial); err != nil {
+			rows.Close()
+			return primary, unavailable(err)
+		}
+		serials[id] = serial
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return primary, unavailable(err)
+	}
+
+	var missing []int
+	for _, id := range ids {
+		if _, ok := serials[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return primary, fmt.Errorf("%w: %v", ErrDeviceNotFound, missing)
+	}
+
+	duplicateSerials := make([]string, 0, len(duplicateIDs))
+	for _, id := range duplicateIDs {
+		duplicateSerials = append(duplicateSerials, serials[id])
+	}
+
+	for _, table := range []string{"device_power", "device_ethernet_fiber", "device_amc_owner"} {
+		query := "UPDATE " + table + " SET serial_number = $1 WHERE serial_number = ANY($2)"
+		if _, err := tx.ExecContext(ctx, query, serials[primaryID], pq.Array(duplicateSerials)); err != nil {
+			logger.ErrorLogger.Printf("Error reassigning %s records to device %d: %v", table, primaryID, err)
+			return primary, unavailable(err)
+		}
+	}
+
+	query := `
+		UPDATE device_location
+		SET last_audited_at = (SELECT MAX(last_audited_at) FROM device_location WHERE id = ANY($2))
+		WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, primaryID, pq.Array(ids)); err != nil {
+		logger.ErrorLogger.Printf("Error updating merged device %d: %v", primaryID, err)
+		return primary, unavailable(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM device_location WHERE id = ANY($1)", pq.Array(duplicateIDs)); err != nil {
+		logger.ErrorLogger.Printf("Error deleting duplicate devices %v: %v", duplicateIDs, err)
+		return primary, unavailable(err)
+	}
+
+	primary, err = scanDeviceLocationDetail(tx.QueryRowContext(ctx, "SELECT "+deviceLocationColumns+" FROM device_location WHERE id = $1", primaryID))
+	if err != nil {
+		return primary, unavailable(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorLogger.Printf("Error committing device merge: %v", err)
+		return primary, unavailable(err)
+	}
+
+	logger.InfoLogger.Printf("Merged devices %v (serials %v) into device %d (serial %s)", duplicateIDs, duplicateSerials, primaryID, primary.SerialNumber)
+	return primary, nil
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMergeDeviceLocationDetails(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, serial_number FROM device_location WHERE id = ANY").WithArgs("{1,7,9}").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "serial_number"}).AddRow(1, "SN-1").AddRow(7, "SN-7").AddRow(9, "SN-9"))
+	for _, table := range []string{"device_power", "device_ethernet_fiber", "device_amc_owner"} {
+		mock.ExpectExec("UPDATE "+table+" SET serial_number").WithArgs("SN-1", `{"SN-7","SN-9"}`).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec("UPDATE device_location").WithArgs(1, "{1,7,9}").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM device_location").WithArgs("{7,9}").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("FROM device_location WHERE id = \\$1").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "serial_number", "device_make_model", "model", "device_type",
+		"data_center", "region", "dc_location", "device_location", "device_row_number", "device_rack_number", "device_ru_number",
+		"last_audited_at", "approval_status", "reviewed_by", "reviewed_at", "custom_fields"}).
+		AddRow(1, "SN-1", "Dell R740", "R740", "server", "DC1", "EU", "Room 1", "IDC1", 3, 4, "10-12", nil, "approved", nil, nil, nil))
+	mock.ExpectCommit()
+
+	primary, err := db.MergeDeviceLocationDetails(context.Background(), 1, []int{7, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary.Id != 1 || primary.SerialNumber != "SN-1" {
+		t.Errorf("primary = %+v, want device 1", primary)
+	}
+}
+
+func TestMergeDeviceLocationDetailsMissing(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, serial_number FROM device_location WHERE id = ANY").WithArgs("{1,7}").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "serial_number"}).AddRow(1, "SN-1"))
+	mock.ExpectRollback()
+
+	if _, err := db.MergeDeviceLocationDetails(context.Background(), 1, []int{7}); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("error = %v, want ErrDeviceNotFound", err)
+	}
+}
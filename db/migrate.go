@@ -0,0 +1,288 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+// MigrationsDir is where numbered migration files are read from, relative
+// to the working directory.
+const MigrationsDir = "./db/migrations"
+
+// migrationLockID is the advisory lock held while migrating so that two
+// instances never apply the same version.
+const migrationLockID = 7318004
+
+// errMigrationApplied skips a migration that another instance applied
+// after the applied versions were read.
+var errMigrationApplied = errors.New("migration already applied")
+
+// migrationFile matches e.g. 0001_create_users.up.sql.
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one schema change, with the SQL to apply and to revert it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState is a migration and when it was applied, if it has been.
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// LoadMigrations reads the migrations in dir, ordered by version. Every
+// version needs an up file; a missing down file only fails when reverting.
+func LoadMigrations(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %v", entry.Name(), err)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ensureMigrationsTable creates the table recording applied versions.
+func (db *DB) ensureMigrationsTable(ctx context.Context) error {
+	conn, err := db.conn()
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`)
+	return unavailable(err)
+}
+
+// appliedMigrations returns when each applied version was applied.
+func (db *DB) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	if err := db.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatus reports every migration and whether it has been applied.
+func (db *DB) MigrationStatus(ctx context.Context, migrations []Migration) ([]MigrationState, error) {
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]MigrationState, len(migrations))
+	for i, migration := range migrations {
+		states[i].Migration = migration
+		if appliedAt, ok := applied[migration.Version]; ok {
+			states[i].AppliedAt = &appliedAt
+		}
+	}
+	return states, nil
+}
+
+// PendingMigrations returns, in order, the migrations MigrateUp would apply
+// without changing the schema.
+func (db *DB) PendingMigrations(ctx context.Context, migrations []Migration) ([]Migration, error) {
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pendingMigrations(migrations, applied), nil
+}
+
+// MigrateUp applies, in order, the migrations newer than the latest applied
+// version, at most steps of them if steps is positive. Each migration runs
+// in its own transaction together with its schema_migrations row, so a
+// failing statement leaves that version unapplied. Every applied migration
+// is logged with its duration and the rows it affected, followed by a
+// summary. It returns the applied migrations; none when the schema is
+// already current.
+func (db *DB) MigrateUp(ctx context.Context, migrations []Migration, steps int) ([]Migration, error) {
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	var done []Migration
+	for _, migration := range pendingMigrations(migrations, applied) {
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		migrationStarted := time.Now()
+		var rows int64
+		err := db.inMigrationTx(ctx, func(tx *sql.Tx) error {
+			// Another instance may have applied it while we waited for the lock
+			var exists bool
+			if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", migration.Version).Scan(&exists); err != nil {
+				return err
+			}
+			if exists {
+				return errMigrationApplied
+			}
+			result, err := tx.ExecContext(ctx, migration.Up)
+			if err != nil {
+				return err
+			}
+			rows, _ = result.RowsAffected()
+			_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", migration.Version)
+			return err
+		})
+		if err == errMigrationApplied {
+			continue
+		}
+		if err != nil {
+			return done, fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		logger.InfoLogger.Printf("Applied migration %04d_%s in %s, %d rows affected\n", migration.Version, migration.Name, time.Since(migrationStarted).Round(time.Millisecond), rows)
+		done = append(done, migration)
+	}
+	if len(done) > 0 {
+		latest := done[len(done)-1]
+		logger.InfoLogger.Printf("Applied %d migrations in %s, schema is now at %04d_%s\n", len(done), time.Since(started).Round(time.Millisecond), latest.Version, latest.Name)
+	}
+	return done, nil
+}
+
+// pendingMigrations returns the migrations newer than the latest applied
+// version, in order.
+func pendingMigrations(migrations []Migration, applied map[int]time.Time) []Migration {
+	current := 0
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+	return pending
+}
+
+// MigrateDown reverts the latest steps applied migrations, newest first,
+// each in its own transaction, logging them like MigrateUp. It returns the
+// reverted migrations.
+func (db *DB) MigrateDown(ctx context.Context, migrations []Migration, steps int) ([]Migration, error) {
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return done, fmt.Errorf("migration %04d_%s has no down file", migration.Version, migration.Name)
+		}
+		migrationStarted := time.Now()
+		var rows int64
+		err := db.inMigrationTx(ctx, func(tx *sql.Tx) error {
+			result, err := tx.ExecContext(ctx, migration.Down)
+			if err != nil {
+				return err
+			}
+			rows, _ = result.RowsAffected()
+			_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		logger.InfoLogger.Printf("Reverted migration %04d_%s in %s, %d rows affected\n", migration.Version, migration.Name, time.Since(migrationStarted).Round(time.Millisecond), rows)
+		done = append(done, migration)
+	}
+	if len(done) > 0 {
+		logger.InfoLogger.Printf("Reverted %d migrations in %s\n", len(done), time.Since(started).Round(time.Millisecond))
+	}
+	return done, nil
+}
+
+// inMigrationTx runs fn in a transaction holding the migration lock,
+// committing only if fn succeeds.
+func (db *DB) inMigrationTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations("migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %04d_%s: version %d expected", migration.Version, migration.Name, i+1)
+		}
+		if migration.Down == "" {
+			t.Errorf("migration %04d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestMigrateUpFromEmptyDatabase(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "init", Up: "CREATE TABLE users (id SERIAL PRIMARY KEY)"},
+		{Version: 2, Name: "device_approval", Up: "ALTER TABLE device_location ADD COLUMN approval_status TEXT"},
+		{Version: 3, Name: "magic_links", Up: "ALTER TABLE users ADD COLUMN magic_token_hash TEXT"},
+	}
+
+	db, mock := newMockDB(t)
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	for _, migration := range migrations {
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+		// Version 2 was applied by another instance while waiting for the lock
+		applied := migration.Version == 2
+		mock.ExpectQuery("SELECT EXISTS").WithArgs(migration.Version).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(applied))
+		if applied {
+			mock.ExpectRollback()
+			continue
+		}
+		mock.ExpectExec(regexp.QuoteMeta(migration.Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(migration.Version).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	done, err := db.MigrateUp(context.Background(), migrations, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 || done[0].Version != 1 || done[1].Version != 3 {
+		t.Errorf("applied %+v, want versions 1 and 3", done)
+	}
+}
+
+func TestMigrateUpLogsProgress(t *testing.T) {
+	var logged bytes.Buffer
+	previous := logger.InfoLogger.Writer()
+	logger.InfoLogger.SetOutput(&logged)
+	t.Cleanup(func() { logger.InfoLogger.SetOutput(previous) })
+
+	migrations := []Migration{
+		{Version: 1, Name: "init", Up: "CREATE TABLE users (id SERIAL PRIMARY KEY)"},
+		{Version: 2, Name: "backfill_roles", Up: "UPDATE users SET role = 'general' WHERE role IS NULL"},
+	}
+	db, mock := newMockDB(t)
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	for i, migration := range migrations {
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT EXISTS").WithArgs(migration.Version).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec(regexp.QuoteMeta(migration.Up)).WillReturnResult(sqlmock.NewResult(0, int64(i*3)))
+		mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(migration.Version).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	if _, err := db.MigrateUp(context.Background(), migrations, 0); err != nil {
+		t.Fatal(err)
+	}
+	out := logged.String()
+	for _, want := range []string{
+		"Applied migration 0001_init in ",
+		", 0 rows affected",
+		"Applied migration 0002_backfill_roles in ",
+		", 3 rows affected",
+		"Applied 2 migrations in ",
+		", schema is now at 0002_backfill_roles",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPendingMigrationsLeavesSchemaUnchanged(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "init", Up: "CREATE TABLE users (id SERIAL PRIMARY KEY)"},
+		{Version: 2, Name: "device_approval", Up: "ALTER TABLE device_location ADD COLUMN approval_status TEXT"},
+		{Version: 3, Name: "magic_links", Up: "ALTER TABLE users ADD COLUMN magic_token_hash TEXT"},
+	}
+
+	// Nothing but reading the applied versions is expected, so running any
+	// migration fails the test
+	db, mock := newMockDB(t)
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+
+	pending, err := db.PendingMigrations(context.Background(), migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].Version != 2 || pending[1].Version != 3 {
+		t.Errorf("pending %+v, want versions 2 and 3", pending)
+	}
+}
+
+var (
+	createTablePattern = regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+IF\s+NOT\s+EXISTS\s+(\w+)\s*\((.*)\)$`)
+	alterTablePattern  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(\w+)\s+(.*)$`)
+	addColumnPattern   = regexp.MustCompile(`(?i)ADD\s+COLUMN\s+(IF\s+NOT\s+EXISTS\s+)?(\w+)`)
+	sqlCommentPattern  = regexp.MustCompile(`--[^\n]*`)
+)
+
+// applyColumns tracks the columns each table has after running the
+// CREATE TABLE IF NOT EXISTS and ALTER TABLE ... ADD COLUMN statements of
+// script against tables. Other statements leave the columns unchanged.
+func applyColumns(t *testing.T, tables map[string]map[string]bool, name, script string) {
+	t.Helper()
+	for _, statement := range strings.Split(sqlCommentPattern.ReplaceAllString(script, ""), ";") {
+		statement = strings.TrimSpace(statement)
+		if match := createTablePattern.FindStringSubmatch(statement); match != nil {
+			if tables[match[1]] != nil {
+				continue
+			}
+			columns := map[string]bool{}
+			for _, definition := range splitDefinitions(match[2]) {
+				column := strings.Fields(definition)[0]
+				switch strings.ToUpper(column) {
+				case "PRIMARY", "UNIQUE", "FOREIGN", "CONSTRAINT", "CHECK":
+					continue
+				}
+				columns[column] = true
+			}
+			tables[match[1]] = columns
+			continue
+		}
+		if match := alterTablePattern.FindStringSubmatch(statement); match != nil {
+			columns := tables[match[1]]
+			if columns == nil {
+				t.Errorf("%s: table %s altered before it exists", name, match[1])
+				continue
+			}
+			for _, add := range addColumnPattern.FindAllStringSubmatch(match[2], -1) {
+				if columns[add[2]] && add[1] == "" {
+					t.Errorf("%s: column %s.%s already exists", name, match[1], add[2])
+				}
+				columns[add[2]] = true
+			}
+		}
+	}
+}
+
+// splitDefinitions splits the body of a CREATE TABLE at the commas outside
+// parentheses.
+func splitDefinitions(body string) []string {
+	var definitions []string
+	depth, start := 0, 0
+	for i, r := range body {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				definitions = append(definitions, body[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(definitions, body[start:])
+}
+
+func TestMigrationsUpgradeBaselineSchema(t *testing.T) {
+	migrations, err := LoadMigrations("migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := os.ReadFile(filepath.Join("testdata", "baseline_schema.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Databases created from the schema.up.sql the project started with must
+	// end up with the same columns as ones created by the migrations alone
+	fresh := map[string]map[string]bool{}
+	upgraded := map[string]map[string]bool{}
+	applyColumns(t, upgraded, "baseline", string(baseline))
+	for _, migration := range migrations {
+		name := fmt.Sprintf("%04d_%s", migration.Version, migration.Name)
+		applyColumns(t, fresh, name, migration.Up)
+		applyColumns(t, upgraded, name, migration.Up)
+	}
+
+	for table, columns := range fresh {
+		for column := range columns {
+			if !upgraded[table][column] {
+				t.Errorf("upgraded database has no column %s.%s", table, column)
+			}
+		}
+	}
+	for table, columns := range upgraded {
+		for column := range columns {
+			if !fresh[table][column] {
+				t.Errorf("fresh database has no column %s.%s", table, column)
+			}
+		}
+	}
+}
@@ -7,5 +7,3 @@ DROP TABLE IF EXISTS device_ethernet_fiber;
 DROP TABLE IF EXISTS device_amc_owner;
 
 DROP TABLE IF EXISTS device_location;
-
-DROP DATABASE assetLocator;
\ No newline at end of file
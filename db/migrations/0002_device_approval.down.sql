@@ -0,0 +1,4 @@
+ALTER TABLE device_location
+    DROP COLUMN IF EXISTS approval_status,
+    DROP COLUMN IF EXISTS reviewed_by,
+    DROP COLUMN IF EXISTS reviewed_at;
@@ -0,0 +1,6 @@
+-- Devices may have to be approved before they are listed; existing devices
+-- are approved
+ALTER TABLE device_location
+    ADD COLUMN IF NOT EXISTS approval_status VARCHAR(32) NOT NULL DEFAULT 'approved',
+    ADD COLUMN IF NOT EXISTS reviewed_by INT REFERENCES users (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ;
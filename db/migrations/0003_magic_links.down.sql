@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS users_magic_token_hash_key;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS magic_token_hash,
+    DROP COLUMN IF EXISTS magic_token_expiry;
@@ -0,0 +1,6 @@
+-- One-time login links are stored as the SHA-256 hash of the emailed token
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS magic_token_hash VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS magic_token_expiry TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_magic_token_hash_key ON users (magic_token_hash);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS device_location_custom_fields_idx;
+
+ALTER TABLE device_location
+    DROP COLUMN IF EXISTS custom_fields;
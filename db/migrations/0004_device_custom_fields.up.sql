@@ -0,0 +1,6 @@
+-- Organization-defined device attributes, validated against CUSTOM_FIELDS.
+-- The GIN index serves equality filters on any key (custom_fields @> ...).
+ALTER TABLE device_location
+    ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS device_location_custom_fields_idx ON device_location USING GIN (custom_fields jsonb_path_ops);
@@ -0,0 +1,45 @@
+-- Roles and the permissions they grant. A permission is an action on a
+-- resource type, e.g. location:delete. The admin role may do anything
+-- regardless of its grants.
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(255) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(100) PRIMARY KEY,
+    resource VARCHAR(50) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    UNIQUE (resource, action)
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(255) NOT NULL REFERENCES roles (name) ON UPDATE CASCADE ON DELETE CASCADE,
+    permission VARCHAR(100) NOT NULL REFERENCES permissions (name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO permissions (name, resource, action)
+SELECT resource || ':' || action, resource, action
+FROM unnest(ARRAY['location', 'owner', 'power', 'fiber']) AS resource,
+     unnest(ARRAY['read', 'write', 'delete']) AS action
+ON CONFLICT DO NOTHING;
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Full access, including user and role management'),
+    ('general', 'Manages the asset inventory')
+ON CONFLICT DO NOTHING;
+
+-- The general role keeps the access it had before permissions existed
+INSERT INTO role_permissions (role, permission)
+SELECT 'general', name FROM permissions
+ON CONFLICT DO NOTHING;
+
+-- Every role in use must exist before users.role references roles
+INSERT INTO roles (name)
+SELECT DISTINCT role FROM users WHERE role IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users
+    ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles (name) ON UPDATE CASCADE;
@@ -0,0 +1,5 @@
+-- Failed password logins since the last successful one, and until when
+-- the account is locked because of them.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS failed_logins INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
@@ -0,0 +1,19 @@
+-- The sessions users are logged in with. JWTs carry the id of their
+-- session, which stops being accepted once the session is revoked. The
+-- refresh tokens of a login belong to its session.
+CREATE TABLE IF NOT EXISTS sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    device VARCHAR(100) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions (user_id);
+
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS session_id INT REFERENCES sessions (id) ON DELETE CASCADE;
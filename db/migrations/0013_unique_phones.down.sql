@@ -0,0 +1,2 @@
+-- The index belongs to the UNIQUE_PHONES setting, not to this migration.
+SELECT 1;
@@ -0,0 +1,5 @@
+-- Unique phone numbers are optional, so the users_phone_key index is not
+-- part of the schema. The server creates it at startup with
+-- UNIQUE_PHONES=true, after normalizing the stored numbers, and drops it
+-- otherwise.
+SELECT 1;
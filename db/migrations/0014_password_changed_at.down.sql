@@ -0,0 +1,3 @@
+-- password_changed_at is kept: databases created with 0001 already had it
+-- before this migration.
+SELECT 1;
@@ -0,0 +1,9 @@
+-- Users created before password expiry was added have no
+-- password_changed_at column, since 0001 only creates missing tables. When
+-- their password was last changed is not known, so it counts from now
+-- rather than expiring every password at once.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMPTZ;
+
+UPDATE users SET password_changed_at = NOW() WHERE password_changed_at IS NULL;
+
+ALTER TABLE users ALTER COLUMN password_changed_at SET DEFAULT NOW();
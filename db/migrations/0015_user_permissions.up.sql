@@ -0,0 +1,6 @@
+-- Reading the list of users, as through GraphQL, is a permission of its
+-- own. No role but admin is granted it, so the list stays admin-only until
+-- a role is given user:read.
+INSERT INTO permissions (name, resource, action)
+VALUES ('user:read', 'user', 'read')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+-- The optional emails each user receives. Keys that are missing take their
+-- default, so new kinds of email need no migration.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notification_preferences JSONB NOT NULL DEFAULT '{}';
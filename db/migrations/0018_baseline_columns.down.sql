@@ -0,0 +1,3 @@
+-- The columns are kept: databases created with 0001 already had them before
+-- this migration.
+SELECT 1;
@@ -0,0 +1,10 @@
+-- Databases created from the original schema.up.sql already have the users
+-- and device_location tables, so 0001 skipped them and these columns, added
+-- to 0001 later, were never created there.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS reset_request_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS reset_window_started_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 0;
+
+ALTER TABLE device_location
+    ADD COLUMN IF NOT EXISTS last_audited_at TIMESTAMPTZ;
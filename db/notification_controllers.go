@@ -0,0 +1,47 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// GetNotificationPreferences returns the notification preferences of a
+// user. Preferences they never set have their default.
+func (db *DB) GetNotificationPreferences(userID int) (models.NotificationPreferences, error) {
+	preferences := models.DefaultNotificationPreferences()
+	var stored []byte
+	err := db.queryRowRead("SELECT notification_preferences FROM users WHERE id = $1", userID).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return preferences, ErrUserNotFound
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error fetching notification preferences: %v", err)
+		return preferences, unavailable(err)
+	}
+	if err := json.Unmarshal(stored, &preferences); err != nil {
+		logger.ErrorLogger.Printf("Error decoding notification preferences of user %d: %v", userID, err)
+		return models.DefaultNotificationPreferences(), nil
+	}
+	return preferences, nil
+}
+
+// SetNotificationPreferences replaces the notification preferences of a
+// user.
+func (db *DB) SetNotificationPreferences(userID int, preferences models.NotificationPreferences) error {
+	stored, err := json.Marshal(preferences)
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec("UPDATE users SET notification_preferences = $2 WHERE id = $1", userID, stored)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error updating notification preferences: %v", err)
+		return unavailable(err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"github.com/lib/pq"
 	"github.com/vikash-parashar/asset-locator/logger" // Import the logger package
 	"github.com/vikash-parashar/asset-locator/models"
 )
@@ -22,8 +23,8 @@ func (db *DB) CreateDeviceAMCOwnerDetail(data *models.DeviceAMCOwnerDetail) erro
 
 // GetAllDeviceAMCOwnerDetail retrieves all records from the device_amc_owner table.
 func (db *DB) GetAllDeviceAMCOwnerDetail() ([]models.DeviceAMCOwnerDetail, error) {
-	query := "SELECT * FROM device_amc_owner"
-	rows, err := db.Query(query)
+	query := "SELECT " + deviceAMCOwnerColumns + " FROM device_amc_owner"
+	rows, err := db.queryRead(query)
 	if err != nil {
 		logger.ErrorLogger.Printf("Error querying DeviceAMCOwnerDetail: %v", err)
 		return nil, err
@@ -32,8 +33,7 @@ func (db *DB) GetAllDeviceAMCOwnerDetail() ([]models.DeviceAMCOwnerDetail, error
 
 	var results []models.DeviceAMCOwnerDetail
 	for rows.Next() {
-		var data models.DeviceAMCOwnerDetail
-		err := rows.Scan(&data.Id, &data.SerialNumber, &data.DeviceMakeModel, &data.Model, &data.PONumber, &data.POOrderDate, &data.EOSLDate, &data.AMCStartDate, &data.AMCEndDate, &data.DeviceOwner)
+		data, err := scanDeviceAMCOwnerDetail(rows)
 		if err != nil {
 			logger.ErrorLogger.Printf("Error scanning DeviceAMCOwnerDetail: %v", err)
 			return nil, err
@@ -75,8 +75,8 @@ func (db *DB) DeleteDeviceAMCOwnerDetail(id int) error {
 
 // FetchDataFromTable1 retrieves data from table 1.
 func (db *DB) FetchDataFromDeviceOwner() ([]*models.DeviceAMCOwnerDetail, error) {
-	query := "SELECT * FROM device_amc_owner"
-	rows, err := db.Query(query)
+	query := "SELECT " + deviceAMCOwnerColumns + " FROM device_amc_owner"
+	rows, err := db.queryRead(query)
 	if err != nil {
 		logger.ErrorLogger.Printf("Error fetching data from table 1: %v", err)
 		return nil, err
@@ -85,8 +85,7 @@ func (db *DB) FetchDataFromDeviceOwner() ([]*models.DeviceAMCOwnerDetail, error)
 
 	var results []*models.DeviceAMCOwnerDetail
 	for rows.Next() {
-		var data models.DeviceAMCOwnerDetail
-		err := rows.Scan(&data.Id, &data.SerialNumber, &data.DeviceMakeModel, &data.Model, &data.PONumber, &data.POOrderDate, &data.EOSLDate, &data.AMCStartDate, &data.AMCEndDate, &data.DeviceOwner)
+		data, err := scanDeviceAMCOwnerDetail(rows)
 		if err != nil {
 			logger.ErrorLogger.Printf("Error scanning data from table 1: %v", err)
 			return nil, err
@@ -97,3 +96,29 @@ func (db *DB) FetchDataFromDeviceOwner() ([]*models.DeviceAMCOwnerDetail, error)
 	logger.InfoLogger.Println("Fetched data from device_amc_owner table successfully")
 	return results, nil
 }
+
+// GetDeviceAMCOwnerDetailsBySerials retrieves all device_amc_owner records for the given serial numbers in a single query.
+func (db *DB) GetDeviceAMCOwnerDetailsBySerials(serials []string) ([]models.DeviceAMCOwnerDetail, error) {
+	query := "SELECT " + deviceAMCOwnerColumns + " FROM device_amc_owner WHERE serial_number = ANY($1)"
+	rows, err := db.queryRead(query, pq.Array(serials))
+	if err != nil {
+		logger.ErrorLogger.Printf("Error querying DeviceAMCOwnerDetail by serials: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.DeviceAMCOwnerDetail
+	for rows.Next() {
+		data, err := scanDeviceAMCOwnerDetail(rows)
+		if err != nil {
+			logger.ErrorLogger.Printf("Error scanning DeviceAMCOwnerDetail: %v", err)
+			return nil, err
+		}
+		results = append(results, data)
+	}
+	if err := rows.Err(); err != nil {
+		logger.ErrorLogger.Printf("Error iterating over DeviceAMCOwnerDetail rows: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// Errors returned by the passkey controllers.
+var (
+	ErrPasskeyNotFound = errors.New("passkey not found")
+	ErrPasskeyExists   = errors.New("passkey is already registered")
+	// ErrPasskeyCloned is returned when an authenticator's signature counter
+	// did not increase, which suggests the credential was copied.
+	ErrPasskeyCloned = errors.New("passkey signature counter did not increase")
+)
+
+const passkeyColumns = "id, user_id, credential_id, public_key, sign_count, name, created_at, last_used_at"
+
+func scanPasskey(row rowScanner) (models.Passkey, error) {
+	var passkey models.Passkey
+	var lastUsedAt sql.NullTime
+	err := row.Scan(&passkey.ID, &passkey.UserID, &passkey.CredentialID, &passkey.PublicKey,
+		&passkey.SignCount, &passkey.Name, &passkey.CreatedAt, &lastUsedAt)
+	if lastUsedAt.Valid {
+		passkey.LastUsedAt = &lastUsedAt.Time
+	}
+	return passkey, err
+}
+
+// AddPasskey stores a passkey registered by a user.
+func (db *DB) AddPasskey(userID int, name string, credential utils.PasskeyCredential) (models.Passkey, error) {
+	query := `
+        INSERT INTO webauthn_credentials (user_id, credential_id, public_key, sign_count, name)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING ` + passkeyColumns
+	passkey, err := scanPasskey(db.QueryRow(query, userID, credential.ID, credential.PublicKey, credential.SignCount, name))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return passkey, ErrPasskeyExists
+		}
+		logger.ErrorLogger.Printf("Error storing passkey: %v", err)
+		return passkey, unavailable(err)
+	}
+	return passkey, nil
+}
+
+// GetPasskeys returns the passkeys of a user, oldest first.
+func (db *DB) GetPasskeys(userID int) ([]models.Passkey, error) {
+	rows, err := db.Query("SELECT "+passkeyColumns+" FROM webauthn_credentials WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error listing passkeys: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	passkeys := []models.Passkey{}
+	for rows.Next() {
+		passkey, err := scanPasskey(rows)
+		if err != nil {
+			return nil, unavailable(err)
+		}
+		passkeys = append(passkeys, passkey)
+	}
+	return passkeys, unavailable(rows.Err())
+}
+
+// GetPasskeyByCredentialID returns the passkey with the given WebAuthn
+// credential id.
+func (db *DB) GetPasskeyByCredentialID(credentialID []byte) (models.Passkey, error) {
+	passkey, err := scanPasskey(db.QueryRow("SELECT "+passkeyColumns+" FROM webauthn_credentials WHERE credential_id = $1", credentialID))
+	if err == sql.ErrNoRows {
+		return passkey, ErrPasskeyNotFound
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error looking up passkey: %v", err)
+		return passkey, unavailable(err)
+	}
+	return passkey, nil
+}
+
+// RecordPasskeyUse stores the signature counter reported at a login with a
+// passkey. Authenticators without a counter always report 0; otherwise the
+// counter must increase, or ErrPasskeyCloned is returned. Checking and
+// storing happen in one statement, so a replayed login loses the race.
+func (db *DB) RecordPasskeyUse(id int, signCount uint32) error {
+	query := `
+        UPDATE webauthn_credentials
+        SET sign_count = $2, last_used_at = NOW()
+        WHERE id = $1 AND (sign_count < $2 OR (sign_count = 0 AND $2 = 0))
+    `
+	result, err := db.Exec(query, id, signCount)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error recording passkey use: %v", err)
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrPasskeyCloned
+	}
+	return nil
+}
+
+// DeletePasskey removes one of a user's passkeys.
+func (db *DB) DeletePasskey(userID, id int) error {
+	result, err := db.Exec("DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error deleting passkey: %v", err)
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrPasskeyNotFound
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"github.com/lib/pq"
 	"github.com/vikash-parashar/asset-locator/logger" // Import the logger package
 	"github.com/vikash-parashar/asset-locator/models"
 )
@@ -22,8 +23,8 @@ func (db *DB) CreateDevicePowerDetail(data *models.DevicePowerDetail) error {
 
 // GetAllDevicePowerDetail retrieves all records from the DevicePowerDetail table.
 func (db *DB) GetAllDevicePowerDetail() ([]models.DevicePowerDetail, error) {
-	query := "SELECT * FROM device_power"
-	rows, err := db.Query(query)
+	query := "SELECT " + devicePowerColumns + " FROM device_power"
+	rows, err := db.queryRead(query)
 	if err != nil {
 		logger.ErrorLogger.Printf("Error querying DevicePowerDetail: %v", err)
 		return nil, err
@@ -32,8 +33,7 @@ func (db *DB) GetAllDevicePowerDetail() ([]models.DevicePowerDetail, error) {
 
 	var results []models.DevicePowerDetail
 	for rows.Next() {
-		var data models.DevicePowerDetail
-		err := rows.Scan(&data.Id, &data.SerialNumber, &data.DeviceMakeModel, &data.Model, &data.DeviceType, &data.TotalPowerWatt, &data.TotalBTU, &data.TotalPowerCable, &data.PowerSocketType)
+		data, err := scanDevicePowerDetail(rows)
 		if err != nil {
 			logger.ErrorLogger.Printf("Error scanning DevicePowerDetail: %v", err)
 			return nil, err
@@ -75,8 +75,8 @@ func (db *DB) DeleteDevicePowerDetail(id int) error {
 
 // FetchDataFromDevicePower retrieves data from table 4.
 func (db *DB) FetchDataFromDevicePower() ([]*models.DevicePowerDetail, error) {
-	query := "SELECT * FROM device_power"
-	rows, err := db.Query(query)
+	query := "SELECT " + devicePowerColumns + " FROM device_power"
+	rows, err := db.queryRead(query)
 	if err != nil {
 		logger.ErrorLogger.Printf("Error fetching data from table 4: %v", err)
 		return nil, err
@@ -85,8 +85,7 @@ func (db *DB) FetchDataFromDevicePower() ([]*models.DevicePowerDetail, error) {
 
 	var results []*models.DevicePowerDetail
 	for rows.Next() {
-		var data models.DevicePowerDetail
-		err := rows.Scan(&data.Id, &data.SerialNumber, &data.DeviceMakeModel, &data.Model, &data.DeviceType, &data.TotalPowerWatt, &data.TotalBTU, &data.TotalPowerCable, &data.PowerSocketType)
+		data, err := scanDevicePowerDetail(rows)
 		if err != nil {
 			logger.ErrorLogger.Printf("Error scanning data from table 4: %v", err)
 			return nil, err
@@ -97,3 +96,29 @@ func (db *DB) FetchDataFromDevicePower() ([]*models.DevicePowerDetail, error) {
 	logger.InfoLogger.Println("Fetched data from device_power table successfully")
 	return results, nil
 }
+
+// GetDevicePowerDetailsBySerials retrieves all device_power records for the given serial numbers in a single query.
+func (db *DB) GetDevicePowerDetailsBySerials(serials []string) ([]models.DevicePowerDetail, error) {
+	query := "SELECT " + devicePowerColumns + " FROM device_power WHERE serial_number = ANY($1)"
+	rows, err := db.queryRead(query, pq.Array(serials))
+	if err != nil {
+		logger.ErrorLogger.Printf("Error querying DevicePowerDetail by serials: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.DevicePowerDetail
+	for rows.Next() {
+		data, err := scanDevicePowerDetail(rows)
+		if err != nil {
+			logger.ErrorLogger.Printf("Error scanning DevicePowerDetail: %v", err)
+			return nil, err
+		}
+		results = append(results, data)
+	}
+	if err := rows.Err(); err != nil {
+		logger.ErrorLogger.Printf("Error iterating over DevicePowerDetail rows: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
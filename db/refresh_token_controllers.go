@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+// Errors returned by RotateRefreshToken.
+var (
+	// ErrRefreshTokenInvalid is returned for a refresh token that is
+	// unknown, expired or revoked.
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or has expired")
+	// ErrRefreshTokenReused is returned for a refresh token that was
+	// already exchanged. Its whole family has been revoked, since either
+	// the client or whoever copied the token is not its rightful holder.
+	ErrRefreshTokenReused = errors.New("refresh token was already used")
+)
+
+// CreateRefreshToken stores the hash of a refresh token issued at login to
+// the given session, starting a new token family, and forgets the user's
+// expired tokens.
+func (db *DB) CreateRefreshToken(userID int, tokenHash string, expiresAt time.Time, sessionID int) error {
+	query := `
+        INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at, session_id)
+        VALUES ($1, $2, nextval('refresh_token_families'), $3, NULLIF($4, 0))
+    `
+	if _, err := db.Exec(query, userID, tokenHash, expiresAt, sessionID); err != nil {
+		logger.ErrorLogger.Printf("Error storing refresh token: %v", err)
+		return err
+	}
+
+	if _, err := db.Exec("DELETE FROM refresh_tokens WHERE user_id = $1 AND expires_at < NOW()", userID); err != nil {
+		logger.WarningLogger.Printf("Error pruning expired refresh tokens: %v", err)
+	}
+	return nil
+}
+
+// RotateRefreshToken exchanges a refresh token for its successor in the same
+// family and returns the token's user and session, 0 for a token issued
+// before sessions were recorded. The successor is valid for ttl, but
+// never beyond maxLifetime after the family was started at login.
+//
+// Every token may be exchanged once. Presenting one that already was revokes
+// its family and session and returns ErrRefreshTokenReused; an unknown, expired or
+// revoked token returns ErrRefreshTokenInvalid.
+func (db *DB) RotateRefreshToken(tokenHash, newTokenHash string, ttl, maxLifetime time.Duration) (userID, sessionID int, err error) {
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error starting refresh token rotation: %v", err)
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var (
+		familyID        int
+		session         sql.NullInt64
+		familyStartedAt time.Time
+		live, used      bool
+		revoked         bool
+	)
+	query := `
+        SELECT user_id, session_id, family_id, family_started_at, expires_at > NOW(),
+               used_at IS NOT NULL, revoked_at IS NOT NULL
+        FROM refresh_tokens
+        WHERE token_hash = $1
+        FOR UPDATE
+    `
+	err = tx.QueryRowContext(ctx, query, tokenHash).Scan(&userID, &session, &familyID, &familyStartedAt, &live, &used, &revoked)
+	if err == sql.ErrNoRows {
+		return 0, 0, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error looking up refresh token: %v", err)
+		return 0, 0, unavailable(err)
+	}
+
+	switch {
+	case revoked:
+		return 0, 0, ErrRefreshTokenInvalid
+	case used:
+		if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL", familyID); err != nil {
+			logger.ErrorLogger.Printf("Error revoking refresh token family: %v", err)
+			return 0, 0, unavailable(err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL", session); err != nil {
+			logger.ErrorLogger.Printf("Error revoking the session of a reused refresh token: %v", err)
+			return 0, 0, unavailable(err)
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, 0, unavailable(err)
+		}
+		logger.WarningLogger.Printf("Refresh token reused, revoked token family %d of user %d", familyID, userID)
+		return 0, 0, ErrRefreshTokenReused
+	case !live:
+		return 0, 0, ErrRefreshTokenInvalid
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	if familyEnd := familyStartedAt.Add(maxLifetime); familyEnd.Before(expiresAt) {
+		expiresAt = familyEnd
+	}
+	if !expiresAt.After(time.Now()) {
+		return 0, 0, ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = NOW() WHERE token_hash = $1", tokenHash); err != nil {
+		logger.ErrorLogger.Printf("Error marking refresh token used: %v", err)
+		return 0, 0, unavailable(err)
+	}
+	insert := `
+        INSERT INTO refresh_tokens (user_id, token_hash, family_id, family_started_at, expires_at, session_id)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `
+	if _, err := tx.ExecContext(ctx, insert, userID, newTokenHash, familyID, familyStartedAt, expiresAt, session); err != nil {
+		logger.ErrorLogger.Printf("Error storing rotated refresh token: %v", err)
+		return 0, 0, unavailable(err)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.ErrorLogger.Printf("Error committing refresh token rotation: %v", err)
+		return 0, 0, unavailable(err)
+	}
+	return userID, int(session.Int64), nil
+}
+
+// RevokeRefreshTokenFamily revokes the family of the given refresh token, as
+// on logout. An unknown token is not an error.
+func (db *DB) RevokeRefreshTokenFamily(tokenHash string) error {
+	query := `
+        UPDATE refresh_tokens
+        SET revoked_at = NOW()
+        WHERE revoked_at IS NULL
+          AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)
+    `
+	if _, err := db.Exec(query, tokenHash); err != nil {
+		logger.ErrorLogger.Printf("Error revoking refresh token family: %v", err)
+		return err
+	}
+	return nil
+}
+
+// RevokeRefreshTokens revokes every refresh token of a user.
+func (db *DB) RevokeRefreshTokens(userID int) error {
+	if _, err := db.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		logger.ErrorLogger.Printf("Error revoking refresh tokens: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+// retryMetrics are published at /debug/vars under "db".
+var retryMetrics = expvar.NewMap("db")
+
+// SetReadRetry makes read queries retry transient errors up to attempts
+// times in total, waiting backoff before the first retry and doubling it
+// for each further one. Writes are never retried.
+func (db *DB) SetReadRetry(attempts int, backoff time.Duration) {
+	db.readAttempts = attempts
+	db.readBackoff = backoff
+}
+
+// isTransient reports whether err is worth retrying: a lost connection, a
+// serialization failure or deadlock, or a server that is shutting down or
+// not yet accepting connections.
+func isTransient(err error) bool {
+	if errors.Is(err, ErrDatabaseUnavailable) {
+		return true
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	code := string(pqErr.Code)
+	return strings.HasPrefix(code, "08") || // connection exception
+		code == "40001" || code == "40P01" || // serialization failure, deadlock
+		code == "57P01" || code == "57P02" || code == "57P03" // shutdown, cannot connect now
+}
+
+// retryRead runs read until it succeeds, fails with an error that is not
+// transient, or runs out of attempts. read must be idempotent.
+func (db *DB) retryRead(read func() error) error {
+	if db == nil {
+		return read()
+	}
+	backoff := db.readBackoff
+	for attempt := 1; ; attempt++ {
+		err := read()
+		if err == nil || !isTransient(err) || db.DB == nil {
+			return err
+		}
+		if attempt >= db.readAttempts {
+			if db.readAttempts > 1 {
+				retryMetrics.Add("read_retries_exhausted", 1)
+			}
+			return err
+		}
+
+		retryMetrics.Add("read_retries", 1)
+		logger.WarningLogger.Printf("Transient error on read (attempt %d of %d), retrying in %s: %v", attempt, db.readAttempts, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// queryRead is Query for idempotent reads, retrying transient errors.
+func (db *DB) queryRead(query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := db.retryRead(func() error {
+		var err error
+		rows, err = db.QueryContext(context.Background(), query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// queryRowRead is QueryRow for idempotent reads; Scan retries transient
+// errors by running the query again.
+func (db *DB) queryRowRead(query string, args ...interface{}) rowScanner {
+	return retryRow{db: db, query: query, args: args}
+}
+
+// retryRow is a row whose query is only run, and retried, by Scan.
+type retryRow struct {
+	db    *DB
+	query string
+	args  []interface{}
+}
+
+func (r retryRow) Scan(dest ...interface{}) error {
+	return r.db.retryRead(func() error {
+		return r.db.QueryRow(r.query, r.args...).Scan(dest...)
+	})
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestRetryRead(t *testing.T) {
+	serialization := &pq.Error{Code: "40001"}
+	syntaxError := &pq.Error{Code: "42601"}
+	tests := []struct {
+		name      string
+		failures  []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"fails once", []error{serialization}, 2, nil},
+		{"not transient", []error{syntaxError}, 1, syntaxError},
+		{"gives up", []error{serialization, serialization, serialization, serialization}, 3, serialization},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &DB{}
+			db.SetReadRetry(3, time.Millisecond)
+			// An unopened DB is never retried, so give it a connection.
+			conn, _, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			db.DB = conn
+
+			calls := 0
+			err = db.retryRead(func() error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+			if calls != tt.wantCalls || !errors.Is(err, tt.wantErr) {
+				t.Errorf("%d calls returning %v, want %d calls returning %v", calls, err, tt.wantCalls, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQueryReadRetriesTransientErrors(t *testing.T) {
+	db, mock := newMockDB(t)
+	db.SetReadRetry(2, time.Millisecond)
+	mock.ExpectQuery("FROM device_location").WillReturnError(&pq.Error{Code: "40P01"})
+	mock.ExpectQuery("FROM device_location").WillReturnRows(sqlmock.NewRows([]string{"device_type", "count"}).AddRow("Server", 3))
+
+	facets, err := db.GetDeviceTypeFacets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(facets) != 1 || facets[0].Count != 3 {
+		t.Errorf("facets = %+v", facets)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{ErrDatabaseUnavailable, true},
+		{&pq.Error{Code: "08006"}, true},
+		{&pq.Error{Code: "57P03"}, true},
+		{&pq.Error{Code: "23505"}, false},
+		{errors.New("sql: no rows in result set"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
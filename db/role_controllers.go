@@ -0,0 +1,178 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// Errors returned by the role controllers.
+var (
+	ErrRoleNotFound = errors.New("role not found")
+	ErrRoleExists   = errors.New("role already exists")
+	// ErrRoleInUse is returned when deleting a role still assigned to users.
+	ErrRoleInUse = errors.New("role is assigned to users")
+	// ErrPermissionUnknown is returned when granting a permission that does
+	// not exist.
+	ErrPermissionUnknown = errors.New("unknown permission")
+)
+
+const roleQuery = `
+    SELECT r.name, r.description, r.created_at,
+           COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+    FROM roles r
+    LEFT JOIN role_permissions rp ON rp.role = r.name
+`
+
+func scanRole(row rowScanner) (models.Role, error) {
+	var role models.Role
+	err := row.Scan(&role.Name, &role.Description, &role.CreatedAt, pq.Array(&role.Permissions))
+	return role, err
+}
+
+// GetRoles returns all roles with their permissions, ordered by name.
+func (db *DB) GetRoles() ([]models.Role, error) {
+	rows, err := db.Query(roleQuery + " GROUP BY r.name ORDER BY r.name")
+	if err != nil {
+		logger.ErrorLogger.Printf("Error listing roles: %v", err)
+		return nil, unavailable(err)
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, unavailable(err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, unavailable(rows.Err())
+}
+
+// GetRole returns a role with its permissions.
+func (db *DB) GetRole(name string) (models.Role, error) {
+	role, err := scanRole(db.QueryRow(roleQuery+" WHERE r.name = $1 GROUP BY r.name", name))
+	if err == sql.ErrNoRows {
+		return role, ErrRoleNotFound
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error looking up role: %v", err)
+		return role, unavailable(err)
+	}
+	return role, nil
+}
+
+// GetPermissions returns the names of all permissions that can be granted.
+func (db *DB) GetPermissions() ([]string, error) {
+	rows, err := db.Query("SELECT name FROM permissions ORDER BY resource, action")
+	if err != nil {
+		logger.ErrorLogger.Printf("Error listing permissions: %v", err)
+		return nil, unavailable(err)
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, unavailable(err)
+		}
+		permissions = append(permissions, name)
+	}
+	return permissions, unavailable(rows.Err())
+}
+
+// CreateRole adds a role without permissions.
+func (db *DB) CreateRole(name, description string) (models.Role, error) {
+	var role models.Role
+	err := db.QueryRow("INSERT INTO roles (name, description) VALUES ($1, $2) RETURNING name, description, created_at", name, description).
+		Scan(&role.Name, &role.Description, &role.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return role, ErrRoleExists
+		}
+		logger.ErrorLogger.Printf("Error creating role: %v", err)
+		return role, unavailable(err)
+	}
+	role.Permissions = []string{}
+	return role, nil
+}
+
+// SetRolePermissions replaces the permissions granted by a role.
+func (db *DB) SetRolePermissions(name string, permissions []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		logger.ErrorLogger.Printf("Error starting permission update: %v", err)
+		return unavailable(err)
+	}
+	defer tx.Rollback()
+
+	// Lock the role, so that it is not deleted meanwhile
+	err = tx.QueryRow("SELECT name FROM roles WHERE name = $1 FOR UPDATE", name).Scan(&name)
+	if err == sql.ErrNoRows {
+		return ErrRoleNotFound
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error looking up role: %v", err)
+		return unavailable(err)
+	}
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role = $1", name); err != nil {
+		logger.ErrorLogger.Printf("Error clearing role permissions: %v", err)
+		return unavailable(err)
+	}
+	if _, err := tx.Exec("INSERT INTO role_permissions (role, permission) SELECT $1, unnest($2::text[])", name, pq.Array(permissions)); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrPermissionUnknown
+		}
+		logger.ErrorLogger.Printf("Error granting role permissions: %v", err)
+		return unavailable(err)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.ErrorLogger.Printf("Error committing permission update: %v", err)
+		return unavailable(err)
+	}
+	return nil
+}
+
+// DeleteRole removes a role that no user has.
+func (db *DB) DeleteRole(name string) error {
+	result, err := db.Exec("DELETE FROM roles WHERE name = $1", name)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrRoleInUse
+		}
+		logger.ErrorLogger.Printf("Error deleting role: %v", err)
+		return unavailable(err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+// GetRoleGrants returns the permissions of every role, by role name.
+func (db *DB) GetRoleGrants() (map[string][]string, error) {
+	rows, err := db.Query("SELECT role, permission FROM role_permissions")
+	if err != nil {
+		logger.ErrorLogger.Printf("Error loading role permissions: %v", err)
+		return nil, unavailable(err)
+	}
+	defer rows.Close()
+
+	grants := make(map[string][]string)
+	for rows.Next() {
+		var role, permission string
+		if err := rows.Scan(&role, &permission); err != nil {
+			return nil, unavailable(err)
+		}
+		grants[role] = append(grants[role], permission)
+	}
+	return grants, unavailable(rows.Err())
+}
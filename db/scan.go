@@ -0,0 +1,84 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// The column lists below are selected together with the matching scan
+// function. Keep each list and its scan function in the same order; adding a
+// field to a model means touching only these two places.
+
+// userColumns lists the users columns read by scanUser.
+const userColumns = `id, first_name, last_name, phone, email, password, role,
+        reset_token, reset_token_expiry, created_at, updated_at,
+        COALESCE(password_changed_at, created_at, NOW()), token_version, locked_until`
+
+// scanUser scans a row selected with userColumns. Nullable columns are read
+// as their zero value.
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	var phone, role, resetToken sql.NullString
+	var resetTokenExpiry, createdAt, updatedAt, lockedUntil sql.NullTime
+	err := row.Scan(&user.ID, &user.FirstName, &user.LastName, &phone, &user.Email, &user.Password, &role,
+		&resetToken, &resetTokenExpiry, &createdAt, &updatedAt, &user.PasswordChangedAt, &user.TokenVersion, &lockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	user.Phone = phone.String
+	user.Role = role.String
+	user.ResetToken = resetToken.String
+	user.ResetTokenExpiry = resetTokenExpiry.Time
+	user.CreatedAt = createdAt.Time
+	user.UpdatedAt = updatedAt.Time
+	if lockedUntil.Valid {
+		user.LockedUntil = &lockedUntil.Time
+	}
+	return user, nil
+}
+
+// deviceLocationColumns lists the device_location columns read by
+// scanDeviceLocationDetail.
+const deviceLocationColumns = "id, serial_number, device_make_model, model, device_type, data_center, region, dc_location, device_location, device_row_number, device_rack_number, device_ru_number, last_audited_at, approval_status, reviewed_by, reviewed_at, custom_fields"
+
+func scanDeviceLocationDetail(row rowScanner) (models.DeviceLocationDetail, error) {
+	var data models.DeviceLocationDetail
+	err := row.Scan(&data.Id, &data.SerialNumber, &data.DeviceMakeModel, &data.Model, &data.DeviceType, &data.DataCenter, &data.Region, &data.DCLocation, &data.DeviceLocation, &data.DeviceRowNumber, &data.DeviceRackNumber, &data.DeviceRUNumber, &data.LastAuditedAt, &data.ApprovalStatus, &data.ReviewedBy, &data.ReviewedAt, &data.CustomFields)
+	return data, err
+}
+
+// deviceAMCOwnerColumns lists the device_amc_owner columns read by
+// scanDeviceAMCOwnerDetail.
+const deviceAMCOwnerColumns = "id, serial_number, device_make_model, model, po_number, po_order_date, eosl_date, amc_start_date, amc_end_date, device_owner"
+
+func scanDeviceAMCOwnerDetail(row rowScanner) (models.DeviceAMCOwnerDetail, error) {
+	var data models.DeviceAMCOwnerDetail
+	err := row.Scan(&data.Id, &data.SerialNumber, &data.DeviceMakeModel, &data.Model, &data.PONumber, &data.POOrderDate, &data.EOSLDate, &data.AMCStartDate, &data.AMCEndDate, &data.DeviceOwner)
+	return data, err
+}
+
+// devicePowerColumns lists the device_power columns read by
+// scanDevicePowerDetail.
+const devicePowerColumns = "id, serial_number, device_make_model, model, device_type, total_power_watt, total_btu, total_power_cable, power_socket_type"
+
+func scanDevicePowerDetail(row rowScanner) (models.DevicePowerDetail, error) {
+	var data models.DevicePowerDetail
+	err := row.Scan(&data.Id, &data.SerialNumber, &data.DeviceMakeModel, &data.Model, &data.DeviceType, &data.TotalPowerWatt, &data.TotalBTU, &data.TotalPowerCable, &data.PowerSocketType)
+	return data, err
+}
+
+// deviceEthernetFiberColumns lists the device_ethernet_fiber columns read by
+// scanDeviceEthernetFiberDetail.
+const deviceEthernetFiberColumns = "id, serial_number, device_make_model, model, device_type, device_physical_port, device_port_type, device_port_mac_address_wwn, connected_device_port"
+
+func scanDeviceEthernetFiberDetail(row rowScanner) (models.DeviceEthernetFiberDetail, error) {
+	var data models.DeviceEthernetFiberDetail
+	err := row.Scan(&data.Id, &data.SerialNumber, &data.DeviceMakeModel, &data.Model, &data.DeviceType, &data.DevicePhysicalPort, &data.DevicePortType, &data.DevicePortMACWWN, &data.ConnectedDevicePort)
+	return data, err
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// newMockDB returns a DB backed by sqlmock, failing the test if an expected
+// query was not run.
+func newMockDB(t *testing.T) (*DB, sqlmock.Sqlmock) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		conn.Close()
+	})
+	return &DB{DB: conn}, mock
+}
+
+func TestScanUserNullColumns(t *testing.T) {
+	db, mock := newMockDB(t)
+	changed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery("FROM users").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "phone", "email", "password", "role",
+		"reset_token", "reset_token_expiry", "created_at", "updated_at", "password_changed_at", "token_version", "locked_until"}).
+		AddRow(7, "Ann", "Lee", nil, "ann@example.com", "hash", nil, nil, nil, nil, nil, changed, 2, nil))
+
+	user, err := db.GetUserByID(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 7 || user.Email != "ann@example.com" || user.Phone != "" || user.Role != "" || user.ResetToken != "" ||
+		!user.ResetTokenExpiry.IsZero() || !user.CreatedAt.IsZero() || user.LockedUntil != nil {
+		t.Errorf("user = %+v, want NULL columns read as zero values", user)
+	}
+	if !user.PasswordChangedAt.Equal(changed) || user.TokenVersion != 2 {
+		t.Errorf("password_changed_at = %s, token_version = %d", user.PasswordChangedAt, user.TokenVersion)
+	}
+}
+
+func TestScanDeviceLocationDetail(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT " + deviceLocationColumns + " FROM device_location").WillReturnRows(sqlmock.NewRows([]string{"id", "serial_number", "device_make_model", "model", "device_type",
+		"data_center", "region", "dc_location", "device_location", "device_row_number", "device_rack_number", "device_ru_number",
+		"last_audited_at", "approval_status", "reviewed_by", "reviewed_at", "custom_fields"}).
+		AddRow(1, "SN-1", "Dell R740", "R740", "server", "DC1", "EU", "Room 1", "IDC1", 3, 4, "10-12", nil, "approved", nil, nil, `{"cost_center":"it"}`))
+
+	devices, err := db.GetAllDeviceLocationDetail()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 {
+		t.Fatalf("got %d devices, want 1", len(devices))
+	}
+	device := devices[0]
+	if device.SerialNumber != "SN-1" || device.DeviceRackNumber != 4 || device.DeviceRUNumber != "10-12" ||
+		device.LastAuditedAt != nil || device.CustomFields["cost_center"] != "it" {
+		t.Errorf("device = %+v", device)
+	}
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// ErrSessionNotFound is returned for a session that is unknown, belongs to
+// another user, or is no longer live.
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionTouchInterval is how often a live session's last-seen time is
+// updated, so that not every request writes to the database.
+const sessionTouchInterval = time.Minute
+
+const sessionColumns = "id, user_id, device, ip, user_agent, created_at, last_seen_at, expires_at"
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	var session models.Session
+	err := row.Scan(&session.ID, &session.UserID, &session.Device, &session.IP, &session.UserAgent,
+		&session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt)
+	return &session, err
+}
+
+// CreateSession records a new session of session.UserID, valid until
+// session.ExpiresAt, and sets its id and timestamps. The user's sessions
+// that expired or were revoked are forgotten.
+func (db *DB) CreateSession(session *models.Session) error {
+	query := `
+        INSERT INTO sessions (user_id, device, ip, user_agent, expires_at)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, last_seen_at
+    `
+	err := db.QueryRow(query, session.UserID, session.Device, session.IP, session.UserAgent, session.ExpiresAt).
+		Scan(&session.ID, &session.CreatedAt, &session.LastSeenAt)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error storing session: %v", err)
+		return unavailable(err)
+	}
+
+	prune := "DELETE FROM sessions WHERE user_id = $1 AND (expires_at < NOW() OR revoked_at IS NOT NULL)"
+	if _, err := db.Exec(prune, session.UserID); err != nil {
+		logger.WarningLogger.Printf("Error pruning ended sessions: %v", err)
+	}
+	return nil
+}
+
+// IsNewDevice reports whether session was started from a device and IP
+// none of the user's other live sessions has, while they have others.
+func (db *DB) IsNewDevice(session *models.Session) (bool, error) {
+	query := `
+        SELECT EXISTS (SELECT 1 FROM sessions WHERE user_id = $1 AND id <> $2)
+           AND NOT EXISTS (SELECT 1 FROM sessions WHERE user_id = $1 AND id <> $2 AND device = $3 AND ip = $4)
+    `
+	var isNew bool
+	if err := db.QueryRow(query, session.UserID, session.ID, session.Device, session.IP).Scan(&isNew); err != nil {
+		logger.ErrorLogger.Printf("Error checking for a new device: %v", err)
+		return false, unavailable(err)
+	}
+	return isNew, nil
+}
+
+// TouchSession reports whether a session is live, neither expired nor
+// revoked, and records that it was just seen from ip.
+func (db *DB) TouchSession(id int, ip string) (bool, error) {
+	query := `
+        WITH live AS (
+            SELECT id, last_seen_at
+            FROM sessions
+            WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+        ), touched AS (
+            UPDATE sessions
+            SET last_seen_at = NOW(), ip = $2
+            FROM live
+            WHERE sessions.id = live.id
+              AND (live.last_seen_at < NOW() - make_interval(secs => $3) OR sessions.ip <> $2)
+        )
+        SELECT EXISTS (SELECT 1 FROM live)
+    `
+	var live bool
+	if err := db.QueryRow(query, id, ip, sessionTouchInterval.Seconds()).Scan(&live); err != nil {
+		logger.ErrorLogger.Printf("Error checking session: %v", err)
+		return false, unavailable(err)
+	}
+	return live, nil
+}
+
+// ExtendSession keeps a live session valid until at least expiresAt, as
+// when its refresh token is exchanged. It returns ErrSessionNotFound if the
+// session is no longer live.
+func (db *DB) ExtendSession(id int, expiresAt time.Time) error {
+	query := `
+        UPDATE sessions
+        SET expires_at = GREATEST(expires_at, $2), last_seen_at = NOW()
+        WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+    `
+	result, err := db.Exec(query, id, expiresAt)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error extending session: %v", err)
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// GetSessions returns the live sessions of a user, latest seen first.
+func (db *DB) GetSessions(userID int) ([]*models.Session, error) {
+	query := `
+        SELECT ` + sessionColumns + `
+        FROM sessions
+        WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+        ORDER BY last_seen_at DESC, id DESC
+    `
+	rows, err := db.queryRead(query, userID)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error listing sessions: %v", err)
+		return nil, unavailable(err)
+	}
+	defer rows.Close()
+
+	sessions := make([]*models.Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			logger.ErrorLogger.Printf("Error scanning session: %v", err)
+			return nil, unavailable(err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, unavailable(rows.Err())
+}
+
+// RevokeSession revokes one live session of a user, along with its refresh
+// tokens. It returns ErrSessionNotFound if the user has no such session.
+func (db *DB) RevokeSession(userID, id int) error {
+	query := `
+        UPDATE sessions
+        SET revoked_at = NOW()
+        WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+    `
+	result, err := db.Exec(query, id, userID)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error revoking session: %v", err)
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrSessionNotFound
+	}
+
+	if _, err := db.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE session_id = $1 AND revoked_at IS NULL", id); err != nil {
+		logger.ErrorLogger.Printf("Error revoking session refresh tokens: %v", err)
+		return err
+	}
+	return nil
+}
+
+// RevokeSessions revokes every live session of a user except the one with
+// id exceptID, which may be 0 to revoke them all, along with their refresh
+// tokens. It returns how many sessions were revoked.
+func (db *DB) RevokeSessions(userID, exceptID int) (int, error) {
+	query := `
+        UPDATE sessions
+        SET revoked_at = NOW()
+        WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL AND expires_at > NOW()
+    `
+	result, err := db.Exec(query, userID, exceptID)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error revoking sessions: %v", err)
+		return 0, err
+	}
+	revoked, _ := result.RowsAffected()
+
+	tokens := `
+        UPDATE refresh_tokens
+        SET revoked_at = NOW()
+        WHERE user_id = $1 AND session_id IS DISTINCT FROM $2 AND revoked_at IS NULL
+    `
+	if _, err := db.Exec(tokens, userID, exceptID); err != nil {
+		logger.ErrorLogger.Printf("Error revoking session refresh tokens: %v", err)
+		return 0, err
+	}
+	return int(revoked), nil
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// GetStocktakeDevices lists the devices to verify during a stocktake, oldest
+// audit first. A non-empty location restricts the list to one device
+// location (e.g. a room), and a non-nil auditedBefore keeps only devices that
+// were never audited or last audited before that time.
+func (db *DB) GetStocktakeDevices(location string, auditedBefore *time.Time) ([]models.DeviceLocationDetail, error) {
+	var conditions []string
+	var args []interface{}
+	if location != "" {
+		args = append(args, location)
+		conditions = append(conditions, fmt.Sprintf("device_location = $%d", len(args)))
+	}
+	if auditedBefore != nil {
+		args = append(args, *auditedBefore)
+		conditions = append(conditions, fmt.Sprintf("(last_audited_at IS NULL OR last_audited_at < $%d)", len(args)))
+	}
+
+	query := "SELECT " + deviceLocationColumns + " FROM device_location"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY last_audited_at ASC NULLS FIRST, id"
+
+	rows, err := db.queryRead(query, args...)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error querying stocktake devices: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]models.DeviceLocationDetail, 0)
+	for rows.Next() {
+		data, err := scanDeviceLocationDetail(rows)
+		if err != nil {
+			logger.ErrorLogger.Printf("Error scanning stocktake device: %v", err)
+			return nil, err
+		}
+		results = append(results, data)
+	}
+	if err := rows.Err(); err != nil {
+		logger.ErrorLogger.Printf("Error iterating over stocktake devices: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// MarkDeviceAudited records that the device with the given serial number was
+// physically verified now. It returns false if no such device exists.
+func (db *DB) MarkDeviceAudited(serial string) (bool, error) {
+	result, err := db.Exec("UPDATE device_location SET last_audited_at = NOW() WHERE serial_number = $1", serial)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error marking device %s as audited: %v", serial, err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorLogger.Printf("Error marking device %s as audited: %v", serial, err)
+		return false, err
+	}
+	if affected > 0 {
+		logger.InfoLogger.Printf("Marked device %s as audited", serial)
+	}
+	return affected > 0, nil
+}
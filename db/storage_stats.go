@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+// storageTables are the tables whose growth is tracked.
+var storageTables = []string{"users", "device_location", "device_amc_owner", "device_power", "device_ethernet_fiber"}
+
+// TableStats is the estimated row count and on-disk size, including indexes
+// and TOAST data, of a table.
+type TableStats struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+	Bytes int64  `json:"bytes"`
+}
+
+// StorageStats is a snapshot of the size of the tracked tables.
+type StorageStats struct {
+	CollectedAt time.Time    `json:"collected_at"`
+	Tables      []TableStats `json:"tables"`
+}
+
+// GetStorageStats returns the size of the tracked tables. Row counts are the
+// planner's estimates from the catalog, so the query stays cheap on large
+// tables; a table that was never analyzed reports zero rows.
+func (db *DB) GetStorageStats(ctx context.Context) (StorageStats, error) {
+	query := `
+		SELECT c.relname, GREATEST(c.reltuples, 0)::BIGINT, pg_total_relation_size(c.oid)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND c.relkind = 'r' AND c.relname = ANY($1)
+		ORDER BY c.relname
+	`
+	stats := StorageStats{CollectedAt: time.Now()}
+	rows, err := db.QueryContext(ctx, query, pq.Array(storageTables))
+	if err != nil {
+		logger.ErrorLogger.Printf("Error querying storage stats: %v", err)
+		return stats, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table TableStats
+		if err := rows.Scan(&table.Table, &table.Rows, &table.Bytes); err != nil {
+			return stats, unavailable(err)
+		}
+		stats.Tables = append(stats.Tables, table)
+	}
+	return stats, unavailable(rows.Err())
+}
+
+// StorageStatsCollector periodically collects StorageStats in the
+// background, so reading them never touches the database.
+type StorageStatsCollector struct {
+	collect  func(context.Context) (StorageStats, error)
+	interval time.Duration
+
+	mu     sync.RWMutex
+	latest StorageStats
+	err    error
+}
+
+// NewStorageStatsCollector returns a collector querying db every interval.
+func NewStorageStatsCollector(db *DB, interval time.Duration) *StorageStatsCollector {
+	return &StorageStatsCollector{collect: db.GetStorageStats, interval: interval}
+}
+
+// Start collects the stats now and then every interval, until the process
+// exits.
+func (s *StorageStatsCollector) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.refresh()
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *StorageStatsCollector) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.interval)
+	defer cancel()
+
+	stats, err := s.collect(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		logger.WarningLogger.Printf("Failed to collect storage stats: %v", err)
+		s.err = err
+		return
+	}
+	s.latest, s.err = stats, nil
+}
+
+// Latest returns the most recent stats, along with the error of the last
+// collection if it failed. Stats are zero until the first collection
+// succeeds.
+func (s *StorageStatsCollector) Latest() (StorageStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.latest, s.err
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetStorageStats(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery("FROM pg_class").WithArgs(`{"users","device_location","device_amc_owner","device_power","device_ethernet_fiber"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"relname", "reltuples", "size"}).AddRow("device_location", 1200, 524288).AddRow("users", 40, 65536))
+
+	stats, err := db.GetStorageStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TableStats{{"device_location", 1200, 524288}, {"users", 40, 65536}}
+	if !reflect.DeepEqual(stats.Tables, want) || stats.CollectedAt.IsZero() {
+		t.Errorf("stats = %+v, want tables %+v", stats, want)
+	}
+}
+
+func TestStorageStatsCollector(t *testing.T) {
+	stub := StorageStats{CollectedAt: time.Now(), Tables: []TableStats{{"users", 40, 65536}}}
+	var failure error
+	collector := &StorageStatsCollector{
+		interval: time.Minute,
+		collect: func(context.Context) (StorageStats, error) {
+			return stub, failure
+		},
+	}
+
+	if stats, _ := collector.Latest(); !stats.CollectedAt.IsZero() {
+		t.Errorf("stats = %+v before the first collection", stats)
+	}
+
+	collector.refresh()
+	if stats, err := collector.Latest(); err != nil || !reflect.DeepEqual(stats, stub) {
+		t.Errorf("Latest() = %+v, %v, want the collected stats", stats, err)
+	}
+
+	// A failed collection keeps the last good stats and reports the error.
+	failure = errors.New("statement timeout")
+	collector.refresh()
+	if stats, err := collector.Latest(); err != failure || !reflect.DeepEqual(stats, stub) {
+		t.Errorf("Latest() = %+v, %v, want the previous stats and %v", stats, err, failure)
+	}
+}
@@ -1,24 +1,111 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/vikash-parashar/asset-locator/logger"
 	"github.com/vikash-parashar/asset-locator/models"
 	"github.com/vikash-parashar/asset-locator/utils"
 )
 
+// ErrPhoneTaken is returned when creating a user whose phone number belongs
+// to another user while unique phone numbers are enforced.
+var ErrPhoneTaken = errors.New("phone number already belongs to another user")
+
+// ErrUserNotFound is returned by SetUserRole for an unknown user.
+var ErrUserNotFound = errors.New("user not found")
+
+// uniquePhoneIndex is the partial unique index on users.phone created by
+// EnforceUniquePhones.
+const uniquePhoneIndex = "users_phone_key"
+
+// EnforceUniquePhones creates the unique index on non-empty phone numbers
+// when enabled, and drops it otherwise. Before the index is created, stored
+// phone numbers are normalized so that differently formatted copies of a
+// number collide; creating it fails if users then share a number.
+func (db *DB) EnforceUniquePhones(ctx context.Context, enabled bool) error {
+	if !enabled {
+		if _, err := db.Exec("DROP INDEX IF EXISTS " + uniquePhoneIndex); err != nil {
+			logger.ErrorLogger.Printf("Error dropping the unique phone index: %v", err)
+			return err
+		}
+		return nil
+	}
+
+	var exists bool
+	if err := db.QueryRow("SELECT to_regclass($1) IS NOT NULL", uniquePhoneIndex).Scan(&exists); err != nil {
+		logger.ErrorLogger.Printf("Error looking up the unique phone index: %v", err)
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error beginning transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, phone FROM users WHERE phone <> '' FOR UPDATE")
+	if err != nil {
+		logger.ErrorLogger.Printf("Error fetching phone numbers: %v", err)
+		return err
+	}
+	normalized := map[int]string{}
+	for rows.Next() {
+		var id int
+		var phone string
+		if err := rows.Scan(&id, &phone); err != nil {
+			rows.Close()
+			return err
+		}
+		if normal := utils.NormalizePhone(phone); normal != phone {
+			normalized[id] = normal
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, phone := range normalized {
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET phone = $2 WHERE id = $1", id, phone); err != nil {
+			logger.ErrorLogger.Printf("Error normalizing the phone number of user %d: %v", id, err)
+			return err
+		}
+	}
+	if len(normalized) > 0 {
+		logger.InfoLogger.Printf("Normalized the phone numbers of %d users\n", len(normalized))
+	}
+
+	if _, err := tx.ExecContext(ctx, "CREATE UNIQUE INDEX "+uniquePhoneIndex+" ON users (phone) WHERE phone <> ''"); err != nil {
+		logger.ErrorLogger.Printf("Error creating the unique phone index: %v", err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// phoneTaken maps a violation of the unique phone index to ErrPhoneTaken.
+func phoneTaken(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == uniquePhoneIndex {
+		return ErrPhoneTaken
+	}
+	return unavailable(err)
+}
+
 func (db *DB) GetUserByEmailID(email string) (*models.User, error) {
-	logger.InfoLogger.Println(email)
 	query := `
-        SELECT id, first_name, last_name,phone, email, password,role
+        SELECT ` + userColumns + `
         FROM users
         WHERE email = $1
     `
-	user := &models.User{}
-	err := db.QueryRow(query, email).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Phone, &user.Email, &user.Password, &user.Role)
+	user, err := scanUser(db.queryRowRead(query, email))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found")
@@ -26,7 +113,6 @@ func (db *DB) GetUserByEmailID(email string) (*models.User, error) {
 		logger.ErrorLogger.Printf("Error fetching user by email: %v", err)
 		return nil, err
 	}
-	logger.InfoLogger.Println("User From DB : ", user)
 	return user, nil
 }
 
@@ -39,32 +125,206 @@ func (db *DB) RegisterUser(user *models.User) error {
 	err := db.QueryRow(query, user.FirstName, user.LastName, user.Phone, user.Email, user.Password, user.Role).Scan(&user.ID)
 	if err != nil {
 		logger.ErrorLogger.Printf("Error registering user: %v", err)
+		return phoneTaken(err)
+	}
+	return nil
+}
+
+// RegisterFirstUserAsAdmin registers user, as an admin if there are no
+// users yet and with user.Role otherwise. The users table is locked against
+// other inserts until the user is created, so of several concurrent first
+// signups only one becomes an admin.
+func (db *DB) RegisterFirstUserAsAdmin(ctx context.Context, user *models.User) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error starting user registration: %v", err)
 		return err
 	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE users IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		logger.ErrorLogger.Printf("Error locking users for registration: %v", err)
+		return unavailable(err)
+	}
+	var first bool
+	if err := tx.QueryRowContext(ctx, "SELECT NOT EXISTS (SELECT 1 FROM users)").Scan(&first); err != nil {
+		logger.ErrorLogger.Printf("Error counting users: %v", err)
+		return unavailable(err)
+	}
+	role := user.Role
+	if first {
+		role = models.UserRoleAdmin
+	}
+
+	query := `
+        INSERT INTO users (first_name, last_name, phone, email, password, role)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id
+    `
+	err = tx.QueryRowContext(ctx, query, user.FirstName, user.LastName, user.Phone, user.Email, user.Password, role).Scan(&user.ID)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error registering user: %v", err)
+		return phoneTaken(err)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.ErrorLogger.Printf("Error committing user registration: %v", err)
+		return unavailable(err)
+	}
+	user.Role = role
+	if first {
+		logger.InfoLogger.Printf("Registered the first user %s as an admin", user.Email)
+	}
 	return nil
 }
 
-func (db *DB) UpdateUserPassword(userID int, newPassword string) error {
+// GetExistingEmails returns which of emails already belong to a user,
+// compared case-insensitively. The keys of the result are lower case.
+func (db *DB) GetExistingEmails(emails []string) (map[string]bool, error) {
+	rows, err := db.queryRead("SELECT LOWER(email) FROM users WHERE LOWER(email) = ANY($1)", pq.Array(emails))
+	if err != nil {
+		logger.ErrorLogger.Printf("Error checking existing emails: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, unavailable(err)
+		}
+		existing[email] = true
+	}
+	return existing, unavailable(rows.Err())
+}
+
+// ImportUsers creates users in a single transaction, so either all of them
+// are created or none is. Each user's reset token and expiry are stored so
+// that an invite can be sent to set a password.
+func (db *DB) ImportUsers(ctx context.Context, users []*models.User) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error starting user import: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
+        INSERT INTO users (first_name, last_name, phone, email, password, role, reset_token, reset_token_expiry)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id
+    `
+	for _, user := range users {
+		err := tx.QueryRowContext(ctx, query, user.FirstName, user.LastName, user.Phone, user.Email, user.Password, user.Role, user.ResetToken, user.ResetTokenExpiry).Scan(&user.ID)
+		if err != nil {
+			logger.ErrorLogger.Printf("Error importing user %s: %v", user.Email, err)
+			return phoneTaken(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorLogger.Printf("Error committing user import: %v", err)
+		return unavailable(err)
+	}
+	logger.InfoLogger.Printf("Imported %d users", len(users))
+	return nil
+}
+
+// UpdateUserPassword sets a user's password, which also lifts a login
+// lockout. The replaced password is added to the user's password history,
+// which is cut down to the historySize-1 latest entries.
+func (db *DB) UpdateUserPassword(userID int, newPassword string, historySize int) error {
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error starting password update: %v", err)
+		return unavailable(err)
+	}
+	defer tx.Rollback()
+
+	var oldPassword string
+	err = tx.QueryRow("SELECT password FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&oldPassword)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		logger.ErrorLogger.Printf("Error looking up user password: %v", err)
+		return unavailable(err)
+	}
+
+	// The current password counts towards the history, so historySize-1
+	// earlier ones are kept
+	if historySize > 1 && oldPassword != "" {
+		if _, err := tx.Exec("INSERT INTO password_history (user_id, password_hash) VALUES ($1, $2)", userID, oldPassword); err != nil {
+			logger.ErrorLogger.Printf("Error recording password history: %v", err)
+			return unavailable(err)
+		}
+	}
+	query := `
+        DELETE FROM password_history
+        WHERE user_id = $1 AND id NOT IN (
+            SELECT id FROM password_history
+            WHERE user_id = $1
+            ORDER BY id DESC
+            LIMIT GREATEST($2 - 1, 0)
+        )
+    `
+	if _, err := tx.Exec(query, userID, historySize); err != nil {
+		logger.ErrorLogger.Printf("Error pruning password history: %v", err)
+		return unavailable(err)
+	}
+
+	query = `
         UPDATE users
-        SET password = $2
+        SET password = $2, password_changed_at = NOW(), failed_logins = 0, locked_until = NULL
         WHERE id = $1
     `
-	_, err := db.Exec(query, userID, newPassword)
-	if err != nil {
+	if _, err := tx.Exec(query, userID, newPassword); err != nil {
 		logger.ErrorLogger.Printf("Error updating user password: %v", err)
-		return err
+		return unavailable(err)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.ErrorLogger.Printf("Error committing password update: %v", err)
+		return unavailable(err)
 	}
 	return nil
 }
 
+// GetPasswordHistory returns the hashes of up to limit passwords a user had
+// before their current one, latest first.
+func (db *DB) GetPasswordHistory(userID, limit int) ([]string, error) {
+	query := `
+        SELECT password_hash
+        FROM password_history
+        WHERE user_id = $1
+        ORDER BY id DESC
+        LIMIT $2
+    `
+	rows, err := db.queryRead(query, userID, limit)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error fetching password history: %v", err)
+		return nil, unavailable(err)
+	}
+	defer rows.Close()
+
+	hashes := make([]string, 0, limit)
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			logger.ErrorLogger.Printf("Error scanning password history: %v", err)
+			return nil, unavailable(err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, unavailable(rows.Err())
+}
+
 // GetAllUsers retrieves all active user records.
 func (db *DB) GetAllUsers() ([]*models.User, error) {
 	query := `
-        SELECT id, first_name, last_name, email, password, role
+        SELECT ` + userColumns + `
         FROM users
     `
-	rows, err := db.Query(query)
+	rows, err := db.queryRead(query)
 	if err != nil {
 		logger.ErrorLogger.Printf("Error fetching all users: %v", err)
 		return nil, err
@@ -73,12 +333,64 @@ func (db *DB) GetAllUsers() ([]*models.User, error) {
 
 	users := make([]*models.User, 0)
 	for rows.Next() {
-		user := &models.User{}
-		err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.Role)
+		user, err := scanUser(rows)
+		if err != nil {
+			logger.ErrorLogger.Printf("Error scanning user rows: %v", err)
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		logger.ErrorLogger.Printf("Error iterating over user rows: %v", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUserByID retrieves a user by id.
+func (db *DB) GetUserByID(id int) (*models.User, error) {
+	query := `
+        SELECT ` + userColumns + `
+        FROM users
+        WHERE id = $1
+    `
+	user, err := scanUser(db.queryRowRead(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		logger.ErrorLogger.Printf("Error fetching user by id: %v", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUsersByIDs retrieves the users with the given ids in a single query.
+// Ids that do not exist are simply absent from the result.
+func (db *DB) GetUsersByIDs(ids []int) ([]*models.User, error) {
+	query := `
+        SELECT ` + userColumns + `
+        FROM users
+        WHERE id = ANY($1)
+        ORDER BY id
+    `
+	rows, err := db.queryRead(query, pq.Array(ids))
+	if err != nil {
+		logger.ErrorLogger.Printf("Error fetching users by ids: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0, len(ids))
+	for rows.Next() {
+		user, err := scanUser(rows)
 		if err != nil {
 			logger.ErrorLogger.Printf("Error scanning user rows: %v", err)
 			return nil, err
 		}
+		// Callers hand these users straight to clients
+		user.Password = ""
+		user.ResetToken = ""
 		users = append(users, user)
 	}
 	if err := rows.Err(); err != nil {
@@ -91,12 +403,11 @@ func (db *DB) GetAllUsers() ([]*models.User, error) {
 // GetUserByResetToken retrieves a user by their reset token.
 func (db *DB) GetUserByResetToken(resetToken string) (*models.User, error) {
 	query := `
-        SELECT id, first_name, last_name, email, password, role
+        SELECT ` + userColumns + `
         FROM users
         WHERE reset_token = $1
     `
-	user := &models.User{}
-	err := db.QueryRow(query, resetToken).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.Role)
+	user, err := scanUser(db.queryRowRead(query, resetToken))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found by reset token")
@@ -122,6 +433,26 @@ func (db *DB) UpdateUser(user *models.User) error {
 	return nil
 }
 
+// UpdateProfile changes the name and phone number of a user. It returns
+// ErrPhoneTaken when unique phone numbers are enforced and the number
+// belongs to another user, and ErrUserNotFound for an unknown user.
+func (db *DB) UpdateProfile(userID int, firstName, lastName, phone string) error {
+	query := `
+        UPDATE users
+        SET first_name = $2, last_name = $3, phone = $4, updated_at = NOW()
+        WHERE id = $1
+    `
+	result, err := db.Exec(query, userID, firstName, lastName, phone)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error updating user profile: %v", err)
+		return phoneTaken(err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
 // SetResetToken sets the reset token and reset token expiry for a user in the database.
 func (db *DB) SetResetToken(userID int, resetToken string, expiryTime time.Time) error {
 	query := `
@@ -137,6 +468,78 @@ func (db *DB) SetResetToken(userID int, resetToken string, expiryTime time.Time)
 	return nil
 }
 
+// RecordResetRequest counts a password reset request for a user and reports
+// whether it is within the limit of max requests per window. The window
+// starts with the first request after the previous one has elapsed.
+func (db *DB) RecordResetRequest(userID int, max int, window time.Duration) (bool, error) {
+	query := `
+        UPDATE users
+        SET reset_request_count = CASE
+                WHEN reset_window_started_at IS NULL OR reset_window_started_at <= NOW() - $2 * INTERVAL '1 second' THEN 1
+                ELSE reset_request_count + 1
+            END,
+            reset_window_started_at = CASE
+                WHEN reset_window_started_at IS NULL OR reset_window_started_at <= NOW() - $2 * INTERVAL '1 second' THEN NOW()
+                ELSE reset_window_started_at
+            END
+        WHERE id = $1
+        RETURNING reset_request_count
+    `
+	var count int
+	if err := db.QueryRow(query, userID, window.Seconds()).Scan(&count); err != nil {
+		logger.ErrorLogger.Printf("Error recording reset request: %v", err)
+		return false, err
+	}
+	return count <= max, nil
+}
+
+// GetTokenVersion returns the current token version of a user. Tokens issued
+// with an older version are revoked.
+func (db *DB) GetTokenVersion(userID int) (int, error) {
+	var version int
+	err := db.queryRowRead("SELECT token_version FROM users WHERE id = $1", userID).Scan(&version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("user not found")
+		}
+		logger.ErrorLogger.Printf("Error fetching token version: %v", err)
+		return 0, err
+	}
+	return version, nil
+}
+
+// IncrementTokenVersion revokes every token issued to a user so far and
+// returns the new token version.
+func (db *DB) IncrementTokenVersion(userID int) (int, error) {
+	var version int
+	err := db.QueryRow("UPDATE users SET token_version = token_version + 1 WHERE id = $1 RETURNING token_version", userID).Scan(&version)
+	if err != nil {
+		logger.ErrorLogger.Printf("Error incrementing token version: %v", err)
+		return 0, err
+	}
+	return version, nil
+}
+
+// SetUserRole changes the role of a user. Tokens issued with the old role
+// are revoked by bumping the token version, which is returned. The role
+// must exist, or ErrRoleNotFound is returned.
+func (db *DB) SetUserRole(userID int, role string) (int, error) {
+	var version int
+	err := db.QueryRow("UPDATE users SET role = $1, token_version = token_version + 1 WHERE id = $2 RETURNING token_version", role, userID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, ErrUserNotFound
+	}
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return 0, ErrRoleNotFound
+		}
+		logger.ErrorLogger.Printf("Error setting user role: %v", err)
+		return 0, unavailable(err)
+	}
+	return version, nil
+}
+
 // ClearResetToken clears the reset token for a user in the database.
 func (db *DB) ClearResetToken(userID int) error {
 	query := `
@@ -155,12 +558,11 @@ func (db *DB) ClearResetToken(userID int) error {
 // VerifyResetToken verifies the reset token for a user.
 func (db *DB) VerifyResetToken(resetToken string) (*models.User, error) {
 	query := `
-        SELECT id, first_name, email, reset_token,reset_token_expiry
+        SELECT ` + userColumns + `
         FROM users
         WHERE reset_token = $1
     `
-	user := &models.User{}
-	err := db.QueryRow(query, resetToken).Scan(&user.ID, &user.FirstName, &user.Email, &user.ResetToken, &user.ResetTokenExpiry)
+	user, err := scanUser(db.queryRowRead(query, resetToken))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("reset token not found")
@@ -168,11 +570,6 @@ func (db *DB) VerifyResetToken(resetToken string) (*models.User, error) {
 		logger.ErrorLogger.Printf("Error fetching user by reset token: %v", err)
 		return nil, err
 	}
-	logger.InfoLogger.Println("user from db for password reset token : ")
-	logger.InfoLogger.Println("getting user from token")
-	// logger.InfoLogger.Println(user.ResetToken)
-	// logger.InfoLogger.Println(user.FirstName)
-	// logger.InfoLogger.Println(user.Email)
 	// Check if the reset token has expired (optional)
 	if utils.IsTokenExpired(user.ResetTokenExpiry) {
 		return nil, errors.New("reset token has expired")
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+func TestRegisterFirstUserAsAdmin(t *testing.T) {
+	tests := []struct {
+		name     string
+		first    bool
+		wantRole string
+	}{
+		{"first user", true, models.UserRoleAdmin},
+		{"later user", false, models.UserRoleGeneral},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectBegin()
+			mock.ExpectExec("LOCK TABLE users").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("SELECT NOT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"first"}).AddRow(tt.first))
+			mock.ExpectQuery("INSERT INTO users").WithArgs("Ann", "Lee", "+14155550100", "ann@example.com", "hash", tt.wantRole).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectCommit()
+
+			user := &models.User{FirstName: "Ann", LastName: "Lee", Phone: "+14155550100", Email: "ann@example.com", Password: "hash", Role: models.UserRoleGeneral}
+			if err := db.RegisterFirstUserAsAdmin(context.Background(), user); err != nil {
+				t.Fatal(err)
+			}
+			if user.ID != 1 || user.Role != tt.wantRole {
+				t.Errorf("user %d has role %s, want 1 with %s", user.ID, user.Role, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestEnforceUniquePhonesNormalizesFirst(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT to_regclass").WithArgs(uniquePhoneIndex).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, phone FROM users").WillReturnRows(sqlmock.NewRows([]string{"id", "phone"}).
+		AddRow(1, "+14155550100").
+		AddRow(2, "0044 20 7946 0958"))
+	mock.ExpectExec("UPDATE users SET phone").WithArgs(2, "+442079460958").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("CREATE UNIQUE INDEX " + uniquePhoneIndex).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	if err := db.EnforceUniquePhones(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnforceUniquePhonesDisabledAllowsDuplicates(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectExec("DROP INDEX IF EXISTS " + uniquePhoneIndex).WillReturnResult(sqlmock.NewResult(0, 0))
+	// Without the index two users may share a number
+	for id := 1; id <= 2; id++ {
+		mock.ExpectQuery("INSERT INTO users").WithArgs("Ann", "Lee", "+14155550100", sqlmock.AnyArg(), "hash", models.UserRoleGeneral).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+	}
+
+	if err := db.EnforceUniquePhones(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"ann@example.com", "ann.lee@example.com"} {
+		user := &models.User{FirstName: "Ann", LastName: "Lee", Phone: "+14155550100", Email: email, Password: "hash", Role: models.UserRoleGeneral}
+		if err := db.RegisterUser(user); err != nil {
+			t.Fatalf("registering %s: %v", email, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dashboardCacheTTL is how long a computed dashboard payload is reused.
+const dashboardCacheTTL = 30 * time.Second
+
+// dashboardPayload holds the dashboard metrics along with the error of every
+// metric that could not be computed.
+type dashboardPayload struct {
+	Metrics map[string]interface{} `json:"metrics"`
+	Errors  map[string]string      `json:"errors,omitempty"`
+}
+
+// AdminDashboard returns aggregated counts for the admin dashboard. Each metric
+// is computed independently, so a failing query only affects its own metric.
+func AdminDashboard(db *db.DB) gin.HandlerFunc {
+	var (
+		mu       sync.Mutex
+		cached   *dashboardPayload
+		cachedAt time.Time
+	)
+
+	return func(c *gin.Context) {
+		logger.InfoLogger.Println("Handling GET request for admin dashboard")
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if cached == nil || time.Since(cachedAt) > dashboardCacheTTL {
+			cached = computeDashboard(db)
+			cachedAt = time.Now()
+		}
+
+		respondSuccess(c, http.StatusOK, "", cached)
+	}
+}
+
+func computeDashboard(db *db.DB) *dashboardPayload {
+	payload := &dashboardPayload{
+		Metrics: make(map[string]interface{}),
+		Errors:  make(map[string]string),
+	}
+
+	collect := func(name string, value interface{}, err error) {
+		if err != nil {
+			logger.ErrorLogger.Printf("Dashboard metric %s failed: %v", name, err)
+			payload.Errors[name] = "failed to compute"
+			return
+		}
+		payload.Metrics[name] = value
+	}
+
+	now := time.Now()
+	weekAgo := now.AddDate(0, 0, -7)
+
+	totalUsers, err := db.CountUsers()
+	collect("total_users", totalUsers, err)
+
+	newUsers, err := db.CountUsersCreatedSince(weekAgo)
+	collect("new_users_this_week", newUsers, err)
+
+	totalDevices, err := db.CountDevices()
+	collect("total_devices", totalDevices, err)
+
+	devicesByType, err := db.CountDevicesByType()
+	collect("devices_by_type", devicesByType, err)
+
+	payload.Metrics["generated_at"] = now
+	return payload
+}
+
+// GetStorageStats returns the latest row counts and on-disk sizes of the
+// main tables, as collected in the background.
+func GetStorageStats(collector *db.StorageStatsCollector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats, err := collector.Latest()
+		if stats.CollectedAt.IsZero() {
+			if err != nil {
+				respondDBError(c, err, "Failed to collect storage stats")
+				return
+			}
+			respondError(c, http.StatusServiceUnavailable, "Storage stats have not been collected yet")
+			return
+		}
+
+		data := gin.H{"stats": stats}
+		if err != nil {
+			// Serve the last good snapshot, but say why it may be stale
+			data["error"] = err.Error()
+		}
+		respondSuccess(c, http.StatusOK, "", data)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAdminDashboard(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users$").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mock.ExpectQuery("WHERE created_at >= ").WillReturnError(errors.New("relation does not exist"))
+	mock.ExpectQuery("FROM device_location$").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(30))
+	mock.ExpectQuery("GROUP BY 1").WillReturnRows(sqlmock.NewRows([]string{"device_type", "count"}).AddRow("server", 20).AddRow("switch", 10))
+
+	handler := AdminDashboard(dbConn)
+	// The second request is answered from the cache, without queries
+	for i := 0; i < 2; i++ {
+		c, recorder := newTestContext(http.MethodGet, "/api/v1/admin/dashboard")
+		handler(c)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+		}
+		var response struct {
+			Data struct {
+				Metrics struct {
+					TotalUsers    *int           `json:"total_users"`
+					NewUsers      *int           `json:"new_users_this_week"`
+					TotalDevices  *int           `json:"total_devices"`
+					DevicesByType map[string]int `json:"devices_by_type"`
+				} `json:"metrics"`
+				Errors map[string]string `json:"errors"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		metrics := response.Data.Metrics
+		if metrics.TotalUsers == nil || *metrics.TotalUsers != 12 || metrics.TotalDevices == nil || *metrics.TotalDevices != 30 {
+			t.Errorf("metrics = %s, want 12 users and 30 devices", recorder.Body)
+		}
+		if metrics.DevicesByType["server"] != 20 || metrics.DevicesByType["switch"] != 10 {
+			t.Errorf("devices_by_type = %v", metrics.DevicesByType)
+		}
+		if metrics.NewUsers != nil || response.Data.Errors["new_users_this_week"] == "" {
+			t.Errorf("the failed metric is not reported as an error: %s", recorder.Body)
+		}
+	}
+}
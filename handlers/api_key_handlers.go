@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// CreateAPIKey creates an API key for the current user. The key itself is
+// only returned in this response; afterwards only its prefix is shown.
+func CreateAPIKey(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		var request struct {
+			Name      string     `json:"name" binding:"required,max=100"`
+			Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=read write"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondBindError(c, err, "Invalid API key")
+			return
+		}
+		name := strings.TrimSpace(request.Name)
+		if name == "" {
+			respondError(c, http.StatusBadRequest, "Name is required")
+			return
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(cfg.APIKeyDefaultTTL)
+		if request.ExpiresAt != nil {
+			expiresAt = *request.ExpiresAt
+		}
+		if !expiresAt.After(now) || expiresAt.After(now.Add(cfg.APIKeyMaxTTL)) {
+			respondError(c, http.StatusBadRequest, "expires_at must be in the future and within "+cfg.APIKeyMaxTTL.String())
+			return
+		}
+
+		secret, prefix, hash, err := utils.GenerateAPIKey()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to generate API key")
+			return
+		}
+		key, err := db.CreateAPIKey(claims.UserId, name, prefix, hash, apiKeyScopes(request.Scopes), expiresAt)
+		if err != nil {
+			respondDBError(c, err, "Failed to create the API key")
+			return
+		}
+
+		logger.InfoLogger.Printf("User %d created API key %d with scopes %v\n", claims.UserId, key.ID, key.Scopes)
+		respondSuccess(c, http.StatusCreated, "API key created, store it now as it will not be shown again", gin.H{
+			"key":     secret,
+			"api_key": key,
+		})
+	}
+}
+
+// GetAPIKeys lists the API keys of the current user.
+func GetAPIKeys(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		keys, err := db.GetAPIKeys(claims.UserId)
+		if err != nil {
+			respondDBError(c, err, "Failed to load API keys")
+			return
+		}
+		respondSuccess(c, http.StatusOK, "API keys retrieved", keys)
+	}
+}
+
+// GetAPIKey returns one of the current user's API keys.
+func GetAPIKey(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		key, err := db.GetAPIKey(claims.UserId, id)
+		if isAPIKeyNotFound(err) {
+			respondError(c, http.StatusNotFound, "API key not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to load the API key")
+			return
+		}
+		respondSuccess(c, http.StatusOK, "API key retrieved", key)
+	}
+}
+
+// UpdateAPIKey renames one of the current user's API keys or changes its
+// scopes. The expiry cannot be extended; a new key must be created instead.
+func UpdateAPIKey(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		var request struct {
+			Name   *string  `json:"name" binding:"omitempty,max=100"`
+			Scopes []string `json:"scopes" binding:"omitempty,min=1,dive,oneof=read write"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondBindError(c, err, "Invalid API key")
+			return
+		}
+
+		key, err := db.GetAPIKey(claims.UserId, id)
+		if isAPIKeyNotFound(err) {
+			respondError(c, http.StatusNotFound, "API key not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to update the API key")
+			return
+		}
+		if request.Name != nil {
+			key.Name = strings.TrimSpace(*request.Name)
+			if key.Name == "" {
+				respondError(c, http.StatusBadRequest, "Name is required")
+				return
+			}
+		}
+		if request.Scopes != nil {
+			key.Scopes = apiKeyScopes(request.Scopes)
+		}
+
+		key, err = db.UpdateAPIKey(claims.UserId, id, key.Name, key.Scopes)
+		if isAPIKeyNotFound(err) {
+			respondError(c, http.StatusNotFound, "API key not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to update the API key")
+			return
+		}
+		logger.InfoLogger.Printf("User %d updated API key %d\n", claims.UserId, key.ID)
+		respondSuccess(c, http.StatusOK, "API key updated", key)
+	}
+}
+
+// DeleteAPIKey revokes one of the current user's API keys.
+func DeleteAPIKey(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		err = db.DeleteAPIKey(claims.UserId, id)
+		if isAPIKeyNotFound(err) {
+			respondError(c, http.StatusNotFound, "API key not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to delete the API key")
+			return
+		}
+		logger.InfoLogger.Printf("User %d deleted API key %d\n", claims.UserId, id)
+		respondSuccess(c, http.StatusOK, "API key deleted", nil)
+	}
+}
+
+// RotateAPIKeys replaces all API keys of the current user with new keys of
+// the same names and scopes, as a security policy may require periodically.
+// The new keys are only returned in this response.
+func RotateAPIKeys(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		rotateAPIKeys(c, db, cfg, claims.UserId, claims.UserId)
+	}
+}
+
+// RotateUserAPIKeys lets an admin replace all API keys of a user during
+// incident response, e.g. POST /api/v1/users/7/keys/rotate. The new keys
+// are returned to the admin to hand over.
+func RotateUserAPIKeys(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		_, err = db.GetUserByID(id)
+		if isUserNotFound(err) {
+			respondError(c, http.StatusNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to rotate the API keys")
+			return
+		}
+		rotateAPIKeys(c, db, cfg, claims.UserId, id)
+	}
+}
+
+// rotateAPIKeys replaces the API keys of userID on behalf of actorID and
+// responds with each new key and its secret.
+func rotateAPIKeys(c *gin.Context, db *db.DB, cfg *config.Config, actorID, userID int) {
+	var secrets []string
+	newKey := func() (string, string, error) {
+		secret, prefix, hash, err := utils.GenerateAPIKey()
+		secrets = append(secrets, secret)
+		return prefix, hash, err
+	}
+	keys, err := db.RotateAPIKeys(actorID, userID, time.Now().Add(cfg.APIKeyDefaultTTL), newKey)
+	if err != nil {
+		respondDBError(c, err, "Failed to rotate the API keys")
+		return
+	}
+
+	rotated := make([]gin.H, len(keys))
+	for i, key := range keys {
+		rotated[i] = gin.H{"key": secrets[i], "api_key": key}
+	}
+	logger.InfoLogger.Printf("User %d rotated the %d API keys of user %d\n", actorID, len(keys), userID)
+	if len(keys) == 0 {
+		respondSuccess(c, http.StatusOK, "No API keys to rotate", rotated)
+		return
+	}
+	respondSuccess(c, http.StatusOK, "API keys rotated, store them now as they will not be shown again", rotated)
+}
+
+// apiKeyScopes returns the requested scopes sorted and without duplicates.
+func apiKeyScopes(scopes []string) []string {
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+	return slices.Compact(scopes)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/middleware"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// apiKeyRows is a result of the api_keys columns holding keys.
+func apiKeyRows(keys ...models.APIKey) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "prefix", "scopes", "expires_at", "created_at", "last_used_at"})
+	for _, key := range keys {
+		rows.AddRow(key.ID, key.UserID, key.Name, key.Prefix, pq.Array(key.Scopes), key.ExpiresAt, time.Now(), nil)
+	}
+	return rows
+}
+
+// rotatedKeys is the data of a rotation response.
+type rotatedKeys []struct {
+	Key    string        `json:"key"`
+	APIKey models.APIKey `json:"api_key"`
+}
+
+func TestRotateAPIKeys(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM api_keys WHERE user_id = ").WithArgs(7).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "scopes", "unexpired"}).
+			AddRow(4, "deploy", "{read,write}", true).
+			AddRow(2, "old", "{read}", false).
+			AddRow(3, "backup", "{read}", true))
+	for i, name := range []string{"backup", "deploy"} {
+		mock.ExpectQuery("INSERT INTO api_keys").WithArgs(7, name, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(apiKeyRows(models.APIKey{ID: 10 + i, UserID: 7, Name: name, Prefix: "ak_" + name, Scopes: []string{"read"}, ExpiresAt: time.Now().Add(time.Hour)}))
+	}
+	mock.ExpectExec("INSERT INTO audit_log").WithArgs(7, 7, models.AuditAPIKeysRotated, "3 keys revoked, 2 replaced").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	r := gin.New()
+	r.POST("/keys/rotate", func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, utils.Claims{UserId: 7})
+	}, RotateAPIKeys(dbConn, &config.Config{APIKeyDefaultTTL: time.Hour}))
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/keys/rotate", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+	var response struct {
+		Data rotatedKeys `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Data) != 2 || response.Data[0].APIKey.Name != "backup" || response.Data[1].APIKey.Name != "deploy" {
+		t.Fatalf("rotated %+v, want backup and deploy", response.Data)
+	}
+	for _, rotated := range response.Data {
+		if !utils.IsAPIKey(rotated.Key) {
+			t.Errorf("key %q of %s is not an API key", rotated.Key, rotated.APIKey.Name)
+		}
+	}
+	if response.Data[0].Key == response.Data[1].Key {
+		t.Error("both replacements have the same key")
+	}
+}
+
+func TestRotateAPIKeysWithoutKeys(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM api_keys WHERE user_id = ").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "scopes", "unexpired"}))
+	mock.ExpectExec("INSERT INTO audit_log").WithArgs(7, 7, models.AuditAPIKeysRotated, "0 keys revoked, 0 replaced").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	r := gin.New()
+	r.POST("/keys/rotate", func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, utils.Claims{UserId: 7})
+	}, RotateAPIKeys(dbConn, &config.Config{APIKeyDefaultTTL: time.Hour}))
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/keys/rotate", nil))
+
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"data":[]`) {
+		t.Errorf("got %d %s, want 200 with no keys", recorder.Code, recorder.Body)
+	}
+}
+
+func TestRotateUserAPIKeysAuditsAdmin(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("WHERE id = ").WithArgs(7).WillReturnRows(userRows(&models.User{ID: 7, Email: "ann@example.com", Role: models.UserRoleGeneral}))
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM api_keys WHERE user_id = ").WithArgs(7).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "scopes", "unexpired"}).AddRow(3, "backup", "{read}", true))
+	mock.ExpectQuery("INSERT INTO api_keys").WithArgs(7, "backup", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(apiKeyRows(models.APIKey{ID: 10, UserID: 7, Name: "backup", Prefix: "ak_backup", Scopes: []string{"read"}, ExpiresAt: time.Now().Add(time.Hour)}))
+	mock.ExpectExec("INSERT INTO audit_log").WithArgs(1, 7, models.AuditAPIKeysRotated, "1 keys revoked, 1 replaced").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	r := gin.New()
+	r.POST("/users/:id/keys/rotate", func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, utils.Claims{UserId: 1, UserRole: models.UserRoleAdmin})
+	}, RotateUserAPIKeys(dbConn, &config.Config{APIKeyDefaultTTL: time.Hour}))
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/users/7/keys/rotate", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+}
+
+func TestRotateUserAPIKeysUnknownUser(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("WHERE id = ").WithArgs(9).WillReturnRows(userRows())
+
+	r := gin.New()
+	r.POST("/users/:id/keys/rotate", func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, utils.Claims{UserId: 1, UserRole: models.UserRoleAdmin})
+	}, RotateUserAPIKeys(dbConn, &config.Config{APIKeyDefaultTTL: time.Hour}))
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/users/9/keys/rotate", nil))
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d: %s", recorder.Code, http.StatusNotFound, recorder.Body)
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/middleware"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// avatarCacheControl lets browsers reuse an avatar for an hour.
+const avatarCacheControl = "private, max-age=3600"
+
+func avatarPath(cfg *config.Config, userID uint) string {
+	return filepath.Join(cfg.AvatarDir, strconv.Itoa(int(userID))+".png")
+}
+
+// currentClaims returns the claims of the authenticated user, as stored in the
+// context by the auth middleware.
+func currentClaims(c *gin.Context) (utils.Claims, bool) {
+	value, ok := c.Get(middleware.ClaimsKey)
+	if !ok {
+		return utils.Claims{}, false
+	}
+	claims, ok := value.(utils.Claims)
+	return claims, ok
+}
+
+// currentUser returns the authenticated user, as loaded into the context by
+// the auth middleware.
+func currentUser(c *gin.Context) (*models.User, bool) {
+	value, ok := c.Get(middleware.UserKey)
+	if !ok {
+		return nil, false
+	}
+	user, ok := value.(*models.User)
+	return user, ok
+}
+
+// UploadAvatar stores a thumbnail of the uploaded "avatar" image as the
+// current user's avatar.
+func UploadAvatar(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.InfoLogger.Println("Handling POST request for avatar upload")
+
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		file, _, err := c.Request.FormFile("avatar")
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Avatar image is missing")
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Failed to read avatar image")
+			return
+		}
+
+		thumbnail, err := utils.AvatarThumbnail(data)
+		if errors.Is(err, utils.ErrUnsupportedImage) {
+			respondError(c, http.StatusUnsupportedMediaType, err.Error())
+			return
+		} else if err != nil {
+			logger.ErrorLogger.Println("Failed to create avatar thumbnail:", err)
+			respondError(c, http.StatusInternalServerError, "Failed to process avatar image")
+			return
+		}
+
+		if err := os.MkdirAll(cfg.AvatarDir, 0755); err != nil {
+			logger.ErrorLogger.Println("Failed to create avatar directory:", err)
+			respondError(c, http.StatusInternalServerError, "Failed to store avatar")
+			return
+		}
+		if err := os.WriteFile(avatarPath(cfg, uint(claims.UserId)), thumbnail, 0644); err != nil {
+			logger.ErrorLogger.Println("Failed to write avatar:", err)
+			respondError(c, http.StatusInternalServerError, "Failed to store avatar")
+			return
+		}
+
+		logger.InfoLogger.Println("Avatar updated for user", claims.UserId)
+		respondSuccess(c, http.StatusOK, "Avatar updated successfully", nil)
+	}
+}
+
+// GetUserAvatar serves a user's avatar, or a generated initials avatar when
+// none has been uploaded.
+func GetUserAvatar(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil || id <= 0 {
+			respondError(c, http.StatusBadRequest, "Invalid user id")
+			return
+		}
+
+		users, err := db.GetUsersByIDs([]int{id})
+		if err != nil {
+			respondDBError(c, err, "Error retrieving user")
+			return
+		}
+		if len(users) == 0 {
+			respondError(c, http.StatusNotFound, "User not found")
+			return
+		}
+		user := users[0]
+
+		c.Header("Cache-Control", avatarCacheControl)
+
+		path := avatarPath(cfg, user.ID)
+		if _, err := os.Stat(path); err == nil {
+			c.File(path)
+			return
+		}
+
+		c.Data(http.StatusOK, "image/svg+xml", utils.InitialsAvatarSVG(user.ID, user.FirstName, user.LastName))
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/middleware"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// avatarUpload returns a multipart body with data as its avatar file.
+func avatarUpload(t *testing.T, data []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("avatar", "me.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	form.Close()
+	return &body, form.FormDataContentType()
+}
+
+func TestUploadAvatar(t *testing.T) {
+	var upload bytes.Buffer
+	if err := png.Encode(&upload, image.NewRGBA(image.Rect(0, 0, 64, 64))); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"PNG", upload.Bytes(), http.StatusOK},
+		{"not an image", []byte("plain text"), http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{AvatarDir: filepath.Join(t.TempDir(), "avatars")}
+			r := gin.New()
+			r.POST("/me/avatar", func(c *gin.Context) {
+				c.Set(middleware.ClaimsKey, utils.Claims{UserId: 7})
+			}, UploadAvatar(cfg))
+
+			body, contentType := avatarUpload(t, tt.data)
+			req := httptest.NewRequest(http.MethodPost, "/me/avatar", body)
+			req.Header.Set("Content-Type", contentType)
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.want, recorder.Body)
+			}
+			_, err := os.Stat(filepath.Join(cfg.AvatarDir, "7.png"))
+			if stored := err == nil; stored != (tt.want == http.StatusOK) {
+				t.Errorf("avatar stored = %v, want %v", stored, tt.want == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestGetUserAvatarFallsBackToInitials(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("WHERE id = ANY").WillReturnRows(userRows(&models.User{ID: 7, FirstName: "Ann", LastName: "Lee"}))
+
+	r := gin.New()
+	r.GET("/users/:id/avatar", GetUserAvatar(dbConn, &config.Config{AvatarDir: t.TempDir()}))
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users/7/avatar", nil))
+
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("got %d %s, want an SVG avatar", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(recorder.Body.String(), ">AL</text>") {
+		t.Errorf("avatar = %s, want the initials AL", recorder.Body)
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// Limits on the number of devices returned by SearchDevices.
+const (
+	defaultDeviceSearchLimit = 100
+	maxDeviceSearchLimit     = 500
+)
+
+// customFieldParam is the prefix of query parameters naming a custom field,
+// e.g. ?cf.cost_center=123.
+const customFieldParam = "cf."
+
+// GetCustomFieldSchema lists the custom fields devices may have and their
+// types, e.g. GET /api/v1/custom-fields.
+func GetCustomFieldSchema(schema map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respondSuccess(c, http.StatusOK, "", gin.H{"custom_fields": schema})
+	}
+}
+
+// GetDeviceCustomFields returns the custom fields of a device, e.g.
+// GET /api/v1/location-details/:id/custom-fields.
+func GetDeviceCustomFields(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		devices, err := db.GetDeviceLocationDetailsByIDs([]int{id})
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch device")
+			return
+		}
+		if len(devices) == 0 {
+			respondError(c, http.StatusNotFound, "Device not found")
+			return
+		}
+		fields := devices[0].CustomFields
+		if fields == nil {
+			fields = models.CustomFields{}
+		}
+		respondSuccess(c, http.StatusOK, "", gin.H{"id": id, "custom_fields": fields})
+	}
+}
+
+// SetDeviceCustomFields replaces the custom fields of a device with the
+// JSON object in the body, e.g. PUT /api/v1/location-details/:id/custom-fields
+// with {"cost_center": "123"}. Every key must be defined in CUSTOM_FIELDS
+// with a value of its type; omitted keys are removed.
+func SetDeviceCustomFields(db *db.DB, schema map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		var raw map[string]json.RawMessage
+		if err := c.ShouldBindJSON(&raw); err != nil {
+			respondBindError(c, err, "Custom fields must be a JSON object")
+			return
+		}
+		fields, err := utils.ValidateCustomFields(raw, schema)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		device, err := db.SetDeviceCustomFields(id, fields)
+		if isDeviceNotFound(err) {
+			respondError(c, http.StatusNotFound, "Device not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to save custom fields")
+			return
+		}
+		logger.InfoLogger.Printf("Set %d custom fields of device %d\n", len(fields), id)
+		respondSuccess(c, http.StatusOK, "Custom fields saved", gin.H{"id": id, "custom_fields": device.CustomFields})
+	}
+}
+
+// SearchDevices lists approved devices filtered and sorted by custom fields,
+// e.g. GET /api/v1/devices?cf.cost_center=123&sort=-cf.purchase_cost&limit=50.
+// Each cf.<name> parameter must equal the field; sort takes one custom
+// field, prefixed with "-" for descending order.
+func SearchDevices(db *db.DB, schema map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := models.CustomFields{}
+		for param, values := range c.Request.URL.Query() {
+			if !strings.HasPrefix(param, customFieldParam) {
+				continue
+			}
+			key := strings.TrimPrefix(param, customFieldParam)
+			typ, ok := schema[key]
+			if !ok {
+				respondError(c, http.StatusBadRequest, "Unknown custom field "+key)
+				return
+			}
+			value, err := utils.ParseCustomFieldValue(typ, values[0])
+			if err != nil {
+				respondError(c, http.StatusBadRequest, "Custom field "+key+" must be a "+typ)
+				return
+			}
+			filter[key] = value
+		}
+
+		var sortKey string
+		var descending bool
+		if sort := c.Query("sort"); sort != "" {
+			descending = strings.HasPrefix(sort, "-")
+			name := strings.TrimPrefix(sort, "-")
+			sortKey = strings.TrimPrefix(name, customFieldParam)
+			if _, ok := schema[sortKey]; !ok || sortKey == name {
+				respondError(c, http.StatusBadRequest, "sort must name a custom field, e.g. cf.cost_center or -cf.cost_center")
+				return
+			}
+		}
+
+		limit := defaultDeviceSearchLimit
+		if raw := c.Query("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > maxDeviceSearchLimit {
+				respondError(c, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxDeviceSearchLimit))
+				return
+			}
+			limit = n
+		}
+
+		devices, err := db.SearchDeviceLocationDetails(filter, sortKey, descending, limit)
+		if err != nil {
+			respondDBError(c, err, "Failed to search devices")
+			return
+		}
+		respondSuccess(c, http.StatusOK, "", gin.H{"devices": devices, "count": len(devices)})
+	}
+}
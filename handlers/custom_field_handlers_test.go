@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+var testCustomFieldSchema = map[string]string{"cost_center": "string", "purchase_cost": "number"}
+
+func TestSetDeviceCustomFields(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"valid", `{"cost_center":"123","purchase_cost":250}`, http.StatusOK},
+		{"unknown key", `{"mac_address":"aa:bb"}`, http.StatusBadRequest},
+		{"wrong type", `{"purchase_cost":"cheap"}`, http.StatusBadRequest},
+		{"nested", `{"cost_center":{"id":"123"}}`, http.StatusBadRequest},
+		{"not an object", `["123"]`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbConn, mock := newMockDB(t)
+			if tt.want == http.StatusOK {
+				fields := models.CustomFields{"cost_center": "123", "purchase_cost": float64(250)}
+				mock.ExpectQuery("UPDATE device_location SET custom_fields").
+					WithArgs(5, `{"cost_center":"123","purchase_cost":250}`).
+					WillReturnRows(deviceRows(models.DeviceLocationDetail{Id: 5, CustomFields: fields}))
+			}
+
+			r := gin.New()
+			r.PUT("/location-details/:id/custom-fields", SetDeviceCustomFields(dbConn, testCustomFieldSchema))
+			req := httptest.NewRequest(http.MethodPut, "/location-details/5/custom-fields", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.want, recorder.Body)
+			}
+			if tt.want == http.StatusOK && !strings.Contains(recorder.Body.String(), `"cost_center":"123"`) {
+				t.Errorf("body = %s, want the saved fields", recorder.Body)
+			}
+		})
+	}
+}
+
+func TestSetDeviceCustomFieldsNotFound(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("UPDATE device_location SET custom_fields").WillReturnRows(deviceRows())
+
+	r := gin.New()
+	r.PUT("/location-details/:id/custom-fields", SetDeviceCustomFields(dbConn, testCustomFieldSchema))
+	req := httptest.NewRequest(http.MethodPut, "/location-details/9/custom-fields", strings.NewReader(`{"cost_center":"123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+}
+
+func TestSearchDevices(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery(`custom_fields @> \$1 ORDER BY custom_fields -> \$2 DESC NULLS LAST, id LIMIT \$3`).
+		WithArgs(`{"cost_center":"123","purchase_cost":250}`, "purchase_cost", 10).
+		WillReturnRows(deviceRows(models.DeviceLocationDetail{Id: 5, CustomFields: models.CustomFields{"cost_center": "123", "purchase_cost": float64(250)}}))
+
+	r := gin.New()
+	r.GET("/devices", SearchDevices(dbConn, testCustomFieldSchema))
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/devices?cf.cost_center=123&cf.purchase_cost=250&sort=-cf.purchase_cost&limit=10", nil))
+
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"count":1`) {
+		t.Errorf("got %d %s, want one device", recorder.Code, recorder.Body)
+	}
+}
+
+func TestSearchDevicesInvalidQuery(t *testing.T) {
+	for _, query := range []string{
+		"cf.mac_address=aa:bb",
+		"cf.purchase_cost=cheap",
+		"sort=cost_center",
+		"sort=cf.mac_address",
+		"limit=0",
+	} {
+		t.Run(query, func(t *testing.T) {
+			dbConn, _ := newMockDB(t)
+			r := gin.New()
+			r.GET("/devices", SearchDevices(dbConn, testCustomFieldSchema))
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/devices?"+query, nil))
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, http.StatusBadRequest, recorder.Body)
+			}
+		})
+	}
+}
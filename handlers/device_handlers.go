@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImageDimension bounds the requested size of generated images.
+const maxImageDimension = 2000
+
+// imageDimension reads an optional pixel size from the query string, falling
+// back to def and rejecting values outside 1..maxImageDimension.
+func imageDimension(c *gin.Context, name string, def int) (int, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return def, true
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 || value > maxImageDimension {
+		return 0, false
+	}
+	return value, true
+}
+
+// GetDeviceBarcode returns a PNG barcode encoding a device's serial number,
+// e.g. GET /api/v1/devices/:serial/barcode?type=code128&width=300&height=100.
+func GetDeviceBarcode(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serial := c.Param("serial")
+		logger.InfoLogger.Println("Generating barcode for device:", serial)
+
+		if barcodeType := c.DefaultQuery("type", "code128"); barcodeType != "code128" {
+			respondError(c, http.StatusBadRequest, "Unsupported barcode type, expected code128")
+			return
+		}
+
+		width, ok := imageDimension(c, "width", cfg.BarcodeWidth)
+		if !ok {
+			respondError(c, http.StatusBadRequest, "Invalid width")
+			return
+		}
+		height, ok := imageDimension(c, "height", cfg.BarcodeHeight)
+		if !ok {
+			respondError(c, http.StatusBadRequest, "Invalid height")
+			return
+		}
+
+		if !utils.IsBarcodeSafe(serial) {
+			respondError(c, http.StatusBadRequest, utils.ErrNotBarcodeSafe.Error())
+			return
+		}
+
+		devices, err := db.GetDeviceLocationDetailsBySerials([]string{serial})
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch device")
+			return
+		}
+		if len(devices) == 0 {
+			respondError(c, http.StatusNotFound, "Device not found")
+			return
+		}
+
+		image, err := utils.Code128PNG(serial, width, height)
+		if err != nil {
+			logger.ErrorLogger.Println("Failed to generate barcode:", err)
+			respondError(c, http.StatusBadRequest, "Failed to generate barcode, the requested size may be too small")
+			return
+		}
+
+		c.Data(http.StatusOK, "image/png", image)
+	}
+}
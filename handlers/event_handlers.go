@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// StreamDeviceEvents streams device changes as server-sent events, e.g.
+// GET /api/v1/events/assets. Each event is named after its type, such as
+// "device.moved", with the DeviceEvent as JSON data. Only admins see devices
+// that are not approved. A comment line is sent every EventHeartbeat to keep
+// proxies from closing an idle connection, and at most MaxEventStreams
+// streams are open at once.
+//
+// With EventBatchWindow set, events are instead coalesced for that long
+// after the first one, or until EventBatchMax devices changed, and sent as
+// a single "devices.batch" event whose data is the array of events, one per
+// device.
+func StreamDeviceEvents(cfg *config.Config) gin.HandlerFunc {
+	var open atomic.Int64
+	return func(c *gin.Context) {
+		user, ok := currentUser(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		if open.Add(1) > int64(cfg.MaxEventStreams) {
+			open.Add(-1)
+			logger.WarningLogger.Printf("Refused event stream for user %d, %d streams are open\n", user.ID, cfg.MaxEventStreams)
+			c.Header("Retry-After", "30")
+			respondError(c, http.StatusServiceUnavailable, "Too many open event streams, please try again later")
+			return
+		}
+		defer open.Add(-1)
+
+		events, unsubscribe := utils.SubscribeDeviceEvents()
+		defer unsubscribe()
+		heartbeat := time.NewTicker(cfg.EventHeartbeat)
+		defer heartbeat.Stop()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.WriteString(": connected\n\n")
+		c.Writer.Flush()
+
+		isAdmin := user.Role == models.UserRoleAdmin
+		var batch utils.DeviceEventBatch
+		var batchDue <-chan time.Time
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					if batch.Len() > 0 {
+						c.SSEvent("devices.batch", batch.Take())
+						c.Writer.Flush()
+					}
+					return
+				}
+				if !isAdmin && event.Device.ApprovalStatus != models.DeviceApproved {
+					continue
+				}
+				if cfg.EventBatchWindow <= 0 {
+					c.SSEvent(event.Type, event)
+					break
+				}
+				if batch.Len() == 0 {
+					batchDue = time.After(cfg.EventBatchWindow)
+				}
+				batch.Add(event)
+				if batch.Len() < cfg.EventBatchMax {
+					continue
+				}
+				c.SSEvent("devices.batch", batch.Take())
+				batchDue = nil
+			case <-batchDue:
+				c.SSEvent("devices.batch", batch.Take())
+				batchDue = nil
+			case <-heartbeat.C:
+				c.Writer.WriteString(": heartbeat\n\n")
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/middleware"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// eventServer serves StreamDeviceEvents to user.
+func eventServer(t *testing.T, cfg *config.Config, user *models.User) *httptest.Server {
+	t.Helper()
+	r := gin.New()
+	r.GET("/events/assets", func(c *gin.Context) {
+		c.Set(middleware.UserKey, user)
+	}, StreamDeviceEvents(cfg))
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// openEventStream opens an event stream on server, returning it once the
+// stream is subscribed.
+func openEventStream(t *testing.T, server *httptest.Server) *bufio.Reader {
+	t.Helper()
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(server.URL + "/events/assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/event-stream") {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	stream := bufio.NewReader(resp.Body)
+	if line, err := stream.ReadString('\n'); err != nil || line != ": connected\n" {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+	return stream
+}
+
+// readEvent returns the name and data of the next event on stream,
+// skipping comments.
+func readEvent(t *testing.T, stream *bufio.Reader) (string, string) {
+	t.Helper()
+	var name, data string
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			data = strings.TrimPrefix(line, "data:")
+		case line == "" && name != "":
+			return name, data
+		}
+	}
+}
+
+func TestStreamDeviceEventsCreated(t *testing.T) {
+	cfg := &config.Config{MaxEventStreams: 10, EventHeartbeat: time.Hour}
+	server := eventServer(t, cfg, &models.User{ID: 7, Role: models.UserRoleGeneral})
+	stream := openEventStream(t, server)
+
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("INSERT INTO device_location").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	device := &models.DeviceLocationDetail{SerialNumber: "SN-42"}
+	if err := dbConn.CreateDeviceLocationDetail(device); err != nil {
+		t.Fatal(err)
+	}
+
+	name, data := readEvent(t, stream)
+	if name != utils.DeviceCreated {
+		t.Errorf("event = %q, want %q", name, utils.DeviceCreated)
+	}
+	var event utils.DeviceEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatalf("decoding %q: %v", data, err)
+	}
+	if event.Device.Id != 42 || event.Device.SerialNumber != "SN-42" {
+		t.Errorf("device = %+v, want id 42 and serial SN-42", event.Device)
+	}
+}
+
+func TestStreamDeviceEventsHidesPendingDevices(t *testing.T) {
+	cfg := &config.Config{MaxEventStreams: 10, EventHeartbeat: time.Hour}
+	server := eventServer(t, cfg, &models.User{ID: 7, Role: models.UserRoleGeneral})
+	stream := openEventStream(t, server)
+
+	utils.PublishDeviceEvent(utils.DeviceCreated, models.DeviceLocationDetail{Id: 1, ApprovalStatus: models.DevicePendingApproval})
+	utils.PublishDeviceEvent(utils.DeviceCreated, models.DeviceLocationDetail{Id: 2, ApprovalStatus: models.DeviceApproved})
+
+	_, data := readEvent(t, stream)
+	var event utils.DeviceEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatalf("decoding %q: %v", data, err)
+	}
+	if event.Device.Id != 2 {
+		t.Errorf("first event is for device %d, want 2", event.Device.Id)
+	}
+}
+
+func TestStreamDeviceEventsLimitsStreams(t *testing.T) {
+	cfg := &config.Config{MaxEventStreams: 1, EventHeartbeat: time.Hour}
+	server := eventServer(t, cfg, &models.User{ID: 7, Role: models.UserRoleAdmin})
+	openEventStream(t, server)
+
+	resp, err := http.Get(server.URL + "/events/assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("missing Retry-After header")
+	}
+}
+
+func TestStreamDeviceEventsBatched(t *testing.T) {
+	cfg := &config.Config{MaxEventStreams: 10, EventHeartbeat: time.Hour, EventBatchWindow: 50 * time.Millisecond, EventBatchMax: 100}
+	server := eventServer(t, cfg, &models.User{ID: 7, Role: models.UserRoleAdmin})
+	stream := openEventStream(t, server)
+
+	for _, rack := range []int{1, 2, 3} {
+		utils.PublishDeviceEvent(utils.DeviceUpdated, models.DeviceLocationDetail{Id: 5, DeviceRackNumber: rack})
+	}
+
+	name, data := readEvent(t, stream)
+	if name != "devices.batch" {
+		t.Errorf("event = %q, want %q", name, "devices.batch")
+	}
+	var events []utils.DeviceEvent
+	if err := json.Unmarshal([]byte(data), &events); err != nil {
+		t.Fatalf("decoding %q: %v", data, err)
+	}
+	if len(events) != 1 || events[0].Device.DeviceRackNumber != 3 {
+		t.Errorf("events = %+v, want one for the latest update", events)
+	}
+}
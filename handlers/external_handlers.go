@@ -16,17 +16,12 @@ func FetchDisks(c *gin.Context) {
 		logger.ErrorLogger.Println("Failed to fetch disk's data")
 		logger.ErrorLogger.Println(err)
 
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch disk's data",
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to fetch disk's data")
 		return
 	}
 
 	logger.InfoLogger.Println("Disk's data fetched successfully from external server.")
 	logger.InfoLogger.Println("Sending disk's data")
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":      "Disk's data fetched successfully",
-		"disk's count": string(data),
-	})
+	respondSuccess(c, http.StatusOK, "Disk's data fetched successfully", gin.H{"disk's count": string(data)})
 }
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// facetCacheTTL is how long distinct values for filter dropdowns are reused.
+const facetCacheTTL = 60 * time.Second
+
+// facetHandler serves the result of load, cached for facetCacheTTL. Failed
+// loads are not cached.
+func facetHandler(name string, load func() ([]models.FacetCount, error)) gin.HandlerFunc {
+	var (
+		mu       sync.Mutex
+		cached   []models.FacetCount
+		cachedAt time.Time
+	)
+
+	return func(c *gin.Context) {
+		logger.InfoLogger.Println("Handling GET request for device", name)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if cached == nil || time.Since(cachedAt) > facetCacheTTL {
+			facets, err := load()
+			if err != nil {
+				respondDBError(c, err, "Failed to fetch device "+name)
+				return
+			}
+			cached = facets
+			cachedAt = time.Now()
+		}
+
+		respondSuccess(c, http.StatusOK, "", gin.H{name: cached})
+	}
+}
+
+// GetDeviceCategories lists the distinct device types with their device
+// counts, e.g. GET /api/v1/devices/categories.
+func GetDeviceCategories(db *db.DB) gin.HandlerFunc {
+	return facetHandler("categories", db.GetDeviceTypeFacets)
+}
+
+// GetDeviceLocations lists the distinct device locations with their device
+// counts, e.g. GET /api/v1/locations.
+func GetDeviceLocations(db *db.DB) gin.HandlerFunc {
+	return facetHandler("locations", db.GetDeviceLocationFacets)
+}
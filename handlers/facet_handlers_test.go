@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+func TestGetDeviceLocations(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT device_location, COUNT").WillReturnRows(sqlmock.NewRows([]string{"device_location", "count"}).
+		AddRow("Berlin", 2).
+		AddRow("Paris", 5))
+
+	handler := GetDeviceLocations(dbConn)
+	want := []models.FacetCount{{Value: "Berlin", Count: 2}, {Value: "Paris", Count: 5}}
+	// The second request is served from the cache without querying again.
+	for i := 0; i < 2; i++ {
+		c, recorder := newTestContext(http.MethodGet, "/api/v1/locations")
+		handler(c)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+		}
+		var body struct {
+			Data struct {
+				Locations []models.FacetCount `json:"locations"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(body.Data.Locations, want) {
+			t.Errorf("locations = %+v, want %+v", body.Data.Locations, want)
+		}
+	}
+}
+
+func TestGetDeviceCategoriesDoesNotCacheErrors(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT device_type, COUNT").WillReturnError(sqlmock.ErrCancelled)
+	mock.ExpectQuery("SELECT device_type, COUNT").WillReturnRows(sqlmock.NewRows([]string{"device_type", "count"}).AddRow("Server", 3))
+
+	handler := GetDeviceCategories(dbConn)
+	c, recorder := newTestContext(http.MethodGet, "/api/v1/devices/categories")
+	handler(c)
+	if recorder.Code == http.StatusOK {
+		t.Fatalf("status = %d after a failed query", recorder.Code)
+	}
+
+	c, recorder = newTestContext(http.MethodGet, "/api/v1/devices/categories")
+	handler(c)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/vikash-parashar/asset-locator/db"
 	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/middleware"
 	"github.com/vikash-parashar/asset-locator/models"
 
 	"github.com/gin-gonic/gin"
@@ -19,11 +20,11 @@ func GetFiberDetails(db *db.DB) gin.HandlerFunc {
 		data, err := db.GetAllDeviceEthernetFiberDetail()
 		if err != nil {
 			logger.ErrorLogger.Println(err)
-			c.HTML(http.StatusOK, "fiber_details.html", gin.H{"data": nil})
+			c.HTML(http.StatusOK, "fiber_details.html", gin.H{"data": nil, "CSRFToken": middleware.CSRFToken(c)})
 			return
 		}
 		logger.InfoLogger.Println("Fiber details fetched successfully.")
-		c.HTML(http.StatusOK, "fiber_details.html", gin.H{"data": data})
+		c.HTML(http.StatusOK, "fiber_details.html", gin.H{"data": data, "CSRFToken": middleware.CSRFToken(c)})
 	}
 }
 
@@ -33,7 +34,7 @@ func GetFiberDetailByID(db *db.DB) gin.HandlerFunc {
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
 			logger.ErrorLogger.Println("Invalid ID:", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			respondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
@@ -41,12 +42,12 @@ func GetFiberDetailByID(db *db.DB) gin.HandlerFunc {
 		fiberDetail, err := db.GetFiberDetailByID(id)
 		if err != nil {
 			logger.ErrorLogger.Println("Fiber detail not found:", err)
-			c.JSON(http.StatusNotFound, gin.H{"error": "Fiber detail not found"})
+			respondError(c, http.StatusNotFound, "Fiber detail not found")
 			return
 		}
 
 		logger.InfoLogger.Println("Fiber detail fetched successfully.")
-		c.JSON(http.StatusOK, fiberDetail)
+		respondSuccess(c, http.StatusOK, "", fiberDetail)
 	}
 }
 
@@ -79,12 +80,12 @@ func CreateNewFiberDetails(db *db.DB) gin.HandlerFunc {
 
 		if err := db.CreateDeviceEthernetFiberDetail(&data); err != nil {
 			logger.ErrorLogger.Println(err)
-			c.JSON(http.StatusOK, gin.H{"success": false, "message": "Failed to create entry"})
+			respondDBError(c, err, "Failed to create entry")
 			return
 		}
 
 		logger.InfoLogger.Println("New fiber details created successfully.")
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Entry Added Successfully"})
+		respondSuccess(c, http.StatusOK, "Entry Added Successfully", nil)
 	}
 }
 
@@ -104,7 +105,7 @@ func UpdateDeviceEthernetFiberDetail(db *db.DB) gin.HandlerFunc {
 		var r DeviceEthernetFiberDetail
 		if err := c.BindJSON(&r); err != nil {
 			logger.ErrorLogger.Println("Invalid JSON data:", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+			respondError(c, http.StatusBadRequest, "Invalid JSON data")
 			return
 		}
 
@@ -124,12 +125,12 @@ func UpdateDeviceEthernetFiberDetail(db *db.DB) gin.HandlerFunc {
 
 		if err := db.UpdateDeviceEthernetFiberDetail(nid, updatedData); err != nil {
 			logger.ErrorLogger.Println("Failed to update DeviceEthernetFiberDetail:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update DeviceEthernetFiberDetail"})
+			respondDBError(c, err, "Failed to update DeviceEthernetFiberDetail")
 			return
 		}
 
 		logger.InfoLogger.Println("DeviceEthernetFiberDetail updated successfully.")
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "DeviceEthernetFiberDetail updated successfully"})
+		respondSuccess(c, http.StatusOK, "DeviceEthernetFiberDetail updated successfully", nil)
 	}
 }
 
@@ -139,37 +140,36 @@ func DeleteDeviceEthernetFiberDetail(db *db.DB) gin.HandlerFunc {
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
 			logger.ErrorLogger.Println("Invalid ID:", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			respondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
 		if err := db.DeleteDeviceEthernetFiberDetail(id); err != nil {
 			logger.ErrorLogger.Println("Failed to delete DeviceEthernetFiberDetail:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete DeviceEthernetFiberDetail"})
+			respondDBError(c, err, "Failed to delete DeviceEthernetFiberDetail")
 			return
 		}
 
 		logger.InfoLogger.Println("DeviceEthernetFiberDetail deleted successfully.")
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "DeviceEthernetFiberDetail deleted successfully"})
+		respondSuccess(c, http.StatusOK, "DeviceEthernetFiberDetail deleted successfully", nil)
 	}
 }
 
 func DownloadDeviceEthernetFiberDetail(db *db.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.InfoLogger.Println("Downloading DeviceEthernetFiberDetails as Excel file")
-		rows, err := db.Query("SELECT * FROM device_ethernet_fiber")
+		devices, err := db.GetAllDeviceEthernetFiberDetail()
 		if err != nil {
 			logger.ErrorLogger.Println("Failed to query the database:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query the database"})
+			respondDBError(c, err, "Failed to query the database")
 			return
 		}
-		defer rows.Close()
 
 		file := xlsx.NewFile()
 		sheet, err := file.AddSheet("DeviceEthernetFiberDetails")
 		if err != nil {
 			logger.ErrorLogger.Println("Failed to create Excel sheet:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create Excel sheet"})
+			respondError(c, http.StatusInternalServerError, "Failed to create Excel sheet")
 			return
 		}
 
@@ -184,13 +184,7 @@ func DownloadDeviceEthernetFiberDetail(db *db.DB) gin.HandlerFunc {
 		headerRow.AddCell().SetString("Device Port MAC/WWN")
 		headerRow.AddCell().SetString("Connected Device Port")
 
-		for rows.Next() {
-			var device models.DeviceEthernetFiberDetail
-			if err := rows.Scan(&device.Id, &device.SerialNumber, &device.DeviceMakeModel, &device.Model, &device.DeviceType, &device.DevicePhysicalPort, &device.DevicePortType, &device.DevicePortMACWWN, &device.ConnectedDevicePort); err != nil {
-				logger.ErrorLogger.Println("Failed to scan database row:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan database row"})
-				return
-			}
+		for _, device := range devices {
 			dataRow := sheet.AddRow()
 			dataRow.AddCell().SetInt(device.Id)
 			dataRow.AddCell().SetString(device.SerialNumber)
@@ -208,7 +202,7 @@ func DownloadDeviceEthernetFiberDetail(db *db.DB) gin.HandlerFunc {
 		err = file.Write(c.Writer)
 		if err != nil {
 			logger.ErrorLogger.Println("Failed to write Excel file to response:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write Excel file to response"})
+			respondError(c, http.StatusInternalServerError, "Failed to write Excel file to response")
 		}
 	}
 }
@@ -216,13 +210,12 @@ func DownloadDeviceEthernetFiberDetail(db *db.DB) gin.HandlerFunc {
 func DownloadDeviceEthernetFiberDetailPDF(db *db.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.InfoLogger.Println("Downloading DeviceEthernetFiberDetails as PDF")
-		rows, err := db.Query("SELECT * FROM device_ethernet_fiber")
+		devices, err := db.GetAllDeviceEthernetFiberDetail()
 		if err != nil {
 			logger.ErrorLogger.Println("Failed to query the database:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query the database"})
+			respondDBError(c, err, "Failed to query the database")
 			return
 		}
-		defer rows.Close()
 
 		pdf := gofpdf.New("P", "mm", "A4", "")
 		pdf.AddPage()
@@ -237,14 +230,7 @@ func DownloadDeviceEthernetFiberDetailPDF(db *db.DB) gin.HandlerFunc {
 		}
 		pdf.Ln(-1)
 
-		for rows.Next() {
-			var device models.DeviceEthernetFiberDetail
-			if err := rows.Scan(&device.Id, &device.SerialNumber, &device.DeviceMakeModel, &device.Model, &device.DeviceType, &device.DevicePhysicalPort, &device.DevicePortType, &device.DevicePortMACWWN, &device.ConnectedDevicePort); err != nil {
-				logger.ErrorLogger.Println("Failed to scan database row:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan database row"})
-				return
-			}
-
+		for _, device := range devices {
 			data := []string{
 				strconv.Itoa(device.Id),
 				device.SerialNumber,
@@ -268,7 +254,7 @@ func DownloadDeviceEthernetFiberDetailPDF(db *db.DB) gin.HandlerFunc {
 		err = pdf.Output(c.Writer)
 		if err != nil {
 			logger.ErrorLogger.Println("Failed to write PDF file to response:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write PDF file to response"})
+			respondError(c, http.StatusInternalServerError, "Failed to write PDF file to response")
 		}
 	}
 }
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/vikash-parashar/asset-locator/authz"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+)
+
+type graphQLContextKey string
+
+const (
+	loaderContextKey graphQLContextKey = "deviceLoader"
+	claimsContextKey graphQLContextKey = "claims"
+)
+
+// deviceLoader batches the detail lookups of a single GraphQL request, so that
+// resolving owner, power and fiber details for N devices costs one query per
+// detail table instead of one query per device.
+type deviceLoader struct {
+	db      *db.DB
+	serials []string
+
+	mu     sync.Mutex
+	owners map[string][]models.DeviceAMCOwnerDetail
+	power  map[string][]models.DevicePowerDetail
+	fibers map[string][]models.DeviceEthernetFiberDetail
+}
+
+// prime registers the serial numbers whose details may be requested later.
+func (l *deviceLoader) prime(devices []models.DeviceLocationDetail) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, d := range devices {
+		l.serials = append(l.serials, d.SerialNumber)
+	}
+}
+
+func (l *deviceLoader) ownersFor(serial string) ([]models.DeviceAMCOwnerDetail, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.owners == nil {
+		rows, err := l.db.GetDeviceAMCOwnerDetailsBySerials(l.serials)
+		if err != nil {
+			return nil, err
+		}
+		l.owners = make(map[string][]models.DeviceAMCOwnerDetail)
+		for _, row := range rows {
+			l.owners[row.SerialNumber] = append(l.owners[row.SerialNumber], row)
+		}
+	}
+	return l.owners[serial], nil
+}
+
+func (l *deviceLoader) powerFor(serial string) ([]models.DevicePowerDetail, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.power == nil {
+		rows, err := l.db.GetDevicePowerDetailsBySerials(l.serials)
+		if err != nil {
+			return nil, err
+		}
+		l.power = make(map[string][]models.DevicePowerDetail)
+		for _, row := range rows {
+			l.power[row.SerialNumber] = append(l.power[row.SerialNumber], row)
+		}
+	}
+	return l.power[serial], nil
+}
+
+func (l *deviceLoader) fibersFor(serial string) ([]models.DeviceEthernetFiberDetail, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.fibers == nil {
+		rows, err := l.db.GetDeviceEthernetFiberDetailsBySerials(l.serials)
+		if err != nil {
+			return nil, err
+		}
+		l.fibers = make(map[string][]models.DeviceEthernetFiberDetail)
+		for _, row := range rows {
+			l.fibers[row.SerialNumber] = append(l.fibers[row.SerialNumber], row)
+		}
+	}
+	return l.fibers[serial], nil
+}
+
+// newGraphQLSchema builds the read-only schema exposing devices and users.
+// Listing users takes the user:read permission from policy.
+func newGraphQLSchema(db *db.DB, policy *authz.Policy) (graphql.Schema, error) {
+	ownerType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "OwnerDetail",
+		Fields: graphql.Fields{
+			"id":                &graphql.Field{Type: graphql.Int},
+			"serial_number":     &graphql.Field{Type: graphql.String},
+			"device_make_model": &graphql.Field{Type: graphql.String},
+			"model":             &graphql.Field{Type: graphql.String},
+			"po_number":         &graphql.Field{Type: graphql.String},
+			"po_order_date":     &graphql.Field{Type: graphql.DateTime},
+			"eosl_date":         &graphql.Field{Type: graphql.DateTime},
+			"amc_start_date":    &graphql.Field{Type: graphql.DateTime},
+			"amc_end_date":      &graphql.Field{Type: graphql.DateTime},
+			"device_owner":      &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	powerType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PowerDetail",
+		Fields: graphql.Fields{
+			"id":                &graphql.Field{Type: graphql.Int},
+			"serial_number":     &graphql.Field{Type: graphql.String},
+			"device_make_model": &graphql.Field{Type: graphql.String},
+			"model":             &graphql.Field{Type: graphql.String},
+			"device_type":       &graphql.Field{Type: graphql.String},
+			"total_power_watt":  &graphql.Field{Type: graphql.Int},
+			"total_btu":         &graphql.Field{Type: graphql.Float},
+			"total_power_cable": &graphql.Field{Type: graphql.Int},
+			"power_socket_type": &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	fiberType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "FiberDetail",
+		Fields: graphql.Fields{
+			"id":                    &graphql.Field{Type: graphql.Int},
+			"serial_number":         &graphql.Field{Type: graphql.String},
+			"device_make_model":     &graphql.Field{Type: graphql.String},
+			"model":                 &graphql.Field{Type: graphql.String},
+			"device_type":           &graphql.Field{Type: graphql.String},
+			"device_physical_port":  &graphql.Field{Type: graphql.String},
+			"device_port_type":      &graphql.Field{Type: graphql.String},
+			"device_port_macwwn":    &graphql.Field{Type: graphql.String},
+			"connected_device_port": &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	serialOf := func(p graphql.ResolveParams) string {
+		return p.Source.(models.DeviceLocationDetail).SerialNumber
+	}
+
+	deviceType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Device",
+		Fields: graphql.Fields{
+			"id":                 &graphql.Field{Type: graphql.Int},
+			"serial_number":      &graphql.Field{Type: graphql.String},
+			"device_make_model":  &graphql.Field{Type: graphql.String},
+			"model":              &graphql.Field{Type: graphql.String},
+			"device_type":        &graphql.Field{Type: graphql.String},
+			"data_center":        &graphql.Field{Type: graphql.String},
+			"region":             &graphql.Field{Type: graphql.String},
+			"dc_location":        &graphql.Field{Type: graphql.String},
+			"device_location":    &graphql.Field{Type: graphql.String},
+			"device_row_number":  &graphql.Field{Type: graphql.Int},
+			"device_rack_number": &graphql.Field{Type: graphql.Int},
+			"device_ru_number":   &graphql.Field{Type: graphql.String},
+			"owners": &graphql.Field{
+				Type: graphql.NewList(ownerType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loaderFrom(p.Context).ownersFor(serialOf(p))
+				},
+			},
+			"power": &graphql.Field{
+				Type: graphql.NewList(powerType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loaderFrom(p.Context).powerFor(serialOf(p))
+				},
+			},
+			"fibers": &graphql.Field{
+				Type: graphql.NewList(fiberType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loaderFrom(p.Context).fibersFor(serialOf(p))
+				},
+			},
+		},
+	})
+
+	// The password and reset token fields are deliberately not exposed.
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":         &graphql.Field{Type: graphql.Int},
+			"first_name": &graphql.Field{Type: graphql.String},
+			"last_name":  &graphql.Field{Type: graphql.String},
+			"phone":      &graphql.Field{Type: graphql.String},
+			"email":      &graphql.Field{Type: graphql.String},
+			"role":       &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"devices": &graphql.Field{
+				Type: graphql.NewList(deviceType),
+				Args: graphql.FieldConfigArgument{
+					"serial_number": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var devices []models.DeviceLocationDetail
+					var err error
+					if serial, ok := p.Args["serial_number"].(string); ok {
+						devices, err = db.GetDeviceLocationDetailsBySerials([]string{serial})
+					} else {
+						devices, err = db.GetAllDeviceLocationDetail()
+					}
+					if err != nil {
+						return nil, err
+					}
+					loaderFrom(p.Context).prime(devices)
+					return devices, nil
+				},
+			},
+			"me": &graphql.Field{
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					claims := claimsFrom(p.Context)
+					return db.GetUserByEmailID(claims.UserEmail)
+				},
+			},
+			"users": &graphql.Field{
+				Type: graphql.NewList(userType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					allowed, err := policy.Allows(claimsFrom(p.Context).UserRole, models.ResourceUser, models.ActionRead)
+					if err != nil {
+						return nil, err
+					}
+					if !allowed {
+						return nil, errors.New("access forbidden")
+					}
+					return db.GetAllUsers()
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+func loaderFrom(ctx context.Context) *deviceLoader {
+	return ctx.Value(loaderContextKey).(*deviceLoader)
+}
+
+func claimsFrom(ctx context.Context) utils.Claims {
+	claims, _ := ctx.Value(claimsContextKey).(utils.Claims)
+	return claims
+}
+
+// GraphQL serves read-only GraphQL queries over devices and users. It must be
+// mounted behind the auth middleware, which provides the caller's claims.
+func GraphQL(db *db.DB, policy *authz.Policy) (gin.HandlerFunc, error) {
+	schema, err := newGraphQLSchema(db, policy)
+	if err != nil {
+		return nil, fmt.Errorf("building the GraphQL schema: %w", err)
+	}
+
+	return func(c *gin.Context) {
+		var request struct {
+			Query         string                 `json:"query" form:"query"`
+			OperationName string                 `json:"operationName" form:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+
+		if c.Request.Method == http.MethodGet {
+			request.Query = c.Query("query")
+			request.OperationName = c.Query("operationName")
+		} else if err := c.ShouldBindJSON(&request); err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid GraphQL request")
+			return
+		}
+
+		if request.Query == "" {
+			respondError(c, http.StatusBadRequest, "Query is missing")
+			return
+		}
+
+		claims, _ := currentClaims(c)
+
+		ctx := context.WithValue(c.Request.Context(), loaderContextKey, &deviceLoader{db: db})
+		ctx = context.WithValue(ctx, claimsContextKey, claims)
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  request.Query,
+			VariableValues: request.Variables,
+			OperationName:  request.OperationName,
+			Context:        ctx,
+		})
+		if result.HasErrors() {
+			logger.WarningLogger.Printf("GraphQL query returned errors: %v", result.Errors)
+		}
+
+		c.JSON(http.StatusOK, result)
+	}, nil
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/authz"
+	"github.com/vikash-parashar/asset-locator/middleware"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+func TestGraphQLUsersPermission(t *testing.T) {
+	tests := []struct {
+		role    string
+		granted bool
+	}{
+		{"auditor", true},
+		{models.UserRoleGeneral, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			dbConn, mock := newMockDB(t)
+			mock.ExpectQuery("FROM role_permissions").WillReturnRows(sqlmock.NewRows([]string{"role", "permission"}).
+				AddRow("auditor", "user:read").
+				AddRow(models.UserRoleGeneral, "location:read"))
+			if tt.granted {
+				mock.ExpectQuery("FROM users").WillReturnRows(userRows(&models.User{ID: 7, Email: "ann@example.com", Role: models.UserRoleGeneral}))
+			}
+
+			graphQL, err := GraphQL(dbConn, authz.NewPolicy(dbConn))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := gin.New()
+			r.POST("/graphql", func(c *gin.Context) {
+				c.Set(middleware.ClaimsKey, utils.Claims{UserId: 1, UserRole: tt.role})
+			}, graphQL)
+
+			req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"{ users { email } }"}`))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			var result struct {
+				Data struct {
+					Users []struct {
+						Email string `json:"email"`
+					} `json:"users"`
+				} `json:"data"`
+				Errors []struct {
+					Message string `json:"message"`
+				} `json:"errors"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if tt.granted && (len(result.Errors) > 0 || len(result.Data.Users) != 1) {
+				t.Errorf("users query = %s, want one user", recorder.Body)
+			}
+			if !tt.granted && (len(result.Errors) == 0 || result.Errors[0].Message != "access forbidden") {
+				t.Errorf("users query = %s, want access forbidden", recorder.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// Statuses reported by the detailed health check.
+const (
+	healthOK          = "ok"
+	healthDegraded    = "degraded"
+	healthUnavailable = "unavailable"
+	healthDown        = "down"
+	healthSkipped     = "skipped"
+)
+
+// dependencyCheck checks one dependency. A failing critical dependency makes
+// the server unavailable; any other failure only degrades it.
+type dependencyCheck struct {
+	name     string
+	critical bool
+	skip     bool
+	check    func(ctx context.Context) (gin.H, error)
+}
+
+// dependencyStatus is the outcome of a dependencyCheck.
+type dependencyStatus struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Details   gin.H  `json:"details,omitempty"`
+}
+
+// runDependencyChecks runs the checks concurrently, giving each timeout to
+// finish, and derives the overall status from their results.
+func runDependencyChecks(checks []dependencyCheck, timeout time.Duration) (string, map[string]dependencyStatus) {
+	statuses := make(map[string]dependencyStatus, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, dep := range checks {
+		if dep.skip {
+			statuses[dep.name] = dependencyStatus{Status: healthSkipped}
+			continue
+		}
+		wg.Add(1)
+		go func(dep dependencyCheck) {
+			defer wg.Done()
+			status := checkDependency(dep, timeout)
+			mu.Lock()
+			statuses[dep.name] = status
+			mu.Unlock()
+		}(dep)
+	}
+	wg.Wait()
+
+	overall := healthOK
+	for _, dep := range checks {
+		if statuses[dep.name].Status != healthDown {
+			continue
+		}
+		if dep.critical {
+			overall = healthUnavailable
+			break
+		}
+		overall = healthDegraded
+	}
+	return overall, statuses
+}
+
+// checkDependency runs one check, reporting it as down when it fails or
+// outlasts timeout. A check that ignores its context is left to finish in
+// the background.
+func checkDependency(dep dependencyCheck, timeout time.Duration) dependencyStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type result struct {
+		details gin.H
+		err     error
+	}
+	done := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		details, err := dep.check(ctx)
+		done <- result{details, err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.err = errors.New("timed out")
+	}
+	status := dependencyStatus{
+		Status:    healthOK,
+		LatencyMS: time.Since(start).Milliseconds(),
+		Details:   res.details,
+	}
+	if res.err != nil {
+		status.Status = healthDown
+		status.Error = res.err.Error()
+	}
+	return status
+}
+
+// HealthDetailed reports the status of each dependency: the database, the
+// avatar storage, the email queue and, when HEALTH_CHECK_SMTP is set, the
+// SMTP server. It answers 503 when the database is down and 200 otherwise,
+// with an overall status of "ok" or "degraded".
+func HealthDetailed(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	checks := []dependencyCheck{
+		{
+			name:     "database",
+			critical: true,
+			check: func(ctx context.Context) (gin.H, error) {
+				return nil, db.PingContext(ctx)
+			},
+		},
+		{
+			name: "smtp",
+			skip: !cfg.HealthCheckSMTP,
+			check: func(ctx context.Context) (gin.H, error) {
+				return nil, utils.CheckSMTP(cfg.HealthCheckTimeout)
+			},
+		},
+		{
+			name: "storage",
+			check: func(ctx context.Context) (gin.H, error) {
+				return gin.H{"path": cfg.AvatarDir}, checkWritableDir(cfg.AvatarDir)
+			},
+		},
+		{
+			name: "email_queue",
+			check: func(ctx context.Context) (gin.H, error) {
+				queued, capacity, retrying := utils.EmailQueueDepth()
+				details := gin.H{"queued": queued, "capacity": capacity, "retrying": retrying}
+				if queued >= capacity {
+					return details, utils.ErrEmailQueueFull
+				}
+				return details, nil
+			},
+		},
+	}
+
+	return func(c *gin.Context) {
+		overall, statuses := runDependencyChecks(checks, cfg.HealthCheckTimeout)
+		code := http.StatusOK
+		if overall == healthUnavailable {
+			code = http.StatusServiceUnavailable
+		}
+		if overall != healthOK {
+			logger.WarningLogger.Printf("Detailed health check is %s: %v\n", overall, statuses)
+		}
+		c.JSON(code, gin.H{"status": overall, "dependencies": statuses})
+	}
+}
+
+// checkWritableDir reports whether files can be created in dir, creating
+// it if needed.
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".health-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
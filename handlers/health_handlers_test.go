@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+)
+
+func TestRunDependencyChecks(t *testing.T) {
+	up := func(context.Context) (gin.H, error) { return nil, nil }
+	down := func(context.Context) (gin.H, error) { return nil, errors.New("connection refused") }
+	hang := func(ctx context.Context) (gin.H, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	tests := []struct {
+		name        string
+		checks      []dependencyCheck
+		wantOverall string
+		want        map[string]string
+	}{
+		{
+			name:        "all up",
+			checks:      []dependencyCheck{{name: "database", critical: true, check: up}, {name: "storage", check: up}},
+			wantOverall: healthOK,
+			want:        map[string]string{"database": healthOK, "storage": healthOK},
+		},
+		{
+			name:        "optional dependency down",
+			checks:      []dependencyCheck{{name: "database", critical: true, check: up}, {name: "smtp", check: down}},
+			wantOverall: healthDegraded,
+			want:        map[string]string{"database": healthOK, "smtp": healthDown},
+		},
+		{
+			name:        "critical dependency down",
+			checks:      []dependencyCheck{{name: "database", critical: true, check: down}, {name: "smtp", check: down}},
+			wantOverall: healthUnavailable,
+			want:        map[string]string{"database": healthDown, "smtp": healthDown},
+		},
+		{
+			name:        "timed out",
+			checks:      []dependencyCheck{{name: "database", critical: true, check: up}, {name: "storage", check: hang}},
+			wantOverall: healthDegraded,
+			want:        map[string]string{"database": healthOK, "storage": healthDown},
+		},
+		{
+			name:        "skipped",
+			checks:      []dependencyCheck{{name: "database", critical: true, check: up}, {name: "smtp", skip: true, check: down}},
+			wantOverall: healthOK,
+			want:        map[string]string{"database": healthOK, "smtp": healthSkipped},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overall, statuses := runDependencyChecks(tt.checks, 20*time.Millisecond)
+			if overall != tt.wantOverall {
+				t.Errorf("overall = %s, want %s", overall, tt.wantOverall)
+			}
+			for name, want := range tt.want {
+				if got := statuses[name].Status; got != want {
+					t.Errorf("%s = %s (%s), want %s", name, got, statuses[name].Error, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthDetailed(t *testing.T) {
+	conn, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	mock.ExpectPing()
+
+	cfg := &config.Config{AvatarDir: t.TempDir(), HealthCheckTimeout: time.Second}
+	tests := []struct {
+		name        string
+		db          *db.DB
+		wantCode    int
+		wantOverall string
+	}{
+		{"database up", &db.DB{DB: conn}, http.StatusOK, healthOK},
+		{"database down", &db.DB{}, http.StatusServiceUnavailable, healthUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, recorder := newTestContext(http.MethodGet, "/health/detailed")
+			HealthDetailed(tt.db, cfg)(c)
+
+			var body struct {
+				Status       string                      `json:"status"`
+				Dependencies map[string]dependencyStatus `json:"dependencies"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if recorder.Code != tt.wantCode || body.Status != tt.wantOverall {
+				t.Errorf("got %d %s, want %d %s: %s", recorder.Code, body.Status, tt.wantCode, tt.wantOverall, recorder.Body)
+			}
+			if body.Dependencies["storage"].Status != healthOK || body.Dependencies["smtp"].Status != healthSkipped {
+				t.Errorf("dependencies = %+v", body.Dependencies)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newMockDB returns a DB backed by sqlmock, failing the test if an expected
+// query was not run.
+func newMockDB(t *testing.T) (*db.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		conn.Close()
+	})
+	return &db.DB{DB: conn}, mock
+}
+
+// userRows is a result of the user columns holding users.
+func userRows(users ...*models.User) *sqlmock.Rows {
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "phone", "email", "password", "role",
+		"reset_token", "reset_token_expiry", "created_at", "updated_at", "password_changed_at", "token_version", "locked_until"})
+	for _, user := range users {
+		var resetTokenExpiry interface{}
+		if !user.ResetTokenExpiry.IsZero() {
+			resetTokenExpiry = user.ResetTokenExpiry
+		}
+		var lockedUntil interface{}
+		if user.LockedUntil != nil {
+			lockedUntil = *user.LockedUntil
+		}
+		rows.AddRow(user.ID, user.FirstName, user.LastName, user.Phone, user.Email, user.Password, user.Role,
+			nil, resetTokenExpiry, now, now, user.PasswordChangedAt, user.TokenVersion, lockedUntil)
+	}
+	return rows
+}
+
+// deviceRows is a result of the device_location columns holding devices.
+func deviceRows(devices ...models.DeviceLocationDetail) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "serial_number", "device_make_model", "model", "device_type", "data_center", "region",
+		"dc_location", "device_location", "device_row_number", "device_rack_number", "device_ru_number", "last_audited_at",
+		"approval_status", "reviewed_by", "reviewed_at", "custom_fields"})
+	for _, d := range devices {
+		customFields, _ := d.CustomFields.Value()
+		if d.ApprovalStatus == "" {
+			d.ApprovalStatus = models.DeviceApproved
+		}
+		rows.AddRow(d.Id, d.SerialNumber, d.DeviceMakeModel, d.Model, d.DeviceType, d.DataCenter, d.Region,
+			d.DCLocation, d.DeviceLocation, d.DeviceRowNumber, d.DeviceRackNumber, d.DeviceRUNumber, d.LastAuditedAt,
+			d.ApprovalStatus, d.ReviewedBy, d.ReviewedAt, customFields)
+	}
+	return rows
+}
+
+// newTestContext returns a context for a request to path.
+func newTestContext(method, path string) (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(method, path, nil)
+	return c, recorder
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+const (
+	// kpiCacheTTL is how long computed KPIs are reused.
+	kpiCacheTTL = 60 * time.Second
+	// kpiMaxPeriods bounds the periods a single request may cover.
+	kpiMaxPeriods = 400
+	// kpiDefaultPeriods is how many periods are covered when no start date
+	// is given.
+	kpiDefaultPeriods = 12
+)
+
+type kpiPayload struct {
+	Period  string                  `json:"period"`
+	From    string                  `json:"from"`
+	To      string                  `json:"to"`
+	Devices int                     `json:"devices"`
+	Series  []models.AssetKPIPeriod `json:"series"`
+}
+
+// AssetKPIs returns asset KPIs for BI tools, e.g. GET
+// /api/v1/admin/kpis?period=week&from=2026-01-01&to=2026-06-30: the number
+// of devices and, per period, the devices bought and reaching end of
+// support life. period is day, week or month (the default); to defaults to
+// today and from to kpiDefaultPeriods periods before it. Results are cached
+// for kpiCacheTTL.
+func AssetKPIs(db *db.DB) gin.HandlerFunc {
+	type cacheEntry struct {
+		payload  *kpiPayload
+		cachedAt time.Time
+	}
+	var (
+		mu    sync.Mutex
+		cache = make(map[string]cacheEntry)
+	)
+
+	return func(c *gin.Context) {
+		period := c.DefaultQuery("period", "month")
+		if period != "day" && period != "week" && period != "month" {
+			respondError(c, http.StatusBadRequest, "period must be day, week or month")
+			return
+		}
+		to := time.Now().UTC().Truncate(24 * time.Hour)
+		if value := c.Query("to"); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, "to must be a date such as 2026-06-30")
+				return
+			}
+			to = parsed
+		}
+		from := addKPIPeriods(kpiPeriodStart(to, period), period, 1-kpiDefaultPeriods)
+		if value := c.Query("from"); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, "from must be a date such as 2026-01-01")
+				return
+			}
+			from = parsed
+		}
+		if from.After(to) {
+			respondError(c, http.StatusBadRequest, "from must not be after to")
+			return
+		}
+
+		var starts []time.Time
+		for start := kpiPeriodStart(from, period); !start.After(to); start = addKPIPeriods(start, period, 1) {
+			if len(starts) == kpiMaxPeriods {
+				respondError(c, http.StatusBadRequest, "The range covers too many periods")
+				return
+			}
+			starts = append(starts, start)
+		}
+
+		key := period + "|" + from.Format("2006-01-02") + "|" + to.Format("2006-01-02")
+		mu.Lock()
+		defer mu.Unlock()
+		if entry, ok := cache[key]; ok && time.Since(entry.cachedAt) <= kpiCacheTTL {
+			respondSuccess(c, http.StatusOK, "", entry.payload)
+			return
+		}
+
+		devices, err := db.CountDevices()
+		if err != nil {
+			respondDBError(c, err, "Failed to compute KPIs")
+			return
+		}
+		// The range is counted from the start of its first period to the
+		// end of its last, so that no period is partial
+		added, retired, err := db.CountAssetsByPeriod(period, starts[0], addKPIPeriods(starts[len(starts)-1], period, 1))
+		if err != nil {
+			respondDBError(c, err, "Failed to compute KPIs")
+			return
+		}
+		payload := &kpiPayload{
+			Period:  period,
+			From:    from.Format("2006-01-02"),
+			To:      to.Format("2006-01-02"),
+			Devices: devices,
+			Series:  make([]models.AssetKPIPeriod, len(starts)),
+		}
+		for i, start := range starts {
+			day := start.Format("2006-01-02")
+			payload.Series[i] = models.AssetKPIPeriod{Start: start, Added: added[day], Retired: retired[day]}
+		}
+
+		for cachedKey, entry := range cache {
+			if time.Since(entry.cachedAt) > kpiCacheTTL {
+				delete(cache, cachedKey)
+			}
+		}
+		cache[key] = cacheEntry{payload: payload, cachedAt: time.Now()}
+		respondSuccess(c, http.StatusOK, "", payload)
+	}
+}
+
+// kpiPeriodStart returns the start of the period containing day, as
+// PostgreSQL's date_trunc does: weeks start on Monday.
+func kpiPeriodStart(day time.Time, period string) time.Time {
+	switch period {
+	case "month":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case "week":
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return day
+	}
+}
+
+// addKPIPeriods moves the start of a period n periods on.
+func addKPIPeriods(start time.Time, period string, n int) time.Time {
+	switch period {
+	case "month":
+		return start.AddDate(0, n, 0)
+	case "week":
+		return start.AddDate(0, 0, 7*n)
+	default:
+		return start.AddDate(0, 0, n)
+	}
+}
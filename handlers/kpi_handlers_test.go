@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAssetKPIs(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+	mock.ExpectQuery("FROM device_amc_owner").WithArgs("week", "2026-01-05", "2026-01-26").
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "start", "count"}).
+			AddRow("added", time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC), 2).
+			AddRow("retired", time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC), 1))
+
+	r := gin.New()
+	r.GET("/admin/kpis", AssetKPIs(dbConn))
+	// The second request is answered from the cache, without queries
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/kpis?period=week&from=2026-01-07&to=2026-01-20", nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+		}
+
+		var body struct {
+			Data kpiPayload `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Data.Devices != 42 {
+			t.Errorf("devices = %d, want 42", body.Data.Devices)
+		}
+		want := []struct {
+			start          string
+			added, retired int
+		}{
+			{"2026-01-05", 2, 0},
+			{"2026-01-12", 0, 0},
+			{"2026-01-19", 0, 1},
+		}
+		if len(body.Data.Series) != len(want) {
+			t.Fatalf("got %d periods, want %d", len(body.Data.Series), len(want))
+		}
+		for i, period := range body.Data.Series {
+			if got := period.Start.Format("2006-01-02"); got != want[i].start || period.Added != want[i].added || period.Retired != want[i].retired {
+				t.Errorf("period %d = %s +%d -%d, want %s +%d -%d", i, got, period.Added, period.Retired, want[i].start, want[i].added, want[i].retired)
+			}
+		}
+	}
+}
+
+func TestAssetKPIsRejectsInvalidRanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unknown period", "period=year"},
+		{"invalid date", "from=01/01/2026"},
+		{"from after to", "from=2026-02-01&to=2026-01-01"},
+		{"too many periods", "period=day&from=2020-01-01&to=2026-01-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbConn, _ := newMockDB(t)
+			c, recorder := newTestContext(http.MethodGet, "/admin/kpis?"+tt.query)
+			AssetKPIs(dbConn)(c)
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestKPIPeriodStart(t *testing.T) {
+	// 2026-01-01 is a Thursday
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for period, want := range map[string]string{"day": "2026-01-01", "week": "2025-12-29", "month": "2026-01-01"} {
+		if got := kpiPeriodStart(day, period).Format("2006-01-02"); got != want {
+			t.Errorf("kpiPeriodStart(%s) = %s, want %s", period, got, want)
+		}
+	}
+	if got := kpiPeriodStart(time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC), "week").Format("2006-01-02"); got != "2026-01-12" {
+		t.Errorf("kpiPeriodStart(Sunday) = %s, want 2026-01-12", got)
+	}
+}
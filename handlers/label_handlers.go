@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Label sheet geometry, in millimetres on an A4 page.
+const (
+	labelPageWidth  = 210.0
+	labelPageHeight = 297.0
+	labelMargin     = 10.0
+	labelPadding    = 2.0
+	// labelQRPixels is the resolution of the embedded QR code images.
+	labelQRPixels = 256
+)
+
+// DownloadDeviceLabels returns a printable PDF sheet of device labels, each
+// with the device's QR code, make/model and serial number, e.g.
+// POST /api/v1/devices/labels with {"ids": [1, 2, 3], "rows": 8, "cols": 3}.
+// rows and cols default to the configured layout.
+func DownloadDeviceLabels(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.InfoLogger.Println("Handling POST request for device labels")
+
+		var request struct {
+			IDs  []int `json:"ids" binding:"required"`
+			Rows int   `json:"rows"`
+			Cols int   `json:"cols"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondBindError(c, err, "Invalid request, expected a JSON list of device ids")
+			return
+		}
+
+		if len(request.IDs) == 0 {
+			respondError(c, http.StatusBadRequest, "At least one device id is required")
+			return
+		}
+		if len(request.IDs) > cfg.MaxLabelsPerRequest {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("At most %d labels may be requested at once", cfg.MaxLabelsPerRequest))
+			return
+		}
+
+		rows, cols := request.Rows, request.Cols
+		if rows == 0 {
+			rows = cfg.LabelRows
+		}
+		if cols == 0 {
+			cols = cfg.LabelCols
+		}
+		if rows < 1 || rows > config.MaxLabelRows || cols < 1 || cols > config.MaxLabelCols {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("rows must be between 1 and %d and cols between 1 and %d", config.MaxLabelRows, config.MaxLabelCols))
+			return
+		}
+
+		devices, err := db.GetDeviceLocationDetailsByIDs(request.IDs)
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch devices")
+			return
+		}
+
+		// Print labels in the order they were requested
+		byID := make(map[int]models.DeviceLocationDetail, len(devices))
+		for _, device := range devices {
+			byID[device.Id] = device
+		}
+		ordered := make([]models.DeviceLocationDetail, 0, len(request.IDs))
+		var missing []string
+		for _, id := range request.IDs {
+			device, ok := byID[id]
+			if !ok {
+				missing = append(missing, strconv.Itoa(id))
+				continue
+			}
+			ordered = append(ordered, device)
+		}
+		if len(missing) > 0 {
+			respondError(c, http.StatusNotFound, "Devices not found: "+strings.Join(missing, ", "))
+			return
+		}
+
+		pdf, err := labelSheetPDF(ordered, rows, cols)
+		if err != nil {
+			logger.ErrorLogger.Println("Failed to generate label sheet:", err)
+			respondError(c, http.StatusInternalServerError, "Failed to generate label sheet")
+			return
+		}
+
+		logger.InfoLogger.Printf("Generated %d device labels\n", len(ordered))
+		c.Header("Content-Disposition", "attachment; filename=DeviceLabels.pdf")
+		c.Data(http.StatusOK, "application/pdf", pdf)
+	}
+}
+
+// labelSheetPDF lays out one label per device on A4 pages of rows x cols
+// labels, adding pages as needed.
+func labelSheetPDF(devices []models.DeviceLocationDetail, rows, cols int) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetFont("Arial", "", 8)
+
+	labelWidth := (labelPageWidth - 2*labelMargin) / float64(cols)
+	labelHeight := (labelPageHeight - 2*labelMargin) / float64(rows)
+	qrSize := labelHeight - 2*labelPadding
+	if qrSize > labelWidth/2 {
+		qrSize = labelWidth / 2
+	}
+	textWidth := labelWidth - qrSize - 3*labelPadding
+	perPage := rows * cols
+
+	for i, device := range devices {
+		if i%perPage == 0 {
+			pdf.AddPage()
+		}
+		slot := i % perPage
+		x := labelMargin + float64(slot%cols)*labelWidth
+		y := labelMargin + float64(slot/cols)*labelHeight
+
+		pdf.Rect(x, y, labelWidth, labelHeight, "D")
+
+		image, err := utils.QRCodePNG(device.SerialNumber, labelQRPixels)
+		if err != nil {
+			return nil, fmt.Errorf("QR code for device %d: %w", device.Id, err)
+		}
+		name := fmt.Sprintf("qr-%d", i)
+		pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(image))
+		pdf.ImageOptions(name, x+labelPadding, y+labelPadding, qrSize, qrSize, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+
+		textX := x + qrSize + 2*labelPadding
+		pdf.SetXY(textX, y+labelPadding)
+		pdf.SetFont("Arial", "B", 8)
+		pdf.CellFormat(textWidth, 4, fitText(pdf, device.DeviceMakeModel, textWidth), "", 2, "L", false, 0, "")
+		pdf.SetFont("Arial", "", 8)
+		pdf.SetX(textX)
+		pdf.CellFormat(textWidth, 4, fitText(pdf, "S/N "+device.SerialNumber, textWidth), "", 2, "L", false, 0, "")
+		pdf.SetX(textX)
+		pdf.CellFormat(textWidth, 4, fitText(pdf, device.DeviceLocation, textWidth), "", 2, "L", false, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fitText shortens text with an ellipsis until it fits width in the current
+// font.
+func fitText(pdf *gofpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
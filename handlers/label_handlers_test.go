@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// labelConfig is the label layout used by the tests.
+var labelConfig = &config.Config{LabelRows: 8, LabelCols: 3, MaxLabelsPerRequest: 3}
+
+// postLabels posts body to DownloadDeviceLabels.
+func postLabels(t *testing.T, handler gin.HandlerFunc, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := gin.New()
+	r.POST("/devices/labels", handler)
+	req := httptest.NewRequest(http.MethodPost, "/devices/labels", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestDownloadDeviceLabels(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("WHERE id = ANY").WithArgs("{2,1}").WillReturnRows(deviceRows(
+		models.DeviceLocationDetail{Id: 1, SerialNumber: "SN-1", DeviceMakeModel: "Dell R740"},
+		models.DeviceLocationDetail{Id: 2, SerialNumber: "SN-2", DeviceMakeModel: "A very long make and model that does not fit on a label"},
+	))
+
+	recorder := postLabels(t, DownloadDeviceLabels(dbConn, labelConfig), `{"ids":[2,1],"rows":1,"cols":1}`)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("got %d %s, want a PDF: %s", recorder.Code, recorder.Header().Get("Content-Type"), recorder.Body)
+	}
+	pdf := recorder.Body.Bytes()
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		t.Fatalf("body is not a PDF: %.20q", pdf)
+	}
+	// One label per page
+	if pages := bytes.Count(pdf, []byte("/Type /Page\n")); pages != 2 {
+		t.Errorf("PDF has %d pages, want 2", pages)
+	}
+}
+
+func TestDownloadDeviceLabelsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"no ids", `{"ids":[]}`, http.StatusBadRequest},
+		{"too many ids", `{"ids":[1,2,3,4]}`, http.StatusBadRequest},
+		{"too many rows", `{"ids":[1],"rows":1000}`, http.StatusBadRequest},
+		{"missing device", `{"ids":[1,9]}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbConn, mock := newMockDB(t)
+			if tt.want == http.StatusNotFound {
+				mock.ExpectQuery("WHERE id = ANY").WillReturnRows(deviceRows(models.DeviceLocationDetail{Id: 1, SerialNumber: "SN-1"}))
+			}
+			recorder := postLabels(t, DownloadDeviceLabels(dbConn, labelConfig), tt.body)
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.want, recorder.Body)
+			}
+		})
+	}
+}
@@ -1,13 +1,18 @@
 package handlers
 
 import (
+	"context"
+	"encoding/csv"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/vikash-parashar/asset-locator/config"
 	"github.com/vikash-parashar/asset-locator/db"
 	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/middleware"
 	"github.com/vikash-parashar/asset-locator/models"
 
 	"github.com/gin-gonic/gin"
@@ -21,11 +26,11 @@ func GetLocationDetails(db *db.DB) gin.HandlerFunc {
 		data, err := db.GetAllDeviceLocationDetail()
 		if err != nil {
 			logger.ErrorLogger.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch data"})
+			respondDBError(c, err, "Failed to fetch data")
 			return
 		}
 		logger.InfoLogger.Println("Location details fetched successfully.")
-		c.HTML(http.StatusOK, "location_details.html", data)
+		c.HTML(http.StatusOK, "location_details.html", gin.H{"data": data, "CSRFToken": middleware.CSRFToken(c)})
 	}
 }
 
@@ -50,12 +55,12 @@ func CreateNewLocationDetails(db *db.DB) gin.HandlerFunc {
 
 		deviceRowNumber, err := strconv.Atoi(deviceRowNumberStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device_row_number"})
+			respondError(c, http.StatusBadRequest, "Invalid device_row_number")
 			return
 		}
 		deviceRackNumber, err := strconv.Atoi(deviceRackNumberStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device_rack_number"})
+			respondError(c, http.StatusBadRequest, "Invalid device_rack_number")
 			return
 		}
 
@@ -76,12 +81,12 @@ func CreateNewLocationDetails(db *db.DB) gin.HandlerFunc {
 
 		if err := db.CreateDeviceLocationDetail(&data); err != nil {
 			logger.ErrorLogger.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create DeviceLocationDetail"})
+			respondDBError(c, err, "Failed to create DeviceLocationDetail")
 			return
 		}
 
 		logger.InfoLogger.Println("New location details created successfully.")
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Entry Added Successfully"})
+		respondSuccess(c, http.StatusOK, "Entry Added Successfully", nil)
 	}
 }
 
@@ -91,7 +96,7 @@ func UpdateDeviceLocationDetail(db *db.DB) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			respondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
@@ -111,7 +116,7 @@ func UpdateDeviceLocationDetail(db *db.DB) gin.HandlerFunc {
 
 		var requestData RequestData
 		if err := c.ShouldBindJSON(&requestData); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+			respondBindError(c, err, "Invalid data")
 			return
 		}
 
@@ -133,12 +138,17 @@ func UpdateDeviceLocationDetail(db *db.DB) gin.HandlerFunc {
 			DeviceRUNumber:   requestData.DeviceRUNumber,
 		}
 
-		if err := db.UpdateDeviceLocationDetail(id, updatedData); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update DeviceLocationDetail"})
+		err = db.UpdateDeviceLocationDetail(id, updatedData)
+		if isDeviceNotFound(err) {
+			respondError(c, http.StatusNotFound, "DeviceLocationDetail not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to update DeviceLocationDetail")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "DeviceLocationDetail updated successfully"})
+		respondSuccess(c, http.StatusOK, "DeviceLocationDetail updated successfully", nil)
 	}
 }
 
@@ -148,16 +158,16 @@ func DeleteDeviceLocationDetail(db *db.DB) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			respondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
 		if err := db.DeleteDeviceLocationDetail(id); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete DeviceLocationDetail"})
+			respondDBError(c, err, "Failed to delete DeviceLocationDetail")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "DeviceLocationDetail deleted successfully"})
+		respondSuccess(c, http.StatusOK, "DeviceLocationDetail deleted successfully", nil)
 	}
 }
 
@@ -165,13 +175,11 @@ func DownloadDeviceLocationDetail(db *db.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.InfoLogger.Println("Downloading DeviceLocationDetails as Excel file")
 		// Query the database for DeviceLocationDetail data
-		rows, err := db.Query("SELECT * FROM device_location")
+		devices, err := db.GetAllDeviceLocationDetail()
 		if err != nil {
-			log.Fatal(err)
-			http.Error(c.Writer, "Failed to query the database", http.StatusInternalServerError)
+			respondDBError(c, err, "Failed to query the database")
 			return
 		}
-		defer rows.Close()
 
 		// Create a new Excel file
 		file := xlsx.NewFile()
@@ -198,13 +206,7 @@ func DownloadDeviceLocationDetail(db *db.DB) gin.HandlerFunc {
 		headerRow.AddCell().SetString("Device RU Number")
 
 		// Add data rows from the database
-		for rows.Next() {
-			var device models.DeviceLocationDetail
-			if err := rows.Scan(&device.Id, &device.SerialNumber, &device.DeviceMakeModel, &device.Model, &device.DeviceType, &device.DataCenter, &device.Region, &device.DCLocation, &device.DeviceLocation, &device.DeviceRowNumber, &device.DeviceRackNumber, &device.DeviceRUNumber); err != nil {
-				log.Fatal(err)
-				http.Error(c.Writer, "Failed to scan database row", http.StatusInternalServerError)
-				return
-			}
+		for _, device := range devices {
 			dataRow := sheet.AddRow()
 			dataRow.AddCell().SetInt(device.Id)
 			dataRow.AddCell().SetString(device.SerialNumber)
@@ -235,13 +237,11 @@ func DownloadDeviceLocationDetailPDF(db *db.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.InfoLogger.Println("Downloading DeviceLocationDetails as PDF")
 		// Query the database for DeviceLocationDetail data
-		rows, err := db.Query("SELECT * FROM device_location")
+		devices, err := db.GetAllDeviceLocationDetail()
 		if err != nil {
-			log.Fatal(err)
-			http.Error(c.Writer, "Failed to query the database", http.StatusInternalServerError)
+			respondDBError(c, err, "Failed to query the database")
 			return
 		}
-		defer rows.Close()
 
 		// Create a new PDF document
 		pdf := gofpdf.New("P", "mm", "A4", "")
@@ -258,14 +258,7 @@ func DownloadDeviceLocationDetailPDF(db *db.DB) gin.HandlerFunc {
 		pdf.Ln(-1)
 
 		// Add data rows from the database
-		for rows.Next() {
-			var device models.DeviceLocationDetail
-			if err := rows.Scan(&device.Id, &device.SerialNumber, &device.DeviceMakeModel, &device.Model, &device.DeviceType, &device.DataCenter, &device.Region, &device.DCLocation, &device.DeviceLocation, &device.DeviceRowNumber, &device.DeviceRackNumber, &device.DeviceRUNumber); err != nil {
-				log.Fatal(err)
-				http.Error(c.Writer, "Failed to scan database row", http.StatusInternalServerError)
-				return
-			}
-
+		for _, device := range devices {
 			data := []string{
 				fmt.Sprint(device.Id),
 				device.SerialNumber,
@@ -295,3 +288,254 @@ func DownloadDeviceLocationDetailPDF(db *db.DB) gin.HandlerFunc {
 		c.Header("Content-Disposition", "attachment; filename=DeviceLocationDetails.pdf")
 	}
 }
+
+// csvFlushEvery is how many rows are written between flushes of a streamed
+// CSV export.
+const csvFlushEvery = 500
+
+// DownloadDeviceLocationDetailCSV streams every device location as CSV. Rows
+// are written to the client as they are read from the database, so memory
+// use does not grow with the size of the inventory. The query is bound to the
+// request context, which is cancelled if the client goes away.
+func DownloadDeviceLocationDetailCSV(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.InfoLogger.Println("Streaming DeviceLocationDetails as CSV")
+
+		ctx := c.Request.Context()
+		if cfg.ExportRequestTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, cfg.ExportRequestTimeout)
+			defer cancel()
+		}
+
+		cursor, err := db.OpenDeviceLocationCursor(ctx)
+		if err != nil {
+			respondDBError(c, err, "Failed to query the database")
+			return
+		}
+		defer cursor.Close()
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", "attachment; filename=DeviceLocationDetails.csv")
+		c.Status(http.StatusOK)
+
+		writer := csv.NewWriter(c.Writer)
+		writer.Write([]string{"ID", "Serial Number", "Device Make Model", "Model", "Device Type", "Data Center", "Region", "DC Location", "Device Location", "Device Row Number", "Device Rack Number", "Device RU Number"})
+
+		count := 0
+		for cursor.Next() {
+			device, err := cursor.Detail()
+			if err != nil {
+				// The status line has already been sent; all we can do is stop.
+				logger.ErrorLogger.Println("Failed to scan database row:", err)
+				return
+			}
+			writer.Write([]string{
+				strconv.Itoa(device.Id),
+				device.SerialNumber,
+				device.DeviceMakeModel,
+				device.Model,
+				device.DeviceType,
+				device.DataCenter,
+				device.Region,
+				device.DCLocation,
+				device.DeviceLocation,
+				strconv.Itoa(device.DeviceRowNumber),
+				strconv.Itoa(device.DeviceRackNumber),
+				device.DeviceRUNumber,
+			})
+
+			count++
+			if count%csvFlushEvery == 0 {
+				writer.Flush()
+				if err := writer.Error(); err != nil {
+					logger.WarningLogger.Println("CSV export aborted, client went away:", err)
+					return
+				}
+				c.Writer.Flush()
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			logger.ErrorLogger.Println("CSV export interrupted:", err)
+			return
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			logger.WarningLogger.Println("CSV export aborted, client went away:", err)
+			return
+		}
+		logger.InfoLogger.Printf("Streamed %d DeviceLocationDetails as CSV\n", count)
+	}
+}
+
+// UpsertDeviceLocationDetailBySerial creates the device with the serial
+// number in the path, or updates it if it already exists, e.g.
+// PUT /api/v1/devices/by-serial/:serial. It answers 201 when the device was
+// created and 200 when it was updated.
+func UpsertDeviceLocationDetailBySerial(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serial := c.Param("serial")
+		logger.InfoLogger.Println("Upserting DeviceLocationDetail by serial:", serial)
+
+		var request struct {
+			SerialNumber     string `json:"serial_number"`
+			DeviceMakeModel  string `json:"device_make_model"`
+			Model            string `json:"model"`
+			DeviceType       string `json:"device_type"`
+			DataCenter       string `json:"data_center"`
+			Region           string `json:"region"`
+			DCLocation       string `json:"dc_location"`
+			DeviceLocation   string `json:"device_location"`
+			DeviceRowNumber  int    `json:"device_row_number"`
+			DeviceRackNumber int    `json:"device_rack_number"`
+			DeviceRUNumber   string `json:"device_ru_number"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondBindError(c, err, "Invalid data")
+			return
+		}
+		if request.SerialNumber != "" && request.SerialNumber != serial {
+			respondError(c, http.StatusBadRequest, "serial_number in the body does not match the URL")
+			return
+		}
+
+		data := &models.DeviceLocationDetail{
+			SerialNumber:     serial,
+			DeviceMakeModel:  request.DeviceMakeModel,
+			Model:            request.Model,
+			DeviceType:       request.DeviceType,
+			DataCenter:       request.DataCenter,
+			Region:           request.Region,
+			DCLocation:       request.DCLocation,
+			DeviceLocation:   request.DeviceLocation,
+			DeviceRowNumber:  request.DeviceRowNumber,
+			DeviceRackNumber: request.DeviceRackNumber,
+			DeviceRUNumber:   request.DeviceRUNumber,
+		}
+
+		created, err := db.UpsertDeviceLocationDetailBySerial(data)
+		if err != nil {
+			respondDBError(c, err, "Failed to save DeviceLocationDetail")
+			return
+		}
+
+		if created {
+			respondSuccess(c, http.StatusCreated, "DeviceLocationDetail created successfully", gin.H{"device": data, "created": true})
+			return
+		}
+		respondSuccess(c, http.StatusOK, "DeviceLocationDetail updated successfully", gin.H{"device": data, "created": false})
+	}
+}
+
+// MergeDevices merges duplicate device records into a primary one, e.g.
+// POST /api/v1/devices/merge with {"primary_id": 1, "duplicate_ids": [7, 9]}.
+// Records linked to the duplicates move to the primary device and the
+// duplicates are deleted.
+func MergeDevices(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			PrimaryID    int   `json:"primary_id" binding:"required"`
+			DuplicateIDs []int `json:"duplicate_ids" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondBindError(c, err, "Invalid request, expected a primary_id and a list of duplicate_ids")
+			return
+		}
+
+		seen := map[int]bool{request.PrimaryID: true}
+		var duplicates []int
+		for _, id := range request.DuplicateIDs {
+			if id == request.PrimaryID {
+				respondError(c, http.StatusBadRequest, "A device cannot be merged into itself")
+				return
+			}
+			if !seen[id] {
+				seen[id] = true
+				duplicates = append(duplicates, id)
+			}
+		}
+		if len(duplicates) == 0 {
+			respondError(c, http.StatusBadRequest, "At least one duplicate id is required")
+			return
+		}
+
+		ids := append([]int{request.PrimaryID}, duplicates...)
+		existing, err := db.GetDeviceLocationDetailsByIDs(ids)
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch devices")
+			return
+		}
+		found := make(map[int]bool, len(existing))
+		for _, device := range existing {
+			found[device.Id] = true
+		}
+		var missing []string
+		for _, id := range ids {
+			if !found[id] {
+				missing = append(missing, strconv.Itoa(id))
+			}
+		}
+		if len(missing) > 0 {
+			respondError(c, http.StatusNotFound, "Devices not found: "+strings.Join(missing, ", "))
+			return
+		}
+
+		logger.InfoLogger.Printf("Merging devices %v into device %d\n", duplicates, request.PrimaryID)
+		device, err := db.MergeDeviceLocationDetails(c.Request.Context(), request.PrimaryID, duplicates)
+		if isDeviceNotFound(err) {
+			// Deleted concurrently since the check above
+			respondError(c, http.StatusNotFound, "Devices not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to merge devices")
+			return
+		}
+
+		respondSuccess(c, http.StatusOK, "Devices merged successfully", gin.H{"device": device, "merged_ids": duplicates})
+	}
+}
+
+// GetPendingDevices lists the devices waiting for approval, e.g.
+// GET /api/v1/location-details/pending.
+func GetPendingDevices(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		devices, err := db.GetPendingDeviceLocationDetails()
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch pending devices")
+			return
+		}
+		respondSuccess(c, http.StatusOK, "Pending devices fetched successfully", devices)
+	}
+}
+
+// ReviewDevice approves or rejects a device pending approval, e.g.
+// POST /api/v1/location-details/:id/approve. Approved devices appear in the
+// listings; rejected ones stay hidden.
+func ReviewDevice(db *db.DB, approve bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		reviewer, ok := currentUser(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		device, err := db.ReviewDeviceLocationDetail(id, approve, int(reviewer.ID))
+		switch {
+		case isDeviceNotFound(err):
+			respondError(c, http.StatusNotFound, "Device not found")
+		case isDeviceNotPending(err):
+			respondError(c, http.StatusConflict, "The device is not pending approval")
+		case err != nil:
+			respondDBError(c, err, "Failed to review device")
+		default:
+			respondSuccess(c, http.StatusOK, "Device "+device.ApprovalStatus, device)
+		}
+	}
+}
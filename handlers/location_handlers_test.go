@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/middleware"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+func TestDownloadDeviceLocationDetailCSV(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	devices := make([]models.DeviceLocationDetail, csvFlushEvery+1)
+	for i := range devices {
+		devices[i] = models.DeviceLocationDetail{Id: i + 1, SerialNumber: "SN", DeviceLocation: "IDC1, 2nd Floor", DeviceRowNumber: 3}
+	}
+	mock.ExpectQuery("FROM device_location").WillReturnRows(deviceRows(devices...))
+
+	c, recorder := newTestContext(http.MethodGet, "/api/v1/location-details/csv")
+	DownloadDeviceLocationDetailCSV(dbConn, &config.Config{ExportRequestTimeout: time.Minute})(c)
+
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("got %d %s, want a CSV file", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(devices)+1 {
+		t.Fatalf("got %d records, want a header and %d devices", len(records), len(devices))
+	}
+	if got := records[1]; got[0] != "1" || got[8] != "IDC1, 2nd Floor" || got[9] != "3" {
+		t.Errorf("first device = %v", got)
+	}
+}
+
+func TestUpsertDeviceLocationDetailBySerial(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		created    bool
+		wantStatus int
+	}{
+		{"created", `{"device_type":"Server","device_location":"IDC1"}`, true, http.StatusCreated},
+		{"updated", `{"serial_number":"SN-1","device_type":"Switch"}`, false, http.StatusOK},
+		{"serial mismatch", `{"serial_number":"SN-2"}`, false, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbConn, mock := newMockDB(t)
+			if tt.wantStatus != http.StatusBadRequest {
+				mock.ExpectQuery("INSERT INTO device_location .* ON CONFLICT \\(serial_number\\) DO UPDATE").
+					WithArgs("SN-1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "approval_status", "created", "moved"}).AddRow(4, models.DeviceApproved, tt.created, false))
+			}
+
+			r := gin.New()
+			r.PUT("/devices/by-serial/:serial", UpsertDeviceLocationDetailBySerial(dbConn))
+			req := httptest.NewRequest(http.MethodPut, "/devices/by-serial/SN-1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestMergeDevicesValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		found      []models.DeviceLocationDetail
+		wantStatus int
+	}{
+		{"into itself", `{"primary_id":1,"duplicate_ids":[7,1]}`, nil, http.StatusBadRequest},
+		{"no duplicates", `{"primary_id":1,"duplicate_ids":[]}`, nil, http.StatusBadRequest},
+		{"missing device", `{"primary_id":1,"duplicate_ids":[7]}`, []models.DeviceLocationDetail{{Id: 1, SerialNumber: "SN-1"}}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbConn, mock := newMockDB(t)
+			if tt.found != nil {
+				mock.ExpectQuery("FROM device_location WHERE id = ANY").WithArgs("{1,7}").WillReturnRows(deviceRows(tt.found...))
+			}
+
+			r := gin.New()
+			r.POST("/devices/merge", MergeDevices(dbConn))
+			req := httptest.NewRequest(http.MethodPost, "/devices/merge", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestReviewDevice(t *testing.T) {
+	tests := []struct {
+		name       string
+		approve    bool
+		reviewed   *models.DeviceLocationDetail
+		exists     bool
+		wantStatus int
+	}{
+		{"approve", true, &models.DeviceLocationDetail{Id: 4, SerialNumber: "SN-4", ApprovalStatus: models.DeviceApproved}, true, http.StatusOK},
+		{"reject", false, &models.DeviceLocationDetail{Id: 4, SerialNumber: "SN-4", ApprovalStatus: models.DeviceRejected}, true, http.StatusOK},
+		{"already reviewed", true, nil, true, http.StatusConflict},
+		{"missing", true, nil, false, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbConn, mock := newMockDB(t)
+			status := models.DeviceRejected
+			if tt.approve {
+				status = models.DeviceApproved
+			}
+			review := mock.ExpectQuery("UPDATE device_location").WithArgs(4, status, 1, models.DevicePendingApproval)
+			if tt.reviewed != nil {
+				review.WillReturnRows(deviceRows(*tt.reviewed))
+			} else {
+				review.WillReturnRows(deviceRows())
+				mock.ExpectQuery("SELECT EXISTS").WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.exists))
+			}
+
+			c, recorder := newTestContext(http.MethodPost, "/api/v1/location-details/4/approve")
+			c.Params = gin.Params{{Key: "id", Value: "4"}}
+			c.Set(middleware.UserKey, &models.User{ID: 1, Role: models.UserRoleAdmin})
+			ReviewDevice(dbConn, tt.approve)(c)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.reviewed != nil && !strings.Contains(recorder.Body.String(), `"approval_status":"`+status+`"`) {
+				t.Errorf("body = %s, want the device %s", recorder.Body, status)
+			}
+		})
+	}
+}
+
+func TestGetPendingDevices(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("WHERE approval_status = \\$1").WithArgs(models.DevicePendingApproval).
+		WillReturnRows(deviceRows(models.DeviceLocationDetail{Id: 4, SerialNumber: "SN-4", ApprovalStatus: models.DevicePendingApproval}))
+
+	c, recorder := newTestContext(http.MethodGet, "/api/v1/location-details/pending")
+	GetPendingDevices(dbConn)(c)
+
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"serial_number":"SN-4"`) {
+		t.Errorf("got %d %s, want the pending device", recorder.Code, recorder.Body)
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// RequestMagicLink emails a one-time login link to the account with the
+// given email. The answer is the same whether or not the account exists.
+func RequestMagicLink(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.InfoLogger.Println("Handling POST request for a magic login link")
+
+		var linkRequest struct {
+			Email string `json:"email" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&linkRequest); err != nil {
+			respondBindError(c, err, "Invalid input data")
+			return
+		}
+
+		const sent = "If the account exists, a login link has been sent to its email"
+		user, err := db.GetUserByEmailID(linkRequest.Email)
+		if isDBUnavailable(err) {
+			respondDBError(c, err, "")
+			return
+		}
+		if err != nil {
+			respondSuccess(c, http.StatusOK, sent, nil)
+			return
+		}
+
+		// Magic links share the per-account budget of reset emails. Beyond
+		// it no new link is sent and the previous one stays valid.
+		allowed, err := db.RecordResetRequest(int(user.ID), cfg.ResetRequestsPerWindow, cfg.ResetRequestWindow)
+		if err != nil {
+			respondDBError(c, err, "Failed to process login link request")
+			return
+		}
+		if !allowed {
+			logger.WarningLogger.Printf("Throttled magic link request for user %d, more than %d within %s\n", user.ID, cfg.ResetRequestsPerWindow, cfg.ResetRequestWindow)
+			respondSuccess(c, http.StatusOK, sent, nil)
+			return
+		}
+
+		token, tokenHash, err := utils.GenerateHashedToken()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to generate login link")
+			return
+		}
+		if err := db.SetMagicLinkToken(int(user.ID), tokenHash, time.Now().Add(cfg.MagicLinkTTL)); err != nil {
+			respondDBError(c, err, "Failed to save login link")
+			return
+		}
+		if err := utils.QueueMagicLinkEmail(user.Email, token, cfg.MagicLinkTTL); err != nil {
+			logger.ErrorLogger.Println("Failed to queue magic link email:", err)
+		}
+
+		logger.InfoLogger.Printf("Magic login link queued for user %d\n", user.ID)
+		respondSuccess(c, http.StatusOK, sent, nil)
+	}
+}
+
+// MagicLogin logs in with the token of a magic link, which is used up by
+// doing so, sets the session cookie and redirects to the homepage.
+func MagicLogin(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			respondError(c, http.StatusBadRequest, "Missing login token")
+			return
+		}
+
+		user, err := db.ConsumeMagicLinkToken(utils.HashToken(token))
+		if isMagicLinkInvalid(err) {
+			respondError(c, http.StatusUnauthorized, "This login link is invalid or has expired")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to log in")
+			return
+		}
+
+		if _, _, err := startSession(c, db, cfg, user); err != nil {
+			respondDBError(c, err, "Failed to start the session")
+			return
+		}
+
+		logger.InfoLogger.Printf("User %d logged in with a magic link\n", user.ID)
+		c.Redirect(http.StatusSeeOther, "/api/v1/homepage")
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// consumeMagicLink matches the query using up a magic link, which only
+// accepts unexpired tokens.
+const consumeMagicLink = "SET magic_token_hash = NULL, magic_token_expiry = NULL\\s+WHERE magic_token_hash = \\$1 AND magic_token_expiry > NOW\\(\\)"
+
+func TestMagicLogin(t *testing.T) {
+	utils.SetSecretKey("test-secret")
+	cfg := &config.Config{SessionDuration: time.Hour, SessionMaxLifetime: time.Hour}
+	user := &models.User{ID: 7, Email: "ann@example.com", Role: models.UserRoleGeneral, PasswordChangedAt: time.Now()}
+
+	dbConn, mock := newMockDB(t)
+	// The first visit logs in and uses up the token
+	mock.ExpectQuery(consumeMagicLink).WithArgs(utils.HashToken("magic")).WillReturnRows(userRows(user))
+	mock.ExpectQuery("INSERT INTO sessions").WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "last_seen_at"}).AddRow(3, time.Now(), time.Now()))
+	mock.ExpectExec("DELETE FROM sessions").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT notification_preferences").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"notification_preferences"}).AddRow(`{"login_alerts":false}`))
+	// A reused or expired token matches no user
+	mock.ExpectQuery(consumeMagicLink).WithArgs(utils.HashToken("magic")).WillReturnRows(userRows())
+
+	r := gin.New()
+	r.GET("/auth/magic", MagicLogin(dbConn, cfg))
+	login := func(query string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/auth/magic"+query, nil))
+		return recorder
+	}
+
+	recorder := login("?token=magic")
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/api/v1/homepage" {
+		t.Fatalf("got %d to %q, want a redirect to the homepage: %s", recorder.Code, recorder.Header().Get("Location"), recorder.Body)
+	}
+	if cookie := recorder.Header().Get("Set-Cookie"); !strings.HasPrefix(cookie, "jwt-token=ey") {
+		t.Errorf("Set-Cookie = %q, want a session cookie", cookie)
+	}
+
+	if recorder := login("?token=magic"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("reused link status = %d, want %d", recorder.Code, http.StatusUnauthorized)
+	}
+	if recorder := login(""); recorder.Code != http.StatusBadRequest {
+		t.Errorf("missing token status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRequestMagicLink(t *testing.T) {
+	cfg := &config.Config{ResetRequestsPerWindow: 3, ResetRequestWindow: time.Hour, MagicLinkTTL: 15 * time.Minute}
+	user := &models.User{ID: 7, Email: "ann@example.com"}
+	tests := []struct {
+		name  string
+		email string
+		known bool
+	}{
+		{"known account", "ann@example.com", true},
+		{"unknown account", "nobody@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbConn, mock := newMockDB(t)
+			if tt.known {
+				mock.ExpectQuery("FROM users").WithArgs(tt.email).WillReturnRows(userRows(user))
+				mock.ExpectQuery("SET reset_request_count").WillReturnRows(sqlmock.NewRows([]string{"reset_request_count"}).AddRow(1))
+				mock.ExpectExec("SET magic_token_hash = \\$1").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+			} else {
+				mock.ExpectQuery("FROM users").WithArgs(tt.email).WillReturnRows(userRows())
+			}
+
+			r := gin.New()
+			r.POST("/auth/magic-link", RequestMagicLink(dbConn, cfg))
+			req := httptest.NewRequest(http.MethodPost, "/auth/magic-link", strings.NewReader(`{"email":"`+tt.email+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			// The answer does not tell whether the account exists
+			if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "If the account exists") {
+				t.Errorf("got %d %s, want the generic answer", recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+// GetNotificationPreferences returns which optional emails the current user
+// receives.
+func GetNotificationPreferences(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		preferences, err := db.GetNotificationPreferences(claims.UserId)
+		if err != nil {
+			respondDBError(c, err, "Failed to load notification preferences")
+			return
+		}
+		respondSuccess(c, http.StatusOK, "Notification preferences retrieved", preferences)
+	}
+}
+
+// UpdateNotificationPreferences changes which optional emails the current
+// user receives, e.g. PUT /api/v1/me/notifications {"login_alerts": false}.
+// Preferences left out of the body keep their value.
+func UpdateNotificationPreferences(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		preferences, err := db.GetNotificationPreferences(claims.UserId)
+		if err != nil {
+			respondDBError(c, err, "Failed to load notification preferences")
+			return
+		}
+		if err := c.ShouldBindJSON(&preferences); err != nil {
+			respondBindError(c, err, "Invalid notification preferences")
+			return
+		}
+
+		if err := db.SetNotificationPreferences(claims.UserId, preferences); err != nil {
+			respondDBError(c, err, "Failed to update notification preferences")
+			return
+		}
+		logger.InfoLogger.Printf("User %d updated their notification preferences\n", claims.UserId)
+		respondSuccess(c, http.StatusOK, "Notification preferences updated", preferences)
+	}
+}
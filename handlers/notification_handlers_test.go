@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/middleware"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+func TestLoginAlertFollowsPreference(t *testing.T) {
+	tests := []struct {
+		name        string
+		preferences string
+		wantEmails  int
+	}{
+		{"default", `{}`, 1},
+		{"turned off", `{"login_alerts":false}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbConn, mock := newMockDB(t)
+			mock.ExpectQuery("INSERT INTO sessions").WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "last_seen_at"}).AddRow(3, time.Now(), time.Now()))
+			mock.ExpectExec("DELETE FROM sessions").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("SELECT notification_preferences").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"notification_preferences"}).AddRow(tt.preferences))
+			if tt.wantEmails > 0 {
+				mock.ExpectQuery("FROM sessions").WithArgs(7, 3, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"new"}).AddRow(true))
+			}
+
+			queued, _, _ := utils.EmailQueueDepth()
+			c, _ := newTestContext(http.MethodPost, "/login")
+			user := &models.User{ID: 7, Email: "ann@example.com"}
+			if _, err := recordSession(c, dbConn, user, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			if after, _, _ := utils.EmailQueueDepth(); after-queued != tt.wantEmails {
+				t.Errorf("queued %d emails, want %d", after-queued, tt.wantEmails)
+			}
+		})
+	}
+}
+
+func TestUpdateNotificationPreferences(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT notification_preferences").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"notification_preferences"}).AddRow(`{}`))
+	mock.ExpectExec("UPDATE users SET notification_preferences").WithArgs(7, []byte(`{"login_alerts":false}`)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := gin.New()
+	r.PUT("/me/notifications", func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, utils.Claims{UserId: 7})
+	}, UpdateNotificationPreferences(dbConn))
+	req := httptest.NewRequest(http.MethodPut, "/me/notifications", strings.NewReader(`{"login_alerts":false}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+}
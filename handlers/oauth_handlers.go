@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// oauthStateCookie carries the state and PKCE verifier of a social login
+// from its start to the provider's callback.
+const oauthStateCookie = "oauth-state"
+
+// OAuthLogin starts a social login by redirecting to the provider's login
+// page, e.g. GET /auth/google.
+func OAuthLogin(cfg *config.Config, provider *utils.OAuthProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state, verifier, err := utils.NewOAuthState()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to start the login")
+			return
+		}
+
+		authURL, err := provider.AuthCodeURL(c.Request.Context(), oauthRedirectURI(cfg, provider), state, verifier)
+		if err != nil {
+			logger.ErrorLogger.Println("Failed to start OAuth login:", err)
+			respondError(c, http.StatusBadGateway, "Login with "+provider.DisplayName+" is unavailable, please try again later")
+			return
+		}
+
+		// Lax, not Strict: the callback is a cross-site navigation from the
+		// provider.
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     oauthStateCookie,
+			Value:    state + "." + verifier,
+			Path:     "/auth/" + provider.Name,
+			MaxAge:   10 * 60,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// OAuthCallback completes a social login, e.g. GET /auth/google/callback.
+// The user linked to the provider account is logged in. An account seen for
+// the first time is linked to the user with the same verified email, or a
+// new user is created for it if its email domain may register.
+func OAuthCallback(db *db.DB, cfg *config.Config, provider *utils.OAuthProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		saved, _ := c.Cookie(oauthStateCookie)
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     oauthStateCookie,
+			Value:    "",
+			Path:     "/auth/" + provider.Name,
+			MaxAge:   -1,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		if reason := c.Query("error"); reason != "" {
+			logger.InfoLogger.Printf("Login with %s was not completed: %s\n", provider.DisplayName, reason)
+			respondError(c, http.StatusUnauthorized, "Login with "+provider.DisplayName+" was cancelled")
+			return
+		}
+		state, verifier, ok := strings.Cut(saved, ".")
+		if !ok || subtle.ConstantTimeCompare([]byte(c.Query("state")), []byte(state)) != 1 {
+			respondError(c, http.StatusBadRequest, "This login has expired, please try again")
+			return
+		}
+		code := c.Query("code")
+		if code == "" {
+			respondError(c, http.StatusBadRequest, "Missing authorization code")
+			return
+		}
+
+		ctx := c.Request.Context()
+		token, err := provider.Exchange(ctx, oauthRedirectURI(cfg, provider), code, verifier)
+		if err != nil {
+			logger.ErrorLogger.Println("OAuth token exchange failed:", err)
+			respondError(c, http.StatusBadGateway, "Login with "+provider.DisplayName+" failed, please try again")
+			return
+		}
+		profile, err := provider.Profile(ctx, token, state)
+		if errors.Is(err, utils.ErrOAuthNoVerifiedEmail) {
+			respondError(c, http.StatusForbidden, "Your "+provider.DisplayName+" account has no verified email address")
+			return
+		}
+		if errors.Is(err, utils.ErrOIDCInvalidToken) {
+			logger.WarningLogger.Println("Rejected OIDC login:", err)
+			respondError(c, http.StatusUnauthorized, "Login with "+provider.DisplayName+" could not be verified")
+			return
+		}
+		if err != nil {
+			logger.ErrorLogger.Println("Fetching the OAuth profile failed:", err)
+			respondError(c, http.StatusBadGateway, "Login with "+provider.DisplayName+" failed, please try again")
+			return
+		}
+
+		user, err := signInWithProfile(c, db, cfg, provider.Name, profile)
+		if isIdentityNotLinked(err) {
+			logger.WarningLogger.Println("Social registration rejected for email domain:", profile.Email)
+			respondError(c, http.StatusForbidden, "Registration is not allowed for this email domain")
+			return
+		}
+		if isIdentityEmailUnverified(err) {
+			logger.WarningLogger.Printf("%s login with an unverified email refused for %s\n", provider.DisplayName, profile.Email)
+			respondError(c, http.StatusForbidden, "Your "+provider.DisplayName+" email address is not verified")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to log in")
+			return
+		}
+
+		if _, _, err := startSession(c, db, cfg, user); err != nil {
+			respondDBError(c, err, "Failed to start the session")
+			return
+		}
+		logger.InfoLogger.Printf("User %d logged in with %s\n", user.ID, provider.DisplayName)
+		c.Redirect(http.StatusSeeOther, "/api/v1/homepage")
+	}
+}
+
+// signInWithProfile finds or creates the user for an identity verified by
+// an external provider. New users get a random password, which they may
+// replace with "forgot password", and the general role unless the provider
+// decides the role. The provider's role is not applied to existing users,
+// whose role is managed here.
+func signInWithProfile(c *gin.Context, db *db.DB, cfg *config.Config, provider string, profile utils.OAuthProfile) (*models.User, error) {
+	password, _, err := utils.GenerateHashedToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	newUser := &models.User{
+		FirstName: profile.FirstName,
+		LastName:  profile.LastName,
+		Email:     profile.Email,
+		Password:  hashedPassword,
+		Role:      models.UserRoleGeneral,
+	}
+	if profile.Role != "" {
+		newUser.Role = profile.Role
+	}
+
+	provision := utils.IsEmailDomainAllowed(profile.Email, cfg.AllowedEmailDomains)
+	user, created, err := db.SignInWithIdentity(c.Request.Context(), provider, profile.Subject, newUser, profile.EmailVerified, provision)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		logger.InfoLogger.Printf("Registered user %d with %s as %s\n", user.ID, provider, user.Role)
+	}
+	return user, nil
+}
+
+// oauthRedirectURI is the callback URL registered with the provider.
+func oauthRedirectURI(cfg *config.Config, provider *utils.OAuthProvider) string {
+	return cfg.OAuthCallbackBaseURL + "/auth/" + provider.Name + "/callback"
+}
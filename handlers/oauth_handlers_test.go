@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+func TestSignInWithProfileKeepsExistingRole(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	existing := &models.User{ID: 7, Email: "ann@example.com", Role: models.UserRoleGeneral}
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM users").WithArgs("oidc", "subject").WillReturnRows(userRows(existing))
+	mock.ExpectRollback()
+
+	c, _ := newTestContext(http.MethodGet, "/auth/oidc/callback")
+	profile := utils.OAuthProfile{Subject: "subject", Email: "ann@example.com", EmailVerified: true, Role: models.UserRoleAdmin}
+	user, err := signInWithProfile(c, dbConn, &config.Config{}, "oidc", profile)
+	if err != nil {
+		t.Fatalf("signInWithProfile() error = %v", err)
+	}
+	if user.Role != models.UserRoleGeneral {
+		t.Errorf("role = %s, want %s", user.Role, models.UserRoleGeneral)
+	}
+}
+
+func TestSignInWithProfileProvisionsRole(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	created := &models.User{ID: 8, Email: "bob@example.com", Role: models.UserRoleAdmin}
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM users").WithArgs("oidc", "subject").WillReturnRows(sqlmock.NewRows(nil))
+	mock.ExpectQuery("FOR UPDATE").WithArgs("bob@example.com").WillReturnRows(sqlmock.NewRows(nil))
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("", "", "", "bob@example.com", sqlmock.AnyArg(), models.UserRoleAdmin).
+		WillReturnRows(userRows(created))
+	mock.ExpectExec("INSERT INTO user_identities").WithArgs(8, "oidc", "subject").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	c, _ := newTestContext(http.MethodGet, "/auth/oidc/callback")
+	profile := utils.OAuthProfile{Subject: "subject", Email: "bob@example.com", EmailVerified: true, Role: models.UserRoleAdmin}
+	user, err := signInWithProfile(c, dbConn, &config.Config{}, "oidc", profile)
+	if err != nil {
+		t.Fatalf("signInWithProfile() error = %v", err)
+	}
+	if user.Role != models.UserRoleAdmin {
+		t.Errorf("role = %s, want %s", user.Role, models.UserRoleAdmin)
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/vikash-parashar/asset-locator/db"
 	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/middleware"
 	"github.com/vikash-parashar/asset-locator/models"
 
 	"github.com/gin-gonic/gin"
@@ -23,10 +24,11 @@ func GetOwnerDetails(db *db.DB) gin.HandlerFunc {
 		data, err := db.GetAllDeviceAMCOwnerDetail()
 		if err != nil {
 			logger.ErrorLogger.Println(err)
+			respondDBError(c, err, "Failed to fetch data")
 			return
 		}
 		logger.InfoLogger.Println("Owner details fetched successfully.")
-		c.HTML(http.StatusOK, "owner_details.html", data)
+		c.HTML(http.StatusOK, "owner_details.html", gin.H{"data": data, "CSRFToken": middleware.CSRFToken(c)})
 	}
 }
 
@@ -73,13 +75,11 @@ func CreateNewOwnerDetails(db *db.DB) gin.HandlerFunc {
 
 		if err := db.CreateDeviceAMCOwnerDetail(&data); err != nil {
 			logger.ErrorLogger.Println(err)
+			respondDBError(c, err, "Failed to create entry")
 			return
 		}
 		logger.InfoLogger.Println("New owner details created successfully.")
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"message": "Entry Added Successfully"},
-		)
+		respondSuccess(c, http.StatusOK, "Entry Added Successfully", nil)
 	}
 }
 
@@ -92,7 +92,7 @@ func UpdateDeviceAMCOwnerDetail(db *db.DB) gin.HandlerFunc {
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
 			logger.ErrorLogger.Println("Invalid ID:", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			respondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
@@ -110,7 +110,7 @@ func UpdateDeviceAMCOwnerDetail(db *db.DB) gin.HandlerFunc {
 
 		var requestData RequestData
 		if err := c.ShouldBindJSON(&requestData); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+			respondBindError(c, err, "Invalid data")
 			return
 		}
 
@@ -160,12 +160,12 @@ func UpdateDeviceAMCOwnerDetail(db *db.DB) gin.HandlerFunc {
 
 		if err := db.UpdateDeviceAMCOwnerDetail(id, updatedData); err != nil {
 			logger.ErrorLogger.Println("Failed to update Device AMC Owner Detail:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update Device AMC Owner Detail"})
+			respondDBError(c, err, "Failed to update Device AMC Owner Detail")
 			return
 		}
 
 		logger.InfoLogger.Println("Device AMC Owner Detail updated successfully.")
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Device AMC Owner Detail updated successfully"})
+		respondSuccess(c, http.StatusOK, "Device AMC Owner Detail updated successfully", nil)
 	}
 }
 
@@ -178,18 +178,18 @@ func DeleteDeviceAMCOwnerDetail(db *db.DB) gin.HandlerFunc {
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
 			logger.ErrorLogger.Println("Invalid ID:", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			respondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
 		if err := db.DeleteDeviceAMCOwnerDetail(id); err != nil {
 			logger.ErrorLogger.Println("Failed to delete Device AMC Owner Detail:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete Device AMC Owner Detail"})
+			respondDBError(c, err, "Failed to delete Device AMC Owner Detail")
 			return
 		}
 
 		logger.InfoLogger.Println("Device AMC Owner Detail deleted successfully.")
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Device AMC Owner Detail deleted successfully"})
+		respondSuccess(c, http.StatusOK, "Device AMC Owner Detail deleted successfully", nil)
 	}
 }
 
@@ -197,13 +197,11 @@ func DownloadDeviceAMCOwnerDetail(db *db.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.InfoLogger.Println("Downloading Device AMC Owner Detail in Excel format")
 		// Query the database for DeviceAMCOwnerDetail data
-		rows, err := db.Query("SELECT * FROM device_amc_owner")
+		devices, err := db.GetAllDeviceAMCOwnerDetail()
 		if err != nil {
-			log.Fatal(err)
-			http.Error(c.Writer, "Failed to query the database", http.StatusInternalServerError)
+			respondDBError(c, err, "Failed to query the database")
 			return
 		}
-		defer rows.Close()
 
 		// Create a new Excel file
 		file := xlsx.NewFile()
@@ -228,13 +226,7 @@ func DownloadDeviceAMCOwnerDetail(db *db.DB) gin.HandlerFunc {
 		headerRow.AddCell().SetString("Device Owner")
 
 		// Add data rows from the database
-		for rows.Next() {
-			var device models.DeviceAMCOwnerDetail
-			if err := rows.Scan(&device.Id, &device.SerialNumber, &device.DeviceMakeModel, &device.Model, &device.PONumber, &device.POOrderDate, &device.EOSLDate, &device.AMCStartDate, &device.AMCEndDate, &device.DeviceOwner); err != nil {
-				log.Fatal(err)
-				http.Error(c.Writer, "Failed to scan database row", http.StatusInternalServerError)
-				return
-			}
+		for _, device := range devices {
 			dataRow := sheet.AddRow()
 			dataRow.AddCell().SetInt(device.Id)
 			dataRow.AddCell().SetString(device.SerialNumber)
@@ -264,13 +256,11 @@ func DownloadDeviceAMCOwnerDetailPDF(db *db.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.InfoLogger.Println("Downloading Device AMC Owner Detail in PDF format")
 		// Query the database for DeviceAMCOwnerDetail data
-		rows, err := db.Query("SELECT * FROM device_amc_owner")
+		devices, err := db.GetAllDeviceAMCOwnerDetail()
 		if err != nil {
-			log.Fatal(err)
-			http.Error(c.Writer, "Failed to query the database", http.StatusInternalServerError)
+			respondDBError(c, err, "Failed to query the database")
 			return
 		}
-		defer rows.Close()
 
 		// Create a new PDF document
 		pdf := gofpdf.New("P", "mm", "A4", "")
@@ -287,14 +277,7 @@ func DownloadDeviceAMCOwnerDetailPDF(db *db.DB) gin.HandlerFunc {
 		pdf.Ln(-1)
 
 		// Add data rows from the database
-		for rows.Next() {
-			var device models.DeviceAMCOwnerDetail
-			if err := rows.Scan(&device.Id, &device.SerialNumber, &device.DeviceMakeModel, &device.Model, &device.PONumber, &device.POOrderDate, &device.EOSLDate, &device.AMCStartDate, &device.AMCEndDate, &device.DeviceOwner); err != nil {
-				log.Fatal(err)
-				http.Error(c.Writer, "Failed to scan database row", http.StatusInternalServerError)
-				return
-			}
-
+		for _, device := range devices {
 			data := []string{
 				fmt.Sprint(device.Id),
 				device.SerialNumber,
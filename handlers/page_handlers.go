@@ -3,7 +3,10 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/vikash-parashar/asset-locator/config"
 	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,8 +16,34 @@ func HealthCheck(c *gin.Context) {
 	c.HTML(http.StatusOK, "healthcheck.html", nil)
 }
 
-func RenderIndexPage(c *gin.Context) {
-	c.HTML(http.StatusOK, "index.html", nil)
+// Healthz reports whether the server can reach the database, for load
+// balancer and orchestrator probes: 200 {"status":"ok"} or 503.
+func Healthz(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := db.Ping(); err != nil {
+			logger.WarningLogger.Println("Health check failed to ping the database:", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// RenderIndexPage renders the login and signup page, including the CAPTCHA
+// widget when signups are verified.
+func RenderIndexPage(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := gin.H{"CSRFToken": middleware.CSRFToken(c)}
+		if cfg.CaptchaEnabled() && cfg.CaptchaSiteKey != "" {
+			provider := config.CaptchaProviders[cfg.CaptchaProvider]
+			data["Captcha"] = gin.H{
+				"SiteKey":     cfg.CaptchaSiteKey,
+				"ScriptURL":   provider.ScriptURL,
+				"WidgetClass": provider.WidgetClass,
+			}
+		}
+		c.HTML(http.StatusOK, "index.html", data)
+	}
 }
 func RenderForgotPasswordPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "forgot_password.html", nil)
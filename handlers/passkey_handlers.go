@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// passkeyResponse is the JSON form of a PublicKeyCredential, as produced by
+// its toJSON method in the browser. Binary fields are base64url encoded.
+// Fields that are not used are declared so that STRICT_JSON accepts them.
+type passkeyResponse struct {
+	ID       string `json:"id" binding:"required"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+		AttestationObject string `json:"attestationObject"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+
+		Transports         []string `json:"transports"`
+		PublicKey          string   `json:"publicKey"`
+		PublicKeyAlgorithm int      `json:"publicKeyAlgorithm"`
+	} `json:"response"`
+
+	RawID                   string                 `json:"rawId"`
+	Type                    string                 `json:"type"`
+	AuthenticatorAttachment string                 `json:"authenticatorAttachment"`
+	ClientExtensionResults  map[string]interface{} `json:"clientExtensionResults"`
+}
+
+// BeginPasskeyRegistration returns the options for navigator.credentials
+// .create() to register a passkey for the current user.
+func BeginPasskeyRegistration(db *db.DB, cfg *config.Config, challenges *utils.PasskeyChallengeStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		existing, err := db.GetPasskeys(int(user.ID))
+		if err != nil {
+			respondDBError(c, err, "Failed to load passkeys")
+			return
+		}
+		challenge, err := challenges.Create(utils.PasskeyRegistration, int(user.ID))
+		if errors.Is(err, utils.ErrTooManyPasskeyChallenges) {
+			respondPasskeyChallengesFull(c)
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to generate passkey challenge")
+			return
+		}
+
+		// Passkeys already on an authenticator are not registered twice
+		exclude := make([]gin.H, 0, len(existing))
+		for _, passkey := range existing {
+			exclude = append(exclude, gin.H{"type": "public-key", "id": base64.RawURLEncoding.EncodeToString(passkey.CredentialID)})
+		}
+		params := make([]gin.H, 0, len(utils.PasskeyAlgorithms))
+		for _, alg := range utils.PasskeyAlgorithms {
+			params = append(params, gin.H{"type": "public-key", "alg": alg})
+		}
+
+		respondSuccess(c, http.StatusOK, "Passkey registration started", gin.H{
+			"challenge": challenge,
+			"rp":        gin.H{"id": cfg.WebAuthnRPID, "name": cfg.WebAuthnRPName},
+			"user": gin.H{
+				"id":          passkeyUserHandle(int(user.ID)),
+				"name":        user.Email,
+				"displayName": strings.TrimSpace(user.FirstName + " " + user.LastName),
+			},
+			"pubKeyCredParams":   params,
+			"excludeCredentials": exclude,
+			"timeout":            challenges.TTL().Milliseconds(),
+			"attestation":        "none",
+			"authenticatorSelection": gin.H{
+				"residentKey":      "required",
+				"userVerification": "required",
+			},
+		})
+	}
+}
+
+// FinishPasskeyRegistration verifies the credential created for a
+// registration challenge and stores it as a passkey of the current user.
+func FinishPasskeyRegistration(db *db.DB, cfg *config.Config, challenges *utils.PasskeyChallengeStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		var registration struct {
+			passkeyResponse
+			Name string `json:"name" binding:"max=100"`
+		}
+		if err := c.ShouldBindJSON(&registration); err != nil {
+			respondBindError(c, err, "Invalid passkey registration")
+			return
+		}
+		clientData, err1 := decodeBase64URL(registration.Response.ClientDataJSON)
+		attestation, err2 := decodeBase64URL(registration.Response.AttestationObject)
+		if err1 != nil || err2 != nil || len(attestation) == 0 {
+			respondError(c, http.StatusBadRequest, "Invalid passkey registration")
+			return
+		}
+
+		challenge, err := utils.PasskeyChallenge(clientData)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid passkey registration")
+			return
+		}
+		if userID, ok := challenges.Consume(utils.PasskeyRegistration, challenge); !ok || userID != int(user.ID) {
+			respondError(c, http.StatusBadRequest, "Passkey registration has expired, please try again")
+			return
+		}
+
+		credential, err := passkeyRelyingParty(cfg).VerifyRegistration(clientData, attestation, challenge)
+		if err != nil {
+			logger.WarningLogger.Printf("Rejected passkey registration for user %d: %v\n", user.ID, err)
+			respondError(c, http.StatusBadRequest, "The passkey could not be verified")
+			return
+		}
+
+		name := strings.TrimSpace(registration.Name)
+		if name == "" {
+			name = "Passkey"
+		}
+		passkey, err := db.AddPasskey(int(user.ID), name, credential)
+		if isPasskeyExists(err) {
+			respondError(c, http.StatusConflict, "This passkey is already registered")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to save the passkey")
+			return
+		}
+
+		logger.InfoLogger.Printf("User %d registered passkey %d\n", user.ID, passkey.ID)
+		respondSuccess(c, http.StatusCreated, "Passkey registered", passkey)
+	}
+}
+
+// GetPasskeys lists the passkeys of the current user.
+func GetPasskeys(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		passkeys, err := db.GetPasskeys(claims.UserId)
+		if err != nil {
+			respondDBError(c, err, "Failed to load passkeys")
+			return
+		}
+		respondSuccess(c, http.StatusOK, "Passkeys retrieved", passkeys)
+	}
+}
+
+// DeletePasskey removes one of the current user's passkeys.
+func DeletePasskey(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		err = db.DeletePasskey(claims.UserId, id)
+		if isPasskeyNotFound(err) {
+			respondError(c, http.StatusNotFound, "Passkey not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to delete the passkey")
+			return
+		}
+		respondSuccess(c, http.StatusOK, "Passkey deleted", nil)
+	}
+}
+
+// BeginPasskeyLogin returns the options for navigator.credentials.get() to
+// log in with a passkey. No account is named: the authenticator offers the
+// passkeys it holds for this site. Since anyone may start a login, each
+// client IP is limited to cfg.PasskeyLoginsPerMinute and answered 429 beyond
+// that.
+func BeginPasskeyLogin(cfg *config.Config, challenges *utils.PasskeyChallengeStore) gin.HandlerFunc {
+	limiter := utils.NewRateLimiter(cfg.PasskeyLoginsPerMinute, cfg.PasskeyLoginsBurst)
+	return func(c *gin.Context) {
+		if !limiter.Allow(c.ClientIP()) {
+			logger.WarningLogger.Println("Too many passkey logins from", c.ClientIP())
+			c.Header("Retry-After", "60")
+			respondError(c, http.StatusTooManyRequests, "Too many login attempts, please try again later")
+			return
+		}
+
+		challenge, err := challenges.Create(utils.PasskeyLogin, 0)
+		if errors.Is(err, utils.ErrTooManyPasskeyChallenges) {
+			respondPasskeyChallengesFull(c)
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to generate passkey challenge")
+			return
+		}
+		respondSuccess(c, http.StatusOK, "Passkey login started", gin.H{
+			"challenge":        challenge,
+			"rpId":             cfg.WebAuthnRPID,
+			"allowCredentials": []gin.H{},
+			"timeout":          challenges.TTL().Milliseconds(),
+			"userVerification": "required",
+		})
+	}
+}
+
+// FinishPasskeyLogin verifies the answer to a login challenge and logs in
+// the owner of the passkey used, like a login with a password.
+func FinishPasskeyLogin(db *db.DB, cfg *config.Config, challenges *utils.PasskeyChallengeStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var assertion passkeyResponse
+		if err := c.ShouldBindJSON(&assertion); err != nil {
+			respondBindError(c, err, "Invalid passkey login")
+			return
+		}
+		credentialID, err1 := decodeBase64URL(assertion.ID)
+		clientData, err2 := decodeBase64URL(assertion.Response.ClientDataJSON)
+		authData, err3 := decodeBase64URL(assertion.Response.AuthenticatorData)
+		signature, err4 := decodeBase64URL(assertion.Response.Signature)
+		userHandle, err5 := decodeBase64URL(assertion.Response.UserHandle)
+		if err := errors.Join(err1, err2, err3, err4, err5); err != nil || len(signature) == 0 {
+			respondError(c, http.StatusBadRequest, "Invalid passkey login")
+			return
+		}
+
+		challenge, err := utils.PasskeyChallenge(clientData)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid passkey login")
+			return
+		}
+		if _, ok := challenges.Consume(utils.PasskeyLogin, challenge); !ok {
+			respondError(c, http.StatusUnauthorized, "Passkey login has expired, please try again")
+			return
+		}
+
+		passkey, err := db.GetPasskeyByCredentialID(credentialID)
+		if isPasskeyNotFound(err) {
+			respondError(c, http.StatusUnauthorized, "This passkey is not registered")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to log in")
+			return
+		}
+		if len(userHandle) > 0 && string(userHandle) != strconv.Itoa(passkey.UserID) {
+			respondError(c, http.StatusUnauthorized, "The passkey could not be verified")
+			return
+		}
+
+		signCount, err := passkeyRelyingParty(cfg).VerifyAssertion(passkey.PublicKey, clientData, authData, signature, challenge)
+		if err != nil {
+			logger.WarningLogger.Printf("Rejected passkey login for user %d: %v\n", passkey.UserID, err)
+			respondError(c, http.StatusUnauthorized, "The passkey could not be verified")
+			return
+		}
+		err = db.RecordPasskeyUse(passkey.ID, signCount)
+		if isPasskeyCloned(err) {
+			logger.WarningLogger.Printf("Signature counter of passkey %d of user %d did not increase, it may have been cloned\n", passkey.ID, passkey.UserID)
+			respondErrorCode(c, http.StatusUnauthorized, "passkey_cloned", "The passkey could not be verified")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to log in")
+			return
+		}
+
+		user, err := db.GetUserByID(passkey.UserID)
+		if err != nil {
+			respondDBError(c, err, "Failed to log in")
+			return
+		}
+		token, sessionID, err := startSession(c, db, cfg, user)
+		if err != nil {
+			respondDBError(c, err, "Failed to start the session")
+			return
+		}
+		refreshToken, err := issueRefreshToken(c, db, cfg, int(user.ID), sessionID)
+		if err != nil {
+			respondDBError(c, err, "Failed to generate refresh token")
+			return
+		}
+
+		logger.InfoLogger.Printf("User %d logged in with passkey %d\n", user.ID, passkey.ID)
+		data := gin.H{"token": token, "refresh_token": refreshToken}
+		if user.PasswordExpired {
+			data["password_expired"] = true
+		}
+		respondSuccess(c, http.StatusOK, "Login successful", data)
+	}
+}
+
+// passkeyRelyingParty describes this server to authenticators.
+func passkeyRelyingParty(cfg *config.Config) utils.RelyingParty {
+	return utils.RelyingParty{ID: cfg.WebAuthnRPID, Name: cfg.WebAuthnRPName, Origins: cfg.WebAuthnOrigins}
+}
+
+// respondPasskeyChallengesFull answers a passkey registration or login that
+// cannot start because too many are in progress.
+func respondPasskeyChallengesFull(c *gin.Context) {
+	logger.WarningLogger.Println("Passkey challenge store is full")
+	c.Header("Retry-After", "60")
+	respondError(c, http.StatusServiceUnavailable, "Too many passkey logins in progress, please try again later")
+}
+
+// passkeyUserHandle is the opaque id a passkey stores for its user, and
+// returns at login.
+func passkeyUserHandle(userID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(userID)))
+}
+
+// decodeBase64URL decodes a base64url value, with or without padding.
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+func TestBeginPasskeyLoginRateLimited(t *testing.T) {
+	cfg := &config.Config{WebAuthnRPID: "localhost", PasskeyLoginsPerMinute: 1, PasskeyLoginsBurst: 2}
+	r := gin.New()
+	r.POST("/auth/passkey/begin", BeginPasskeyLogin(cfg, utils.NewPasskeyChallengeStore(time.Minute, 100)))
+
+	begin := func(ip string) int {
+		req := httptest.NewRequest(http.MethodPost, "/auth/passkey/begin", nil)
+		req.RemoteAddr = ip + ":1234"
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+	for i := 0; i < 2; i++ {
+		if code := begin("192.0.2.1"); code != http.StatusOK {
+			t.Fatalf("login %d: status = %d, want %d", i+1, code, http.StatusOK)
+		}
+	}
+	if code := begin("192.0.2.1"); code != http.StatusTooManyRequests {
+		t.Errorf("login beyond the burst: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := begin("192.0.2.2"); code != http.StatusOK {
+		t.Errorf("login from another IP: status = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestBeginPasskeyLoginStoreFull(t *testing.T) {
+	cfg := &config.Config{WebAuthnRPID: "localhost", PasskeyLoginsPerMinute: 100, PasskeyLoginsBurst: 100}
+	r := gin.New()
+	r.POST("/auth/passkey/begin", BeginPasskeyLogin(cfg, utils.NewPasskeyChallengeStore(time.Minute, 1)))
+
+	for i, want := range []int{http.StatusOK, http.StatusServiceUnavailable} {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/auth/passkey/begin", nil))
+		if recorder.Code != want {
+			t.Errorf("login %d: status = %d, want %d", i+1, recorder.Code, want)
+		}
+	}
+}
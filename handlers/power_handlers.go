@@ -8,6 +8,7 @@ import (
 
 	"github.com/vikash-parashar/asset-locator/db"
 	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/middleware"
 	"github.com/vikash-parashar/asset-locator/models"
 
 	"github.com/gin-gonic/gin"
@@ -23,9 +24,10 @@ func GetPowerDetails(db *db.DB) gin.HandlerFunc {
 		data, err := db.GetAllDevicePowerDetail()
 		if err != nil {
 			logger.ErrorLogger.Println("Failed to retrieve power details:", err)
+			respondDBError(c, err, "Failed to fetch data")
 			return
 		}
-		c.HTML(http.StatusOK, "power_details.html", data)
+		c.HTML(http.StatusOK, "power_details.html", gin.H{"data": data, "CSRFToken": middleware.CSRFToken(c)})
 	}
 }
 
@@ -67,12 +69,10 @@ func CreateNewPowerDetails(db *db.DB) gin.HandlerFunc {
 
 		if err := db.CreateDevicePowerDetail(&data); err != nil {
 			logger.ErrorLogger.Println("Failed to create new Power Details entry:", err)
+			respondDBError(c, err, "Failed to create entry")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"message": "Entry Added Successfully"},
-		)
+		respondSuccess(c, http.StatusOK, "Entry Added Successfully", nil)
 	}
 }
 
@@ -84,17 +84,17 @@ func DeleteDevicePowerDetail(db *db.DB) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			respondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
 		if err := db.DeleteDevicePowerDetail(id); err != nil {
 			logger.ErrorLogger.Println("Failed to delete Power Details:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete Power Details"})
+			respondDBError(c, err, "Failed to delete Power Details")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Power Details deleted successfully"})
+		respondSuccess(c, http.StatusOK, "Power Details deleted successfully", nil)
 	}
 }
 
@@ -105,7 +105,7 @@ func UpdateDevicePowerDetail(db *db.DB) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			respondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
@@ -122,7 +122,7 @@ func UpdateDevicePowerDetail(db *db.DB) gin.HandlerFunc {
 
 		var requestData RequestData
 		if err := c.ShouldBindJSON(&requestData); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+			respondBindError(c, err, "Invalid data")
 			return
 		}
 
@@ -144,11 +144,11 @@ func UpdateDevicePowerDetail(db *db.DB) gin.HandlerFunc {
 
 		if err := db.UpdateDevicePowerDetail(id, updatedData); err != nil {
 			logger.ErrorLogger.Println("Failed to update Power Details:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update Power Details"})
+			respondDBError(c, err, "Failed to update Power Details")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Power Details updated successfully"})
+		respondSuccess(c, http.StatusOK, "Power Details updated successfully", nil)
 	}
 }
 
@@ -157,13 +157,11 @@ func DownloadDevicePowerDetail(db *db.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.InfoLogger.Println("Handling GET request for downloading Power Details in Excel format")
 
-		rows, err := db.Query("SELECT * FROM device_power")
+		devices, err := db.GetAllDevicePowerDetail()
 		if err != nil {
-			log.Fatal(err)
-			http.Error(c.Writer, "Failed to query the database", http.StatusInternalServerError)
+			respondDBError(c, err, "Failed to query the database")
 			return
 		}
-		defer rows.Close()
 
 		// Create a new Excel file
 		file := xlsx.NewFile()
@@ -187,13 +185,7 @@ func DownloadDevicePowerDetail(db *db.DB) gin.HandlerFunc {
 		headerRow.AddCell().SetString("Power Socket Type")
 
 		// Add data rows from the database
-		for rows.Next() {
-			var device models.DevicePowerDetail
-			if err := rows.Scan(&device.Id, &device.SerialNumber, &device.DeviceMakeModel, &device.Model, &device.DeviceType, &device.TotalPowerWatt, &device.TotalBTU, &device.TotalPowerCable, &device.PowerSocketType); err != nil {
-				log.Fatal(err)
-				http.Error(c.Writer, "Failed to scan database row", http.StatusInternalServerError)
-				return
-			}
+		for _, device := range devices {
 			dataRow := sheet.AddRow()
 			dataRow.AddCell().SetInt(device.Id)
 			dataRow.AddCell().SetString(device.SerialNumber)
@@ -224,13 +216,11 @@ func DownloadDevicePowerDetailPDF(db *db.DB) gin.HandlerFunc {
 		logger.InfoLogger.Println("Handling GET request for downloading Power Details in PDF format")
 
 		// Query the database for DevicePowerDetail data
-		rows, err := db.Query("SELECT * FROM device_power")
+		devices, err := db.GetAllDevicePowerDetail()
 		if err != nil {
-			log.Fatal(err)
-			http.Error(c.Writer, "Failed to query the database", http.StatusInternalServerError)
+			respondDBError(c, err, "Failed to query the database")
 			return
 		}
-		defer rows.Close()
 
 		// Create a new PDF document
 		pdf := gofpdf.New("P", "mm", "A4", "")
@@ -247,14 +237,7 @@ func DownloadDevicePowerDetailPDF(db *db.DB) gin.HandlerFunc {
 		pdf.Ln(-1)
 
 		// Add data rows from the database
-		for rows.Next() {
-			var device models.DevicePowerDetail
-			if err := rows.Scan(&device.Id, &device.SerialNumber, &device.DeviceMakeModel, &device.Model, &device.DeviceType, &device.TotalPowerWatt, &device.TotalBTU, &device.TotalPowerCable, &device.PowerSocketType); err != nil {
-				log.Fatal(err)
-				http.Error(c.Writer, "Failed to scan database row", http.StatusInternalServerError)
-				return
-			}
-
+		for _, device := range devices {
 			data := []string{
 				fmt.Sprint(device.Id),
 				device.SerialNumber,
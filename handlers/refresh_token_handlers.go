@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// refreshTokenCookie holds the refresh token of browser clients. It is
+// HttpOnly and SameSite=Strict, so neither scripts nor other sites see it.
+const refreshTokenCookie = "refresh-token"
+
+// RefreshToken exchanges a refresh token, sent as refresh_token in a JSON
+// body or in the refresh-token cookie, for a short-lived access token and
+// the next refresh token. The presented token is used up; presenting it
+// again signs out every session started from the same login.
+func RefreshToken(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var refreshRequest struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&refreshRequest); err != nil {
+				respondBindError(c, err, "Invalid input data")
+				return
+			}
+		}
+		token := refreshRequest.RefreshToken
+		if token == "" {
+			token, _ = c.Cookie(refreshTokenCookie)
+		}
+		if token == "" {
+			respondError(c, http.StatusUnauthorized, "Missing refresh token")
+			return
+		}
+
+		newToken, newTokenHash, err := utils.GenerateHashedToken()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to generate refresh token")
+			return
+		}
+		userID, sessionID, err := db.RotateRefreshToken(utils.HashToken(token), newTokenHash, cfg.RefreshTokenDuration, cfg.SessionMaxLifetime)
+		if isRefreshTokenReused(err) {
+			clearRefreshTokenCookie(c)
+			respondErrorCode(c, http.StatusUnauthorized, "refresh_token_reused", "This refresh token was already used, please log in again")
+			return
+		}
+		if isRefreshTokenInvalid(err) {
+			clearRefreshTokenCookie(c)
+			respondError(c, http.StatusUnauthorized, "Invalid or expired refresh token")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to refresh the session")
+			return
+		}
+		// Tokens issued before sessions were recorded have none to extend
+		if sessionID != 0 {
+			err := db.ExtendSession(sessionID, time.Now().Add(cfg.RefreshTokenDuration))
+			if isSessionNotFound(err) {
+				clearRefreshTokenCookie(c)
+				respondError(c, http.StatusUnauthorized, "Invalid or expired refresh token")
+				return
+			}
+			if err != nil {
+				respondDBError(c, err, "Failed to refresh the session")
+				return
+			}
+		}
+
+		user, err := db.GetUserByID(userID)
+		if err != nil {
+			respondDBError(c, err, "Failed to refresh the session")
+			return
+		}
+		maxAge := time.Duration(cfg.PasswordMaxAgeDays) * 24 * time.Hour
+		user.PasswordExpired = utils.IsPasswordExpired(user.PasswordChangedAt, maxAge)
+
+		accessToken, err := utils.GenerateJWTToken(user, cfg.AccessTokenDuration, false, sessionID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to generate JWT token")
+			return
+		}
+
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     "jwt-token",
+			Value:    accessToken,
+			Path:     "/",
+			Expires:  time.Now().Add(cfg.AccessTokenDuration),
+			HttpOnly: true,
+		})
+		setRefreshTokenCookie(c, newToken, cfg.RefreshTokenDuration)
+
+		respondSuccess(c, http.StatusOK, "Session refreshed", gin.H{
+			"token":         accessToken,
+			"refresh_token": newToken,
+			"expires_in":    int(cfg.AccessTokenDuration.Seconds()),
+		})
+	}
+}
+
+// issueRefreshToken starts a new refresh token family for a user who just
+// logged in with the given session, which lives on as long as the token,
+// sets its cookie and returns the token.
+func issueRefreshToken(c *gin.Context, db *db.DB, cfg *config.Config, userID, sessionID int) (string, error) {
+	token, tokenHash, err := utils.GenerateHashedToken()
+	if err != nil {
+		return "", err
+	}
+	expiresAt := cfg.RefreshTokenExpiry()
+	if err := db.CreateRefreshToken(userID, tokenHash, expiresAt, sessionID); err != nil {
+		return "", err
+	}
+	if err := db.ExtendSession(sessionID, expiresAt); err != nil {
+		return "", err
+	}
+	setRefreshTokenCookie(c, token, cfg.RefreshTokenDuration)
+	return token, nil
+}
+
+// revokeRefreshTokenCookie revokes the family of the refresh token sent in
+// the refresh-token cookie, if any, and clears the cookie.
+func revokeRefreshTokenCookie(c *gin.Context, db *db.DB) {
+	token, err := c.Cookie(refreshTokenCookie)
+	if err != nil || token == "" {
+		return
+	}
+	if err := db.RevokeRefreshTokenFamily(utils.HashToken(token)); err != nil {
+		logger.ErrorLogger.Println("Failed to revoke refresh token on logout:", err)
+	}
+	clearRefreshTokenCookie(c)
+}
+
+func setRefreshTokenCookie(c *gin.Context, token string, ttl time.Duration) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(ttl),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func clearRefreshTokenCookie(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
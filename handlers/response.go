@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/vikash-parashar/asset-locator/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// envelope is the shape of every JSON response sent by the handlers, so that
+// clients can parse successes and failures the same way.
+type envelope struct {
+	Success bool        `json:"success"`
+	Data    interface{} `json:"data,omitempty"`
+	Message string      `json:"message,omitempty"`
+	// Code is a machine-readable reason attached to some errors.
+	Code string `json:"code,omitempty"`
+}
+
+// respondSuccess writes {"success": true, "data": data, "message": message}.
+func respondSuccess(c *gin.Context, status int, message string, data interface{}) {
+	c.JSON(status, envelope{Success: true, Data: data, Message: message})
+}
+
+// respondError writes {"success": false, "message": message}.
+func respondError(c *gin.Context, status int, message string) {
+	c.JSON(status, envelope{Success: false, Message: message})
+}
+
+// respondErrorData is respondError with data describing the failure, such as
+// a per-row validation report.
+func respondErrorData(c *gin.Context, status int, message string, data interface{}) {
+	c.JSON(status, envelope{Success: false, Data: data, Message: message})
+}
+
+// respondErrorCode is respondError with a machine-readable code, for failures
+// that clients are expected to handle differently.
+func respondErrorCode(c *gin.Context, status int, code, message string) {
+	c.JSON(status, envelope{Success: false, Message: message, Code: code})
+}
+
+// unknownFieldPrefix starts the decoding error for an unexpected JSON field
+// when strict JSON decoding is enabled.
+const unknownFieldPrefix = "json: unknown field "
+
+// respondBindError answers a request body that could not be bound with a
+// 400. An unexpected JSON field is named, with the code unknown_field, so a
+// misspelled key is easy to spot; other errors get message.
+func respondBindError(c *gin.Context, err error, message string) {
+	if field, ok := strings.CutPrefix(err.Error(), unknownFieldPrefix); ok {
+		respondErrorCode(c, http.StatusBadRequest, "unknown_field", "Unknown field "+field)
+		return
+	}
+	respondError(c, http.StatusBadRequest, message)
+}
+
+// isDBUnavailable reports whether err means the database could not be used.
+func isDBUnavailable(err error) bool {
+	return errors.Is(err, db.ErrDatabaseUnavailable)
+}
+
+// respondDBError answers a failed database call: 503 when the database is
+// unavailable, so clients know to retry, and 500 with message otherwise.
+func respondDBError(c *gin.Context, err error, message string) {
+	if isDBUnavailable(err) {
+		respondError(c, http.StatusServiceUnavailable, "The database is unavailable, please try again later")
+		return
+	}
+	respondError(c, http.StatusInternalServerError, message)
+}
+
+// isDeviceNotFound reports whether err means a device does not exist.
+func isDeviceNotFound(err error) bool {
+	return errors.Is(err, db.ErrDeviceNotFound)
+}
+
+// isDeviceNotPending reports whether err means a device is not pending
+// approval.
+func isDeviceNotPending(err error) bool {
+	return errors.Is(err, db.ErrDeviceNotPending)
+}
+
+// isPhoneTaken reports whether err is db.ErrPhoneTaken.
+func isPhoneTaken(err error) bool {
+	return errors.Is(err, db.ErrPhoneTaken)
+}
+
+// isMagicLinkInvalid reports whether err is db.ErrMagicLinkInvalid.
+func isMagicLinkInvalid(err error) bool {
+	return errors.Is(err, db.ErrMagicLinkInvalid)
+}
+
+// isRefreshTokenInvalid reports whether err is db.ErrRefreshTokenInvalid.
+func isRefreshTokenInvalid(err error) bool {
+	return errors.Is(err, db.ErrRefreshTokenInvalid)
+}
+
+// isRefreshTokenReused reports whether err is db.ErrRefreshTokenReused.
+func isRefreshTokenReused(err error) bool {
+	return errors.Is(err, db.ErrRefreshTokenReused)
+}
+
+// isPasskeyNotFound reports whether err is db.ErrPasskeyNotFound.
+func isPasskeyNotFound(err error) bool {
+	return errors.Is(err, db.ErrPasskeyNotFound)
+}
+
+// isPasskeyExists reports whether err is db.ErrPasskeyExists.
+func isPasskeyExists(err error) bool {
+	return errors.Is(err, db.ErrPasskeyExists)
+}
+
+// isPasskeyCloned reports whether err is db.ErrPasskeyCloned.
+func isPasskeyCloned(err error) bool {
+	return errors.Is(err, db.ErrPasskeyCloned)
+}
+
+// isIdentityNotLinked reports whether err is db.ErrIdentityNotLinked.
+func isIdentityNotLinked(err error) bool {
+	return errors.Is(err, db.ErrIdentityNotLinked)
+}
+
+// isIdentityEmailUnverified reports whether err is
+// db.ErrIdentityEmailUnverified.
+func isIdentityEmailUnverified(err error) bool {
+	return errors.Is(err, db.ErrIdentityEmailUnverified)
+}
+
+// isAPIKeyNotFound reports whether err is db.ErrAPIKeyNotFound.
+func isAPIKeyNotFound(err error) bool {
+	return errors.Is(err, db.ErrAPIKeyNotFound)
+}
+
+// isSessionNotFound reports whether err is db.ErrSessionNotFound.
+func isSessionNotFound(err error) bool {
+	return errors.Is(err, db.ErrSessionNotFound)
+}
+
+// isRoleNotFound reports whether err is db.ErrRoleNotFound.
+func isRoleNotFound(err error) bool {
+	return errors.Is(err, db.ErrRoleNotFound)
+}
+
+// isRoleExists reports whether err is db.ErrRoleExists.
+func isRoleExists(err error) bool {
+	return errors.Is(err, db.ErrRoleExists)
+}
+
+// isRoleInUse reports whether err is db.ErrRoleInUse.
+func isRoleInUse(err error) bool {
+	return errors.Is(err, db.ErrRoleInUse)
+}
+
+// isPermissionUnknown reports whether err is db.ErrPermissionUnknown.
+func isPermissionUnknown(err error) bool {
+	return errors.Is(err, db.ErrPermissionUnknown)
+}
+
+// isUserNotFound reports whether err is db.ErrUserNotFound.
+func isUserNotFound(err error) bool {
+	return errors.Is(err, db.ErrUserNotFound)
+}
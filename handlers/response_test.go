@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+)
+
+func TestResponseEnvelope(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(c *gin.Context)
+		want    string
+	}{
+		{
+			name:    "success with data",
+			respond: func(c *gin.Context) { respondSuccess(c, http.StatusOK, "", gin.H{"id": 1}) },
+			want:    `{"success":true,"data":{"id":1}}`,
+		},
+		{
+			name:    "success with message",
+			respond: func(c *gin.Context) { respondSuccess(c, http.StatusCreated, "Created", nil) },
+			want:    `{"success":true,"message":"Created"}`,
+		},
+		{
+			name:    "error",
+			respond: func(c *gin.Context) { respondError(c, http.StatusBadRequest, "Invalid id") },
+			want:    `{"success":false,"message":"Invalid id"}`,
+		},
+		{
+			name:    "error with code",
+			respond: func(c *gin.Context) { respondErrorCode(c, http.StatusUnauthorized, "token_expired", "Token expired") },
+			want:    `{"success":false,"message":"Token expired","code":"token_expired"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, recorder := newTestContext(http.MethodGet, "/")
+			tt.respond(c)
+			if recorder.Body.String() != tt.want {
+				t.Errorf("body = %s, want %s", recorder.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestRespondDBErrorUnavailable(t *testing.T) {
+	c, recorder := newTestContext(http.MethodGet, "/api/v1/locations")
+	GetDeviceLocations(&db.DB{})(c)
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d: %s", recorder.Code, http.StatusServiceUnavailable, recorder.Body)
+	}
+}
+
+func TestStrictJSONNamesUnknownField(t *testing.T) {
+	binding.EnableDecoderDisallowUnknownFields = true
+	t.Cleanup(func() { binding.EnableDecoderDisallowUnknownFields = false })
+
+	dbConn, _ := newMockDB(t)
+	r := gin.New()
+	r.POST("/forgot-password", ForgotPassword(dbConn, &config.Config{}))
+	req := httptest.NewRequest(http.MethodPost, "/forgot-password", strings.NewReader(`{"email":"ann@example.com","emial":"ann@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	want := `{"success":false,"message":"Unknown field \"emial\"","code":"unknown_field"}`
+	if recorder.Code != http.StatusBadRequest || recorder.Body.String() != want {
+		t.Errorf("got %d %s, want 400 %s", recorder.Code, recorder.Body, want)
+	}
+}
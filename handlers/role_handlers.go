@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/authz"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// roleName matches the names new roles may have.
+var roleName = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,49}$`)
+
+// GetRoles lists the roles with the permissions they grant.
+func GetRoles(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles, err := db.GetRoles()
+		if err != nil {
+			respondDBError(c, err, "Failed to load roles")
+			return
+		}
+		respondSuccess(c, http.StatusOK, "Roles retrieved", roles)
+	}
+}
+
+// GetPermissions lists the permissions roles may grant.
+func GetPermissions(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permissions, err := db.GetPermissions()
+		if err != nil {
+			respondDBError(c, err, "Failed to load permissions")
+			return
+		}
+		respondSuccess(c, http.StatusOK, "Permissions retrieved", permissions)
+	}
+}
+
+// CreateRole adds a role, which grants nothing until permissions are set.
+func CreateRole(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Name        string `json:"name" binding:"required"`
+			Description string `json:"description" binding:"max=255"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondBindError(c, err, "Invalid role")
+			return
+		}
+		if !roleName.MatchString(request.Name) {
+			respondError(c, http.StatusBadRequest, "Role names must start with a lowercase letter and contain only lowercase letters, digits, - and _, up to 50 characters")
+			return
+		}
+
+		role, err := db.CreateRole(request.Name, strings.TrimSpace(request.Description))
+		if isRoleExists(err) {
+			respondError(c, http.StatusConflict, "A role with this name already exists")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to create the role")
+			return
+		}
+		logger.InfoLogger.Printf("Created role %s\n", role.Name)
+		respondSuccess(c, http.StatusCreated, "Role created", role)
+	}
+}
+
+// SetRolePermissions replaces the permissions a role grants, e.g.
+// PUT /api/v1/roles/auditor/permissions with
+// {"permissions": ["location:read", "owner:read"]}.
+func SetRolePermissions(db *db.DB, policy *authz.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if name == models.UserRoleAdmin {
+			respondError(c, http.StatusBadRequest, "The admin role always has every permission")
+			return
+		}
+
+		var request struct {
+			Permissions []string `json:"permissions" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondBindError(c, err, "Invalid permissions")
+			return
+		}
+
+		err := db.SetRolePermissions(name, request.Permissions)
+		if isRoleNotFound(err) {
+			respondError(c, http.StatusNotFound, "Role not found")
+			return
+		}
+		if isPermissionUnknown(err) {
+			respondError(c, http.StatusBadRequest, "Unknown permission, see GET /api/v1/permissions")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to update the role")
+			return
+		}
+		if err := policy.Reload(); err != nil {
+			logger.ErrorLogger.Printf("Failed to reload permissions: %v\n", err)
+		}
+
+		role, err := db.GetRole(name)
+		if err != nil {
+			respondDBError(c, err, "Failed to load the role")
+			return
+		}
+		logger.InfoLogger.Printf("Set the permissions of role %s to %v\n", name, role.Permissions)
+		respondSuccess(c, http.StatusOK, "Role updated", role)
+	}
+}
+
+// DeleteRole removes a role no user has. The built-in roles cannot be
+// removed.
+func DeleteRole(db *db.DB, policy *authz.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if name == models.UserRoleAdmin || name == models.UserRoleGeneral {
+			respondError(c, http.StatusBadRequest, "Built-in roles cannot be deleted")
+			return
+		}
+
+		err := db.DeleteRole(name)
+		if isRoleNotFound(err) {
+			respondError(c, http.StatusNotFound, "Role not found")
+			return
+		}
+		if isRoleInUse(err) {
+			respondError(c, http.StatusConflict, "The role is still assigned to users")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to delete the role")
+			return
+		}
+		if err := policy.Reload(); err != nil {
+			logger.ErrorLogger.Printf("Failed to reload permissions: %v\n", err)
+		}
+		logger.InfoLogger.Printf("Deleted role %s\n", name)
+		respondSuccess(c, http.StatusOK, "Role deleted", nil)
+	}
+}
+
+// SetUserRole assigns a role to a user, signing them out everywhere so
+// that their new permissions apply. Admins cannot change their own role,
+// so that the last admin cannot be demoted by accident.
+func SetUserRole(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		if id == claims.UserId {
+			respondError(c, http.StatusBadRequest, "You cannot change your own role")
+			return
+		}
+
+		var request struct {
+			Role string `json:"role" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondBindError(c, err, "Invalid role")
+			return
+		}
+
+		_, err = db.SetUserRole(id, request.Role)
+		if isUserNotFound(err) {
+			respondError(c, http.StatusNotFound, "User not found")
+			return
+		}
+		if isRoleNotFound(err) {
+			respondError(c, http.StatusBadRequest, "Role not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to change the role")
+			return
+		}
+		logger.InfoLogger.Printf("User %d set the role of user %d to %s\n", claims.UserId, id, request.Role)
+		respondSuccess(c, http.StatusOK, "Role changed", gin.H{"id": id, "role": request.Role})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// samlRequestCookie carries the id of a SAML authentication request from
+// the start of the login to the response posted to the ACS.
+const samlRequestCookie = "saml-request"
+
+// SAMLMetadata serves the service provider metadata to register with the
+// identity provider, at GET /saml/metadata.
+func SAMLMetadata(sp *utils.SAMLServiceProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		metadata, err := sp.Metadata()
+		if err != nil {
+			logger.ErrorLogger.Println("Failed to build SAML metadata:", err)
+			respondError(c, http.StatusInternalServerError, "Failed to build SAML metadata")
+			return
+		}
+		c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+	}
+}
+
+// SAMLLogin starts a SAML login by redirecting to the identity provider,
+// at GET /saml/login.
+func SAMLLogin(cfg *config.Config, sp *utils.SAMLServiceProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		redirectURL, requestID, err := sp.AuthnRequestURL(c.Request.Context())
+		if err != nil {
+			logger.ErrorLogger.Println("Failed to start SAML login:", err)
+			respondError(c, http.StatusBadGateway, "Login with "+cfg.SAMLDisplayName+" is unavailable, please try again later")
+			return
+		}
+
+		// The IdP posts the response cross-site, which only sends cookies
+		// marked SameSite=None, and those must be Secure.
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     samlRequestCookie,
+			Value:    requestID,
+			Path:     "/saml",
+			MaxAge:   10 * 60,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteNoneMode,
+		})
+		c.Redirect(http.StatusFound, redirectURL)
+	}
+}
+
+// SAMLACS is the assertion consumer service the identity provider posts its
+// response to, at POST /saml/acs. Only responses to a login started here are
+// accepted, not IdP-initiated ones. The user is found or created like for
+// an OAuth login.
+func SAMLACS(db *db.DB, cfg *config.Config, sp *utils.SAMLServiceProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID, _ := c.Cookie(samlRequestCookie)
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     samlRequestCookie,
+			Value:    "",
+			Path:     "/saml",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteNoneMode,
+		})
+		if requestID == "" {
+			respondError(c, http.StatusBadRequest, "This login has expired, please try again")
+			return
+		}
+		if err := c.Request.ParseForm(); err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid SAML response")
+			return
+		}
+
+		profile, err := sp.ParseResponse(c.Request, requestID)
+		if errors.Is(err, utils.ErrOAuthNoVerifiedEmail) {
+			respondError(c, http.StatusForbidden, "Your "+cfg.SAMLDisplayName+" account has no email address")
+			return
+		}
+		if errors.Is(err, utils.ErrSAMLInvalidResponse) {
+			logger.WarningLogger.Println("Rejected SAML login:", err)
+			respondError(c, http.StatusUnauthorized, "Login with "+cfg.SAMLDisplayName+" could not be verified")
+			return
+		}
+		if err != nil {
+			logger.ErrorLogger.Println("SAML login failed:", err)
+			respondError(c, http.StatusBadGateway, "Login with "+cfg.SAMLDisplayName+" failed, please try again")
+			return
+		}
+
+		user, err := signInWithProfile(c, db, cfg, "saml", profile)
+		if isIdentityNotLinked(err) {
+			logger.WarningLogger.Println("SAML registration rejected for email domain:", profile.Email)
+			respondError(c, http.StatusForbidden, "Registration is not allowed for this email domain")
+			return
+		}
+		if isIdentityEmailUnverified(err) {
+			logger.WarningLogger.Printf("%s login with an unverified email refused for %s\n", cfg.SAMLDisplayName, profile.Email)
+			respondError(c, http.StatusForbidden, "Your "+cfg.SAMLDisplayName+" email address is not verified")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to log in")
+			return
+		}
+
+		if _, _, err := startSession(c, db, cfg, user); err != nil {
+			respondDBError(c, err, "Failed to start the session")
+			return
+		}
+		logger.InfoLogger.Printf("User %d logged in with %s\n", user.ID, cfg.SAMLDisplayName)
+		c.Redirect(http.StatusSeeOther, "/api/v1/homepage")
+	}
+}
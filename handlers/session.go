@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+// startSession logs in a user who signed in without a password form, with
+// a magic link, passkey or identity provider: it records the session,
+// issues a normal-length JWT for it, sets that as the session cookie and
+// returns it with the session id.
+func startSession(c *gin.Context, db *db.DB, cfg *config.Config, user *models.User) (string, int, error) {
+	maxAge := time.Duration(cfg.PasswordMaxAgeDays) * 24 * time.Hour
+	user.PasswordExpired = utils.IsPasswordExpired(user.PasswordChangedAt, maxAge)
+
+	sessionDuration := cfg.LoginSessionDuration(false)
+	sessionID, err := recordSession(c, db, user, sessionDuration)
+	if err != nil {
+		return "", 0, err
+	}
+	token, err := utils.GenerateJWTToken(user, sessionDuration, false, sessionID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// The path is explicit, since the cookie would otherwise only be sent
+	// back to /auth.
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "jwt-token",
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(sessionDuration),
+		HttpOnly: true,
+	})
+	return token, sessionID, nil
+}
+
+// recordSession records a login of a user from this client, valid for ttl,
+// and returns the session id. The user is alerted if the client is new.
+func recordSession(c *gin.Context, db *db.DB, user *models.User, ttl time.Duration) (int, error) {
+	userAgent := c.Request.UserAgent()
+	session := &models.Session{
+		UserID:    int(user.ID),
+		Device:    utils.DeviceName(userAgent),
+		IP:        c.ClientIP(),
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := db.CreateSession(session); err != nil {
+		return 0, err
+	}
+	alertNewDevice(db, user, session)
+	return session.ID, nil
+}
+
+// alertNewDevice emails a user about session if none of their other
+// sessions is from the same device and IP, unless they turned login alerts
+// off. A first login is not reported. The login goes ahead if the alert
+// cannot be sent.
+func alertNewDevice(db *db.DB, user *models.User, session *models.Session) {
+	preferences, err := db.GetNotificationPreferences(session.UserID)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to load the notification preferences of user %d: %v\n", session.UserID, err)
+		return
+	}
+	if !preferences.LoginAlerts {
+		return
+	}
+	isNew, err := db.IsNewDevice(session)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to check session %d for a new device: %v\n", session.ID, err)
+		return
+	}
+	if isNew {
+		if err := utils.QueueLoginAlertEmail(user.Email, session.Device, session.IP, session.CreatedAt); err != nil {
+			logger.ErrorLogger.Printf("Failed to queue the login alert for user %d: %v\n", session.UserID, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+// GetSessions lists the live sessions of the current user, marking the one
+// the request was made with.
+func GetSessions(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		sessions, err := db.GetSessions(claims.UserId)
+		if err != nil {
+			respondDBError(c, err, "Failed to load sessions")
+			return
+		}
+		for _, session := range sessions {
+			session.Current = session.ID == claims.SessionID
+		}
+		respondSuccess(c, http.StatusOK, "Sessions retrieved", sessions)
+	}
+}
+
+// RevokeSession signs the current user out of one of their sessions, e.g.
+// DELETE /api/v1/me/sessions/7. Its tokens stop being accepted at once.
+func RevokeSession(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		err = db.RevokeSession(claims.UserId, id)
+		if isSessionNotFound(err) {
+			respondError(c, http.StatusNotFound, "Session not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to revoke the session")
+			return
+		}
+		logger.InfoLogger.Printf("User %d revoked session %d\n", claims.UserId, id)
+		respondSuccess(c, http.StatusOK, "Session revoked", nil)
+	}
+}
+
+// RevokeOtherSessions signs the current user out of every session but the
+// one the request was made with.
+func RevokeOtherSessions(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		revoked, err := db.RevokeSessions(claims.UserId, claims.SessionID)
+		if err != nil {
+			respondDBError(c, err, "Failed to revoke sessions")
+			return
+		}
+		logger.InfoLogger.Printf("User %d revoked %d other sessions\n", claims.UserId, revoked)
+		respondSuccess(c, http.StatusOK, "Other sessions revoked", gin.H{"revoked": revoked})
+	}
+}
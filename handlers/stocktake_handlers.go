@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetStocktake lists devices for a physical inventory count, e.g.
+// GET /api/v1/devices/stocktake?overdue=true&location=IDC1, 2nd Floor.
+// With overdue=true only devices not verified within the configured
+// stocktake interval are returned.
+func GetStocktake(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.InfoLogger.Println("Handling GET request for stocktake devices")
+
+		overdue := false
+		if raw := c.Query("overdue"); raw != "" {
+			value, err := strconv.ParseBool(raw)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, "Invalid overdue flag, expected true or false")
+				return
+			}
+			overdue = value
+		}
+
+		var auditedBefore *time.Time
+		if overdue {
+			cutoff := time.Now().AddDate(0, 0, -cfg.StocktakeIntervalDays)
+			auditedBefore = &cutoff
+		}
+
+		devices, err := db.GetStocktakeDevices(c.Query("location"), auditedBefore)
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch stocktake devices")
+			return
+		}
+
+		respondSuccess(c, http.StatusOK, "", gin.H{"devices": devices, "interval_days": cfg.StocktakeIntervalDays})
+	}
+}
+
+// VerifyDevice marks a device as physically verified now, e.g.
+// POST /api/v1/devices/:serial/verify.
+func VerifyDevice(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serial := c.Param("serial")
+		logger.InfoLogger.Println("Verifying device:", serial)
+
+		found, err := db.MarkDeviceAudited(serial)
+		if err != nil {
+			respondDBError(c, err, "Failed to verify device")
+			return
+		}
+		if !found {
+			respondError(c, http.StatusNotFound, "Device not found")
+			return
+		}
+
+		respondSuccess(c, http.StatusOK, "Device verified successfully", nil)
+	}
+}
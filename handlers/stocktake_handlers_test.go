@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+func TestGetStocktakeOverdue(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	audited := time.Now().AddDate(0, -3, 0)
+	mock.ExpectQuery(`WHERE device_location = \$1 AND \(last_audited_at IS NULL OR last_audited_at < \$2\) ORDER BY last_audited_at ASC NULLS FIRST`).
+		WithArgs("IDC1, 2nd Floor", sqlmock.AnyArg()).
+		WillReturnRows(deviceRows(
+			models.DeviceLocationDetail{Id: 1, SerialNumber: "SN-1"},
+			models.DeviceLocationDetail{Id: 2, SerialNumber: "SN-2", LastAuditedAt: &audited},
+		))
+
+	c, recorder := newTestContext(http.MethodGet, "/api/v1/devices/stocktake?overdue=true&location="+url.QueryEscape("IDC1, 2nd Floor"))
+	GetStocktake(dbConn, &config.Config{StocktakeIntervalDays: 30})(c)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+	var response struct {
+		Data struct {
+			Devices      []models.DeviceLocationDetail `json:"devices"`
+			IntervalDays int                           `json:"interval_days"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Data.Devices) != 2 || response.Data.IntervalDays != 30 {
+		t.Errorf("response = %s, want 2 devices and a 30 day interval", recorder.Body)
+	}
+}
+
+func TestGetStocktakeInvalidOverdue(t *testing.T) {
+	dbConn, _ := newMockDB(t)
+	c, recorder := newTestContext(http.MethodGet, "/api/v1/devices/stocktake?overdue=maybe")
+	GetStocktake(dbConn, &config.Config{StocktakeIntervalDays: 30})(c)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
+
+func TestVerifyDevice(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		want     int
+	}{
+		{"found", 1, http.StatusOK},
+		{"not found", 0, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbConn, mock := newMockDB(t)
+			mock.ExpectExec("SET last_audited_at = NOW()").WithArgs("SN-1").WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			c, recorder := newTestContext(http.MethodPost, "/api/v1/devices/SN-1/verify")
+			c.Params = gin.Params{{Key: "serial", Value: "SN-1"}}
+			VerifyDevice(dbConn)(c)
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
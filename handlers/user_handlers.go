@@ -3,11 +3,17 @@
 package handlers
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/vikash-parashar/asset-locator/config"
 	"github.com/vikash-parashar/asset-locator/db"
 	"github.com/vikash-parashar/asset-locator/logger"
 	"github.com/vikash-parashar/asset-locator/models"
@@ -16,8 +22,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// SignUp handles the registration of a new user.
-func SignUp(db *db.DB) gin.HandlerFunc {
+// SignUp handles the registration of a new user, whose password must meet
+// policy.
+func SignUp(db *db.DB, cfg *config.Config, policy *utils.PasswordPolicy) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.InfoLogger.Println("Handling POST request for user registration")
 
@@ -27,30 +34,59 @@ func SignUp(db *db.DB) gin.HandlerFunc {
 			Phone     string `json:"phone" binding:"required"`
 			Email     string `json:"email" binding:"required"`
 			Password  string `json:"password" binding:"required"`
+			// CaptchaToken is required when CAPTCHA verification is enabled.
+			CaptchaToken string `json:"captcha_token"`
 		}
 
 		if err := c.ShouldBindJSON(&signupRequest); err != nil {
 			logger.ErrorLogger.Println("Invalid form data for user registration:", err)
-			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid form data"})
+			respondBindError(c, err, "Invalid form data")
+			return
+		}
+
+		// Checked before the CAPTCHA, whose token can only be used once
+		err := policy.Validate(signupRequest.Password, signupRequest.Email, signupRequest.FirstName, signupRequest.LastName)
+		if err != nil {
+			respondPasswordPolicyError(c, err)
+			return
+		}
+
+		if cfg.CaptchaEnabled() {
+			err := utils.VerifyCaptcha(c.Request.Context(), cfg.CaptchaVerifyURL, cfg.CaptchaSecret, signupRequest.CaptchaToken, c.ClientIP())
+			if errors.Is(err, utils.ErrCaptchaMissing) || errors.Is(err, utils.ErrCaptchaInvalid) {
+				logger.WarningLogger.Println("Registration rejected by CAPTCHA check:", err)
+				respondError(c, http.StatusBadRequest, "CAPTCHA verification failed, please try again")
+				return
+			}
+			if err != nil {
+				logger.ErrorLogger.Println("CAPTCHA verification unavailable:", err)
+				respondError(c, http.StatusServiceUnavailable, "CAPTCHA verification is unavailable, please try again later")
+				return
+			}
+		}
+
+		if !utils.IsEmailDomainAllowed(signupRequest.Email, cfg.AllowedEmailDomains) {
+			logger.WarningLogger.Println("Registration rejected for email domain:", signupRequest.Email)
+			respondError(c, http.StatusForbidden, "Registration is not allowed for this email domain")
 			return
 		}
 
 		// Check if the user already exists (by email or any other unique identifier)
-		_, err := db.GetUserByEmailID(signupRequest.Email)
+		_, err = db.GetUserByEmailID(signupRequest.Email)
 		if err == nil {
-			c.JSON(http.StatusConflict, gin.H{"success": false, "message": "User with this email already exists"})
+			respondError(c, http.StatusConflict, "User with this email already exists")
 			return
 		}
 		// Create a new user
 		newUser := &models.User{
 			FirstName: signupRequest.FirstName,
 			LastName:  signupRequest.LastName,
-			Phone:     signupRequest.Phone,
+			Phone:     utils.NormalizePhone(signupRequest.Phone),
 			Email:     signupRequest.Email,
 			Password:  signupRequest.Password,
 		}
 
-		if newUser.Email == "gowithvikash@gmail.com" {
+		if newUser.Email == "gowithvikash@gmail.com" && !cfg.FirstUserIsAdmin {
 			newUser.Role = "admin"
 		} else {
 			newUser.Role = "general"
@@ -59,78 +95,244 @@ func SignUp(db *db.DB) gin.HandlerFunc {
 		hashedPassword, err := utils.HashPassword(newUser.Password)
 		if err != nil {
 			log.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to hash password"})
+			respondError(c, http.StatusInternalServerError, "Failed to hash password")
 			return
 		}
 		newUser.Password = hashedPassword
 
-		if err := db.RegisterUser(newUser); err != nil {
+		if cfg.FirstUserIsAdmin {
+			err = db.RegisterFirstUserAsAdmin(c.Request.Context(), newUser)
+		} else {
+			err = db.RegisterUser(newUser)
+		}
+		if err != nil {
 			logger.ErrorLogger.Println("Failed to create user:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to create user"})
+			if isPhoneTaken(err) {
+				respondError(c, http.StatusConflict, "User with this phone number already exists")
+				return
+			}
+			respondDBError(c, err, "Failed to create user")
 			return
 		}
 
 		logger.InfoLogger.Println("User registered successfully")
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "User registered successfully"})
+		respondSuccess(c, http.StatusOK, "User registered successfully", nil)
+	}
+}
+
+// CheckEmailAvailability tells a registration form whether an email is
+// still free, e.g. GET /auth/available?email=jane@example.com. Since it
+// reveals which emails have accounts, each client IP is limited to
+// cfg.AvailabilityChecksPerMinute checks and answered 429 beyond that.
+func CheckEmailAvailability(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	limiter := utils.NewRateLimiter(cfg.AvailabilityChecksPerMinute, cfg.AvailabilityChecksBurst)
+	return func(c *gin.Context) {
+		if !limiter.Allow(c.ClientIP()) {
+			logger.WarningLogger.Println("Too many email availability checks from", c.ClientIP())
+			c.Header("Retry-After", "60")
+			respondError(c, http.StatusTooManyRequests, "Too many checks, please try again later")
+			return
+		}
+
+		email := strings.ToLower(strings.TrimSpace(c.Query("email")))
+		if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+			respondError(c, http.StatusBadRequest, "A valid email address is required")
+			return
+		}
+
+		existing, err := db.GetExistingEmails([]string{email})
+		if err != nil {
+			respondDBError(c, err, "Failed to check the email address")
+			return
+		}
+		respondSuccess(c, http.StatusOK, "Email availability checked", gin.H{
+			"email":     email,
+			"available": !existing[email],
+		})
 	}
 }
 
 // Login handles the user login and returns a JWT token upon successful login.
-func Login(db *db.DB) gin.HandlerFunc {
+// When the password is older than the configured maximum age the login still
+// succeeds, but the response and token are flagged so the user is forced to
+// change it before normal use.
+func Login(db *db.DB, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.InfoLogger.Println("Handling POST request for user login")
 
 		var loginRequest struct {
 			Email    string `form:"email" binding:"required"`
 			Password string `form:"password" binding:"required"`
+			Remember bool   `form:"remember"`
 		}
 
 		if err := c.ShouldBind(&loginRequest); err != nil {
 			logger.ErrorLogger.Println("Invalid form data for user login:", err)
-			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid form data"})
+			respondBindError(c, err, "Invalid form data")
 			return
 		}
 
-		fmt.Println(loginRequest)
-
-		// Check if the user exists in the database
+		// Check if the user exists in the database. An unknown email still
+		// costs a bcrypt comparison and gets the same message as a wrong
+		// password, so neither timing nor wording reveals which accounts exist.
 		user, err := db.GetUserByEmailID(loginRequest.Email)
+		if isDBUnavailable(err) {
+			respondDBError(c, err, "")
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Incorrect email or password"})
+			utils.VerifyDummyPassword(loginRequest.Password)
+			respondError(c, http.StatusUnauthorized, "Incorrect email or password")
+			return
+		}
 
+		// Locked accounts are refused without checking the password, so
+		// that guessing cannot go on during the lockout
+		if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+			respondAccountLocked(c, *user.LockedUntil)
 			return
 		}
 
 		// Verify the password
 		if !utils.VerifyPassword(loginRequest.Password, user.Password) {
-			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Incorrect password"})
+			if cfg.LoginLockoutThreshold > 0 {
+				lockedUntil, err := db.RecordFailedLogin(int(user.ID), cfg.LoginLockoutThreshold, cfg.LoginLockoutDuration, cfg.LoginLockoutMaxDuration)
+				if err != nil {
+					respondDBError(c, err, "Failed to log in")
+					return
+				}
+				if lockedUntil.After(time.Now()) {
+					logger.WarningLogger.Printf("Locked user %d until %s after repeated failed logins\n", user.ID, lockedUntil.Format(time.RFC3339))
+					respondAccountLocked(c, lockedUntil)
+					return
+				}
+			}
+			respondError(c, http.StatusUnauthorized, "Incorrect email or password")
+			return
+		}
+		if err := db.ResetFailedLogins(int(user.ID)); err != nil {
+			respondDBError(c, err, "Failed to log in")
 			return
 		}
 
-		// Generate a JWT token
-		token, err := utils.GenerateJWTToken(user)
+		maxAge := time.Duration(cfg.PasswordMaxAgeDays) * 24 * time.Hour
+		user.PasswordExpired = utils.IsPasswordExpired(user.PasswordChangedAt, maxAge)
+
+		// Generate a JWT token, long-lived when "remember me" was checked
+		sessionDuration := cfg.LoginSessionDuration(loginRequest.Remember)
+		sessionID, err := recordSession(c, db, user, sessionDuration)
+		if err != nil {
+			respondDBError(c, err, "Failed to start the session")
+			return
+		}
+		token, err := utils.GenerateJWTToken(user, sessionDuration, loginRequest.Remember, sessionID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to generate JWT token"})
+			respondError(c, http.StatusInternalServerError, "Failed to generate JWT token")
 			return
 		}
 
 		cookie := http.Cookie{
 			Name:    "jwt-token",
 			Value:   token,
-			Expires: time.Now().Add(60 * time.Minute),
+			Expires: time.Now().Add(sessionDuration),
 		}
 		http.SetCookie(c.Writer, &cookie)
 
+		// The refresh token lets API clients keep going with short-lived
+		// access tokens from /auth/refresh
+		refreshToken, err := issueRefreshToken(c, db, cfg, int(user.ID), sessionID)
+		if err != nil {
+			respondDBError(c, err, "Failed to generate refresh token")
+			return
+		}
+
+		if user.PasswordExpired {
+			logger.WarningLogger.Printf("User %s logged in with an expired password\n", user.Email)
+			respondSuccess(c, http.StatusOK, "Login successful, but your password has expired and must be changed", gin.H{"token": token, "refresh_token": refreshToken, "password_expired": true})
+			return
+		}
+
 		logger.InfoLogger.Println("User logged in successfully")
-		c.JSON(http.StatusOK, gin.H{"success": true, "token": token, "message": "Login successful"})
+		respondSuccess(c, http.StatusOK, "Login successful", gin.H{"token": token, "refresh_token": refreshToken})
 	}
 }
 
-// Logout handles the user logout by clearing the JWT token cookie.
-func Logout() gin.HandlerFunc {
+// respondAccountLocked answers a login to an account locked until
+// lockedUntil with 423 and the time to wait.
+func respondAccountLocked(c *gin.Context, lockedUntil time.Time) {
+	wait := time.Until(lockedUntil).Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())))
+	respondErrorCode(c, http.StatusLocked, "account_locked", fmt.Sprintf("Too many failed logins, your account is locked for %s", wait))
+}
+
+// GetUserSecurity shows the failed logins and lockout of a user, e.g. GET
+// /api/v1/users/7/security.
+func GetUserSecurity(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		security, err := db.GetUserSecurity(id)
+		if isUserNotFound(err) {
+			respondError(c, http.StatusNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to load the user's login security")
+			return
+		}
+		respondSuccess(c, http.StatusOK, "", security)
+	}
+}
+
+// UnlockUser lifts the login lockout of a user, e.g. POST
+// /api/v1/users/7/unlock. The unlock is recorded in the audit log.
+func UnlockUser(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		err = db.UnlockUser(claims.UserId, id)
+		if isUserNotFound(err) {
+			respondError(c, http.StatusNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to unlock the user")
+			return
+		}
+		logger.InfoLogger.Printf("User %d unlocked user %d\n", claims.UserId, id)
+		respondSuccess(c, http.StatusOK, "User unlocked", nil)
+	}
+}
+
+// Logout handles the user logout by clearing the JWT token cookie and
+// revoking the session and refresh token of this client.
+func Logout(db *db.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.InfoLogger.Println("Handling GET request for user logout")
 
+		revokeRefreshTokenCookie(c, db)
+		if claims, err := utils.VerifyJWTToken(utils.CookieToken(c.Request)); err == nil && claims.SessionID != 0 {
+			if err := db.RevokeSession(claims.UserId, claims.SessionID); err != nil && !isSessionNotFound(err) {
+				logger.ErrorLogger.Println("Failed to revoke the session on logout:", err)
+			}
+		}
+
 		// Clear the JWT token cookie by setting its expiration to a past time
 		cookie := http.Cookie{
 			Name:     "jwt-token",
@@ -144,12 +346,97 @@ func Logout() gin.HandlerFunc {
 		http.SetCookie(c.Writer, &cookie)
 		c.Redirect(http.StatusPermanentRedirect, "/")
 		logger.InfoLogger.Println("User logged out successfully")
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Logout successful"})
+		respondSuccess(c, http.StatusOK, "Logout successful", nil)
+	}
+}
+
+// ValidateToken checks the JWT sent as a Bearer header or cookie, for
+// gateways such as nginx auth_request. A valid token gets an empty 200 with
+// the user in the X-User-Id, X-User-Email and X-User-Role headers; anything
+// else gets an empty 401, or 403 while the user's password has expired.
+func ValidateToken(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := utils.TokenFromRequest(c.Request)
+		if token == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := utils.VerifyJWTToken(token)
+		if err != nil {
+			logger.InfoLogger.Println("Rejected token on validation:", err)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		version, err := db.GetTokenVersion(claims.UserId)
+		if isDBUnavailable(err) {
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil || claims.TokenVersion < version {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if claims.SessionID != 0 {
+			live, err := db.TouchSession(claims.SessionID, c.ClientIP())
+			if err != nil {
+				c.AbortWithStatus(http.StatusServiceUnavailable)
+				return
+			}
+			if !live {
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+		}
+		if claims.PasswordExpired {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		c.Header("X-User-Id", strconv.Itoa(claims.UserId))
+		c.Header("X-User-Email", claims.UserEmail)
+		c.Header("X-User-Role", claims.UserRole)
+		c.Status(http.StatusOK)
+	}
+}
+
+// LogoutAll signs the current user out everywhere by bumping their token
+// version, which revokes every token issued so far, revoking their sessions
+// and refresh tokens, and clears the session cookies of this client.
+func LogoutAll(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		if _, err := db.IncrementTokenVersion(claims.UserId); err != nil {
+			respondDBError(c, err, "Failed to sign out")
+			return
+		}
+		if _, err := db.RevokeSessions(claims.UserId, 0); err != nil {
+			respondDBError(c, err, "Failed to sign out")
+			return
+		}
+		clearRefreshTokenCookie(c)
+
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     "jwt-token",
+			Value:    "",
+			Expires:  time.Unix(0, 0),
+			Path:     "/",
+			SameSite: http.SameSiteNoneMode,
+			HttpOnly: true,
+			Secure:   true,
+		})
+		logger.InfoLogger.Printf("User %s signed out everywhere\n", claims.UserEmail)
+		respondSuccess(c, http.StatusOK, "Signed out of all sessions", nil)
 	}
 }
 
 // ForgotPassword handles the process of resetting a user's forgotten password.
-func ForgotPassword(db *db.DB) gin.HandlerFunc {
+func ForgotPassword(db *db.DB, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.InfoLogger.Println("Handling POST request for password reset")
 
@@ -158,135 +445,454 @@ func ForgotPassword(db *db.DB) gin.HandlerFunc {
 			Email string `json:"email" binding:"required"`
 		}
 		if err := c.ShouldBindJSON(&resetRequest); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid input data"})
+			respondBindError(c, err, "Invalid input data")
 			return
 		}
 
 		// Check if the user exists in the database
 		user, err := db.GetUserByEmailID(resetRequest.Email)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "User not found"})
+			respondError(c, http.StatusNotFound, "User not found")
+			return
+		}
+
+		// Limit reset requests per account. Beyond the limit the request looks
+		// successful, but no new token is generated or sent, so a token that
+		// was already sent stays valid until it expires.
+		allowed, err := db.RecordResetRequest(int(user.ID), cfg.ResetRequestsPerWindow, cfg.ResetRequestWindow)
+		if err != nil {
+			respondDBError(c, err, "Failed to process reset request")
+			return
+		}
+		if !allowed {
+			logger.WarningLogger.Printf("Throttled password reset request for user %d, more than %d within %s\n", user.ID, cfg.ResetRequestsPerWindow, cfg.ResetRequestWindow)
+			respondSuccess(c, http.StatusOK, "Reset instructions sent to your email", nil)
 			return
 		}
 
 		// Generate a unique reset token and set an expiration time for it (e.g., 1 hour)
 		resetToken, err := utils.GeneratePasswordResetToken(user)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to generate reset token"})
+			respondError(c, http.StatusInternalServerError, "Failed to generate reset token")
 			return
 		}
 
 		expiryTime := time.Now().Add(1 * time.Hour)
 		// Save the reset token in the database associated with the user's account
 		if err := db.SetResetToken(int(user.ID), resetToken, expiryTime); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to save reset token"})
+			respondDBError(c, err, "Failed to save reset token")
 			return
 		}
 
-		// Send an email to the user with the reset URL
-		err = utils.SendResetPasswordEmail(user.Email, resetToken)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to send reset email"})
-			return
+		// Queue an email to the user with the reset URL. Delivery is retried in
+		// the background and the token stays valid for its lifetime, so an
+		// unreachable mail server does not fail the request.
+		if err := utils.QueueResetPasswordEmail(user.Email, resetToken); err != nil {
+			logger.ErrorLogger.Println("Failed to queue reset email:", err)
 		}
 
-		logger.InfoLogger.Println("Password reset instructions sent successfully")
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Reset instructions sent to your email"})
+		logger.InfoLogger.Println("Password reset instructions queued successfully")
+		respondSuccess(c, http.StatusOK, "Reset instructions sent to your email", nil)
 	}
 }
 
-func ResetPassword(db *db.DB) gin.HandlerFunc {
+// resetSessionCookie holds the id of the reset session started by opening
+// an emailed reset link.
+const resetSessionCookie = "reset-session"
+
+// ResetPassword sets a new password. API clients pass the reset token as
+// ?token=; the reset page instead relies on the reset session started by
+// RenderResetPasswordPage and echoes the session's CSRF token in the
+// X-CSRF-Token header or csrf_token form field. A session is ended by a
+// successful reset, so its form cannot be submitted twice. The user is
+// signed out everywhere.
+func ResetPassword(db *db.DB, cfg *config.Config, sessions *utils.ResetSessionStore, policy *utils.PasswordPolicy) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.InfoLogger.Println("Handling POST request for resetting password")
+		c.Header("Referrer-Policy", "no-referrer")
 
 		resetToken := c.Query("token")
-
+		sessionID := ""
 		if resetToken == "" {
-			logger.ErrorLogger.Println("Reset token is missing")
-			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Reset token is missing"})
-			return
+			id, _ := c.Cookie(resetSessionCookie)
+			session, ok := sessions.Get(id)
+			if !ok {
+				logger.ErrorLogger.Println("Reset token is missing")
+				respondError(c, http.StatusBadRequest, "Reset token is missing or the reset link has expired")
+				return
+			}
+			submitted := c.GetHeader("X-CSRF-Token")
+			if submitted == "" {
+				submitted = c.PostForm("csrf_token")
+			}
+			if subtle.ConstantTimeCompare([]byte(submitted), []byte(session.CSRFToken)) != 1 {
+				logger.WarningLogger.Println("Missing or invalid CSRF token for the reset session")
+				respondError(c, http.StatusForbidden, "Missing or invalid CSRF token")
+				return
+			}
+			resetToken, sessionID = session.Token, id
 		}
 
-		log.Println(resetToken)
 		// Parse the new password from the request body
 		var resetRequest struct {
 			NewPassword string `json:"new_password" binding:"required"`
 		}
 		if err := c.ShouldBindJSON(&resetRequest); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid input data"})
+			respondBindError(c, err, "Invalid input data")
 			return
 		}
 
 		// Verify the reset token
 		user, err := db.VerifyResetToken(resetToken)
+		if isDBUnavailable(err) {
+			respondDBError(c, err, "")
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Invalid or expired reset token"})
+			endResetSession(c, sessions, sessionID)
+			respondError(c, http.StatusUnauthorized, "Invalid or expired reset token")
+			return
+		}
+
+		// Going back to a recent, possibly compromised, password is not a reset
+		reused, err := reusesPassword(db, user, resetRequest.NewPassword, cfg.PasswordHistorySize)
+		if err != nil {
+			respondDBError(c, err, "Failed to check the password history")
+			return
+		}
+		if reused {
+			respondPasswordReused(c, cfg.PasswordHistorySize)
+			return
+		}
+		if err := policy.Validate(resetRequest.NewPassword, user.Email, user.FirstName, user.LastName); err != nil {
+			respondPasswordPolicyError(c, err)
 			return
 		}
 
 		// Hash the new password
 		hashedPassword, err := utils.HashPassword(resetRequest.NewPassword)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to hash the new password"})
+			respondError(c, http.StatusInternalServerError, "Failed to hash the new password")
 			return
 		}
 
 		// Update the user's password in the database
-		if err := db.UpdateUserPassword(int(user.ID), hashedPassword); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to update the password"})
+		if err := db.UpdateUserPassword(int(user.ID), hashedPassword, cfg.PasswordHistorySize); err != nil {
+			respondDBError(c, err, "Failed to update the password")
 			return
 		}
 
 		// Clear the reset token from the database
 		if err := db.ClearResetToken(int(user.ID)); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to clear the reset token"})
+			respondDBError(c, err, "Failed to clear the reset token")
 			return
 		}
 
+		// Whoever knew the old password may hold a session
+		if _, err := db.IncrementTokenVersion(int(user.ID)); err != nil {
+			respondDBError(c, err, "Failed to sign out other sessions")
+			return
+		}
+		if _, err := db.RevokeSessions(int(user.ID), 0); err != nil {
+			respondDBError(c, err, "Failed to sign out other sessions")
+			return
+		}
+
+		endResetSession(c, sessions, sessionID)
 		logger.InfoLogger.Println("Password reset successful")
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Password reset successful"})
+		respondSuccess(c, http.StatusOK, "Password reset successful", nil)
 	}
 }
 
-func GetCurrentUser(db *db.DB) gin.HandlerFunc {
+// ChangePassword replaces the current user's password, given the current
+// one. Every other session is signed out; this client gets a new session.
+func ChangePassword(db *db.DB, cfg *config.Config, policy *utils.PasswordPolicy) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		logger.InfoLogger.Println("Handling GET request for current user details")
+		user, ok := currentUser(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
 
-		// Retrieve the JWT token from the cookie
-		cookie, err := c.Request.Cookie("jwt-token")
+		var request struct {
+			CurrentPassword string `json:"current_password" binding:"required"`
+			NewPassword     string `json:"new_password" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondBindError(c, err, "Invalid input data")
+			return
+		}
+		if !utils.VerifyPassword(request.CurrentPassword, user.Password) {
+			respondError(c, http.StatusForbidden, "Current password is incorrect")
+			return
+		}
+		reused, err := reusesPassword(db, user, request.NewPassword, cfg.PasswordHistorySize)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
-			c.Abort()
+			respondDBError(c, err, "Failed to check the password history")
+			return
+		}
+		if reused {
+			respondPasswordReused(c, cfg.PasswordHistorySize)
+			return
+		}
+		if err := policy.Validate(request.NewPassword, user.Email, user.FirstName, user.LastName); err != nil {
+			respondPasswordPolicyError(c, err)
 			return
 		}
 
-		token := cookie.Value
+		hashedPassword, err := utils.HashPassword(request.NewPassword)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to hash the new password")
+			return
+		}
+		if err := db.UpdateUserPassword(int(user.ID), hashedPassword, cfg.PasswordHistorySize); err != nil {
+			respondDBError(c, err, "Failed to update the password")
+			return
+		}
 
-		claims, valid := utils.VerifyJWTToken(token)
-		if !valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
-			c.Abort()
+		// Whoever knew the old password may hold a session
+		version, err := db.IncrementTokenVersion(int(user.ID))
+		if err != nil {
+			respondDBError(c, err, "Failed to sign out other sessions")
+			return
+		}
+		if _, err := db.RevokeSessions(int(user.ID), 0); err != nil {
+			respondDBError(c, err, "Failed to sign out other sessions")
+			return
+		}
+		user.TokenVersion, user.PasswordChangedAt = version, time.Now()
+		token, sessionID, err := startSession(c, db, cfg, user)
+		if err != nil {
+			respondDBError(c, err, "Failed to start the session")
+			return
+		}
+		refreshToken, err := issueRefreshToken(c, db, cfg, int(user.ID), sessionID)
+		if err != nil {
+			respondDBError(c, err, "Failed to generate refresh token")
 			return
 		}
 
-		// Extract the user email from the claims
-		userEmail := claims.UserEmail
+		logger.InfoLogger.Printf("User %d changed their password\n", user.ID)
+		respondSuccess(c, http.StatusOK, "Password changed", gin.H{"token": token, "refresh_token": refreshToken})
+	}
+}
 
-		// Retrieve the user based on the user email from the database
-		user, err := db.GetUserByEmailID(userEmail)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving user"})
-			c.Abort()
+// reusesPassword reports whether password is the user's current one or one
+// of the historySize-1 before it.
+func reusesPassword(db *db.DB, user *models.User, password string, historySize int) (bool, error) {
+	if utils.VerifyPassword(password, user.Password) {
+		return true, nil
+	}
+	if historySize <= 1 {
+		return false, nil
+	}
+	hashes, err := db.GetPasswordHistory(int(user.ID), historySize-1)
+	if err != nil {
+		return false, err
+	}
+	for _, hash := range hashes {
+		if utils.VerifyPassword(password, hash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// respondPasswordReused answers a new password that reuses a recent one.
+func respondPasswordReused(c *gin.Context, historySize int) {
+	if historySize <= 1 {
+		respondError(c, http.StatusBadRequest, "New password must be different from the current password")
+		return
+	}
+	respondError(c, http.StatusBadRequest, fmt.Sprintf("New password must be different from your last %d passwords", historySize))
+}
+
+// respondPasswordPolicyError answers a password refused by the policy with
+// 400 and the policy's violations.
+func respondPasswordPolicyError(c *gin.Context, err error) {
+	var policyErr *utils.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		respondError(c, http.StatusBadRequest, "Invalid password")
+		return
+	}
+	respondErrorData(c, http.StatusBadRequest, "The password "+strings.Join(policyErr.Violations, ", "), gin.H{"violations": policyErr.Violations})
+}
+
+// endResetSession forgets the reset session with the given id, if any, and
+// clears its cookie.
+func endResetSession(c *gin.Context, sessions *utils.ResetSessionStore, id string) {
+	if id == "" {
+		return
+	}
+	sessions.Delete(id)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     resetSessionCookie,
+		Value:    "",
+		Path:     "/reset-password",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func GetCurrentUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.InfoLogger.Println("Handling GET request for current user details")
+
+		current, ok := currentUser(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
 			return
 		}
 
+		// Never hand out the password hash or reset token
+		user := *current
+		user.Password = ""
+		user.ResetToken = ""
+
 		// Send the user information in the response
 		logger.InfoLogger.Println("Current user details retrieved successfully")
-		c.JSON(http.StatusOK, gin.H{"user": user})
+		respondSuccess(c, http.StatusOK, "", gin.H{"user": user})
 	}
 }
 
-func RenderResetPasswordPage(c *gin.Context) {
-	logger.InfoLogger.Println("Rendering reset password page")
-	c.HTML(http.StatusOK, "reset_password.html", gin.H{})
+// UpdateProfile changes the name and phone number of the current user, e.g.
+// PUT /api/v1/me/profile {"first_name": "Jane", "last_name": "Doe",
+// "phone": "+44 20 7946 0958"}. A phone number taken by another user is
+// rejected with a 409 when unique phone numbers are enforced.
+func UpdateProfile(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		var request struct {
+			FirstName string `json:"first_name" binding:"required"`
+			LastName  string `json:"last_name" binding:"required"`
+			Phone     string `json:"phone" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondBindError(c, err, "Invalid profile")
+			return
+		}
+
+		err := db.UpdateProfile(claims.UserId, request.FirstName, request.LastName, utils.NormalizePhone(request.Phone))
+		if isPhoneTaken(err) {
+			respondError(c, http.StatusConflict, "User with this phone number already exists")
+			return
+		}
+		if isUserNotFound(err) {
+			respondError(c, http.StatusNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to update profile")
+			return
+		}
+		logger.InfoLogger.Printf("User %d updated their profile\n", claims.UserId)
+		respondSuccess(c, http.StatusOK, "Profile updated", nil)
+	}
+}
+
+// GetUsersByIDs returns the users for a comma separated list of ids in a
+// single query, e.g. GET /api/v1/users?ids=1,2,3, along with the ids that
+// were not found.
+func GetUsersByIDs(db *db.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.InfoLogger.Println("Handling GET request for users by ids")
+
+		var ids []int
+		seen := make(map[int]bool)
+		for _, raw := range strings.Split(c.Query("ids"), ",") {
+			raw = strings.TrimSpace(raw)
+			if raw == "" {
+				continue
+			}
+			id, err := strconv.Atoi(raw)
+			if err != nil || id <= 0 {
+				respondError(c, http.StatusBadRequest, "Invalid user id: "+raw)
+				return
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+
+		if len(ids) == 0 {
+			respondError(c, http.StatusBadRequest, "At least one user id is required")
+			return
+		}
+		if len(ids) > cfg.UsersBatchMaxIDs {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("At most %d ids may be requested at once", cfg.UsersBatchMaxIDs))
+			return
+		}
+
+		users, err := db.GetUsersByIDs(ids)
+		if err != nil {
+			respondDBError(c, err, "Error retrieving users")
+			return
+		}
+
+		found := make(map[int]bool, len(users))
+		for _, user := range users {
+			found[int(user.ID)] = true
+		}
+		missing := make([]int, 0)
+		for _, id := range ids {
+			if !found[id] {
+				missing = append(missing, id)
+			}
+		}
+
+		respondSuccess(c, http.StatusOK, "", gin.H{"users": users, "missing_ids": missing})
+	}
+}
+
+// RenderResetPasswordPage serves the page opened by an emailed reset link.
+// A request with ?token= verifies the token, keeps it in a reset session
+// and redirects to the same page without it, so the token does not stay in
+// the address bar, history or Referer headers. The page is then rendered
+// with the session's CSRF token for the form.
+func RenderResetPasswordPage(db *db.DB, sessions *utils.ResetSessionStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.InfoLogger.Println("Rendering reset password page")
+		c.Header("Referrer-Policy", "no-referrer")
+		c.Header("Cache-Control", "no-store")
+
+		if token := c.Query("token"); token != "" {
+			if _, err := db.VerifyResetToken(token); err != nil {
+				if isDBUnavailable(err) {
+					respondDBError(c, err, "")
+					return
+				}
+				c.HTML(http.StatusOK, "reset_password.html", gin.H{"Error": "This reset link is invalid or has expired. Please request a new one."})
+				return
+			}
+			id, _, err := sessions.Create(token)
+			if err != nil {
+				respondError(c, http.StatusInternalServerError, "Failed to start the password reset")
+				return
+			}
+			http.SetCookie(c.Writer, &http.Cookie{
+				Name:     resetSessionCookie,
+				Value:    id,
+				Path:     "/reset-password",
+				MaxAge:   int(sessions.TTL().Seconds()),
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+			c.Redirect(http.StatusSeeOther, "/reset-password")
+			return
+		}
+
+		id, _ := c.Cookie(resetSessionCookie)
+		session, ok := sessions.Get(id)
+		if !ok {
+			c.HTML(http.StatusOK, "reset_password.html", gin.H{"Error": "This reset link is invalid or has expired. Please request a new one."})
+			return
+		}
+		c.HTML(http.StatusOK, "reset_password.html", gin.H{"CSRFToken": session.CSRFToken})
+	}
 }
@@ -0,0 +1,634 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/vikash-parashar/asset-locator/config"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/middleware"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+func TestResetPasswordSignsOutEverywhere(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	hash, err := utils.HashPassword("old password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{ID: 7, Email: "ann@example.com", Password: hash, Role: models.UserRoleGeneral, ResetTokenExpiry: time.Now().Add(time.Hour)}
+
+	mock.ExpectQuery("WHERE reset_token = ").WithArgs("reset-token").WillReturnRows(userRows(user))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT password FROM users").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(hash))
+	mock.ExpectExec("DELETE FROM password_history").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE users").WithArgs(7, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SET reset_token = NULL").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("token_version = token_version \\+ 1").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(1))
+	mock.ExpectExec("UPDATE sessions").WithArgs(7, 0).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE refresh_tokens").WithArgs(7, 0).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	cfg := &config.Config{PasswordHistorySize: 1}
+	r := gin.New()
+	r.POST("/reset-password", ResetPassword(dbConn, cfg, utils.NewResetSessionStore(time.Minute), utils.NewPasswordPolicy(8, nil, 0, nil)))
+
+	req := httptest.NewRequest(http.MethodPost, "/reset-password?token=reset-token", strings.NewReader(`{"new_password":"a much newer passphrase"}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+}
+
+func TestSignUpPhoneTaken(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("WHERE email = ").WithArgs("bob@example.com").WillReturnRows(sqlmock.NewRows(nil))
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("Bob", "Ray", "+14155550100", "bob@example.com", sqlmock.AnyArg(), models.UserRoleGeneral).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "users_phone_key"})
+
+	r := gin.New()
+	r.POST("/signup", SignUp(dbConn, &config.Config{}, utils.NewPasswordPolicy(8, nil, 0, nil)))
+	body := `{"first_name":"Bob","last_name":"Ray","phone":"+1 (415) 555-0100","email":"bob@example.com","password":"a much newer passphrase"}`
+	req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d: %s", recorder.Code, http.StatusConflict, recorder.Body)
+	}
+}
+
+func TestLoginPasswordExpiry(t *testing.T) {
+	utils.SetSecretKey("test-secret")
+	hash, err := utils.HashPassword("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		changedAt time.Time
+		expired   bool
+	}{
+		{"not expired", time.Now().AddDate(0, 0, -10), false},
+		{"expired", time.Now().AddDate(0, 0, -100), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbConn, mock := newMockDB(t)
+			user := &models.User{ID: 7, Email: "ann@example.com", Password: hash, Role: models.UserRoleGeneral, PasswordChangedAt: tt.changedAt}
+			mock.ExpectQuery("WHERE email = ").WithArgs("ann@example.com").WillReturnRows(userRows(user))
+			mock.ExpectExec("SET failed_logins = 0").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("INSERT INTO sessions").WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "last_seen_at"}).AddRow(3, time.Now(), time.Now()))
+			mock.ExpectExec("DELETE FROM sessions").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("SELECT notification_preferences").WillReturnRows(sqlmock.NewRows([]string{"notification_preferences"}).AddRow(`{"login_alerts":false}`))
+			mock.ExpectExec("INSERT INTO refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("DELETE FROM refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("UPDATE sessions").WillReturnResult(sqlmock.NewResult(0, 1))
+
+			cfg := &config.Config{PasswordMaxAgeDays: 90, SessionDuration: time.Hour, SessionMaxLifetime: time.Hour, RefreshTokenDuration: time.Hour}
+			r := gin.New()
+			r.POST("/login", Login(dbConn, cfg))
+			req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("email=ann@example.com&password=correct+horse+battery"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+			}
+			var response struct {
+				Data struct {
+					Token           string `json:"token"`
+					PasswordExpired bool   `json:"password_expired"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Data.PasswordExpired != tt.expired {
+				t.Errorf("password_expired = %v, want %v", response.Data.PasswordExpired, tt.expired)
+			}
+			claims, err := utils.VerifyJWTToken(response.Data.Token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.PasswordExpired != tt.expired {
+				t.Errorf("token password_expired = %v, want %v", claims.PasswordExpired, tt.expired)
+			}
+		})
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"saved", nil, http.StatusOK},
+		{"phone taken", &pq.Error{Code: "23505", Constraint: "users_phone_key"}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbConn, mock := newMockDB(t)
+			update := mock.ExpectExec("UPDATE users").WithArgs(7, "Ann", "Lee", "+442079460958")
+			if tt.err != nil {
+				update.WillReturnError(tt.err)
+			} else {
+				update.WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			r := gin.New()
+			r.PUT("/me/profile", func(c *gin.Context) {
+				c.Set(middleware.ClaimsKey, utils.Claims{UserId: 7})
+			}, UpdateProfile(dbConn))
+			body := `{"first_name":"Ann","last_name":"Lee","phone":"0044 20 7946 0958"}`
+			req := httptest.NewRequest(http.MethodPut, "/me/profile", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestSignUpEmailDomainNotAllowed(t *testing.T) {
+	dbConn, _ := newMockDB(t)
+	r := gin.New()
+	r.POST("/signup", SignUp(dbConn, &config.Config{AllowedEmailDomains: []string{"example.com"}}, utils.NewPasswordPolicy(8, nil, 0, nil)))
+	body := `{"first_name":"Bob","last_name":"Ray","phone":"+14155550100","email":"bob@other.org","password":"a much newer passphrase"}`
+	req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d: %s", recorder.Code, http.StatusForbidden, recorder.Body)
+	}
+}
+
+func TestGetUsersByIDs(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("WHERE id = ANY").WithArgs("{3,1,2}").WillReturnRows(userRows(
+		&models.User{ID: 1, Email: "ann@example.com", Password: "hash", Role: models.UserRoleGeneral},
+		&models.User{ID: 3, Email: "bob@example.com", Password: "hash", Role: models.UserRoleAdmin},
+	))
+
+	c, recorder := newTestContext(http.MethodGet, "/api/v1/users?ids=3,1,3,+2")
+	GetUsersByIDs(dbConn, &config.Config{UsersBatchMaxIDs: 3})(c)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+	var response struct {
+		Data struct {
+			Users []struct {
+				ID       int    `json:"id"`
+				Password string `json:"password"`
+			} `json:"users"`
+			MissingIDs []int `json:"missing_ids"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Data.Users) != 2 || response.Data.Users[0].ID != 1 || response.Data.Users[1].ID != 3 {
+		t.Errorf("users = %+v, want users 1 and 3", response.Data.Users)
+	}
+	for _, user := range response.Data.Users {
+		if user.Password != "" {
+			t.Errorf("user %d has its password hash in the response", user.ID)
+		}
+	}
+	if len(response.Data.MissingIDs) != 1 || response.Data.MissingIDs[0] != 2 {
+		t.Errorf("missing_ids = %v, want [2]", response.Data.MissingIDs)
+	}
+}
+
+func TestGetUsersByIDsRejectsInvalidIDs(t *testing.T) {
+	for _, ids := range []string{"", "1,x", "0", "1,2,3,4"} {
+		t.Run(ids, func(t *testing.T) {
+			dbConn, _ := newMockDB(t)
+			c, recorder := newTestContext(http.MethodGet, "/api/v1/users?ids="+ids)
+			GetUsersByIDs(dbConn, &config.Config{UsersBatchMaxIDs: 3})(c)
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestResetPasswordRejectsCurrentPassword(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	hash, err := utils.HashPassword("the current passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{ID: 7, Email: "ann@example.com", Password: hash, Role: models.UserRoleGeneral, ResetTokenExpiry: time.Now().Add(time.Hour)}
+	mock.ExpectQuery("WHERE reset_token = ").WithArgs("reset-token").WillReturnRows(userRows(user))
+
+	r := gin.New()
+	r.POST("/reset-password", ResetPassword(dbConn, &config.Config{PasswordHistorySize: 1}, utils.NewResetSessionStore(time.Minute), utils.NewPasswordPolicy(8, nil, 0, nil)))
+	req := httptest.NewRequest(http.MethodPost, "/reset-password?token=reset-token", strings.NewReader(`{"new_password":"the current passphrase"}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "different from the current password") {
+		t.Errorf("got %d %s, want 400 asking for a different password", recorder.Code, recorder.Body)
+	}
+}
+
+func TestLoginUnknownEmail(t *testing.T) {
+	hash, err := utils.HashPassword("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("WHERE email = ").WithArgs("nobody@example.com").WillReturnRows(userRows())
+	mock.ExpectQuery("WHERE email = ").WithArgs("ann@example.com").WillReturnRows(userRows(&models.User{ID: 7, Email: "ann@example.com", Password: hash}))
+
+	r := gin.New()
+	r.POST("/login", Login(dbConn, &config.Config{}))
+	var bodies []string
+	for _, email := range []string{"nobody@example.com", "ann@example.com"} {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("email="+email+"&password=wrong+password"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want %d", email, recorder.Code, http.StatusUnauthorized)
+		}
+		bodies = append(bodies, recorder.Body.String())
+	}
+	if bodies[0] != bodies[1] {
+		t.Errorf("an unknown email is answered %s, a wrong password %s", bodies[0], bodies[1])
+	}
+}
+
+func TestUnlockUserAllowsNextLogin(t *testing.T) {
+	utils.SetSecretKey("test-secret")
+	hash, err := utils.HashPassword("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockedUntil := time.Now().Add(time.Hour)
+	dbConn, mock := newMockDB(t)
+
+	r := gin.New()
+	r.POST("/login", Login(dbConn, &config.Config{SessionDuration: time.Hour, SessionMaxLifetime: time.Hour, RefreshTokenDuration: time.Hour}))
+	admin := r.Group("/users/:id", func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, utils.Claims{UserId: 1, UserRole: models.UserRoleAdmin})
+	})
+	admin.GET("/security", GetUserSecurity(dbConn))
+	admin.POST("/unlock", UnlockUser(dbConn))
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		return recorder
+	}
+	login := "email=ann@example.com&password=correct+horse+battery"
+
+	// The locked account is refused even with the right password
+	mock.ExpectQuery("WHERE email = ").WithArgs("ann@example.com").
+		WillReturnRows(userRows(&models.User{ID: 7, Email: "ann@example.com", Password: hash, LockedUntil: &lockedUntil}))
+	if recorder := serve(http.MethodPost, "/login", login); recorder.Code != http.StatusLocked {
+		t.Fatalf("locked login: status = %d, want %d: %s", recorder.Code, http.StatusLocked, recorder.Body)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT failed_logins FROM users").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"failed_logins"}).AddRow(5))
+	mock.ExpectExec("SET failed_logins = 0, locked_until = NULL").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_log").WithArgs(1, 7, models.AuditUserUnlocked, "5 failed logins cleared").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if recorder := serve(http.MethodPost, "/users/7/unlock", ""); recorder.Code != http.StatusOK {
+		t.Fatalf("unlock: status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+
+	mock.ExpectQuery("SELECT failed_logins, locked_until").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"failed_logins", "locked_until", "locked"}).AddRow(0, nil, false))
+	recorder := serve(http.MethodGet, "/users/7/security", "")
+	var response struct {
+		Data models.UserSecurity `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Data.FailedLogins != 0 || response.Data.Locked || response.Data.LockedUntil != nil {
+		t.Errorf("security after unlock = %+v, want no failed logins and no lock", response.Data)
+	}
+
+	mock.ExpectQuery("WHERE email = ").WithArgs("ann@example.com").
+		WillReturnRows(userRows(&models.User{ID: 7, Email: "ann@example.com", Password: hash, Role: models.UserRoleGeneral, PasswordChangedAt: time.Now()}))
+	mock.ExpectExec("SET failed_logins = 0").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("INSERT INTO sessions").WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "last_seen_at"}).AddRow(3, time.Now(), time.Now()))
+	mock.ExpectExec("DELETE FROM sessions").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT notification_preferences").WillReturnRows(sqlmock.NewRows([]string{"notification_preferences"}).AddRow(`{"login_alerts":false}`))
+	mock.ExpectExec("INSERT INTO refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE sessions").WillReturnResult(sqlmock.NewResult(0, 1))
+	if recorder := serve(http.MethodPost, "/login", login); recorder.Code != http.StatusOK {
+		t.Errorf("login after unlock: status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+}
+
+func TestUnlockUnknownUser(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT failed_logins FROM users").WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"failed_logins"}))
+	mock.ExpectRollback()
+
+	r := gin.New()
+	r.POST("/users/:id/unlock", func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, utils.Claims{UserId: 1, UserRole: models.UserRoleAdmin})
+	}, UnlockUser(dbConn))
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/users/9/unlock", nil))
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d: %s", recorder.Code, http.StatusNotFound, recorder.Body)
+	}
+}
+
+func TestLoginLogsNoCredentials(t *testing.T) {
+	var logged bytes.Buffer
+	for _, l := range []*log.Logger{logger.InfoLogger, logger.WarningLogger, logger.ErrorLogger} {
+		previous := l.Writer()
+		l.SetOutput(&logged)
+		t.Cleanup(func() { l.SetOutput(previous) })
+	}
+	hash, err := utils.HashPassword("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("WHERE email = ").WithArgs("ann@example.com").WillReturnRows(userRows(&models.User{ID: 7, Email: "ann@example.com", Password: hash}))
+
+	r := gin.New()
+	r.POST("/login", Login(dbConn, &config.Config{}))
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("email=ann@example.com&password=wrong+password"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	for _, secret := range []string{"wrong password", hash, "ann@example.com"} {
+		if strings.Contains(logged.String(), secret) {
+			t.Errorf("log contains %q:\n%s", secret, logged.String())
+		}
+	}
+}
+
+func TestSignUpRequiresCaptcha(t *testing.T) {
+	dbConn, _ := newMockDB(t)
+	cfg := &config.Config{CaptchaProvider: "turnstile", CaptchaSecret: "secret", CaptchaVerifyURL: "http://127.0.0.1:1/siteverify"}
+	r := gin.New()
+	r.POST("/signup", SignUp(dbConn, cfg, utils.NewPasswordPolicy(8, nil, 0, nil)))
+	body := `{"first_name":"Bob","last_name":"Ray","phone":"+14155550100","email":"bob@example.com","password":"a much newer passphrase"}`
+	req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "CAPTCHA") {
+		t.Errorf("got %d %s, want 400 for the missing CAPTCHA", recorder.Code, recorder.Body)
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	utils.SetSecretKey("test-secret")
+	token, err := utils.GenerateJWTToken(&models.User{ID: 7, Email: "ann@example.com", Role: "admin", TokenVersion: 2}, time.Hour, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		cookie     string
+		version    int
+		wantStatus int
+	}{
+		{"bearer header", "Bearer " + token, "", 2, http.StatusOK},
+		{"cookie", "", token, 2, http.StatusOK},
+		{"revoked", "Bearer " + token, "", 3, http.StatusUnauthorized},
+		{"invalid", "Bearer not-a-token", "", -1, http.StatusUnauthorized},
+		{"missing", "", "", -1, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbConn, mock := newMockDB(t)
+			if tt.version >= 0 {
+				mock.ExpectQuery("SELECT token_version FROM users").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(tt.version))
+			}
+
+			c, recorder := newTestContext(http.MethodGet, "/auth/validate")
+			if tt.header != "" {
+				c.Request.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				c.Request.AddCookie(&http.Cookie{Name: "jwt-token", Value: tt.cookie})
+			}
+			ValidateToken(dbConn)(c)
+			c.Writer.WriteHeaderNow()
+
+			if recorder.Code != tt.wantStatus || recorder.Body.Len() != 0 {
+				t.Fatalf("got %d %q, want an empty %d", recorder.Code, recorder.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if id := recorder.Header().Get("X-User-Id"); id != "" {
+					t.Errorf("X-User-Id = %q on a rejected token", id)
+				}
+				return
+			}
+			for header, want := range map[string]string{"X-User-Id": "7", "X-User-Email": "ann@example.com", "X-User-Role": "admin"} {
+				if got := recorder.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestForgotPasswordPerAccountLimit(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	cfg := &config.Config{ResetRequestsPerWindow: 3, ResetRequestWindow: time.Hour}
+	user := &models.User{ID: 7, Email: "ann@example.com"}
+	for count := 1; count <= 4; count++ {
+		mock.ExpectQuery("FROM users").WithArgs(user.Email).WillReturnRows(userRows(user))
+		mock.ExpectQuery("SET reset_request_count").WithArgs(7, time.Hour.Seconds()).WillReturnRows(sqlmock.NewRows([]string{"reset_request_count"}).AddRow(count))
+		// Only requests within the limit regenerate the token.
+		if count <= 3 {
+			mock.ExpectExec("SET reset_token").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+	}
+
+	r := gin.New()
+	r.POST("/forgot-password", ForgotPassword(dbConn, cfg))
+	for count := 1; count <= 4; count++ {
+		req := httptest.NewRequest(http.MethodPost, "/forgot-password", strings.NewReader(`{"email":"ann@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "Reset instructions sent") {
+			t.Errorf("request %d: got %d %s, want the generic success message", count, recorder.Code, recorder.Body)
+		}
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("UPDATE users SET token_version = token_version \\+ 1").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(3))
+	mock.ExpectExec("UPDATE sessions").WithArgs(7, 0).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE refresh_tokens").WithArgs(7, 0).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	c, recorder := newTestContext(http.MethodPost, "/api/v1/me/logout-all")
+	c.Set(middleware.ClaimsKey, utils.Claims{UserId: 7, UserEmail: "ann@example.com"})
+	LogoutAll(dbConn)(c)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == "jwt-token" && cookie.Value == "" {
+			return
+		}
+	}
+	t.Errorf("Set-Cookie = %q, want the jwt-token cookie cleared", recorder.Header().Values("Set-Cookie"))
+}
+
+func TestCheckEmailAvailability(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT LOWER\\(email\\) FROM users").WithArgs(`{"new@example.com"}`).WillReturnRows(sqlmock.NewRows([]string{"email"}))
+	mock.ExpectQuery("SELECT LOWER\\(email\\) FROM users").WithArgs(`{"ann@example.com"}`).WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("ann@example.com"))
+
+	r := gin.New()
+	r.GET("/auth/available", CheckEmailAvailability(dbConn, &config.Config{AvailabilityChecksPerMinute: 1, AvailabilityChecksBurst: 3}))
+	tests := []struct {
+		name          string
+		email         string
+		wantStatus    int
+		wantAvailable bool
+	}{
+		{"available", "new@example.com", http.StatusOK, true},
+		{"taken, normalized", "%20Ann@Example.com%20", http.StatusOK, false},
+		{"invalid", "ann", http.StatusBadRequest, false},
+		{"rate limited", "new@example.com", http.StatusTooManyRequests, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auth/available?email="+tt.email, nil)
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var body struct {
+				Data struct {
+					Available bool `json:"available"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Data.Available != tt.wantAvailable {
+				t.Errorf("available = %t, want %t", body.Data.Available, tt.wantAvailable)
+			}
+		})
+	}
+}
+
+func TestResetPasswordLandingFlow(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	hash, err := utils.HashPassword("old password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{ID: 7, Email: "ann@example.com", Password: hash, Role: models.UserRoleGeneral, ResetTokenExpiry: time.Now().Add(time.Hour)}
+
+	sessions := utils.NewResetSessionStore(time.Minute)
+	r := gin.New()
+	r.SetHTMLTemplate(template.Must(template.New("reset_password.html").Parse(`{{.CSRFToken}}{{.Error}}`)))
+	r.GET("/reset-password", RenderResetPasswordPage(dbConn, sessions))
+	r.POST("/reset-password", ResetPassword(dbConn, &config.Config{}, sessions, utils.NewPasswordPolicy(8, nil, 0, nil)))
+
+	// The link's token is exchanged for a session and dropped from the URL
+	mock.ExpectQuery("WHERE reset_token = ").WithArgs("reset-token").WillReturnRows(userRows(user))
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/reset-password?token=reset-token", nil))
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/reset-password" {
+		t.Fatalf("got %d to %q, want a redirect to /reset-password", recorder.Code, recorder.Header().Get("Location"))
+	}
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != resetSessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %v, want an HttpOnly %s cookie", cookies, resetSessionCookie)
+	}
+	cookie := cookies[0]
+
+	req := httptest.NewRequest(http.MethodGet, "/reset-password", nil)
+	req.AddCookie(cookie)
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	csrfToken := recorder.Body.String()
+	if recorder.Code != http.StatusOK || csrfToken == "" {
+		t.Fatalf("got %d %q, want the page with a CSRF token", recorder.Code, csrfToken)
+	}
+
+	post := func(csrfToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/reset-password", strings.NewReader(`{"new_password":"a much newer passphrase"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", csrfToken)
+		req.AddCookie(cookie)
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if recorder := post("wrong"); recorder.Code != http.StatusForbidden {
+		t.Errorf("wrong CSRF token: status = %d, want %d", recorder.Code, http.StatusForbidden)
+	}
+
+	mock.ExpectQuery("WHERE reset_token = ").WithArgs("reset-token").WillReturnRows(userRows(user))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT password FROM users").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(hash))
+	mock.ExpectExec("DELETE FROM password_history").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE users").WithArgs(7, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SET reset_token = NULL").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("token_version = token_version \\+ 1").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(1))
+	mock.ExpectExec("UPDATE sessions").WithArgs(7, 0).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE refresh_tokens").WithArgs(7, 0).WillReturnResult(sqlmock.NewResult(0, 0))
+	if recorder := post(csrfToken); recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+
+	// The session ends with the reset, so the form cannot be sent again
+	if recorder := post(csrfToken); recorder.Code != http.StatusBadRequest {
+		t.Errorf("second reset: status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// inviteLifetime is how long an imported user's invite link stays valid.
+const inviteLifetime = 72 * time.Hour
+
+// importColumns are the columns of a user import CSV, in any order.
+var importColumns = []string{"name", "email", "phone", "role"}
+
+// importRow is the outcome of one row of a user import.
+type importRow struct {
+	Row    int    `json:"row"`
+	Email  string `json:"email"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// importReport is the result of a user import.
+type importReport struct {
+	DryRun  bool        `json:"dry_run"`
+	Created int         `json:"created"`
+	Rows    []importRow `json:"rows"`
+}
+
+// ImportUsers creates users from a CSV upload with the columns name, email,
+// phone and role, e.g. POST /api/v1/users/import?dry_run=true. The CSV is
+// sent as the "file" field of a multipart form or as the request body.
+//
+// Every row is validated first and the response reports each row. If any
+// row is invalid nothing is created; otherwise all users are created in one
+// transaction with a random password and sent an invite to set their own.
+// With dry_run=true the rows are only validated.
+func ImportUsers(db *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.InfoLogger.Println("Handling POST request for user import")
+		dryRun := c.Query("dry_run") == "true"
+
+		var body io.Reader = c.Request.Body
+		if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+			file, _, err := c.Request.FormFile("file")
+			if err != nil {
+				respondError(c, http.StatusBadRequest, "Missing CSV file")
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+
+		records, err := csv.NewReader(body).ReadAll()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid CSV: "+err.Error())
+			return
+		}
+		if len(records) < 2 {
+			respondError(c, http.StatusBadRequest, "The CSV must have a header row and at least one user")
+			return
+		}
+		columns, err := importColumnIndexes(records[0])
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		users, report := parseImportRows(records[1:], columns)
+		report.DryRun = dryRun
+
+		// Reject emails that already belong to a user
+		emails := make([]string, 0, len(users))
+		for _, user := range users {
+			if user != nil {
+				emails = append(emails, strings.ToLower(user.Email))
+			}
+		}
+		existing, err := db.GetExistingEmails(emails)
+		if err != nil {
+			respondDBError(c, err, "Failed to check existing users")
+			return
+		}
+		valid := true
+		for i, user := range users {
+			if user != nil && existing[strings.ToLower(user.Email)] {
+				users[i] = nil
+				report.Rows[i].Status = "invalid"
+				report.Rows[i].Error = "a user with this email already exists"
+			}
+			if users[i] == nil {
+				valid = false
+			}
+		}
+
+		if !valid {
+			respondErrorData(c, http.StatusUnprocessableEntity, "Some rows are invalid, no users were created", report)
+			return
+		}
+		if dryRun {
+			respondSuccess(c, http.StatusOK, "All rows are valid", report)
+			return
+		}
+
+		// Imported users get a random password that nobody knows, hashed once
+		// for the whole import since hashing is deliberately slow
+		unusablePassword, err := randomPasswordHash()
+		if err != nil {
+			logger.ErrorLogger.Println("Failed to generate a password for imported users:", err)
+			respondError(c, http.StatusInternalServerError, "Failed to prepare users")
+			return
+		}
+		for _, user := range users {
+			user.Password = unusablePassword
+			if err := prepareInvite(user); err != nil {
+				logger.ErrorLogger.Println("Failed to prepare imported user:", err)
+				respondError(c, http.StatusInternalServerError, "Failed to prepare users")
+				return
+			}
+		}
+		if err := db.ImportUsers(c.Request.Context(), users); err != nil {
+			if isPhoneTaken(err) {
+				respondError(c, http.StatusConflict, "A phone number in the import already belongs to another user, no users were created")
+				return
+			}
+			respondDBError(c, err, "Failed to create users")
+			return
+		}
+
+		for i, user := range users {
+			report.Rows[i].Status = "created"
+			if err := utils.QueueInviteEmail(user.Email, user.ResetToken); err != nil {
+				logger.ErrorLogger.Printf("Failed to queue invite for %s: %v\n", user.Email, err)
+			}
+		}
+		report.Created = len(users)
+
+		logger.InfoLogger.Printf("Imported %d users\n", len(users))
+		respondSuccess(c, http.StatusCreated, fmt.Sprintf("%d users created", len(users)), report)
+	}
+}
+
+// importColumnIndexes maps each of importColumns to its index in header.
+func importColumnIndexes(header []string) (map[string]int, error) {
+	indexes := make(map[string]int, len(header))
+	for i, name := range header {
+		indexes[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, column := range importColumns {
+		if _, ok := indexes[column]; !ok {
+			return nil, fmt.Errorf("the CSV header must include the columns %s", strings.Join(importColumns, ", "))
+		}
+	}
+	return indexes, nil
+}
+
+// parseImportRows validates each record. The returned users line up with the
+// records, with nil for invalid rows, and the report describes every row.
+func parseImportRows(records [][]string, columns map[string]int) ([]*models.User, importReport) {
+	users := make([]*models.User, len(records))
+	report := importReport{Rows: make([]importRow, len(records))}
+	seen := make(map[string]int)
+
+	for i, record := range records {
+		field := func(name string) string {
+			if index := columns[name]; index < len(record) {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+		// Rows are numbered as in the file, after the header
+		row := importRow{Row: i + 2, Email: field("email"), Status: "valid"}
+
+		user, err := importUser(field("name"), row.Email, field("phone"), field("role"))
+		if err == nil {
+			key := strings.ToLower(user.Email)
+			if first, ok := seen[key]; ok {
+				err = fmt.Errorf("duplicate of row %d", first)
+			} else {
+				seen[key] = row.Row
+			}
+		}
+		if err != nil {
+			row.Status = "invalid"
+			row.Error = err.Error()
+		} else {
+			users[i] = user
+		}
+		report.Rows[i] = row
+	}
+	return users, report
+}
+
+// importUser validates the fields of one import row.
+func importUser(name, email, phone, role string) (*models.User, error) {
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return nil, errors.New("invalid email address")
+	}
+	if role == "" {
+		role = models.UserRoleGeneral
+	}
+	role = strings.ToLower(role)
+	if role != models.UserRoleAdmin && role != models.UserRoleGeneral {
+		return nil, fmt.Errorf("invalid role %q, expected %s or %s", role, models.UserRoleAdmin, models.UserRoleGeneral)
+	}
+
+	firstName, lastName, _ := strings.Cut(name, " ")
+	return &models.User{
+		FirstName: firstName,
+		LastName:  strings.TrimSpace(lastName),
+		Phone:     utils.NormalizePhone(phone),
+		Email:     email,
+		Role:      role,
+	}, nil
+}
+
+// randomPasswordHash returns the hash of a random password that is
+// immediately forgotten.
+func randomPasswordHash() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return utils.HashPassword(hex.EncodeToString(secret))
+}
+
+// prepareInvite gives an imported user an invite token to choose their
+// password.
+func prepareInvite(user *models.User) error {
+	token, err := utils.GeneratePasswordResetToken(user)
+	if err != nil {
+		return err
+	}
+	user.ResetToken = token
+	user.ResetTokenExpiry = time.Now().Add(inviteLifetime)
+	return nil
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// postImport sends csv to ImportUsers and returns the response and its
+// decoded report.
+func postImport(t *testing.T, handler gin.HandlerFunc, query, csv string) (*httptest.ResponseRecorder, importReport) {
+	t.Helper()
+	r := gin.New()
+	r.POST("/users/import", handler)
+	req := httptest.NewRequest(http.MethodPost, "/users/import"+query, strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	var body struct {
+		Data importReport `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%v: %s", err, recorder.Body)
+	}
+	return recorder, body.Data
+}
+
+func TestImportUsers(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT LOWER\\(email\\) FROM users").WithArgs(`{"ann@example.com","bob@example.com"}`).WillReturnRows(sqlmock.NewRows([]string{"email"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO users").WithArgs("Ann", "Lee", sqlmock.AnyArg(), "ann@example.com", sqlmock.AnyArg(), "admin", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO users").WithArgs("Bob", "", sqlmock.AnyArg(), "bob@example.com", sqlmock.AnyArg(), "general", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+
+	csv := "email,name,phone,role\nann@example.com,Ann Lee,+14155550100,Admin\nbob@example.com,Bob,,\n"
+	recorder, report := postImport(t, ImportUsers(dbConn), "", csv)
+
+	if recorder.Code != http.StatusCreated || report.Created != 2 {
+		t.Fatalf("got %d with %d created, want 201 with 2: %s", recorder.Code, report.Created, recorder.Body)
+	}
+	for _, row := range report.Rows {
+		if row.Status != "created" {
+			t.Errorf("row %d status = %s, want created", row.Row, row.Status)
+		}
+	}
+}
+
+func TestImportUsersDryRun(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT LOWER\\(email\\) FROM users").WillReturnRows(sqlmock.NewRows([]string{"email"}))
+
+	recorder, report := postImport(t, ImportUsers(dbConn), "?dry_run=true", "name,email,phone,role\nAnn Lee,ann@example.com,,general\n")
+
+	if recorder.Code != http.StatusOK || !report.DryRun || report.Created != 0 || report.Rows[0].Status != "valid" {
+		t.Errorf("got %d %+v, want a valid dry run that creates nobody", recorder.Code, report)
+	}
+}
+
+func TestImportUsersInvalidRows(t *testing.T) {
+	dbConn, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT LOWER\\(email\\) FROM users").WithArgs(`{"ann@example.com","taken@example.com"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("taken@example.com"))
+
+	csv := "name,email,phone,role\n" +
+		"Ann Lee,ann@example.com,,general\n" +
+		"Ann Again,ANN@example.com,,general\n" +
+		"Tim Taken,Taken@example.com,,general\n" +
+		"Rob Root,rob@example.com,,root\n" +
+		"No Email,not-an-email,,general\n"
+	recorder, report := postImport(t, ImportUsers(dbConn), "", csv)
+
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusUnprocessableEntity, recorder.Body)
+	}
+	want := []importRow{
+		{Row: 2, Email: "ann@example.com", Status: "valid"},
+		{Row: 3, Email: "ANN@example.com", Status: "invalid", Error: "duplicate of row 2"},
+		{Row: 4, Email: "Taken@example.com", Status: "invalid", Error: "a user with this email already exists"},
+		{Row: 5, Email: "rob@example.com", Status: "invalid", Error: `invalid role "root", expected admin or general`},
+		{Row: 6, Email: "not-an-email", Status: "invalid", Error: "invalid email address"},
+	}
+	if len(report.Rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(report.Rows), len(want))
+	}
+	for i, row := range report.Rows {
+		if row != want[i] {
+			t.Errorf("row = %+v, want %+v", row, want[i])
+		}
+	}
+}
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"html/template"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/vikash-parashar/asset-locator/config"
 	"github.com/vikash-parashar/asset-locator/db"
 	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/middleware"
 	"github.com/vikash-parashar/asset-locator/routes"
+	"github.com/vikash-parashar/asset-locator/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/joho/godotenv"
 )
 
@@ -21,21 +32,75 @@ func loadEnvVariables() {
 
 // main function
 func main() {
+	migrate := flag.String("migrate", "", "apply (up) or revert (down) database migrations, or list them (status), then exit")
+	steps := flag.Int("steps", 0, "number of migrations to apply or revert; up defaults to all, down to one")
+	dryRun := flag.Bool("dry-run", false, "list the migrations -migrate up would apply, with their SQL, without applying them; exits with status 1 if any are pending")
+	flag.Parse()
+	if *dryRun && *migrate == "" {
+		*migrate = "up"
+	}
+
 	loadEnvVariables()
 
 	// Load configuration
 	cfg := config.LoadConfig()
+	cfg.LogEffective()
+	if err := cfg.Validate(); err != nil {
+		logger.ErrorLogger.Fatalf("Invalid configuration: %v", err)
+	}
+	utils.SetSecretKey(cfg.JWTSecret)
+	utils.SetRequestIDHeader(cfg.RequestIDHeader)
 
 	// Initialize the database connection
-	dbConn, err := db.NewDB(cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+	dbConn, err := db.NewDB(cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode, cfg.DBSSLRootCert)
 	if err != nil {
-		logger.ErrorLogger.Printf("Error connecting to the database: %v", err)
+		logger.ErrorLogger.Fatalf("Error connecting to the database: %v", err)
 	}
 	defer dbConn.Close()
+	dbConn.SetReadRetry(cfg.DBReadAttempts, cfg.DBReadRetryBackoff)
+	dbConn.SetRequireDeviceApproval(cfg.RequireDeviceApproval)
+
+	if *migrate != "" {
+		if err := runMigrations(dbConn, *migrate, *steps, *dryRun); err != nil {
+			dbConn.Close()
+			if errors.Is(err, errPendingMigrations) {
+				os.Exit(1)
+			}
+			logger.ErrorLogger.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// Bring the schema up to date at startup when configured, for local
+	// development
+	if cfg.AutoMigrate {
+		if err := autoMigrate(dbConn); err != nil {
+			dbConn.Close()
+			logger.ErrorLogger.Fatalf("Automatic migration failed: %v", err)
+		}
+	}
+
+	// Create or drop the unique phone index to match the configuration
+	if err := dbConn.EnforceUniquePhones(context.Background(), cfg.UniquePhones); err != nil {
+		dbConn.Close()
+		logger.ErrorLogger.Fatalf("Error configuring unique phone numbers: %v", err)
+	}
+
+	// Deliver queued emails in the background
+	utils.StartEmailWorker(cfg.EmailRatePerMinute, cfg.EmailBurst)
+
+	// Reject unknown JSON fields, e.g. a misspelled key, when configured
+	binding.EnableDecoderDisallowUnknownFields = cfg.StrictJSON
 
 	// Setting server mux as default mux
 	r := gin.Default()
 
+	// Only trust X-Forwarded-For from the configured proxies when working
+	// out the client IP
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.ErrorLogger.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	// Serve static files from the "static" directory
 	r.Static("/static", "./static")
 
@@ -49,8 +114,162 @@ func main() {
 	// Load HTML templates
 	r.LoadHTMLGlob("templates/*.html")
 
+	// Count in-flight requests and refuse new ones once shutting down
+	drainer := middleware.NewDrainer()
+	r.Use(drainer.Middleware())
+
 	// Set up routes from the routes package
-	routes.SetupRoutes(r, dbConn)
+	if err := routes.SetupRoutes(r, dbConn, cfg); err != nil {
+		dbConn.Close()
+		logger.ErrorLogger.Fatalf("Failed to set up routes: %v", err)
+	}
+
+	server := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: r,
+	}
+	if cfg.UseHTTPS {
+		// Validate has already checked the TLS settings
+		server.TLSConfig, _ = cfg.TLSConfig()
+	}
+
+	// Stop accepting connections on SIGINT or SIGTERM and give in-flight
+	// requests ShutdownTimeout, and queued emails EmailDrainTimeout, to
+	// finish before the database is closed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg.UseHTTPS {
+			logger.InfoLogger.Printf("Serving HTTPS on port %s with TLS %s or later\n", cfg.Port, cfg.TLSMinVersion)
+			serveErr <- server.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+		} else {
+			logger.InfoLogger.Printf("Serving HTTP on port %s\n", cfg.Port)
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		logger.ErrorLogger.Println(err)
+		return
+	case <-ctx.Done():
+	}
+	stop()
+
+	logger.InfoLogger.Printf("Shutting down, waiting for %d in-flight requests\n", drainer.InFlight())
+	drainer.Drain()
+	// End the event streams, which would otherwise outlast any timeout
+	utils.CloseDeviceEvents()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.ErrorLogger.Printf("Graceful shutdown did not complete (%v), closing %d requests still in flight\n", err, drainer.InFlight())
+		server.Close()
+	} else {
+		logger.InfoLogger.Println("Server stopped")
+	}
 
-	logger.ErrorLogger.Println(r.Run(":" + cfg.Port))
+	if pending := utils.DrainEmails(cfg.EmailDrainTimeout); pending > 0 {
+		logger.ErrorLogger.Printf("Exiting with %d emails not yet delivered\n", pending)
+	}
+}
+
+// autoMigrate applies every pending migration and logs what it applied.
+func autoMigrate(dbConn *db.DB) error {
+	migrations, err := db.LoadMigrations(db.MigrationsDir)
+	if err != nil {
+		return err
+	}
+	logger.InfoLogger.Println("AUTO_MIGRATE is set, applying pending migrations")
+	applied, err := dbConn.MigrateUp(context.Background(), migrations, 0)
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		logger.InfoLogger.Println("Auto-migration: database is up to date")
+	}
+	return nil
+}
+
+// errPendingMigrations is returned by a dry run that found migrations to
+// apply, so that CI checks can fail on an outdated schema.
+var errPendingMigrations = errors.New("migrations are pending")
+
+// runMigrations applies, reverts or lists the migrations in db.MigrationsDir
+// for the -migrate flag. With dryRun, up only lists what it would apply.
+func runMigrations(dbConn *db.DB, command string, steps int, dryRun bool) error {
+	if steps < 0 {
+		return fmt.Errorf("invalid -steps %d, expected a positive number", steps)
+	}
+	if dryRun && command != "up" {
+		return fmt.Errorf("-dry-run only applies to -migrate up, not %q", command)
+	}
+	migrations, err := db.LoadMigrations(db.MigrationsDir)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		if dryRun {
+			return dryRunMigrations(ctx, dbConn, migrations, steps)
+		}
+		applied, err := dbConn.MigrateUp(ctx, migrations, steps)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			logger.InfoLogger.Println("Database is up to date")
+		}
+	case "down":
+		if steps == 0 {
+			steps = 1
+		}
+		reverted, err := dbConn.MigrateDown(ctx, migrations, steps)
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			logger.InfoLogger.Println("No migrations to revert")
+		}
+	case "status":
+		states, err := dbConn.MigrationStatus(ctx, migrations)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			status := "pending"
+			if state.AppliedAt != nil {
+				status = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			logger.InfoLogger.Printf("%04d_%-30s %s\n", state.Version, state.Name, status)
+		}
+	default:
+		return fmt.Errorf("unknown -migrate command %q, expected up, down or status", command)
+	}
+	return nil
+}
+
+// dryRunMigrations logs the migrations -migrate up would apply and their
+// SQL, without running them.
+func dryRunMigrations(ctx context.Context, dbConn *db.DB, migrations []db.Migration, steps int) error {
+	pending, err := dbConn.PendingMigrations(ctx, migrations)
+	if err != nil {
+		return err
+	}
+	if steps > 0 && len(pending) > steps {
+		pending = pending[:steps]
+	}
+	if len(pending) == 0 {
+		logger.InfoLogger.Println("Dry run: database is up to date")
+		return nil
+	}
+	for _, migration := range pending {
+		logger.InfoLogger.Printf("Dry run: would apply %04d_%s\n%s\n", migration.Version, migration.Name, migration.Up)
+	}
+	logger.InfoLogger.Printf("Dry run: %d migrations pending\n", len(pending))
+	return errPendingMigrations
 }
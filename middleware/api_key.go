@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+const (
+	// APIKeyHeader carries the API key of a programmatic request.
+	APIKeyHeader = "X-API-Key"
+	// APIKeyKey holds the models.APIKey a request was authenticated with.
+	APIKeyKey = "apiKey"
+)
+
+// authenticateAPIKey authenticates the request as the owner of an API key,
+// storing the same context values as a token would and the key under
+// APIKeyKey. Keys without the write scope may only make safe requests.
+func authenticateAPIKey(c *gin.Context, dbConn *db.DB, apiKey string) {
+	if !utils.IsAPIKey(apiKey) {
+		logger.WarningLogger.Printf("Malformed API key for %s %s\n", c.Request.Method, c.Request.URL.Path)
+		abortUnauthorized(c, "api_key_invalid", "Invalid API key")
+		return
+	}
+
+	key, err := dbConn.AuthenticateAPIKey(utils.HashToken(apiKey))
+	if err != nil {
+		if errors.Is(err, db.ErrAPIKeyInvalid) {
+			logger.WarningLogger.Printf("Invalid or expired API key for %s %s\n", c.Request.Method, c.Request.URL.Path)
+			abortUnauthorized(c, "api_key_invalid", "Invalid or expired API key")
+			return
+		}
+		logger.ErrorLogger.Printf("Failed to authenticate API key: %v\n", err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"success": false, "message": "The database is unavailable, please try again later"})
+		return
+	}
+	user, err := dbConn.GetUserByID(key.UserID)
+	if err != nil {
+		if errors.Is(err, db.ErrDatabaseUnavailable) {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"success": false, "message": "The database is unavailable, please try again later"})
+			return
+		}
+		logger.WarningLogger.Printf("API key %d of unknown user %d: %s\n", key.ID, key.UserID, err)
+		abortUnauthorized(c, "api_key_invalid", "Invalid or expired API key")
+		return
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		if !slices.Contains(key.Scopes, models.APIKeyScopeWrite) {
+			logger.WarningLogger.Printf("API key %d without write scope refused for %s %s\n", key.ID, c.Request.Method, c.Request.URL.Path)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "code": "insufficient_scope", "message": "This API key may only read"})
+			return
+		}
+	}
+
+	c.Set(ClaimsKey, utils.Claims{
+		UserId:       int(user.ID),
+		UserEmail:    user.Email,
+		UserRole:     user.Role,
+		TokenVersion: user.TokenVersion,
+	})
+	c.Set(UserKey, user)
+	c.Set(APIKeyKey, key)
+	c.Next()
+}
+
+// RequireSession refuses, with 403, requests authenticated with an API key,
+// so that a leaked key cannot be used to create further keys or to manage
+// the account. It must run after RequireAuth.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(APIKeyKey); ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "code": "session_required", "message": "This action requires logging in, not an API key"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,66 +1,247 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/vikash-parashar/asset-locator/authz"
+	"github.com/vikash-parashar/asset-locator/db"
 	"github.com/vikash-parashar/asset-locator/logger"
+	"github.com/vikash-parashar/asset-locator/models"
 	"github.com/vikash-parashar/asset-locator/utils"
 
-	"github.com/dgrijalva/jwt-go"
-	"github.com/gin-gonic/gin" // Update with your actual import path
+	"github.com/gin-gonic/gin"
 )
 
-// Claims represents the JWT claims.
-type Claims struct {
-	UserId    int    `json:"user_id"`
-	UserEmail string `json:"user_email"`
-	UserRole  string `json:"user_role"`
-	jwt.StandardClaims
+// Keys under which RequireAuth stores the authenticated session in the gin
+// context.
+const (
+	// ClaimsKey holds the token's utils.Claims.
+	ClaimsKey = "claims"
+	// UserKey holds the authenticated *models.User.
+	UserKey = "user"
+)
+
+// AuthMode selects where RequireAuth looks for the JWT.
+type AuthMode string
+
+const (
+	// AuthAny accepts an API key, a bearer token or, without either, the
+	// cookie.
+	AuthAny AuthMode = "any"
+	// AuthCookie accepts only the jwt-token cookie, for browser pages whose
+	// form submissions are protected by CSRF.
+	AuthCookie AuthMode = "cookie"
+	// AuthBearer accepts only an "Authorization: Bearer" header or an API
+	// key, for programmatic clients.
+	AuthBearer AuthMode = "bearer"
+)
+
+// token returns the JWT of r that mode accepts, or "".
+func (mode AuthMode) token(r *http.Request) string {
+	switch mode {
+	case AuthCookie:
+		return utils.CookieToken(r)
+	case AuthBearer:
+		return utils.BearerToken(r)
+	default:
+		return utils.TokenFromRequest(r)
+	}
+}
+
+// RequireAuth authenticates the request with the JWT that mode accepts: an
+// "Authorization: Bearer" header, the jwt-token cookie, or either. A token
+// sent the other way is ignored. The token's claims and the user it belongs
+// to are stored in the context under ClaimsKey and UserKey. Unless mode is
+// AuthCookie, a request with an X-API-Key header is authenticated with that
+// key instead.
+//
+// A missing, invalid or expired token, or one revoked by bumping the user's
+// token version or revoking its session, gets a 401; browsers navigating to
+// a page are redirected to the login page instead. Users whose password has
+// expired get a 403 until they change it.
+func RequireAuth(dbConn *db.DB, mode AuthMode) gin.HandlerFunc {
+	return requireAuth(dbConn, mode, false)
 }
 
-// AuthMiddleware checks JWT tokens from cookies and enforces user roles.
-func AuthMiddleware(roles ...string) gin.HandlerFunc {
+// RequireAuthExpiredPassword is RequireAuth for the routes users whose
+// password has expired may still use, i.e. changing it.
+func RequireAuthExpiredPassword(dbConn *db.DB, mode AuthMode) gin.HandlerFunc {
+	return requireAuth(dbConn, mode, true)
+}
+
+func requireAuth(dbConn *db.DB, mode AuthMode, allowExpiredPassword bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Retrieve the JWT token from the cookie
-		cookie, err := c.Request.Cookie("jwt-token")
+		if apiKey := c.GetHeader(APIKeyHeader); apiKey != "" && mode != AuthCookie {
+			authenticateAPIKey(c, dbConn, apiKey)
+			return
+		}
+
+		token := mode.token(c.Request)
+		if token == "" {
+			logger.InfoLogger.Printf("No token for %s %s\n", c.Request.Method, c.Request.URL.Path)
+			abortUnauthorized(c, "", "Authentication required")
+			return
+		}
+
+		claims, err := utils.VerifyJWTToken(token)
 		if err != nil {
-			// Token not found, redirect to login page
-			logger.ErrorLogger.Printf("Token not found, redirecting to login page: %s\n", err)
-			c.Redirect(http.StatusSeeOther, "http://localhost:8080")
-			c.Abort()
+			// An expired session is routine; a bad signature or malformed
+			// token is not. The code lets clients tell them apart.
+			switch {
+			case errors.Is(err, utils.ErrTokenExpired):
+				logger.InfoLogger.Printf("Expired token: %s\n", err)
+				abortUnauthorized(c, "token_expired", "Your session has expired, please log in again")
+			case errors.Is(err, utils.ErrInvalidSignature):
+				logger.WarningLogger.Printf("Invalid token: %s\n", err)
+				abortUnauthorized(c, "token_invalid", "Invalid token signature")
+			default:
+				logger.WarningLogger.Printf("Invalid token: %s\n", err)
+				abortUnauthorized(c, "token_malformed", "Malformed token")
+			}
 			return
 		}
 
-		token := cookie.Value
+		user, err := dbConn.GetUserByID(claims.UserId)
+		if err != nil {
+			if errors.Is(err, db.ErrDatabaseUnavailable) {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"success": false, "message": "The database is unavailable, please try again later"})
+				return
+			}
+			logger.WarningLogger.Printf("Token for unknown user %d: %s\n", claims.UserId, err)
+			abortUnauthorized(c, "token_invalid", "Invalid token")
+			return
+		}
 
-		claims, valid := utils.VerifyJWTToken(token)
-		if !valid {
-			// Token is invalid or expired, redirect to login page
-			logger.WarningLogger.Printf("Invalid or expired token, redirecting to login page\n")
-			c.Redirect(http.StatusSeeOther, "http://localhost:8080/")
-			c.Abort()
+		// Reject tokens revoked by a "sign out everywhere"
+		if claims.TokenVersion < user.TokenVersion {
+			logger.InfoLogger.Printf("Revoked token for user %s\n", claims.UserEmail)
+			abortUnauthorized(c, "token_revoked", "Your session has been signed out, please log in again")
 			return
 		}
 
-		// Check if the user has the required role
-		hasRequiredRole := false
-		userRole := claims.UserRole // Access the user role from the claims
+		// Reject tokens of sessions that were revoked or have ended
+		if claims.SessionID != 0 {
+			live, err := dbConn.TouchSession(claims.SessionID, c.ClientIP())
+			if err != nil {
+				logger.ErrorLogger.Printf("Failed to check session %d: %v\n", claims.SessionID, err)
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"success": false, "message": "The database is unavailable, please try again later"})
+				return
+			}
+			if !live {
+				logger.InfoLogger.Printf("Token of revoked session %d for user %s\n", claims.SessionID, claims.UserEmail)
+				abortUnauthorized(c, "token_revoked", "Your session has been signed out, please log in again")
+				return
+			}
+		}
+
+		// Users with an expired password may only change it
+		if claims.PasswordExpired && !allowExpiredPassword {
+			logger.WarningLogger.Printf("Password expired for user %s\n", claims.UserEmail)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "code": "password_expired", "message": "Your password has expired, please change it"})
+			return
+		}
+
+		c.Set(ClaimsKey, claims)
+		c.Set(UserKey, user)
+		c.Next()
+	}
+}
+
+// RequireRole lets through only users with one of roles, answering 403
+// otherwise. It must run after RequireAuth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.Get(UserKey)
+		if !ok {
+			abortUnauthorized(c, "", "Authentication required")
+			return
+		}
 
-		for _, role := range roles {
-			if userRole == role {
-				hasRequiredRole = true
-				break
+		role := user.(*models.User).Role
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
 			}
 		}
 
-		if !hasRequiredRole {
-			logger.ErrorLogger.Printf("Access Forbidden for role: %s\n", userRole)
-			c.JSON(http.StatusForbidden, gin.H{"message": "Access Forbidden"})
-			c.Abort()
+		logger.WarningLogger.Printf("Access forbidden for role %q on %s %s\n", role, c.Request.Method, c.Request.URL.Path)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "message": "Access Forbidden"})
+	}
+}
+
+// RequirePermission lets through only users whose role grants action on
+// resource, answering 403 otherwise. It must run after RequireAuth.
+func RequirePermission(policy *authz.Policy, resource, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get(UserKey)
+		if !ok {
+			abortUnauthorized(c, "", "Authentication required")
+			return
+		}
+		role := value.(*models.User).Role
+
+		allowed, err := policy.Allows(role, resource, action)
+		if err != nil {
+			logger.ErrorLogger.Printf("Failed to load permissions: %v\n", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"success": false, "message": "The database is unavailable, please try again later"})
+			return
+		}
+		if !allowed {
+			logger.WarningLogger.Printf("Permission %s denied for role %q on %s %s\n", authz.Permission(resource, action), role, c.Request.Method, c.Request.URL.Path)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "message": "Access Forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// MinAccountAge refuses, with 403, users whose account is younger than
+// minAge, so that freshly registered accounts cannot abuse sensitive
+// actions straight away. Admins are exempt and a zero minAge lets everyone
+// through. It must run after RequireAuth.
+func MinAccountAge(minAge time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get(UserKey)
+		if !ok {
+			abortUnauthorized(c, "", "Authentication required")
+			return
+		}
+		user := value.(*models.User)
+		if minAge <= 0 || user.Role == models.UserRoleAdmin {
+			c.Next()
 			return
 		}
 
-		logger.InfoLogger.Printf("User %s has access\n", claims.UserEmail)
+		if age := time.Since(user.CreatedAt); age < minAge {
+			wait := (minAge - age).Round(time.Second)
+			logger.WarningLogger.Printf("Refused %s %s for user %d, account is only %s old\n", c.Request.Method, c.Request.URL.Path, user.ID, age.Round(time.Second))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"code":    "account_too_new",
+				"message": fmt.Sprintf("Your account is too new for this action, please try again in %s", wait),
+			})
+			return
+		}
 		c.Next()
 	}
 }
+
+// abortUnauthorized answers 401 with an optional machine-readable code, or
+// redirects browsers navigating to a page to the login page.
+func abortUnauthorized(c *gin.Context, code, message string) {
+	if c.Request.Method == http.MethodGet && strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.Redirect(http.StatusSeeOther, "/login")
+		c.Abort()
+		return
+	}
+	body := gin.H{"success": false, "message": message}
+	if code != "" {
+		body["code"] = code
+	}
+	c.AbortWithStatusJSON(http.StatusUnauthorized, body)
+}
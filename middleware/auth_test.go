@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/db"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+func TestRequireAuthPasswordExpired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	utils.SetSecretKey("test-secret")
+
+	user := &models.User{ID: 7, Email: "ann@example.com", Role: models.UserRoleGeneral, PasswordExpired: true}
+	token, err := utils.GenerateJWTToken(user, time.Minute, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		middleware func(*db.DB, AuthMode) gin.HandlerFunc
+		want       int
+	}{
+		{"RequireAuth", RequireAuth, http.StatusForbidden},
+		{"RequireAuthExpiredPassword", RequireAuthExpiredPassword, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			now := time.Now()
+			mock.ExpectQuery("FROM users").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "phone", "email", "password", "role",
+				"reset_token", "reset_token_expiry", "created_at", "updated_at", "password_changed_at", "token_version", "locked_until"}).
+				AddRow(7, "Ann", "Lee", nil, "ann@example.com", "hash", models.UserRoleGeneral, nil, nil, now, now, now.AddDate(-1, 0, 0), 0, nil))
+
+			r := gin.New()
+			r.POST("/api/v1/me/password", tt.middleware(&db.DB{DB: conn}, AuthBearer), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/me/password", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.want, recorder.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRequireAuthReportsRejectedTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := &models.User{ID: 7, Email: "ann@example.com", Role: models.UserRoleGeneral}
+	utils.SetSecretKey("other-secret")
+	forged, err := utils.GenerateJWTToken(user, time.Minute, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	utils.SetSecretKey("test-secret")
+	expired, err := utils.GenerateJWTToken(user, -time.Minute, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		code  string
+	}{
+		{"expired", expired, "token_expired"},
+		{"other secret", forged, "token_invalid"},
+		{"malformed", "not-a-token", "token_malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/api/v1/me", RequireAuth(&db.DB{}, AuthBearer), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusUnauthorized || !strings.Contains(recorder.Body.String(), `"code":"`+tt.code+`"`) {
+				t.Errorf("got %d %s, want 401 with code %s", recorder.Code, recorder.Body, tt.code)
+			}
+		})
+	}
+}
+
+func TestRequireAuthRevokedToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	utils.SetSecretKey("test-secret")
+	token, err := utils.GenerateJWTToken(&models.User{ID: 7, Email: "ann@example.com", Role: models.UserRoleGeneral, TokenVersion: 1}, time.Minute, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	now := time.Now()
+	// The user signed out everywhere since the token was issued.
+	mock.ExpectQuery("FROM users").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "phone", "email", "password", "role",
+		"reset_token", "reset_token_expiry", "created_at", "updated_at", "password_changed_at", "token_version", "locked_until"}).
+		AddRow(7, "Ann", "Lee", nil, "ann@example.com", "hash", models.UserRoleGeneral, nil, nil, now, now, now, 2, nil))
+
+	r := gin.New()
+	r.GET("/api/v1/me", RequireAuth(&db.DB{DB: conn}, AuthBearer), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusUnauthorized || !strings.Contains(recorder.Body.String(), `"code":"token_revoked"`) {
+		t.Errorf("got %d %s, want 401 with code token_revoked", recorder.Code, recorder.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRequireAuthModes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	utils.SetSecretKey("test-secret")
+	token, err := utils.GenerateJWTToken(&models.User{ID: 7, Email: "ann@example.com", Role: models.UserRoleGeneral}, time.Minute, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		mode   AuthMode
+		cookie bool
+		want   int
+	}{
+		{AuthCookie, true, http.StatusOK},
+		{AuthCookie, false, http.StatusUnauthorized},
+		{AuthBearer, false, http.StatusOK},
+		{AuthBearer, true, http.StatusUnauthorized},
+		{AuthAny, true, http.StatusOK},
+		{AuthAny, false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		sentAs := "bearer"
+		if tt.cookie {
+			sentAs = "cookie"
+		}
+		t.Run(string(tt.mode)+" mode, "+sentAs+" token", func(t *testing.T) {
+			conn, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if tt.want == http.StatusOK {
+				now := time.Now()
+				mock.ExpectQuery("FROM users").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "phone", "email", "password", "role",
+					"reset_token", "reset_token_expiry", "created_at", "updated_at", "password_changed_at", "token_version", "locked_until"}).
+					AddRow(7, "Ann", "Lee", nil, "ann@example.com", "hash", models.UserRoleGeneral, nil, nil, now, now, now, 0, nil))
+			}
+
+			r := gin.New()
+			r.GET("/api/v1/me", RequireAuth(&db.DB{DB: conn}, tt.mode), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: "jwt-token", Value: token})
+			} else {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.want, recorder.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestMinAccountAge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		minAge time.Duration
+		user   models.User
+		want   int
+	}{
+		{"fresh account", 24 * time.Hour, models.User{ID: 7, Role: models.UserRoleGeneral, CreatedAt: time.Now()}, http.StatusForbidden},
+		{"older account", 24 * time.Hour, models.User{ID: 7, Role: models.UserRoleGeneral, CreatedAt: time.Now().Add(-48 * time.Hour)}, http.StatusOK},
+		{"fresh admin", 24 * time.Hour, models.User{ID: 1, Role: models.UserRoleAdmin, CreatedAt: time.Now()}, http.StatusOK},
+		{"disabled", 0, models.User{ID: 7, Role: models.UserRoleGeneral, CreatedAt: time.Now()}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.DELETE("/api/v1/owners/3", func(c *gin.Context) {
+				c.Set(UserKey, &tt.user)
+			}, MinAccountAge(tt.minAge), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/api/v1/owners/3", nil))
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.want, recorder.Body)
+			}
+			if tt.want == http.StatusForbidden && !strings.Contains(recorder.Body.String(), "account_too_new") {
+				t.Errorf("body = %s, want code account_too_new", recorder.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartMemory is how much of a multipart form is kept in memory; larger
+// file parts are spilled to temporary files.
+const multipartMemory = 8 << 20
+
+// MaxBodySize rejects request bodies larger than limit bytes with a 413. The
+// declared Content-Length is checked up front, and form bodies are parsed
+// eagerly through an http.MaxBytesReader so that an oversized upload without
+// a Content-Length is also reported as a 413 rather than as missing fields.
+// A malformed or truncated form is rejected with a 400 for the same reason.
+// Temporary files of a multipart form are removed by net/http, both when
+// parsing fails and once the request is done.
+func MaxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			abortTooLarge(c, limit)
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+
+		contentType := strings.ToLower(c.GetHeader("Content-Type"))
+		var err error
+		switch {
+		case strings.HasPrefix(contentType, "multipart/form-data"):
+			err = c.Request.ParseMultipartForm(multipartMemory)
+		case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+			err = c.Request.ParseForm()
+		}
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			abortTooLarge(c, limit)
+			return
+		}
+		if err != nil {
+			abortMalformed(c, err)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func abortMalformed(c *gin.Context, err error) {
+	logger.WarningLogger.Printf("Malformed form body for %s %s: %v\n", c.Request.Method, c.Request.URL.Path, err)
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+		"success": false,
+		"message": "Malformed or incomplete form data, please upload it again",
+	})
+}
+
+func abortTooLarge(c *gin.Context, limit int64) {
+	logger.WarningLogger.Printf("Request body for %s %s exceeds %d bytes\n", c.Request.Method, c.Request.URL.Path, limit)
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+		"success": false,
+		"message": fmt.Sprintf("Request body is too large, the limit is %d bytes", limit),
+	})
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newBodyLimitEngine returns an engine limiting POST /upload to limit bytes
+// and answering with the size of the body or form it received.
+func newBodyLimitEngine(limit int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/upload", MaxBodySize(limit), func(c *gin.Context) {
+		if c.Request.Form != nil {
+			c.String(http.StatusOK, c.Request.FormValue("data"))
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Status(http.StatusRequestEntityTooLarge)
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+	return r
+}
+
+func TestMaxBodySize(t *testing.T) {
+	r := newBodyLimitEngine(16)
+	tests := []struct {
+		name          string
+		contentType   string
+		body          string
+		contentLength bool
+		want          int
+	}{
+		{"within the limit", "application/octet-stream", "small", true, http.StatusOK},
+		{"declared too large", "application/octet-stream", strings.Repeat("x", 17), true, http.StatusRequestEntityTooLarge},
+		{"form too large without length", "application/x-www-form-urlencoded", "data=" + strings.Repeat("x", 20), false, http.StatusRequestEntityTooLarge},
+		{"form within the limit", "application/x-www-form-urlencoded", "data=small", false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if !tt.contentLength {
+				req.ContentLength = -1
+			}
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.want, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestMaxBodySizeMalformedMultipart(t *testing.T) {
+	r := newBodyLimitEngine(1 << 20)
+	const boundary = "xYzZY"
+	complete := "--" + boundary + "\r\n" +
+		"Content-Disposition: form-data; name=\"data\"; filename=\"devices.csv\"\r\n\r\n" +
+		"serial_number,device_type\r\n" +
+		"--" + boundary + "--\r\n"
+	// The upload stops in the middle of the file
+	truncated := complete[:strings.Index(complete, "device_type")]
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"complete", "multipart/form-data; boundary=" + boundary, complete, http.StatusOK},
+		{"truncated", "multipart/form-data; boundary=" + boundary, truncated, http.StatusBadRequest},
+		{"missing boundary", "multipart/form-data", complete, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.want, recorder.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedValue replaces the value of sensitive fields in logged bodies.
+const redactedValue = "[REDACTED]"
+
+// sensitiveFields are substrings of field names whose values are never
+// logged, e.g. "password", "confirm_password" or "reset_token".
+var sensitiveFields = []string{"password", "token", "secret"}
+
+// bodyLogWriter keeps a copy of at most limit bytes of the response body.
+type bodyLogWriter struct {
+	gin.ResponseWriter
+	body  bytes.Buffer
+	limit int
+}
+
+func (w *bodyLogWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyLogWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// capture keeps one byte more than the limit so truncation can be reported.
+func (w *bodyLogWriter) capture(data []byte) {
+	if room := w.limit + 1 - w.body.Len(); room > 0 {
+		if len(data) > room {
+			data = data[:room]
+		}
+		w.body.Write(data)
+	}
+}
+
+// BodyLogger logs request and response bodies for debugging client
+// integrations. Values of sensitive fields in JSON and form bodies are
+// redacted, other bodies are summarized by size, and everything is truncated
+// to maxBytes. It is meant for development only and must not be installed in
+// release mode.
+func BodyLogger(maxBytes int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		contentType := c.GetHeader("Content-Type")
+		var requestBody []byte
+		if c.Request.Body != nil && isTextBody(contentType) {
+			body, err := io.ReadAll(c.Request.Body)
+			c.Request.Body.Close()
+			if err != nil {
+				logger.WarningLogger.Printf("Failed to read request body for logging: %v\n", err)
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			requestBody = body
+		}
+
+		writer := &bodyLogWriter{ResponseWriter: c.Writer, limit: maxBytes}
+		c.Writer = writer
+
+		c.Next()
+
+		request := "<empty>"
+		switch {
+		case len(requestBody) > 0:
+			request = truncate(redactBody(contentType, requestBody), maxBytes)
+		case c.Request.ContentLength > 0:
+			request = fmt.Sprintf("<%d bytes of %s>", c.Request.ContentLength, contentType)
+		}
+
+		response := "<empty>"
+		if writer.body.Len() > 0 {
+			responseType := writer.Header().Get("Content-Type")
+			if isTextBody(responseType) {
+				response = truncate(redactBody(responseType, writer.body.Bytes()), maxBytes)
+			} else {
+				response = fmt.Sprintf("<%d bytes of %s>", writer.Size(), responseType)
+			}
+		}
+
+		logger.InfoLogger.Printf("%s %s -> %d\nrequest body: %s\nresponse body: %s\n",
+			c.Request.Method, c.Request.URL.Path, writer.Status(), request, response)
+	}
+}
+
+// isTextBody reports whether bodies of contentType are logged verbatim.
+func isTextBody(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return strings.HasPrefix(contentType, "application/json") ||
+		strings.HasPrefix(contentType, "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(contentType, "text/plain")
+}
+
+// redactBody returns body with the values of sensitive fields replaced. JSON
+// that fails to parse, e.g. because it was truncated, is logged as a size
+// only so that no secret can leak through it.
+func redactBody(contentType string, body []byte) string {
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			return fmt.Sprintf("<%d bytes of unparseable JSON>", len(body))
+		}
+		redacted, err := json.Marshal(redactValue(value))
+		if err != nil {
+			return fmt.Sprintf("<%d bytes of JSON>", len(body))
+		}
+		return string(redacted)
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return fmt.Sprintf("<%d bytes of unparseable form>", len(body))
+		}
+		for key := range values {
+			if isSensitiveField(key) {
+				values[key] = []string{redactedValue}
+			}
+		}
+		return values.Encode()
+	}
+	return string(body)
+}
+
+// redactValue walks a decoded JSON value and redacts sensitive fields.
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSensitiveField(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, field := range sensitiveFields {
+		if strings.Contains(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	return fmt.Sprintf("%s... (%d bytes truncated)", s[:maxBytes], len(s)-maxBytes)
+}
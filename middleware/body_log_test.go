@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+// captureInfoLog collects what InfoLogger writes until the test ends.
+func captureInfoLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := logger.InfoLogger.Writer()
+	logger.InfoLogger.SetOutput(&buf)
+	t.Cleanup(func() { logger.InfoLogger.SetOutput(previous) })
+	return &buf
+}
+
+func TestBodyLoggerRedactsSecrets(t *testing.T) {
+	logged := captureInfoLog(t)
+	r := gin.New()
+	r.Use(BodyLogger(4096))
+	r.POST("/login", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"token": "eyJhbGciOi", "email": "ann@example.com"})
+	})
+
+	body := `{"email":"ann@example.com","password":"hunter2"}`
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	out := logged.String()
+	for _, secret := range []string{"hunter2", "eyJhbGciOi"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log contains %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"password":"[REDACTED]"`) || !strings.Contains(out, "ann@example.com") {
+		t.Errorf("log does not show the redacted body:\n%s", out)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"nested JSON", "application/json", `{"user":{"reset_token":"abc"}}`, `{"user":{"reset_token":"[REDACTED]"}}`},
+		{"form", "application/x-www-form-urlencoded", "email=a%40b.c&password=x", "email=a%40b.c&password=%5BREDACTED%5D"},
+		{"truncated JSON", "application/json", `{"password":"hun`, "<16 bytes of unparseable JSON>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactBody(tt.contentType, []byte(tt.body)); got != tt.want {
+				t.Errorf("redactBody = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ipConcurrency counts the in-flight requests of each client IP.
+type ipConcurrency struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+	limit    int
+}
+
+// acquire reserves a slot for ip, reporting false if it has none left.
+func (l *ipConcurrency) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[ip] >= l.limit {
+		return false
+	}
+	l.inFlight[ip]++
+	return true
+}
+
+func (l *ipConcurrency) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[ip] <= 1 {
+		delete(l.inFlight, ip)
+		return
+	}
+	l.inFlight[ip]--
+}
+
+// ConcurrencyPerIP limits each client IP to limit requests in flight at
+// once, answering 429 to any request beyond that. It protects the server
+// from a single client tying up workers with many slow requests. The slot
+// is released as soon as the request completes, even if a handler panics.
+func ConcurrencyPerIP(limit int) gin.HandlerFunc {
+	limiter := &ipConcurrency{inFlight: make(map[string]int), limit: limit}
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		if !limiter.acquire(ip) {
+			logger.WarningLogger.Printf("Too many concurrent requests from %s, limit is %d\n", ip, limit)
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"message": "Too many concurrent requests, please retry shortly",
+			})
+			return
+		}
+		defer limiter.release(ip)
+
+		c.Next()
+	}
+}
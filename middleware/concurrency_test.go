@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConcurrencyPerIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	r := gin.New()
+	r.Use(ConcurrencyPerIP(2))
+	r.GET("/slow", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-unblock
+		c.Status(http.StatusOK)
+	})
+	r.GET("/fast", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	get := func(path, ip string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// Fill both slots of one client with requests that stay in flight.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code := get("/slow", "192.0.2.1"); code != http.StatusOK {
+				t.Errorf("in-flight request status = %d, want %d", code, http.StatusOK)
+			}
+		}()
+		<-entered
+	}
+
+	if code := get("/fast", "192.0.2.1"); code != http.StatusTooManyRequests {
+		t.Errorf("third request status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := get("/fast", "192.0.2.2"); code != http.StatusOK {
+		t.Errorf("other client status = %d, want %d", code, http.StatusOK)
+	}
+
+	close(unblock)
+	wg.Wait()
+	if code := get("/fast", "192.0.2.1"); code != http.StatusOK {
+		t.Errorf("status after completion = %d, want %d", code, http.StatusOK)
+	}
+}
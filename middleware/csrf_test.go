@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CSRF("/exempt"))
+	ok := func(c *gin.Context) {
+		c.String(http.StatusOK, CSRFToken(c))
+	}
+	r.GET("/form", ok)
+	r.POST("/form", ok)
+	r.POST("/exempt", ok)
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		header      map[string]string
+		want        int
+	}{
+		{"form without token", "/form", "application/x-www-form-urlencoded", "name=x", nil, http.StatusForbidden},
+		{"form with wrong token", "/form", "application/x-www-form-urlencoded", "csrf_token=wrong", nil, http.StatusForbidden},
+		{"form with token field", "/form", "application/x-www-form-urlencoded", "csrf_token=the-token", nil, http.StatusOK},
+		{"form with token header", "/form", "multipart/form-data; boundary=x", "", map[string]string{"X-CSRF-Token": "the-token"}, http.StatusOK},
+		{"text/plain without token", "/form", "text/plain", "name=x", nil, http.StatusForbidden},
+		{"JSON", "/form", "application/json", "{}", nil, http.StatusOK},
+		{"bearer token", "/form", "application/x-www-form-urlencoded", "name=x", map[string]string{"Authorization": "Bearer abc"}, http.StatusOK},
+		{"API key", "/form", "application/x-www-form-urlencoded", "name=x", map[string]string{APIKeyHeader: "abc"}, http.StatusOK},
+		{"exempt path", "/exempt", "application/x-www-form-urlencoded", "name=x", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.AddCookie(&http.Cookie{Name: "csrf-token", Value: "the-token"})
+			for key, value := range tt.header {
+				req.Header.Set(key, value)
+			}
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSRFIssuesToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CSRF("/exempt"))
+	r.GET("/form", func(c *gin.Context) {
+		c.String(http.StatusOK, CSRFToken(c))
+	})
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/form", nil))
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "csrf-token" || cookies[0].Value == "" {
+		t.Fatalf("cookies = %v, want a csrf-token cookie", cookies)
+	}
+	if recorder.Body.String() != cookies[0].Value {
+		t.Errorf("CSRFToken() = %q, want the cookie's token %q", recorder.Body, cookies[0].Value)
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Drainer tracks the requests in flight and, once draining, refuses new
+// ones so that the server can shut down after the current ones finish.
+type Drainer struct {
+	inFlight atomic.Int64
+	draining atomic.Bool
+}
+
+// NewDrainer returns a Drainer that accepts requests until Drain is called.
+func NewDrainer() *Drainer {
+	return &Drainer{}
+}
+
+// Middleware counts each request while it runs and answers 503 with
+// "Connection: close" to requests arriving after Drain, telling load
+// balancers and clients to go elsewhere.
+func (d *Drainer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d.draining.Load() {
+			c.Header("Connection", "close")
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"success": false,
+				"message": "The server is shutting down, please retry",
+			})
+			return
+		}
+		d.inFlight.Add(1)
+		defer d.inFlight.Add(-1)
+
+		c.Next()
+	}
+}
+
+// Drain makes the middleware refuse all further requests.
+func (d *Drainer) Drain() {
+	d.draining.Store(true)
+}
+
+// InFlight returns the number of requests currently being handled.
+func (d *Drainer) InFlight() int64 {
+	return d.inFlight.Load()
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDrainer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	drainer := NewDrainer()
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	r := gin.New()
+	r.Use(drainer.Middleware())
+	r.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-finish
+		c.Status(http.StatusOK)
+	})
+	r.GET("/fast", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	slow := make(chan int)
+	go func() {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		slow <- recorder.Code
+	}()
+	<-started
+	if n := drainer.InFlight(); n != 1 {
+		t.Errorf("InFlight() = %d, want 1", n)
+	}
+
+	drainer.Drain()
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Connection") != "close" {
+		t.Errorf("new request while draining got %d, Connection %q, want 503 and close", recorder.Code, recorder.Header().Get("Connection"))
+	}
+
+	// The request already in flight still completes
+	close(finish)
+	if code := <-slow; code != http.StatusOK {
+		t.Errorf("in-flight request status = %d, want %d", code, http.StatusOK)
+	}
+	if n := drainer.InFlight(); n != 0 {
+		t.Errorf("InFlight() = %d after the request finished, want 0", n)
+	}
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compressibleTypes are the content types worth compressing. Images, PDFs
+// and spreadsheets are already compressed and are sent as they are.
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// gzipWriter compresses the response body, deciding on the first write once
+// the handler has set the Content-Type.
+type gzipWriter struct {
+	gin.ResponseWriter
+	level   int
+	decided bool
+	gz      *gzip.Writer
+}
+
+// decide starts compressing if the response is of a compressible type and
+// not already encoded.
+func (w *gzipWriter) decide(data []byte) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" && len(data) > 0 {
+		contentType = http.DetectContentType(data)
+		header.Set("Content-Type", contentType)
+	}
+	if !isCompressible(contentType) {
+		return
+	}
+
+	// The length of the compressed body is not known up front
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", "gzip")
+	w.gz, _ = gzip.NewWriterLevel(w.ResponseWriter, w.level)
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	w.decide(data)
+	if w.gz == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.gz.Write(data)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow sends the headers before any body was written, e.g. for a
+// response without a body, so the response is not compressed.
+func (w *gzipWriter) WriteHeaderNow() {
+	w.decided = true
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Flush sends everything compressed so far, for streamed responses.
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Gzip compresses responses at level (1 to 9) for clients that accept gzip.
+// Only textual content types are compressed, and skip lists route paths, as
+// returned by c.FullPath(), that are never compressed, such as binary
+// downloads.
+func Gzip(level int, skip map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept-Encoding")
+		if skip[c.FullPath()] || c.Request.Method == http.MethodHead ||
+			!strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+			c.Next()
+			return
+		}
+
+		writer := &gzipWriter{ResponseWriter: c.Writer, level: level}
+		c.Writer = writer
+		defer func() {
+			if writer.gz != nil {
+				writer.gz.Close()
+			}
+			c.Writer = writer.ResponseWriter
+		}()
+
+		c.Next()
+	}
+}
+
+func isCompressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGzip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	text := strings.Repeat("asset ", 200)
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+	r := gin.New()
+	r.Use(Gzip(gzip.BestSpeed, map[string]bool{"/devices/:id/qr": true}))
+	r.GET("/text", func(c *gin.Context) {
+		c.String(http.StatusOK, text)
+	})
+	r.GET("/devices/:id/qr", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/plain", []byte(text))
+	})
+	r.GET("/image", func(c *gin.Context) {
+		c.Data(http.StatusOK, "image/png", png)
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantGzip       bool
+		want           []byte
+	}{
+		{"text", "/text", "gzip, deflate", true, []byte(text)},
+		{"client without gzip", "/text", "", false, []byte(text)},
+		{"opted-out route", "/devices/7/qr", "gzip", false, []byte(text)},
+		{"binary content", "/image", "gzip", false, png},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			body := recorder.Body.Bytes()
+			if gzipped := recorder.Header().Get("Content-Encoding") == "gzip"; gzipped != tt.wantGzip {
+				t.Fatalf("Content-Encoding = %q, want gzip %t", recorder.Header().Get("Content-Encoding"), tt.wantGzip)
+			}
+			if tt.wantGzip {
+				if recorder.Header().Get("Content-Length") != "" {
+					t.Errorf("Content-Length = %s on a compressed response", recorder.Header().Get("Content-Length"))
+				}
+				gz, err := gzip.NewReader(recorder.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(gz); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(body, tt.want) {
+				t.Errorf("body = %.40q..., want %.40q...", body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+// ipAllowed reports whether ip may pass: an address in allow always does,
+// otherwise it is refused when allow is not empty or when it is in deny.
+func ipAllowed(ip net.IP, allow, deny []*net.IPNet) bool {
+	if ip == nil {
+		return len(allow) == 0 && len(deny) == 0
+	}
+	if containsIP(allow, ip) {
+		return true
+	}
+	return len(allow) == 0 && !containsIP(deny, ip)
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPFilter answers 403 to clients whose IP is not allowed by the allow and
+// deny lists. The client IP is taken from c.ClientIP, so X-Forwarded-For
+// only counts when sent by a trusted proxy.
+func IPFilter(allow, deny []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		if !ipAllowed(net.ParseIP(ip), allow, deny) {
+			logger.WarningLogger.Printf("Refused %s %s from %s, not an allowed IP\n", c.Request.Method, c.Request.URL.Path, ip)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "Access from your IP address is not allowed",
+			})
+			return
+		}
+		c.Next()
+	}
+}
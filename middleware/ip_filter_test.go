@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cidrs parses ranges, failing the test on a malformed one.
+func cidrs(t *testing.T, ranges ...string) []*net.IPNet {
+	t.Helper()
+	nets := make([]*net.IPNet, 0, len(ranges))
+	for _, r := range ranges {
+		_, ipNet, err := net.ParseCIDR(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+func TestIPFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		allow, deny  []string
+		remoteAddr   string
+		forwardedFor string
+		want         int
+	}{
+		{"no restriction", nil, nil, "203.0.113.9", "", http.StatusOK},
+		{"in the allowlist", []string{"10.0.0.0/8"}, nil, "10.20.30.40", "", http.StatusOK},
+		{"outside the allowlist", []string{"10.0.0.0/8"}, nil, "11.0.0.1", "", http.StatusForbidden},
+		{"in the denylist", nil, []string{"203.0.113.0/24"}, "203.0.113.9", "", http.StatusForbidden},
+		{"outside the denylist", nil, []string{"203.0.113.0/24"}, "203.0.114.1", "", http.StatusOK},
+		{"allowlist wins", []string{"203.0.113.9/32"}, []string{"203.0.113.0/24"}, "203.0.113.9", "", http.StatusOK},
+		{"IPv6", []string{"2001:db8::/32"}, nil, "[2001:db8::1]", "", http.StatusOK},
+		{"via trusted proxy", []string{"10.0.0.0/8"}, nil, "192.0.2.1", "10.1.1.1", http.StatusOK},
+		{"forged via untrusted client", []string{"10.0.0.0/8"}, nil, "198.51.100.7", "10.1.1.1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			if err := r.SetTrustedProxies([]string{"192.0.2.1"}); err != nil {
+				t.Fatal(err)
+			}
+			r.GET("/admin", IPFilter(cidrs(t, tt.allow...), cidrs(t, tt.deny...)), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.RemoteAddr = tt.remoteAddr + ":4321"
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
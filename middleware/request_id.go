@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/vikash-parashar/asset-locator/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRequestIDLength bounds request ids accepted from clients.
+const maxRequestIDLength = 128
+
+// RequestID gives every request an id, taken from the incoming request id
+// header when it is present and sane, or generated otherwise. The id is
+// echoed in the response, stored as "request_id" in the gin context, and
+// forwarded with a W3C traceparent on outbound calls made with the request
+// context.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := utils.RequestIDHeader()
+		id := c.GetHeader(header)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Set("request_id", id)
+		c.Header(header, id)
+		ctx := utils.WithCorrelation(c.Request.Context(), id, c.GetHeader("traceparent"))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// validRequestID accepts ids of printable ASCII characters only, so a
+// client cannot inject anything into the logs or outbound headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
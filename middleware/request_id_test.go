@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/utils"
+)
+
+func TestRequestIDPropagatesToOutboundCalls(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var outboundID string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outboundID = r.Header.Get("X-Request-ID")
+	}))
+	defer upstream.Close()
+
+	client := utils.NewOutboundClient(time.Second)
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/notify", func(c *gin.Context) {
+		req, _ := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, upstream.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		want     string
+	}{
+		{"from client", "abc-123", "abc-123"},
+		{"generated", "", ""},
+		{"unsafe", "bad id\r\nX-Injected: 1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/notify", nil)
+			if tt.incoming != "" {
+				req.Header.Set("X-Request-ID", tt.incoming)
+			}
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			id := recorder.Header().Get("X-Request-ID")
+			if tt.want != "" && id != tt.want {
+				t.Errorf("response id = %q, want %q", id, tt.want)
+			}
+			if (tt.want == "" && len(id) != 32) || strings.ContainsAny(id, " \r\n") {
+				t.Errorf("response id = %q, want a generated id", id)
+			}
+			if outboundID != id {
+				t.Errorf("outbound id = %q, want %q", outboundID, id)
+			}
+		})
+	}
+}
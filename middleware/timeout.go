@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timeoutWriter buffers a handler's response so that nothing reaches the
+// client until the handler finishes in time. Writes after the deadline are
+// discarded.
+type timeoutWriter struct {
+	gin.ResponseWriter
+
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	code     int
+	written  bool
+	timedOut bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.written {
+		return
+	}
+	w.code = code
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written = true
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) Status() int {
+	return w.code
+}
+
+func (w *timeoutWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *timeoutWriter) Written() bool {
+	return w.written
+}
+
+// Flush is a no-op: the response is only sent once the handler is done.
+func (w *timeoutWriter) Flush() {}
+
+// Timeout bounds every request by a deadline. The request context is
+// cancelled at the deadline, and if the handler has not finished by then the
+// client receives a 503 instead of the buffered response. overrides maps a
+// route path, as returned by c.FullPath(), to its own timeout for routes that
+// are intentionally slow. A non-positive timeout disables the limit.
+func Timeout(timeout time.Duration, overrides map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := timeout
+		if override, ok := overrides[c.FullPath()]; ok {
+			limit = override
+		}
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), limit)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		original := c.Writer
+		buffered := &timeoutWriter{ResponseWriter: original, header: make(http.Header), code: http.StatusOK}
+		c.Writer = buffered
+
+		done := make(chan struct{})
+		var panicValue interface{}
+		go func() {
+			defer func() {
+				panicValue = recover()
+				close(done)
+			}()
+			c.Next()
+		}()
+
+		select {
+		case <-done:
+			c.Writer = original
+			if panicValue != nil {
+				panic(panicValue)
+			}
+			for key, values := range buffered.header {
+				original.Header()[key] = values
+			}
+			original.WriteHeader(buffered.code)
+			original.Write(buffered.body.Bytes())
+
+		case <-ctx.Done():
+			buffered.mu.Lock()
+			buffered.timedOut = true
+			buffered.mu.Unlock()
+
+			logger.WarningLogger.Printf("Request %s %s timed out after %s\n", c.Request.Method, c.Request.URL.Path, limit)
+			body := []byte(`{"success":false,"message":"Request timed out"}`)
+			original.Header().Set("Content-Type", "application/json; charset=utf-8")
+			original.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			original.WriteHeader(http.StatusServiceUnavailable)
+			original.Write(body)
+			original.Flush()
+
+			// The handler still owns the gin context; wait for it to notice the
+			// cancelled context before the context is returned to the pool.
+			<-done
+			c.Writer = original
+		}
+	}
+}
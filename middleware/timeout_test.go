@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// slow answers after 50ms, or when the request is cancelled
+	slow := func(c *gin.Context) {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			c.Header("X-Answered", "yes")
+			c.String(http.StatusCreated, "done")
+		case <-c.Request.Context().Done():
+		}
+	}
+	r := gin.New()
+	r.Use(Timeout(10*time.Millisecond, map[string]time.Duration{"/export": time.Second}))
+	r.GET("/slow", slow)
+	r.GET("/export", slow)
+	r.GET("/fast", func(c *gin.Context) {
+		c.String(http.StatusOK, "fast")
+	})
+
+	tests := []struct {
+		path string
+		want int
+		body string
+	}{
+		{"/fast", http.StatusOK, "fast"},
+		{"/slow", http.StatusServiceUnavailable, "Request timed out"},
+		{"/export", http.StatusCreated, "done"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if recorder.Code != tt.want || !strings.Contains(recorder.Body.String(), tt.body) {
+				t.Errorf("got %d %s, want %d %s", recorder.Code, recorder.Body, tt.want, tt.body)
+			}
+			if tt.want == http.StatusCreated && recorder.Header().Get("X-Answered") != "yes" {
+				t.Error("the handler's headers were not copied to the response")
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package models
+
+// Actions recorded in the audit log.
+const (
+	AuditAPIKeysRotated = "api_keys.rotated"
+	AuditUserUnlocked   = "user.unlocked"
+)
@@ -0,0 +1,8 @@
+package models
+
+// FacetCount is a distinct column value and the number of rows holding it,
+// used to populate filter dropdowns.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// AssetKPIPeriod counts the devices bought, by purchase order date, and
+// retired, by end of support life, in the period starting at Start.
+type AssetKPIPeriod struct {
+	Start   time.Time `json:"start"`
+	Added   int       `json:"added"`
+	Retired int       `json:"retired"`
+}
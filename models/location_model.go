@@ -1,16 +1,65 @@
 package models
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Approval statuses of a device. Devices are approved unless approval is
+// required, in which case new and moved devices are pending until an admin
+// reviews them.
+const (
+	DeviceApproved        = "approved"
+	DevicePendingApproval = "pending_approval"
+	DeviceRejected        = "rejected"
+)
+
 type DeviceLocationDetail struct {
-	Id               int    `json:"id"`
-	SerialNumber     string `json:"serial_number"`
-	DeviceMakeModel  string `json:"device_make_model"`
-	Model            string `json:"model"`
-	DeviceType       string `json:"device_type"`
-	DataCenter       string `json:"data_center"`
-	Region           string `json:"region"`
-	DCLocation       string `json:"dc_location"`
-	DeviceLocation   string `json:"device_location"`
-	DeviceRowNumber  int    `json:"device_row_number"`
-	DeviceRackNumber int    `json:"device_rack_number"`
-	DeviceRUNumber   string `json:"device_ru_number"`
+	Id               int          `json:"id"`
+	SerialNumber     string       `json:"serial_number"`
+	DeviceMakeModel  string       `json:"device_make_model"`
+	Model            string       `json:"model"`
+	DeviceType       string       `json:"device_type"`
+	DataCenter       string       `json:"data_center"`
+	Region           string       `json:"region"`
+	DCLocation       string       `json:"dc_location"`
+	DeviceLocation   string       `json:"device_location"`
+	DeviceRowNumber  int          `json:"device_row_number"`
+	DeviceRackNumber int          `json:"device_rack_number"`
+	DeviceRUNumber   string       `json:"device_ru_number"`
+	LastAuditedAt    *time.Time   `json:"last_audited_at,omitempty"`
+	ApprovalStatus   string       `json:"approval_status"`
+	ReviewedBy       *int         `json:"reviewed_by,omitempty"`
+	ReviewedAt       *time.Time   `json:"reviewed_at,omitempty"`
+	CustomFields     CustomFields `json:"custom_fields,omitempty"`
+}
+
+// CustomFields are organization-defined attributes of a device, such as a
+// cost center, stored as a JSON object of strings, numbers and booleans.
+type CustomFields map[string]interface{}
+
+// Value stores the fields as a JSON object.
+func (f CustomFields) Value() (driver.Value, error) {
+	if f == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(f)
+	return string(b), err
+}
+
+// Scan reads the fields from a JSON object.
+func (f *CustomFields) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*f = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, f)
+	case string:
+		return json.Unmarshal([]byte(v), f)
+	default:
+		return fmt.Errorf("cannot scan %T into CustomFields", src)
+	}
 }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Actions a permission may grant on a resource type.
+const (
+	ActionRead   = "read"
+	ActionWrite  = "write"
+	ActionDelete = "delete"
+)
+
+// Resource types whose access is controlled by permissions.
+const (
+	ResourceLocation = "location"
+	ResourceOwner    = "owner"
+	ResourcePower    = "power"
+	ResourceFiber    = "fiber"
+	ResourceUser     = "user"
+)
+
+// Role is a named set of permissions assigned to users. Permissions are
+// named resource:action, e.g. location:delete.
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -6,6 +6,13 @@ const (
 	UserRoleAdmin   = "admin"
 	UserRoleGeneral = "general"
 )
+
+// API key scopes. A read key may only make GET requests; a write key may
+// do anything its user may.
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeWrite = "write"
+)
 const (
 	DeviceTypeServer        = "Server"
 	DeviceTypeObjectStorage = "Object Storage"
@@ -38,4 +45,83 @@ type User struct {
 	ResetTokenExpiry time.Time `json:"reset_token_expiry"`
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
+
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+	// PasswordExpired is computed at login from PasswordChangedAt and the
+	// configured maximum password age; it is not stored.
+	PasswordExpired bool `json:"password_expired"`
+	// TokenVersion is embedded in issued tokens; bumping it revokes them all.
+	TokenVersion int `json:"-"`
+	// LockedUntil is set while password logins are refused after too many
+	// failed attempts.
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+// UserSecurity is the login lockout state of a user, as shown to admins.
+type UserSecurity struct {
+	UserID int `json:"user_id"`
+	// FailedLogins counts the failed password logins since the last
+	// successful one.
+	FailedLogins int `json:"failed_logins"`
+	// Locked is set while LockedUntil is in the future.
+	Locked      bool       `json:"locked"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+// NotificationPreferences are the optional emails a user receives. Emails
+// needed to get into the account, such as password reset and login links,
+// are always sent.
+type NotificationPreferences struct {
+	// LoginAlerts reports logins from a device the user has not logged in
+	// from before.
+	LoginAlerts bool `json:"login_alerts"`
+}
+
+// DefaultNotificationPreferences are the preferences of a user who has not
+// changed them: every alert is sent.
+func DefaultNotificationPreferences() NotificationPreferences {
+	return NotificationPreferences{LoginAlerts: true}
+}
+
+// Passkey is a WebAuthn credential a user registered for passwordless
+// login. Only the name and dates are shown to the user.
+type Passkey struct {
+	ID           int        `json:"id"`
+	UserID       int        `json:"-"`
+	CredentialID []byte     `json:"-"`
+	PublicKey    []byte     `json:"-"`
+	SignCount    uint32     `json:"-"`
+	Name         string     `json:"name"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+}
+
+// APIKey lets scripts and integrations act as a user with the X-API-Key
+// header. Only a hash of the key is stored; Prefix identifies it to the
+// user.
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// Session is a login of a user on one device. Revoking it signs that
+// device out.
+type Session struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"-"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session the request listing the sessions was made
+	// with.
+	Current bool `json:"current"`
 }
@@ -1,71 +1,369 @@
 package routes
 
 import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/authz"
+	"github.com/vikash-parashar/asset-locator/config"
 	"github.com/vikash-parashar/asset-locator/db"
 	"github.com/vikash-parashar/asset-locator/handlers"
 	"github.com/vikash-parashar/asset-locator/middleware"
+	"github.com/vikash-parashar/asset-locator/models"
+	"github.com/vikash-parashar/asset-locator/utils"
 )
 
-func SetupRoutes(r *gin.Engine, dbConn *db.DB) {
+// customFieldsMaxBytes limits the body of a custom fields update, keeping
+// clients from sending large or deeply nested JSON.
+const customFieldsMaxBytes = 16 << 10
+
+// SetupRoutes registers every route on r. It fails if a configured
+// integration, such as SAML, cannot be set up.
+func SetupRoutes(r *gin.Engine, dbConn *db.DB, cfg *config.Config) error {
+	noRoute(r, cfg)
+
+	// Correlate logs and outbound calls with the request
+	r.Use(middleware.RequestID())
+
+	// Keep a single client from tying up the server with slow requests
+	if cfg.MaxConcurrentPerIP > 0 {
+		r.Use(middleware.ConcurrencyPerIP(cfg.MaxConcurrentPerIP))
+	}
+
+	// Compress textual responses; binary downloads are sent as they are
+	gzipSkip := make(map[string]bool)
+	if cfg.GzipLevel > 0 {
+		r.Use(middleware.Gzip(cfg.GzipLevel, gzipSkip))
+	}
+
+	// Bound every request by a deadline; exports get their own, longer one
+	timeoutOverrides := make(map[string]time.Duration)
+	r.Use(middleware.Timeout(cfg.RequestTimeout, timeoutOverrides))
+
+	// Log redacted bodies while debugging client integrations
+	if cfg.LogBodies && !cfg.IsRelease() {
+		r.Use(middleware.BodyLogger(cfg.LogBodyMaxBytes))
+	}
+
+	// Protect cookie-authenticated form submissions against CSRF. The SAML
+	// ACS is posted cross-site by the identity provider; the signed
+	// response answering a request of this browser protects it instead.
+	r.Use(middleware.CSRF("/saml/acs"))
+	defer func() {
+		for _, route := range r.Routes() {
+			if strings.HasSuffix(route.Path, "/pdf") || strings.HasSuffix(route.Path, "/excel") {
+				timeoutOverrides[route.Path] = cfg.ExportRequestTimeout
+				gzipSkip[route.Path] = true
+			}
+			if strings.HasSuffix(route.Path, "/barcode") || strings.HasSuffix(route.Path, "/avatar") || strings.HasSuffix(route.Path, "/labels") {
+				gzipSkip[route.Path] = true
+			}
+			// Streamed exports cannot be buffered by the timeout middleware;
+			// they enforce the export deadline themselves.
+			if strings.HasSuffix(route.Path, "/csv") {
+				timeoutOverrides[route.Path] = 0
+			}
+			// Event streams stay open and must reach the client unbuffered
+			if strings.HasSuffix(route.Path, "/events/assets") {
+				timeoutOverrides[route.Path] = 0
+				gzipSkip[route.Path] = true
+			}
+		}
+	}()
+
 	// Unprotected routes
-	r.GET("/", handlers.RenderIndexPage)
-	r.GET("/signup", handlers.RenderIndexPage)
-	r.GET("/login", handlers.RenderIndexPage)
+	r.GET("/", handlers.RenderIndexPage(cfg))
+	r.GET("/signup", handlers.RenderIndexPage(cfg))
+	r.GET("/login", handlers.RenderIndexPage(cfg))
 	r.GET("/about", handlers.RenderAboutPage)
 	r.GET("/help", handlers.RenderGetHelpPage)
 	r.GET("/health-check", handlers.HealthCheck)
-	r.POST("/signup", handlers.SignUp(dbConn))
+	r.GET("/healthz", handlers.Healthz(dbConn))
+	// The config has been validated, so the banned passwords can be read
+	bannedPasswords, _ := cfg.BannedPasswords()
+	passwordPolicy := utils.NewPasswordPolicy(cfg.PasswordMinLength, cfg.PasswordRequiredClasses, cfg.PasswordMinScore, bannedPasswords)
+	r.POST("/signup", handlers.SignUp(dbConn, cfg, passwordPolicy))
 
-	r.POST("/login", handlers.Login(dbConn))
-	r.POST("/logout", handlers.Logout())
+	r.POST("/login", handlers.Login(dbConn, cfg))
+	r.POST("/logout", handlers.Logout(dbConn))
+	r.GET("/auth/validate", handlers.ValidateToken(dbConn))
+	r.POST("/auth/refresh", handlers.RefreshToken(dbConn, cfg))
+	r.GET("/auth/available", handlers.CheckEmailAvailability(dbConn, cfg))
 	r.GET("/forget-password-page", handlers.RenderForgotPasswordPage)
-	r.POST("/forget-password", handlers.ForgotPassword(dbConn))
-	r.GET("/reset-password", handlers.RenderResetPasswordPage)
-	r.POST("/reset-password", handlers.ResetPassword(dbConn))
+	r.POST("/forget-password", handlers.ForgotPassword(dbConn, cfg))
+	resetSessions := utils.NewResetSessionStore(cfg.ResetSessionTTL)
+	r.GET("/reset-password", handlers.RenderResetPasswordPage(dbConn, resetSessions))
+	r.POST("/reset-password", handlers.ResetPassword(dbConn, cfg, resetSessions, passwordPolicy))
+	r.POST("/auth/magic-link", handlers.RequestMagicLink(dbConn, cfg))
+	r.GET("/auth/magic", handlers.MagicLogin(dbConn, cfg))
+	passkeyChallenges := utils.NewPasskeyChallengeStore(cfg.PasskeyChallengeTTL, cfg.PasskeyMaxChallenges)
+	r.POST("/auth/passkey/begin", handlers.BeginPasskeyLogin(cfg, passkeyChallenges))
+	r.POST("/auth/passkey/finish", handlers.FinishPasskeyLogin(dbConn, cfg, passkeyChallenges))
+
+	// Social and single sign-on login with the providers that are configured
+	var oauthProviders []*utils.OAuthProvider
+	if cfg.GoogleClientID != "" {
+		oauthProviders = append(oauthProviders, utils.GoogleOAuth(cfg.GoogleClientID, cfg.GoogleClientSecret))
+	}
+	if cfg.GitHubClientID != "" {
+		oauthProviders = append(oauthProviders, utils.GitHubOAuth(cfg.GitHubClientID, cfg.GitHubClientSecret))
+	}
+	if cfg.OIDCIssuer != "" {
+		oauthProviders = append(oauthProviders, utils.OIDC(utils.OIDCOptions{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			DisplayName:  cfg.OIDCDisplayName,
+			Scopes:       cfg.OIDCScopes,
+			RoleClaim:    cfg.OIDCRoleClaim,
+			AdminRoles:   cfg.OIDCAdminRoles,
+		}))
+	}
+	for _, provider := range oauthProviders {
+		r.GET("/auth/"+provider.Name, handlers.OAuthLogin(cfg, provider))
+		r.GET("/auth/"+provider.Name+"/callback", handlers.OAuthCallback(dbConn, cfg, provider))
+	}
+	if cfg.SAMLEnabled() {
+		samlSP, err := newSAMLServiceProvider(cfg)
+		if err != nil {
+			return fmt.Errorf("invalid SAML configuration: %w", err)
+		}
+		r.GET("/saml/metadata", handlers.SAMLMetadata(samlSP))
+		r.GET("/saml/login", handlers.SAMLLogin(cfg, samlSP))
+		r.POST("/saml/acs", handlers.SAMLACS(dbConn, cfg, samlSP))
+	}
 
-	// Protected routes
-	protected := r.Group("/api/v1", middleware.AuthMiddleware("admin", "general"))
+	// Protected routes. Browser pages and their form submissions form the
+	// web group, everything else the API group; each accepts the token the
+	// way its auth mode is configured.
+	requireAuth := middleware.RequireAuth(dbConn, middleware.AuthMode(cfg.APIAuthMode))
+	web := r.Group("/api/v1", middleware.RequireAuth(dbConn, middleware.AuthMode(cfg.WebAuthMode)))
+	protected := r.Group("/api/v1", requireAuth)
+
+	// Users whose password has expired are refused by the groups above
+	// until they change it here
+	passwordChange := r.Group("/api/v1", middleware.RequireAuthExpiredPassword(dbConn, middleware.AuthMode(cfg.APIAuthMode)))
+
+	// Routes on the inventory require the permission for their resource
+	// type and action from the user's role
+	policy := authz.NewPolicy(dbConn)
+	can := func(resource, action string) gin.HandlerFunc {
+		return middleware.RequirePermission(policy, resource, action)
+	}
+
+	// Limit for form uploads creating device records
+	uploadLimit := middleware.MaxBodySize(cfg.MaxUploadBytes)
+
+	// Destructive and bulk actions are not open to brand-new accounts
+	established := middleware.MinAccountAge(cfg.MinAccountAge)
 
 	// Homepage
-	protected.GET("/homepage", handlers.RenderHomePage(dbConn))
+	web.GET("/homepage", handlers.RenderHomePage(dbConn))
 
 	// for fetching disk details from external server
-	protected.GET("/disk-details", handlers.FetchDisks)
+	protected.GET("/disk-details", can(models.ResourceLocation, models.ActionRead), handlers.FetchDisks)
 
 	// User
-	protected.GET("/get-current-user", handlers.GetCurrentUser(dbConn))
+	protected.GET("/get-current-user", handlers.GetCurrentUser())
+	protected.POST("/me/logout-all", handlers.LogoutAll(dbConn))
+	protected.PUT("/me/profile", handlers.UpdateProfile(dbConn))
+	passwordChange.POST("/me/password", middleware.RequireSession(), handlers.ChangePassword(dbConn, cfg, passwordPolicy))
+	protected.GET("/me/notifications", handlers.GetNotificationPreferences(dbConn))
+	protected.PUT("/me/notifications", handlers.UpdateNotificationPreferences(dbConn))
+	protected.GET("/me/passkeys", handlers.GetPasskeys(dbConn))
+	protected.POST("/me/passkeys/begin", handlers.BeginPasskeyRegistration(dbConn, cfg, passkeyChallenges))
+	protected.POST("/me/passkeys/finish", handlers.FinishPasskeyRegistration(dbConn, cfg, passkeyChallenges))
+	protected.DELETE("/me/passkeys/:id", handlers.DeletePasskey(dbConn))
+
+	// The devices the user is logged in on; DELETE without an id signs out
+	// all but the current one
+	sessions := protected.Group("/me/sessions", middleware.RequireSession())
+	sessions.GET("", handlers.GetSessions(dbConn))
+	sessions.DELETE("", handlers.RevokeOtherSessions(dbConn))
+	sessions.DELETE("/:id", handlers.RevokeSession(dbConn))
+
+	// API keys for scripts and integrations, managed from a login session
+	apiKeys := protected.Group("/keys", middleware.RequireSession())
+	apiKeys.GET("", handlers.GetAPIKeys(dbConn))
+	apiKeys.POST("", handlers.CreateAPIKey(dbConn, cfg))
+	apiKeys.POST("/rotate", handlers.RotateAPIKeys(dbConn, cfg))
+	apiKeys.GET("/:id", handlers.GetAPIKey(dbConn))
+	apiKeys.PATCH("/:id", handlers.UpdateAPIKey(dbConn))
+	apiKeys.DELETE("/:id", handlers.DeleteAPIKey(dbConn))
+
+	protected.POST("/me/avatar", middleware.MaxBodySize(cfg.MaxAvatarBytes), handlers.UploadAvatar(cfg))
+	protected.GET("/users/:id/avatar", handlers.GetUserAvatar(dbConn, cfg))
 
 	// Location Details
-	protected.GET("/location-details", handlers.GetLocationDetails(dbConn))
-	protected.POST("/location-details", handlers.CreateNewLocationDetails(dbConn))
-	protected.PATCH("/location-details/:id", handlers.UpdateDeviceLocationDetail(dbConn))
-	protected.DELETE("/location-details/:id", handlers.DeleteDeviceLocationDetail(dbConn))
-	protected.GET("/location-details/pdf", handlers.DownloadDeviceLocationDetailPDF(dbConn))
-	protected.GET("/location-details/excel", handlers.DownloadDeviceLocationDetail(dbConn))
+	web.GET("/location-details", can(models.ResourceLocation, models.ActionRead), handlers.GetLocationDetails(dbConn))
+	web.POST("/location-details", can(models.ResourceLocation, models.ActionWrite), uploadLimit, handlers.CreateNewLocationDetails(dbConn))
+	protected.PATCH("/location-details/:id", can(models.ResourceLocation, models.ActionWrite), handlers.UpdateDeviceLocationDetail(dbConn))
+	protected.DELETE("/location-details/:id", can(models.ResourceLocation, models.ActionDelete), established, handlers.DeleteDeviceLocationDetail(dbConn))
+	protected.GET("/location-details/pdf", can(models.ResourceLocation, models.ActionRead), handlers.DownloadDeviceLocationDetailPDF(dbConn))
+	protected.GET("/location-details/excel", can(models.ResourceLocation, models.ActionRead), handlers.DownloadDeviceLocationDetail(dbConn))
+	protected.GET("/location-details/csv", can(models.ResourceLocation, models.ActionRead), handlers.DownloadDeviceLocationDetailCSV(dbConn, cfg))
 
 	// Owner Details
-	protected.GET("/owner-details", handlers.GetOwnerDetails(dbConn))
-	protected.POST("/owner-details", handlers.CreateNewOwnerDetails(dbConn))
-	protected.PATCH("/owner-details/:id", handlers.UpdateDeviceAMCOwnerDetail(dbConn))
-	protected.DELETE("/owner-details/:id", handlers.DeleteDeviceAMCOwnerDetail(dbConn))
-	protected.GET("/owner-details/pdf", handlers.DownloadDeviceAMCOwnerDetailPDF(dbConn))
-	protected.GET("/owner-details/excel", handlers.DownloadDeviceAMCOwnerDetail(dbConn))
+	web.GET("/owner-details", can(models.ResourceOwner, models.ActionRead), handlers.GetOwnerDetails(dbConn))
+	web.POST("/owner-details", can(models.ResourceOwner, models.ActionWrite), uploadLimit, handlers.CreateNewOwnerDetails(dbConn))
+	protected.PATCH("/owner-details/:id", can(models.ResourceOwner, models.ActionWrite), handlers.UpdateDeviceAMCOwnerDetail(dbConn))
+	protected.DELETE("/owner-details/:id", can(models.ResourceOwner, models.ActionDelete), established, handlers.DeleteDeviceAMCOwnerDetail(dbConn))
+	protected.GET("/owner-details/pdf", can(models.ResourceOwner, models.ActionRead), handlers.DownloadDeviceAMCOwnerDetailPDF(dbConn))
+	protected.GET("/owner-details/excel", can(models.ResourceOwner, models.ActionRead), handlers.DownloadDeviceAMCOwnerDetail(dbConn))
 
 	// Power Details
-	protected.GET("/power-details", handlers.GetPowerDetails(dbConn))
-	protected.POST("/power-details", handlers.CreateNewPowerDetails(dbConn))
-	protected.PATCH("/power-details/:id", handlers.UpdateDevicePowerDetail(dbConn))
-	protected.DELETE("/power-details/:id", handlers.DeleteDevicePowerDetail(dbConn))
-	protected.GET("/power-details/pdf", handlers.DownloadDevicePowerDetailPDF(dbConn))
-	protected.GET("/power-details/excel", handlers.DownloadDevicePowerDetail(dbConn))
+	web.GET("/power-details", can(models.ResourcePower, models.ActionRead), handlers.GetPowerDetails(dbConn))
+	web.POST("/power-details", can(models.ResourcePower, models.ActionWrite), uploadLimit, handlers.CreateNewPowerDetails(dbConn))
+	protected.PATCH("/power-details/:id", can(models.ResourcePower, models.ActionWrite), handlers.UpdateDevicePowerDetail(dbConn))
+	protected.DELETE("/power-details/:id", can(models.ResourcePower, models.ActionDelete), established, handlers.DeleteDevicePowerDetail(dbConn))
+	protected.GET("/power-details/pdf", can(models.ResourcePower, models.ActionRead), handlers.DownloadDevicePowerDetailPDF(dbConn))
+	protected.GET("/power-details/excel", can(models.ResourcePower, models.ActionRead), handlers.DownloadDevicePowerDetail(dbConn))
 
 	// Fiber Details
-	protected.GET("/fiber-details", handlers.GetFiberDetails(dbConn))
-	protected.GET("/fiber-details/:id", handlers.GetFiberDetailByID(dbConn))
-	protected.POST("/fiber-details", handlers.CreateNewFiberDetails(dbConn))
-	protected.PATCH("/fiber-details/:id", handlers.UpdateDeviceEthernetFiberDetail(dbConn))
-	protected.DELETE("/fiber-details/:id", handlers.DeleteDeviceEthernetFiberDetail(dbConn))
-	protected.GET("/fiber-details/pdf", handlers.DownloadDeviceEthernetFiberDetailPDF(dbConn))
-	protected.GET("/fiber-details/excel", handlers.DownloadDeviceEthernetFiberDetail(dbConn))
+	web.GET("/fiber-details", can(models.ResourceFiber, models.ActionRead), handlers.GetFiberDetails(dbConn))
+	protected.GET("/fiber-details/:id", can(models.ResourceFiber, models.ActionRead), handlers.GetFiberDetailByID(dbConn))
+	web.POST("/fiber-details", can(models.ResourceFiber, models.ActionWrite), uploadLimit, handlers.CreateNewFiberDetails(dbConn))
+	protected.PATCH("/fiber-details/:id", can(models.ResourceFiber, models.ActionWrite), handlers.UpdateDeviceEthernetFiberDetail(dbConn))
+	protected.DELETE("/fiber-details/:id", can(models.ResourceFiber, models.ActionDelete), established, handlers.DeleteDeviceEthernetFiberDetail(dbConn))
+	protected.GET("/fiber-details/pdf", can(models.ResourceFiber, models.ActionRead), handlers.DownloadDeviceEthernetFiberDetailPDF(dbConn))
+	protected.GET("/fiber-details/excel", can(models.ResourceFiber, models.ActionRead), handlers.DownloadDeviceEthernetFiberDetail(dbConn))
+
+	// Devices
+	protected.GET("/devices/categories", can(models.ResourceLocation, models.ActionRead), handlers.GetDeviceCategories(dbConn))
+	protected.GET("/locations", can(models.ResourceLocation, models.ActionRead), handlers.GetDeviceLocations(dbConn))
+	protected.GET("/devices/stocktake", can(models.ResourceLocation, models.ActionRead), handlers.GetStocktake(dbConn, cfg))
+	protected.POST("/devices/labels", can(models.ResourceLocation, models.ActionRead), handlers.DownloadDeviceLabels(dbConn, cfg))
+	protected.GET("/devices/:serial/barcode", can(models.ResourceLocation, models.ActionRead), handlers.GetDeviceBarcode(dbConn, cfg))
+	protected.POST("/devices/:serial/verify", can(models.ResourceLocation, models.ActionWrite), handlers.VerifyDevice(dbConn))
+	protected.PUT("/devices/by-serial/:serial", can(models.ResourceLocation, models.ActionWrite), established, uploadLimit, handlers.UpsertDeviceLocationDetailBySerial(dbConn))
+
+	// Custom fields; the config has been validated, so the schema parses
+	customFields, _ := cfg.CustomFieldSchema()
+	protected.GET("/custom-fields", can(models.ResourceLocation, models.ActionRead), handlers.GetCustomFieldSchema(customFields))
+	protected.GET("/devices", can(models.ResourceLocation, models.ActionRead), handlers.SearchDevices(dbConn, customFields))
+	protected.GET("/location-details/:id/custom-fields", can(models.ResourceLocation, models.ActionRead), handlers.GetDeviceCustomFields(dbConn))
+	protected.PUT("/location-details/:id/custom-fields", can(models.ResourceLocation, models.ActionWrite), middleware.MaxBodySize(customFieldsMaxBytes), handlers.SetDeviceCustomFields(dbConn, customFields))
+
+	// Live device changes as server-sent events
+	protected.GET("/events/assets", can(models.ResourceLocation, models.ActionRead), handlers.StreamDeviceEvents(cfg))
+
+	// Admin-only routes, optionally limited to some client IPs. The config
+	// has been validated, so the lists parse.
+	allowIPs, denyIPs, _ := cfg.AdminIPNets()
+	admin := r.Group("/api/v1", middleware.IPFilter(allowIPs, denyIPs), requireAuth, middleware.RequireRole(models.UserRoleAdmin))
+
+	// Per-dependency health, for operators rather than probes
+	healthDetailed := handlers.HealthDetailed(dbConn, cfg)
+	if cfg.DetailedHealthAdminOnly {
+		r.GET("/health/detailed", middleware.IPFilter(allowIPs, denyIPs), requireAuth, middleware.RequireRole(models.UserRoleAdmin), healthDetailed)
+	} else {
+		r.GET("/health/detailed", healthDetailed)
+	}
+
+	// Users
+	admin.GET("/users", handlers.GetUsersByIDs(dbConn, cfg))
+	admin.POST("/users/import", middleware.MaxBodySize(cfg.MaxImportBytes), handlers.ImportUsers(dbConn))
+	admin.PUT("/users/:id/role", handlers.SetUserRole(dbConn))
+	admin.GET("/users/:id/security", handlers.GetUserSecurity(dbConn))
+	admin.POST("/users/:id/unlock", handlers.UnlockUser(dbConn))
+	admin.POST("/users/:id/keys/rotate", handlers.RotateUserAPIKeys(dbConn, cfg))
+
+	// Roles and the permissions they grant
+	admin.GET("/roles", handlers.GetRoles(dbConn))
+	admin.POST("/roles", handlers.CreateRole(dbConn))
+	admin.PUT("/roles/:name/permissions", handlers.SetRolePermissions(dbConn, policy))
+	admin.DELETE("/roles/:name", handlers.DeleteRole(dbConn, policy))
+	admin.GET("/permissions", handlers.GetPermissions(dbConn))
+
+	// Devices
+	admin.POST("/devices/merge", handlers.MergeDevices(dbConn))
+	admin.GET("/location-details/pending", handlers.GetPendingDevices(dbConn))
+	admin.POST("/location-details/:id/approve", handlers.ReviewDevice(dbConn, true))
+	admin.POST("/location-details/:id/reject", handlers.ReviewDevice(dbConn, false))
+
+	// Table sizes, collected in the background
+	if cfg.StorageStatsInterval > 0 {
+		storageStats := db.NewStorageStatsCollector(dbConn, cfg.StorageStatsInterval)
+		storageStats.Start()
+		admin.GET("/admin/stats/storage", handlers.GetStorageStats(storageStats))
+	}
+
+	// Runtime metrics, e.g. the email queue
+	admin.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+
+	// Dashboard
+	admin.GET("/admin/dashboard", handlers.AdminDashboard(dbConn))
+	admin.GET("/admin/kpis", handlers.AssetKPIs(dbConn))
+
+	// Read-only GraphQL queries over devices and users
+	if cfg.GraphQLEnabled {
+		graphQL, err := handlers.GraphQL(dbConn, policy)
+		if err != nil {
+			return err
+		}
+		protected.GET("/graphql", can(models.ResourceLocation, models.ActionRead), graphQL)
+		protected.POST("/graphql", can(models.ResourceLocation, models.ActionRead), graphQL)
+	}
+	return nil
+}
+
+// newSAMLServiceProvider builds the SAML service provider from cfg.
+func newSAMLServiceProvider(cfg *config.Config) (*utils.SAMLServiceProvider, error) {
+	metadata, err := cfg.SAMLIDPMetadata()
+	if err != nil {
+		return nil, err
+	}
+	key, certificate, err := cfg.SAMLKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	return utils.NewSAMLServiceProvider(utils.SAMLOptions{
+		BaseURL:        cfg.OAuthCallbackBaseURL,
+		EntityID:       cfg.SAMLEntityID,
+		IDPMetadataURL: cfg.SAMLIDPMetadataURL,
+		IDPMetadata:    metadata,
+		Key:            key,
+		Certificate:    certificate,
+		EmailAttribute: cfg.SAMLEmailAttribute,
+		RoleAttribute:  cfg.SAMLRoleAttribute,
+		AdminRoles:     cfg.SAMLAdminRoles,
+	})
+}
+
+// noRoute handles requests that match no registered route.
+//
+// Trailing slashes are normalized so that "/path" and "/path/" behave the
+// same: in "redirect" mode gin answers with a 301 (GET) or 307 redirect to
+// the registered route, in "rewrite" mode the slash is dropped and the
+// request is routed again internally. In SPA mode, remaining GET requests
+// outside /api and /static are served the SPA index so client-side routing
+// works on deep links; API paths always get a JSON 404.
+func noRoute(r *gin.Engine, cfg *config.Config) {
+	r.RedirectTrailingSlash = cfg.TrailingSlashMode != "rewrite"
+
+	r.NoRoute(func(c *gin.Context) {
+		path := c.Request.URL.Path
+		isStatic := strings.HasPrefix(path, "/static/")
+		isAPI := strings.HasPrefix(path, "/api/")
+
+		if cfg.TrailingSlashMode == "rewrite" && len(path) > 1 && strings.HasSuffix(path, "/") && !isStatic {
+			c.Request.URL.Path = strings.TrimRight(path, "/")
+			if c.Request.URL.Path == "" {
+				c.Request.URL.Path = "/"
+			}
+			r.HandleContext(c)
+			return
+		}
+
+		if cfg.SPAMode && !isAPI && !isStatic && (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) {
+			c.File(cfg.SPAIndex)
+			return
+		}
+
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Not found"})
+	})
 }
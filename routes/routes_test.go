@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikash-parashar/asset-locator/config"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestEngine returns an engine with noRoute set up for cfg and a single
+// /api/v1/items route.
+func newTestEngine(cfg *config.Config) *gin.Engine {
+	r := gin.New()
+	items := func(c *gin.Context) {
+		c.String(http.StatusOK, "items")
+	}
+	r.GET("/api/v1/items", items)
+	r.POST("/api/v1/items", items)
+	noRoute(r, cfg)
+	return r
+}
+
+func TestTrailingSlash(t *testing.T) {
+	tests := []struct {
+		mode     string
+		method   string
+		want     int
+		location string
+	}{
+		{"redirect", http.MethodGet, http.StatusMovedPermanently, "/api/v1/items"},
+		{"redirect", http.MethodPost, http.StatusTemporaryRedirect, "/api/v1/items"},
+		{"redirect", http.MethodDelete, http.StatusNotFound, ""},
+		{"rewrite", http.MethodGet, http.StatusOK, ""},
+		{"rewrite", http.MethodPost, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.method, func(t *testing.T) {
+			r := newTestEngine(&config.Config{TrailingSlashMode: tt.mode})
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest(tt.method, "/api/v1/items/", nil))
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.want)
+			}
+			if location := recorder.Header().Get("Location"); location != tt.location {
+				t.Errorf("Location = %q, want %q", location, tt.location)
+			}
+		})
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	index := filepath.Join(t.TempDir(), "index.html")
+	if err := os.WriteFile(index, []byte("<html>app</html>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestEngine(&config.Config{TrailingSlashMode: "redirect", SPAMode: true, SPAIndex: index})
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+		body   string
+	}{
+		{http.MethodGet, "/devices/42", http.StatusOK, "<html>app</html>"},
+		{http.MethodGet, "/api/v1/missing", http.StatusNotFound, `"success":false`},
+		{http.MethodGet, "/static/missing.js", http.StatusNotFound, `"success":false`},
+		{http.MethodPost, "/devices/42", http.StatusNotFound, `"success":false`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, nil))
+			if recorder.Code != tt.want || !strings.Contains(recorder.Body.String(), tt.body) {
+				t.Errorf("got %d %s, want %d %s", recorder.Code, recorder.Body, tt.want, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package utils
+
+import "strings"
+
+// apiKeyPrefix marks API keys, so that they are recognized when leaked,
+// e.g. by secret scanners.
+const apiKeyPrefix = "alk_"
+
+// GenerateAPIKey returns a new API key, the prefix shown to tell it apart
+// from the user's other keys, and the hash under which it is stored.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	token, _, err := GenerateHashedToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	key = apiKeyPrefix + token
+	return key, key[:len(apiKeyPrefix)+8], HashToken(key), nil
+}
+
+// IsAPIKey reports whether key looks like a key from GenerateAPIKey.
+func IsAPIKey(key string) bool {
+	return strings.HasPrefix(key, apiKeyPrefix) && len(key) > len(apiKeyPrefix)+8
+}
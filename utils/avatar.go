@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	_ "image/gif" // register GIF decoding for avatar uploads
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// AvatarSize is the width and height of stored avatar thumbnails.
+const AvatarSize = 128
+
+// ErrUnsupportedImage is returned for uploads that are not PNG, JPEG or GIF.
+var ErrUnsupportedImage = errors.New("avatar must be a PNG, JPEG or GIF image")
+
+var avatarContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+// AvatarThumbnail validates an uploaded image by its content, crops it to a
+// centered square and scales it down to an AvatarSize PNG.
+func AvatarThumbnail(data []byte) ([]byte, error) {
+	if !avatarContentTypes[http.DetectContentType(data)] {
+		return nil, ErrUnsupportedImage
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	bounds := src.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+	square := image.Rect(x0, y0, x0+side, y0+side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, AvatarSize, AvatarSize))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, square, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// avatarColors are the background colors of generated initials avatars.
+var avatarColors = []string{"#1abc9c", "#3498db", "#9b59b6", "#e67e22", "#e74c3c", "#34495e"}
+
+// InitialsAvatarSVG renders a default avatar with the user's initials. The
+// background color is derived from the user id so it stays stable.
+func InitialsAvatarSVG(userID uint, firstName, lastName string) []byte {
+	initials := ""
+	for _, name := range []string{firstName, lastName} {
+		if name = strings.TrimSpace(name); name != "" {
+			initials += strings.ToUpper(string([]rune(name)[0]))
+		}
+	}
+	if initials == "" {
+		initials = "?"
+	}
+
+	color := avatarColors[int(userID)%len(avatarColors)]
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="%[1]d" viewBox="0 0 %[1]d %[1]d">`+
+		`<rect width="100%%" height="100%%" fill="%[2]s"/>`+
+		`<text x="50%%" y="50%%" dy=".35em" text-anchor="middle" font-family="Arial, sans-serif" font-size="%[3]d" fill="#ffffff">%[4]s</text>`+
+		`</svg>`, AvatarSize, color, AvatarSize*2/5, html.EscapeString(initials)))
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestAvatarThumbnail(t *testing.T) {
+	var upload bytes.Buffer
+	if err := png.Encode(&upload, image.NewRGBA(image.Rect(0, 0, 400, 200))); err != nil {
+		t.Fatal(err)
+	}
+	data, err := AvatarThumbnail(upload.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbnail, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := thumbnail.Bounds().Size(); size.X != AvatarSize || size.Y != AvatarSize {
+		t.Errorf("thumbnail is %dx%d, want %dx%d", size.X, size.Y, AvatarSize, AvatarSize)
+	}
+
+	for _, data := range [][]byte{[]byte("<svg></svg>"), []byte("\x89PNG\r\n\x1a\ntruncated")} {
+		if _, err := AvatarThumbnail(data); !errors.Is(err, ErrUnsupportedImage) {
+			t.Errorf("AvatarThumbnail(%q) = %v, want %v", data, err, ErrUnsupportedImage)
+		}
+	}
+}
+
+func TestInitialsAvatarSVG(t *testing.T) {
+	tests := []struct {
+		first, last string
+		want        string
+	}{
+		{"ann", "lee", ">AL</text>"},
+		{"Émile", "", ">É</text>"},
+		{"", "", ">?</text>"},
+		{"<b>", "&", ">&lt;&amp;</text>"},
+	}
+	for _, tt := range tests {
+		if svg := string(InitialsAvatarSVG(1, tt.first, tt.last)); !strings.Contains(svg, tt.want) {
+			t.Errorf("InitialsAvatarSVG(%q, %q) = %s, want it to contain %s", tt.first, tt.last, svg, tt.want)
+		}
+	}
+}
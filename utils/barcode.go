@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/png"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+)
+
+// ErrNotBarcodeSafe is returned for values that cannot be encoded as a
+// linear barcode.
+var ErrNotBarcodeSafe = errors.New("value contains characters that cannot be encoded in a barcode")
+
+// IsBarcodeSafe reports whether value is non-empty printable ASCII, which
+// every supported symbology can encode and scanners reproduce reliably.
+func IsBarcodeSafe(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// EncodePNG scales a barcode or QR code to width x height pixels and encodes
+// it as an 8-bit grayscale PNG, which PDF generators can embed as well.
+func EncodePNG(code barcode.Barcode, width, height int) ([]byte, error) {
+	scaled, err := barcode.Scale(code, width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	gray := image.NewGray(scaled.Bounds())
+	draw.Draw(gray, gray.Bounds(), scaled, scaled.Bounds().Min, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gray); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Code128PNG renders value as a Code128 barcode PNG.
+func Code128PNG(value string, width, height int) ([]byte, error) {
+	if !IsBarcodeSafe(value) {
+		return nil, ErrNotBarcodeSafe
+	}
+	code, err := code128.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return EncodePNG(code, width, height)
+}
+
+// QRCodePNG renders value as a square QR code PNG of size x size pixels,
+// using medium error correction so a partly scuffed label still scans.
+func QRCodePNG(value string, size int) ([]byte, error) {
+	code, err := qr.Encode(value, qr.M, qr.Auto)
+	if err != nil {
+		return nil, err
+	}
+	return EncodePNG(code, size, size)
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"testing"
+)
+
+func TestCode128PNG(t *testing.T) {
+	data, err := Code128PNG("SN-12345", 300, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 300 || size.Y != 100 {
+		t.Errorf("image is %dx%d, want 300x100", size.X, size.Y)
+	}
+
+	if _, err := Code128PNG("SN-12345", 10, 100); err == nil {
+		t.Error("Code128PNG() succeeded with a width too small for the barcode")
+	}
+	for _, value := range []string{"", "SN\n1", "Seriennummer-ä"} {
+		if _, err := Code128PNG(value, 300, 100); !errors.Is(err, ErrNotBarcodeSafe) {
+			t.Errorf("Code128PNG(%q) = %v, want %v", value, err, ErrNotBarcodeSafe)
+		}
+	}
+}
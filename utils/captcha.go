@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Errors returned by VerifyCaptcha for tokens the user must retry.
+var (
+	ErrCaptchaMissing = errors.New("captcha token is missing")
+	ErrCaptchaInvalid = errors.New("captcha token is invalid")
+)
+
+var captchaClient = NewOutboundClient(10 * time.Second)
+
+// VerifyCaptcha checks a client-supplied CAPTCHA token against the provider's
+// siteverify endpoint. reCAPTCHA, hCaptcha and Turnstile share the same
+// protocol. Other errors mean the provider could not be reached.
+func VerifyCaptcha(ctx context.Context, verifyURL, secret, token, remoteIP string) error {
+	if token == "" {
+		return ErrCaptchaMissing
+	}
+
+	form := url.Values{"secret": {secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := captchaClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("captcha verification request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha verification returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid captcha verification response: %w", err)
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: %s", ErrCaptchaInvalid, strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerifyCaptcha(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("secret") != "secret" || r.PostFormValue("remoteip") != "203.0.113.9" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		switch r.PostFormValue("response") {
+		case "good":
+			fmt.Fprint(w, `{"success":true}`)
+		case "broken":
+			http.Error(w, "down", http.StatusInternalServerError)
+		default:
+			fmt.Fprint(w, `{"success":false,"error-codes":["invalid-input-response"]}`)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		token   string
+		wantErr error
+		other   bool
+	}{
+		{token: "good"},
+		{token: "", wantErr: ErrCaptchaMissing},
+		{token: "bad", wantErr: ErrCaptchaInvalid},
+		{token: "broken", other: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			err := VerifyCaptcha(context.Background(), server.URL, "secret", tt.token, "203.0.113.9")
+			switch {
+			case tt.other:
+				if err == nil || errors.Is(err, ErrCaptchaInvalid) || errors.Is(err, ErrCaptchaMissing) {
+					t.Errorf("VerifyCaptcha() = %v, want an unavailability error", err)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("VerifyCaptcha() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// customFieldMaxString caps the length of a string custom field.
+const customFieldMaxString = 255
+
+// ValidateCustomFields checks raw custom field values against schema, the
+// type of each allowed key, and returns them decoded. Only flat strings,
+// numbers and booleans are accepted; objects, arrays and null are not.
+func ValidateCustomFields(raw map[string]json.RawMessage, schema map[string]string) (models.CustomFields, error) {
+	fields := make(models.CustomFields, len(raw))
+	for key, value := range raw {
+		typ, ok := schema[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown custom field %q", key)
+		}
+		value = bytes.TrimSpace(value)
+		var err error
+		switch {
+		case bytes.Equal(value, []byte("null")):
+			err = fmt.Errorf("custom field %q must not be null", key)
+		case typ == "string":
+			var s string
+			if err = json.Unmarshal(value, &s); err == nil && len(s) > customFieldMaxString {
+				err = fmt.Errorf("custom field %q is longer than %d characters", key, customFieldMaxString)
+			}
+			fields[key] = s
+		case typ == "number":
+			var f float64
+			err = json.Unmarshal(value, &f)
+			fields[key] = f
+		case typ == "boolean":
+			var b bool
+			err = json.Unmarshal(value, &b)
+			fields[key] = b
+		}
+		if err != nil {
+			if _, ok := err.(*json.UnmarshalTypeError); ok {
+				err = fmt.Errorf("custom field %q must be a %s", key, typ)
+			}
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// ParseCustomFieldValue converts a query string value to the type of a
+// custom field, for filtering on it.
+func ParseCustomFieldValue(typ, value string) (interface{}, error) {
+	switch typ {
+	case "number":
+		return strconv.ParseFloat(value, 64)
+	case "boolean":
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateCustomFields(t *testing.T) {
+	schema := map[string]string{"cost_center": "string", "purchase_cost": "number", "under_warranty": "boolean"}
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"valid", `{"cost_center":"123","purchase_cost":1999.5,"under_warranty":true}`, ""},
+		{"empty", `{}`, ""},
+		{"unknown key", `{"mac_address":"aa:bb"}`, `unknown custom field "mac_address"`},
+		{"wrong type", `{"purchase_cost":"cheap"}`, `custom field "purchase_cost" must be a number`},
+		{"nested object", `{"cost_center":{"id":"123"}}`, `custom field "cost_center" must be a string`},
+		{"array", `{"under_warranty":[true]}`, `custom field "under_warranty" must be a boolean`},
+		{"null", `{"cost_center":null}`, `custom field "cost_center" must not be null`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(tt.body), &raw); err != nil {
+				t.Fatal(err)
+			}
+			fields, err := ValidateCustomFields(raw, schema)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(fields) != len(raw) {
+				t.Errorf("got %d fields, want %d", len(fields), len(raw))
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// Types of DeviceEvent.
+const (
+	DeviceCreated = "device.created"
+	DeviceUpdated = "device.updated"
+	DeviceMoved   = "device.moved"
+)
+
+// deviceEventBuffer is how many events a subscriber may fall behind before
+// further events are dropped for it.
+const deviceEventBuffer = 32
+
+// DeviceEvent describes a change to a device record.
+type DeviceEvent struct {
+	Type   string                      `json:"type"`
+	Device models.DeviceLocationDetail `json:"device"`
+	At     time.Time                   `json:"at"`
+}
+
+var (
+	deviceEventsMu     sync.Mutex
+	deviceEventSubs    = make(map[chan DeviceEvent]struct{})
+	deviceEventsClosed bool
+)
+
+// SubscribeDeviceEvents returns a channel receiving the device events
+// published from now on and a function ending the subscription. The channel
+// is closed when the subscription ends or CloseDeviceEvents is called.
+func SubscribeDeviceEvents() (<-chan DeviceEvent, func()) {
+	ch := make(chan DeviceEvent, deviceEventBuffer)
+	deviceEventsMu.Lock()
+	defer deviceEventsMu.Unlock()
+	if deviceEventsClosed {
+		close(ch)
+		return ch, func() {}
+	}
+	deviceEventSubs[ch] = struct{}{}
+
+	return ch, func() {
+		deviceEventsMu.Lock()
+		defer deviceEventsMu.Unlock()
+		if _, ok := deviceEventSubs[ch]; ok {
+			delete(deviceEventSubs, ch)
+			close(ch)
+		}
+	}
+}
+
+// PublishDeviceEvent sends an event to every subscriber without blocking;
+// a subscriber whose buffer is full misses it.
+func PublishDeviceEvent(eventType string, device models.DeviceLocationDetail) {
+	event := DeviceEvent{Type: eventType, Device: device, At: time.Now().UTC()}
+	deviceEventsMu.Lock()
+	defer deviceEventsMu.Unlock()
+	for ch := range deviceEventSubs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// CloseDeviceEvents ends every subscription, e.g. at shutdown so that
+// streaming requests finish.
+func CloseDeviceEvents() {
+	deviceEventsMu.Lock()
+	defer deviceEventsMu.Unlock()
+	deviceEventsClosed = true
+	for ch := range deviceEventSubs {
+		delete(deviceEventSubs, ch)
+		close(ch)
+	}
+}
+
+// DeviceEventBatch coalesces device events. Several events for the same
+// device collapse into one carrying the latest device, positioned where
+// the latest event arrived, so the batch stays in the order of last change.
+// A device created or moved within the batch keeps that type.
+type DeviceEventBatch struct {
+	events []DeviceEvent
+}
+
+// Add adds an event to the batch, replacing an earlier one for the device.
+func (b *DeviceEventBatch) Add(event DeviceEvent) {
+	for i, earlier := range b.events {
+		if earlier.Device.Id != event.Device.Id {
+			continue
+		}
+		if earlier.Type == DeviceCreated || (earlier.Type == DeviceMoved && event.Type == DeviceUpdated) {
+			event.Type = earlier.Type
+		}
+		b.events = append(b.events[:i], b.events[i+1:]...)
+		break
+	}
+	b.events = append(b.events, event)
+}
+
+// Len returns the number of events in the batch.
+func (b *DeviceEventBatch) Len() int {
+	return len(b.events)
+}
+
+// Take returns the batched events and empties the batch.
+func (b *DeviceEventBatch) Take() []DeviceEvent {
+	events := b.events
+	b.events = nil
+	return events
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+func TestDeviceEventBatch(t *testing.T) {
+	var batch DeviceEventBatch
+	batch.Add(DeviceEvent{Type: DeviceUpdated, Device: models.DeviceLocationDetail{Id: 1, Model: "a"}})
+	batch.Add(DeviceEvent{Type: DeviceCreated, Device: models.DeviceLocationDetail{Id: 2}})
+	batch.Add(DeviceEvent{Type: DeviceMoved, Device: models.DeviceLocationDetail{Id: 1, Model: "b"}})
+	batch.Add(DeviceEvent{Type: DeviceUpdated, Device: models.DeviceLocationDetail{Id: 1, Model: "c"}})
+	batch.Add(DeviceEvent{Type: DeviceUpdated, Device: models.DeviceLocationDetail{Id: 2}})
+
+	events := batch.Take()
+	want := []struct {
+		id        int
+		eventType string
+	}{
+		{1, DeviceMoved},
+		{2, DeviceCreated},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		if events[i].Device.Id != w.id || events[i].Type != w.eventType {
+			t.Errorf("event %d = %s for device %d, want %s for device %d", i, events[i].Type, events[i].Device.Id, w.eventType, w.id)
+		}
+	}
+	if events[0].Device.Model != "c" {
+		t.Errorf("device 1 model = %q, want the latest, %q", events[0].Device.Model, "c")
+	}
+	if batch.Len() != 0 {
+		t.Errorf("Len after Take = %d, want 0", batch.Len())
+	}
+}
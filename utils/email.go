@@ -2,24 +2,28 @@ package utils
 
 import (
 	"crypto/tls"
+	"html"
+	"net"
 	"net/smtp"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/vikash-parashar/asset-locator/logger"
 )
 
-// SendResetPasswordEmail sends a reset email to the user using Gmail SMTP.
-func SendResetPasswordEmail(recipientEmail, resetToken string) error {
+// Gmail SMTP server and port with TLS
+const (
+	smtpServer = "smtp.gmail.com"
+	smtpPort   = 587
+)
 
+// sendEmail delivers an HTML email to a single recipient using Gmail SMTP.
+func sendEmail(recipientEmail, subject, body string) error {
 	// Retrieve email settings from environment variables
 	emailUsername := os.Getenv("EMAIL_USERNAME")
 	emailPassword := os.Getenv("EMAIL_PASSWORD")
 
-	// Gmail SMTP server and port with TLS
-	smtpServer := "smtp.gmail.com"
-	smtpPort := 587
-
 	// Set up authentication
 	auth := smtp.PlainAuth("", emailUsername, emailPassword, smtpServer)
 
@@ -63,20 +67,99 @@ func SendResetPasswordEmail(recipientEmail, resetToken string) error {
 		logger.ErrorLogger.Println("Failed to open data connection:", err)
 		return err
 	}
-	defer wc.Close()
 
 	message := "To: " + recipientEmail + "\r\n" +
-		"<h2>Subject: Password Reset Request</h2>\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/html; charset=\"UTF-8\"\r\n" +
 		"\r\n" +
-		"<p>To reset your password, click on the following link:</p>\r\n" +
-		"http://localhost:8080/reset-password?token=" + resetToken
+		body
 
-	_, err = wc.Write([]byte(message))
-	if err != nil {
+	if _, err = wc.Write([]byte(message)); err != nil {
 		logger.ErrorLogger.Println("Failed to send email data:", err)
+		wc.Close()
+		return err
+	}
+	if err := wc.Close(); err != nil {
+		logger.ErrorLogger.Println("Failed to finish email data:", err)
 		return err
 	}
 
 	logger.InfoLogger.Println("Email sent successfully")
-	return nil
+	return client.Quit()
+}
+
+// CheckSMTP reports whether a connection to the SMTP server can be opened
+// within timeout, without sending anything.
+func CheckSMTP(timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(smtpServer, strconv.Itoa(smtpPort)), timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// resetPasswordEmail builds the subject and body of a password reset email.
+func resetPasswordEmail(resetToken string) (string, string) {
+	body := "<h2>Password Reset Request</h2>\r\n" +
+		"<p>To reset your password, click on the following link:</p>\r\n" +
+		"http://localhost:8080/reset-password?token=" + resetToken
+	return "Password Reset Request", body
+}
+
+// SendResetPasswordEmail sends a reset email to the user using Gmail SMTP.
+func SendResetPasswordEmail(recipientEmail, resetToken string) error {
+	subject, body := resetPasswordEmail(resetToken)
+	return sendEmail(recipientEmail, subject, body)
+}
+
+// inviteEmail builds the subject and body of an invite to set a password.
+func inviteEmail(resetToken string) (string, string) {
+	body := "<h2>Welcome to Asset Locator</h2>\r\n" +
+		"<p>An account has been created for you. To choose your password, click on the following link:</p>\r\n" +
+		"http://localhost:8080/reset-password?token=" + resetToken
+	return "Your Asset Locator account", body
+}
+
+// QueueInviteEmail queues an invite for a user created by an administrator,
+// linking to the page where they set their password.
+func QueueInviteEmail(recipientEmail, resetToken string) error {
+	subject, body := inviteEmail(resetToken)
+	return EnqueueEmail(EmailJob{To: recipientEmail, Subject: subject, Body: body})
+}
+
+// magicLinkEmail builds the subject and body of a one-time login link.
+func magicLinkEmail(token string, ttl time.Duration) (string, string) {
+	body := "<h2>Log in to Asset Locator</h2>\r\n" +
+		"<p>To log in, click on the following link. It can be used once and expires in " + ttl.String() + ":</p>\r\n" +
+		"http://localhost:8080/auth/magic?token=" + token
+	return "Your Asset Locator login link", body
+}
+
+// QueueMagicLinkEmail queues a one-time login link for background delivery.
+func QueueMagicLinkEmail(recipientEmail, token string, ttl time.Duration) error {
+	subject, body := magicLinkEmail(token, ttl)
+	return EnqueueEmail(EmailJob{To: recipientEmail, Subject: subject, Body: body})
+}
+
+// loginAlertEmail builds the subject and body of an alert about a login
+// from a new device.
+func loginAlertEmail(device, ip string, at time.Time) (string, string) {
+	body := "<h2>New login to Asset Locator</h2>\r\n" +
+		"<p>Your account was logged in to from " + html.EscapeString(device) + " at " + html.EscapeString(ip) + " on " + at.UTC().Format("2 January 2006 15:04 MST") + ".</p>\r\n" +
+		"<p>If this was not you, change your password and sign out your other sessions. You can turn these alerts off in your notification settings.</p>"
+	return "New login to your Asset Locator account", body
+}
+
+// QueueLoginAlertEmail queues an alert about a login from a new device.
+func QueueLoginAlertEmail(recipientEmail, device, ip string, at time.Time) error {
+	subject, body := loginAlertEmail(device, ip, at)
+	return EnqueueEmail(EmailJob{To: recipientEmail, Subject: subject, Body: body})
+}
+
+// QueueResetPasswordEmail queues a reset email for background delivery, so a
+// temporarily unreachable SMTP server does not fail the request.
+func QueueResetPasswordEmail(recipientEmail, resetToken string) error {
+	subject, body := resetPasswordEmail(resetToken)
+	return EnqueueEmail(EmailJob{To: recipientEmail, Subject: subject, Body: body})
 }
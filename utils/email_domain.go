@@ -0,0 +1,32 @@
+package utils
+
+import "strings"
+
+// IsEmailDomainAllowed reports whether the domain of email is in the allowed
+// list. Entries of the form "*.example.com" match any subdomain of
+// example.com. An empty list allows every domain.
+func IsEmailDomainAllowed(email string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(strings.TrimSpace(email[at+1:]))
+
+	for _, entry := range allowed {
+		entry = strings.ToLower(entry)
+		if strings.HasPrefix(entry, "*.") {
+			if strings.HasSuffix(domain, entry[1:]) {
+				return true
+			}
+			continue
+		}
+		if domain == entry {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package utils
+
+import "testing"
+
+func TestIsEmailDomainAllowed(t *testing.T) {
+	allowed := []string{"example.com", "*.corp.example"}
+	tests := []struct {
+		email   string
+		allowed []string
+		want    bool
+	}{
+		{"ann@example.com", nil, true},
+		{"ann@example.com", allowed, true},
+		{"ann@EXAMPLE.com", allowed, true},
+		{"ann@mail.example.com", allowed, false},
+		{"ann@badexample.com", allowed, false},
+		{"ann@eu.corp.example", allowed, true},
+		{"ann@corp.example", allowed, false},
+		{"ann@evilcorp.example", allowed, false},
+		{"ann", allowed, false},
+		{`"ann@example.com"@other.org`, allowed, false},
+	}
+	for _, tt := range tests {
+		if got := IsEmailDomainAllowed(tt.email, tt.allowed); got != tt.want {
+			t.Errorf("IsEmailDomainAllowed(%q, %v) = %v, want %v", tt.email, tt.allowed, got, tt.want)
+		}
+	}
+}
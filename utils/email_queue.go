@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"errors"
+	"expvar"
+	"sync/atomic"
+	"time"
+
+	"github.com/vikash-parashar/asset-locator/logger"
+)
+
+// EmailJob is an email waiting to be delivered by the background worker.
+type EmailJob struct {
+	To      string
+	Subject string
+	Body    string
+
+	attempts int
+}
+
+const (
+	emailQueueSize      = 100
+	emailMaxAttempts    = 5
+	emailRetryBaseDelay = 30 * time.Second
+)
+
+// ErrEmailQueueFull is returned when the email queue cannot accept more jobs.
+var ErrEmailQueueFull = errors.New("email queue is full")
+
+var (
+	emailQueue = make(chan EmailJob, emailQueueSize)
+
+	// emailLimiter smooths outgoing mail to the configured rate; nil sends
+	// as fast as the SMTP server accepts.
+	emailLimiter *tokenBucket
+
+	// emailMetrics are published at /debug/vars under "email".
+	emailMetrics = expvar.NewMap("email")
+
+	// emailsPending counts the jobs queued or being delivered, and
+	// emailsRetrying those waiting to be requeued after a failure.
+	emailsPending  atomic.Int64
+	emailsRetrying atomic.Int64
+
+	// deliverEmail performs the actual delivery; it is a variable so the
+	// transport can be replaced.
+	deliverEmail = sendEmail
+)
+
+// EnqueueEmail adds an email to the delivery queue without blocking.
+func EnqueueEmail(job EmailJob) error {
+	emailsPending.Add(1)
+	select {
+	case emailQueue <- job:
+		logger.InfoLogger.Printf("Queued email %q for %s\n", job.Subject, job.To)
+		return nil
+	default:
+		emailsPending.Add(-1)
+		emailMetrics.Add("dropped", 1)
+		logger.ErrorLogger.Printf("Email queue is full, dropping email %q for %s\n", job.Subject, job.To)
+		return ErrEmailQueueFull
+	}
+}
+
+func init() {
+	emailMetrics.Set("queue_depth", expvar.Func(func() interface{} {
+		return len(emailQueue)
+	}))
+}
+
+// EmailQueueDepth returns the jobs waiting in the queue, the queue's
+// capacity and the failed jobs waiting to be retried.
+func EmailQueueDepth() (queued, capacity, retrying int) {
+	return len(emailQueue), cap(emailQueue), int(emailsRetrying.Load())
+}
+
+// StartEmailWorker starts the goroutine delivering queued emails. Failed
+// deliveries are retried with exponential backoff; after emailMaxAttempts
+// the email is logged as permanently failed.
+//
+// Deliveries are limited to ratePerMinute with bursts of up to burst emails;
+// excess emails wait in the queue. A ratePerMinute of zero disables the
+// limit.
+func StartEmailWorker(ratePerMinute float64, burst int) {
+	if ratePerMinute > 0 {
+		emailLimiter = newTokenBucket(ratePerMinute/60, burst)
+	}
+	go func() {
+		for job := range emailQueue {
+			if emailLimiter != nil {
+				if delay := emailLimiter.Wait(); delay > 0 {
+					emailMetrics.Add("delayed", 1)
+					emailMetrics.AddFloat("delay_seconds", delay.Seconds())
+				}
+			}
+			processEmailJob(job)
+			emailsPending.Add(-1)
+		}
+	}()
+}
+
+// DrainEmails waits up to timeout for the queued emails to be delivered, for
+// use at shutdown. It returns how many emails were still queued, being sent
+// or waiting for a retry when it gave up; those are lost.
+func DrainEmails(timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for emailsPending.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return int(emailsPending.Load() + emailsRetrying.Load())
+}
+
+func processEmailJob(job EmailJob) {
+	job.attempts++
+	err := deliverEmail(job.To, job.Subject, job.Body)
+	if err == nil {
+		emailMetrics.Add("sent", 1)
+		return
+	}
+	emailMetrics.Add("failed", 1)
+
+	if job.attempts >= emailMaxAttempts {
+		logger.ErrorLogger.Printf("Giving up on email %q for %s after %d attempts: %v\n", job.Subject, job.To, job.attempts, err)
+		return
+	}
+
+	delay := emailRetryBaseDelay << (job.attempts - 1)
+	logger.WarningLogger.Printf("Email %q for %s failed (attempt %d), retrying in %s: %v\n", job.Subject, job.To, job.attempts, delay, err)
+	emailsRetrying.Add(1)
+	time.AfterFunc(delay, func() {
+		emailsRetrying.Add(-1)
+		if err := EnqueueEmail(job); err != nil {
+			logger.ErrorLogger.Printf("Could not requeue email %q for %s: %v\n", job.Subject, job.To, err)
+		}
+	})
+}
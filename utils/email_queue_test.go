@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+// stubEmailDelivery replaces the email transport with deliver for the test.
+func stubEmailDelivery(t *testing.T, deliver func(to, subject, body string) error) {
+	t.Helper()
+	saved := deliverEmail
+	deliverEmail = deliver
+	t.Cleanup(func() { deliverEmail = saved })
+}
+
+func TestEnqueueEmailFull(t *testing.T) {
+	t.Cleanup(func() {
+		for len(emailQueue) > 0 {
+			<-emailQueue
+			emailsPending.Add(-1)
+		}
+	})
+	for len(emailQueue) < cap(emailQueue) {
+		if err := EnqueueEmail(EmailJob{To: "ann@example.com", Subject: "Hello"}); err != nil {
+			t.Fatalf("EnqueueEmail() = %v with room in the queue", err)
+		}
+	}
+	if err := EnqueueEmail(EmailJob{To: "ann@example.com", Subject: "Hello"}); !errors.Is(err, ErrEmailQueueFull) {
+		t.Errorf("EnqueueEmail() = %v, want %v", err, ErrEmailQueueFull)
+	}
+}
+
+func TestProcessEmailJobRetries(t *testing.T) {
+	deliveries := 0
+	stubEmailDelivery(t, func(to, subject, body string) error {
+		deliveries++
+		return errors.New("connection refused")
+	})
+
+	retrying := emailsRetrying.Load()
+	processEmailJob(EmailJob{To: "ann@example.com", Subject: "Hello"})
+	if got := emailsRetrying.Load() - retrying; got != 1 {
+		t.Errorf("%d emails waiting for a retry after the first failure, want 1", got)
+	}
+
+	retrying = emailsRetrying.Load()
+	processEmailJob(EmailJob{To: "ann@example.com", Subject: "Hello", attempts: emailMaxAttempts - 1})
+	if got := emailsRetrying.Load() - retrying; got != 0 {
+		t.Errorf("%d emails waiting for a retry after the last attempt, want 0", got)
+	}
+	if deliveries != 2 {
+		t.Errorf("%d deliveries, want 2", deliveries)
+	}
+}
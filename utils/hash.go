@@ -1,6 +1,11 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 // VerifyPassword checks if the provided password matches the hashed password stored in the database.
 func VerifyPassword(inputPassword, hashedPassword string) bool {
@@ -8,6 +13,23 @@ func VerifyPassword(inputPassword, hashedPassword string) bool {
 	return err == nil
 }
 
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// VerifyDummyPassword performs a bcrypt comparison against a constant hash of
+// the same cost as real password hashes, and always reports false. Login uses
+// it when no user matches the email, so that the response takes as long as a
+// wrong password would and does not reveal whether the account exists.
+func VerifyDummyPassword(inputPassword string) bool {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(inputPassword))
+	return false
+}
+
 // Hash a password using bcrypt
 func HashPassword(password string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -16,3 +38,12 @@ func HashPassword(password string) (string, error) {
 	}
 	return string(hashedPassword), nil
 }
+
+// IsPasswordExpired reports whether a password last changed at changedAt is
+// older than maxAge. A non-positive maxAge disables expiry.
+func IsPasswordExpired(changedAt time.Time, maxAge time.Duration) bool {
+	if maxAge <= 0 {
+		return false
+	}
+	return time.Since(changedAt) > maxAge
+}
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestVerifyDummyPassword(t *testing.T) {
+	for _, password := range []string{"", "dummy-password", "anything else"} {
+		if VerifyDummyPassword(password) {
+			t.Errorf("VerifyDummyPassword(%q) = true", password)
+		}
+	}
+	// The comparison must cost as much as one against a real hash
+	if cost, err := bcrypt.Cost(dummyHash); err != nil || cost != bcrypt.DefaultCost {
+		t.Errorf("dummy hash cost = %d, %v, want %d", cost, err, bcrypt.DefaultCost)
+	}
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// GenerateHashedToken returns a random opaque token, as sent to the user in
+// a magic link or as a refresh token, and the hash under which it is
+// stored. Only the hash is kept, so a leaked table cannot be used to log in.
+func GenerateHashedToken() (token, hash string, err error) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(randomBytes)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the stored form of a token from GenerateHashedToken.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,9 +3,11 @@ package utils
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/vikash-parashar/asset-locator/models"
@@ -15,6 +17,14 @@ import (
 
 var jwtSecret string
 
+// Errors returned, wrapped, by ValidateJWTToken and VerifyJWTToken. Use
+// errors.Is to tell them apart.
+var (
+	ErrTokenExpired     = errors.New("token has expired")
+	ErrTokenMalformed   = errors.New("token is malformed")
+	ErrInvalidSignature = errors.New("token signature is invalid")
+)
+
 func init() {
 	GetSecretKey()
 }
@@ -24,6 +34,17 @@ type Claims struct {
 	UserId    int    `json:"user_id"`
 	UserEmail string `json:"user_email"`
 	UserRole  string `json:"user_role"`
+	// PasswordExpired marks a session issued for a user whose password must be
+	// rotated before normal use.
+	PasswordExpired bool `json:"password_expired,omitempty"`
+	// Remember marks a long-lived "remember me" session.
+	Remember bool `json:"remember,omitempty"`
+	// TokenVersion must match the user's current token version; older
+	// tokens have been revoked with a "sign out everywhere".
+	TokenVersion int `json:"token_version"`
+	// SessionID is the session the token belongs to, which must not have
+	// been revoked. Tokens issued before sessions were recorded have none.
+	SessionID int `json:"sid,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -31,14 +52,27 @@ func GetSecretKey() {
 	jwtSecret = os.Getenv("JWT_SECRET")
 }
 
-// GenerateJWTToken generates a JWT token for a user.
-func GenerateJWTToken(user *models.User) (string, error) {
+// SetSecretKey sets the secret used to sign and verify JWT tokens. It is
+// called at startup with the validated configuration value.
+func SetSecretKey(secret string) {
+	jwtSecret = secret
+}
+
+// GenerateJWTToken generates a JWT token for a user that is valid for ttl.
+// remember records whether the session was issued for a "remember me" login
+// and sessionID the recorded session the token belongs to.
+func GenerateJWTToken(user *models.User, ttl time.Duration, remember bool, sessionID int) (string, error) {
 	claims := Claims{
 		UserId:    int(user.ID),
 		UserEmail: user.Email,
 		UserRole:  user.Role,
+
+		PasswordExpired: user.PasswordExpired,
+		Remember:        remember,
+		TokenVersion:    user.TokenVersion,
+		SessionID:       sessionID,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 1).Unix(),
+			ExpiresAt: time.Now().Add(ttl).Unix(),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -47,42 +81,96 @@ func GenerateJWTToken(user *models.User) (string, error) {
 
 // ValidateJWTToken validates a JWT token and returns the token object.
 func ValidateJWTToken(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
+	token, err := jwt.Parse(tokenString, signingKey)
+	if err != nil {
+		return nil, tokenError(err)
+	}
+	return token, nil
 }
 
-// ExtractClaims extracts JWT claims from an HTTP request.
-func ExtractClaims(r *http.Request) (Claims, bool) {
-	tokenString := r.Header.Get("Authorization")
-	if tokenString == "" {
-		return Claims{}, false
+// signingKey returns the key used to verify a token, refusing tokens signed
+// with anything other than HMAC.
+func signingKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
 	}
-	tokenString = tokenString[len("Bearer "):]
+	return []byte(jwtSecret), nil
+}
 
-	token, err := ValidateJWTToken(tokenString)
-	if err != nil || !token.Valid {
-		return Claims{}, false
+// tokenError maps a jwt-go validation error onto ErrTokenExpired,
+// ErrTokenMalformed or ErrInvalidSignature, keeping the original message.
+func tokenError(err error) error {
+	var validationErr *jwt.ValidationError
+	if !errors.As(err, &validationErr) {
+		return fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+	}
+	switch {
+	case validationErr.Errors&jwt.ValidationErrorMalformed != 0:
+		return fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+	case validationErr.Errors&(jwt.ValidationErrorSignatureInvalid|jwt.ValidationErrorUnverifiable) != 0:
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	case validationErr.Errors&jwt.ValidationErrorExpired != 0:
+		return fmt.Errorf("%w: %v", ErrTokenExpired, err)
+	default:
+		return fmt.Errorf("%w: %v", ErrTokenMalformed, err)
 	}
+}
 
-	claims, ok := token.Claims.(Claims)
-	if !ok {
+// TokenFromRequest returns the JWT sent with r, either as an
+// "Authorization: Bearer" header or in the jwt-token cookie, or "" if there
+// is none. The header takes precedence.
+func TokenFromRequest(r *http.Request) string {
+	if token := BearerToken(r); token != "" {
+		return token
+	}
+	return CookieToken(r)
+}
+
+// BearerToken returns the JWT sent with r as an "Authorization: Bearer"
+// header, or "" if there is none.
+func BearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(header[len("Bearer "):])
+	}
+	return ""
+}
+
+// CookieToken returns the JWT sent with r in the jwt-token cookie, or "" if
+// there is none.
+func CookieToken(r *http.Request) string {
+	if cookie, err := r.Cookie("jwt-token"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// ExtractClaims extracts the claims of a valid JWT sent with an HTTP request
+// as a Bearer header or cookie, reporting false if there is none.
+func ExtractClaims(r *http.Request) (Claims, bool) {
+	tokenString := TokenFromRequest(r)
+	if tokenString == "" {
 		return Claims{}, false
 	}
 
+	claims, err := VerifyJWTToken(tokenString)
+	if err != nil {
+		return Claims{}, false
+	}
 	return claims, true
 }
 
-// VerifyJWTToken validates and verifies a JWT token and returns the claims and a boolean indicating validity.
-func VerifyJWTToken(tokenString string) (Claims, bool) {
+// VerifyJWTToken validates and verifies a JWT token and returns its claims.
+// The error wraps ErrTokenExpired, ErrTokenMalformed or ErrInvalidSignature.
+func VerifyJWTToken(tokenString string) (Claims, error) {
 	claims := Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &claims, signingKey)
 	if err != nil {
-		return Claims{}, false
+		return Claims{}, tokenError(err)
+	}
+	if !token.Valid {
+		return Claims{}, ErrInvalidSignature
 	}
-	return claims, token.Valid
+	return claims, nil
 }
 
 // GeneratePasswordResetToken generates a password reset token for a user.
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+func TestVerifyJWTToken(t *testing.T) {
+	SetSecretKey("test-secret")
+	user := &models.User{ID: 7, Email: "ann@example.com", Role: models.UserRoleAdmin, TokenVersion: 2}
+
+	valid, err := GenerateJWTToken(user, time.Minute, true, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := VerifyJWTToken(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserId != 7 || claims.UserEmail != "ann@example.com" || claims.UserRole != models.UserRoleAdmin ||
+		!claims.Remember || claims.TokenVersion != 2 || claims.SessionID != 3 {
+		t.Errorf("claims = %+v, want those of user 7", claims)
+	}
+
+	expired, err := GenerateJWTToken(user, -time.Minute, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetSecretKey("other-secret")
+	forged, err := GenerateJWTToken(user, time.Minute, false, 0)
+	SetSecretKey("test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, Claims{UserId: 7}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"expired", expired, ErrTokenExpired},
+		{"other secret", forged, ErrInvalidSignature},
+		{"alg none", unsigned, ErrInvalidSignature},
+		{"garbage", "not.a.token", ErrTokenMalformed},
+		{"empty", "", ErrTokenMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyJWTToken(tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("VerifyJWTToken() = %v, want %v", err, tt.want)
+			}
+			if _, err := ValidateJWTToken(tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("ValidateJWTToken() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
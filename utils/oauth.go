@@ -0,0 +1,255 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrOAuthNoVerifiedEmail is returned when a provider does not vouch for an
+// email address of the user, which is needed to find or create the account.
+var ErrOAuthNoVerifiedEmail = errors.New("provider returned no verified email address")
+
+var oauthClient = NewOutboundClient(10 * time.Second)
+
+// OAuthProfile is the user signed in at an OAuth provider. Subject is the
+// provider's stable id for the user. Role is set when the provider decides
+// the role of users it registers.
+type OAuthProfile struct {
+	Subject   string
+	Email     string
+	FirstName string
+	LastName  string
+	Role      string
+	// EmailVerified is set when the provider vouches that the user owns
+	// Email. Only then may the login be linked to an existing account.
+	EmailVerified bool
+}
+
+// OAuthToken is the answer of a provider's token endpoint.
+type OAuthToken struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+}
+
+// OAuthProvider is an OAuth2 authorization server used for social or
+// single sign-on login with the authorization code flow and PKCE.
+type OAuthProvider struct {
+	// Name appears in the login routes, e.g. /auth/google, and
+	// DisplayName in messages to the user.
+	Name         string
+	DisplayName  string
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	Scopes       []string
+
+	// oidc is set for an OpenID Connect provider, whose endpoints are
+	// discovered instead of being set in AuthURL and TokenURL.
+	oidc    *oidcIssuer
+	profile func(ctx context.Context, token OAuthToken, nonce string) (OAuthProfile, error)
+}
+
+// GoogleOAuth returns the Google provider for the given client.
+func GoogleOAuth(clientID, clientSecret string) *OAuthProvider {
+	return &OAuthProvider{
+		Name:         "google",
+		DisplayName:  "Google",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:     "https://oauth2.googleapis.com/token",
+		Scopes:       []string{"openid", "email", "profile"},
+		profile:      googleProfile,
+	}
+}
+
+// GitHubOAuth returns the GitHub provider for the given OAuth app.
+func GitHubOAuth(clientID, clientSecret string) *OAuthProvider {
+	return &OAuthProvider{
+		Name:         "github",
+		DisplayName:  "GitHub",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		AuthURL:      "https://github.com/login/oauth/authorize",
+		TokenURL:     "https://github.com/login/oauth/access_token",
+		Scopes:       []string{"read:user", "user:email"},
+		profile:      githubProfile,
+	}
+}
+
+// NewOAuthState returns a random state, to tie the callback to the browser
+// that started the login, and a PKCE code verifier.
+func NewOAuthState() (state, verifier string, err error) {
+	if state, err = randomToken(); err != nil {
+		return "", "", err
+	}
+	if verifier, err = randomToken(); err != nil {
+		return "", "", err
+	}
+	return state, verifier, nil
+}
+
+// endpoints returns the authorization and token endpoints.
+func (p *OAuthProvider) endpoints(ctx context.Context) (authURL, tokenURL string, err error) {
+	if p.oidc != nil {
+		return p.oidc.endpoints(ctx)
+	}
+	return p.AuthURL, p.TokenURL, nil
+}
+
+// AuthCodeURL returns the provider's login page URL that sends the user
+// back to redirectURI. OpenID Connect providers are asked to put the state
+// into the ID token as its nonce.
+func (p *OAuthProvider) AuthCodeURL(ctx context.Context, redirectURI, state, verifier string) (string, error) {
+	authURL, _, err := p.endpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	if p.oidc != nil {
+		query.Set("nonce", state)
+	}
+	separator := "?"
+	if strings.Contains(authURL, "?") {
+		separator = "&"
+	}
+	return authURL + separator + query.Encode(), nil
+}
+
+// Exchange trades the code passed to the callback for tokens.
+func (p *OAuthProvider) Exchange(ctx context.Context, redirectURI, code, verifier string) (OAuthToken, error) {
+	_, tokenURL, err := p.endpoints(ctx)
+	if err != nil {
+		return OAuthToken{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OAuthToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token OAuthToken
+	if err := doOAuthRequest(req, &token); err != nil {
+		return OAuthToken{}, fmt.Errorf("%s token exchange failed: %w", p.Name, err)
+	}
+	if token.AccessToken == "" {
+		return OAuthToken{}, fmt.Errorf("%s token exchange returned no access token", p.Name)
+	}
+	return token, nil
+}
+
+// Profile returns the signed-in user, given the tokens from Exchange and
+// the state of the login.
+func (p *OAuthProvider) Profile(ctx context.Context, token OAuthToken, state string) (OAuthProfile, error) {
+	return p.profile(ctx, token, state)
+}
+
+func googleProfile(ctx context.Context, token OAuthToken, _ string) (OAuthProfile, error) {
+	var info struct {
+		Subject       string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		GivenName     string `json:"given_name"`
+		FamilyName    string `json:"family_name"`
+	}
+	if err := getOAuthJSON(ctx, "https://openidconnect.googleapis.com/v1/userinfo", token.AccessToken, &info); err != nil {
+		return OAuthProfile{}, err
+	}
+	if info.Subject == "" {
+		return OAuthProfile{}, errors.New("google userinfo returned no subject")
+	}
+	if info.Email == "" || !info.EmailVerified {
+		return OAuthProfile{}, ErrOAuthNoVerifiedEmail
+	}
+	return OAuthProfile{Subject: info.Subject, Email: info.Email, EmailVerified: true, FirstName: info.GivenName, LastName: info.FamilyName}, nil
+}
+
+func githubProfile(ctx context.Context, token OAuthToken, _ string) (OAuthProfile, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getOAuthJSON(ctx, "https://api.github.com/user", token.AccessToken, &user); err != nil {
+		return OAuthProfile{}, err
+	}
+
+	// The public profile email may be unverified; the primary one of the
+	// account's emails is used instead
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getOAuthJSON(ctx, "https://api.github.com/user/emails", token.AccessToken, &emails); err != nil {
+		return OAuthProfile{}, err
+	}
+	profile := OAuthProfile{Subject: fmt.Sprint(user.ID)}
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			profile.Email, profile.EmailVerified = email.Email, true
+		}
+	}
+	if profile.Email == "" {
+		return OAuthProfile{}, ErrOAuthNoVerifiedEmail
+	}
+
+	name := strings.TrimSpace(user.Name)
+	if name == "" {
+		name = user.Login
+	}
+	profile.FirstName, profile.LastName, _ = strings.Cut(name, " ")
+	return profile, nil
+}
+
+func getOAuthJSON(ctx context.Context, endpoint, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if err := doOAuthRequest(req, v); err != nil {
+		return fmt.Errorf("fetching %s failed: %w", endpoint, err)
+	}
+	return nil
+}
+
+// doOAuthRequest sends req and decodes its JSON answer into v.
+func doOAuthRequest(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := oauthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// ErrOIDCInvalidToken is returned, wrapped, for an ID token that does not
+// verify.
+var ErrOIDCInvalidToken = errors.New("ID token is invalid")
+
+// jwksRefreshInterval limits how often the signing keys are fetched again
+// for a token signed with an unknown key.
+const jwksRefreshInterval = time.Minute
+
+// OIDCOptions configure an OpenID Connect provider such as Keycloak, Okta or
+// Azure AD.
+type OIDCOptions struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	DisplayName  string
+	Scopes       []string
+	// RoleClaim names the ID token claim holding the user's roles or
+	// groups, as a dotted path for nested claims such as
+	// realm_access.roles. Users with one of AdminRoles, compared
+	// case-insensitively, are registered as admins and all others as
+	// general users.
+	// Roles are left alone if RoleClaim is empty.
+	RoleClaim  string
+	AdminRoles []string
+}
+
+// oidcIssuer holds what is discovered about an issuer. It is fetched on
+// first use, so that the server starts while the issuer is unreachable.
+type oidcIssuer struct {
+	options OIDCOptions
+
+	mu        sync.Mutex
+	authURL   string
+	tokenURL  string
+	jwksURI   string
+	keys      map[string]crypto.PublicKey
+	keysFetch time.Time
+}
+
+// OIDC returns the OpenID Connect provider described by options. Its
+// endpoints and signing keys are discovered from the issuer.
+func OIDC(options OIDCOptions) *OAuthProvider {
+	issuer := &oidcIssuer{options: options}
+	return &OAuthProvider{
+		Name:         "oidc",
+		DisplayName:  options.DisplayName,
+		ClientID:     options.ClientID,
+		ClientSecret: options.ClientSecret,
+		Scopes:       options.Scopes,
+		oidc:         issuer,
+		profile:      issuer.profile,
+	}
+}
+
+// endpoints returns the discovered authorization and token endpoints.
+func (o *oidcIssuer) endpoints(ctx context.Context) (authURL, tokenURL string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.discover(ctx); err != nil {
+		return "", "", err
+	}
+	return o.authURL, o.tokenURL, nil
+}
+
+// discover fetches the issuer's metadata unless it is known. o.mu is held.
+func (o *oidcIssuer) discover(ctx context.Context) error {
+	if o.authURL != "" {
+		return nil
+	}
+	var metadata struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	endpoint := strings.TrimSuffix(o.options.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if err := doOAuthRequest(req, &metadata); err != nil {
+		return fmt.Errorf("OIDC discovery at %s failed: %w", endpoint, err)
+	}
+	if metadata.Issuer != o.options.Issuer {
+		return fmt.Errorf("OIDC discovery returned issuer %q, expected %q", metadata.Issuer, o.options.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return errors.New("OIDC discovery returned incomplete metadata")
+	}
+	o.authURL, o.tokenURL, o.jwksURI = metadata.AuthorizationEndpoint, metadata.TokenEndpoint, metadata.JWKSURI
+	return nil
+}
+
+// key returns the issuer's signing key with the given id, fetching the key
+// set again if the key is unknown, as after a key rotation.
+func (o *oidcIssuer) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if key, ok := o.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(o.keysFetch) < jwksRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrOIDCInvalidToken, kid)
+	}
+	if err := o.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := doOAuthRequest(req, &set); err != nil {
+		return nil, fmt.Errorf("fetching the OIDC signing keys failed: %w", err)
+	}
+	o.keysFetch = time.Now()
+
+	o.keys = make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch {
+		case jwk.Kty == "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(jwk.E)
+			exponent := new(big.Int).SetBytes(e)
+			if err1 != nil || err2 != nil || !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+				continue
+			}
+			o.keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+		case jwk.Kty == "EC" && jwk.Crv == "P-256":
+			x, err1 := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(jwk.Y)
+			key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if err1 != nil || err2 != nil || !key.Curve.IsOnCurve(key.X, key.Y) {
+				continue
+			}
+			o.keys[jwk.Kid] = key
+		}
+	}
+	if key, ok := o.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown signing key %q", ErrOIDCInvalidToken, kid)
+}
+
+// profile verifies the ID token returned with the access token and maps its
+// claims onto a profile.
+func (o *oidcIssuer) profile(ctx context.Context, token OAuthToken, nonce string) (OAuthProfile, error) {
+	if token.IDToken == "" {
+		return OAuthProfile{}, fmt.Errorf("%w: token response has no ID token", ErrOIDCInvalidToken)
+	}
+	claims, err := o.verify(ctx, token.IDToken, nonce)
+	if err != nil {
+		return OAuthProfile{}, err
+	}
+
+	profile := OAuthProfile{}
+	profile.Subject, _ = claims["sub"].(string)
+	profile.Email, _ = claims["email"].(string)
+	profile.FirstName, _ = claims["given_name"].(string)
+	profile.LastName, _ = claims["family_name"].(string)
+	if profile.Subject == "" {
+		return OAuthProfile{}, fmt.Errorf("%w: no subject", ErrOIDCInvalidToken)
+	}
+	// The email links the login to an existing account, so it must be one
+	// the issuer has verified. preferred_username is not used instead: it
+	// is a display name that many issuers let users choose.
+	if verified, _ := claims["email_verified"].(bool); profile.Email == "" || !verified {
+		return OAuthProfile{}, ErrOAuthNoVerifiedEmail
+	}
+	profile.EmailVerified = true
+
+	if o.options.RoleClaim != "" {
+		profile.Role = models.UserRoleGeneral
+		for _, role := range claimStrings(claims, o.options.RoleClaim) {
+			for _, adminRole := range o.options.AdminRoles {
+				if strings.EqualFold(role, adminRole) {
+					profile.Role = models.UserRoleAdmin
+				}
+			}
+		}
+	}
+	return profile, nil
+}
+
+// verify checks the signature, issuer, audience, lifetime and nonce of an
+// ID token and returns its claims.
+func (o *oidcIssuer) verify(ctx context.Context, idToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.Alg() {
+		case "RS256", "ES256":
+		default:
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return o.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCInvalidToken, err)
+	}
+
+	if !claims.VerifyIssuer(o.options.Issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrOIDCInvalidToken)
+	}
+	audience := claimStrings(claims, "aud")
+	if !slices.Contains(audience, o.options.ClientID) {
+		return nil, fmt.Errorf("%w: issued for another client", ErrOIDCInvalidToken)
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != o.options.ClientID {
+		return nil, fmt.Errorf("%w: issued for another client", ErrOIDCInvalidToken)
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: no expiry", ErrOIDCInvalidToken)
+	}
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCInvalidToken)
+	}
+	return claims, nil
+}
+
+// claimStrings returns the string or strings at a dotted claim path.
+func claimStrings(claims map[string]interface{}, path string) []string {
+	var value interface{} = claims
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// testIssuer serves the discovery document and key set of an OIDC issuer
+// that signs ID tokens with key.
+func testIssuer(t *testing.T, key *rsa.PrivateKey) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	return server
+}
+
+func TestOIDCProfileRequiresVerifiedEmail(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := testIssuer(t, key)
+	issuer := &oidcIssuer{options: OIDCOptions{Issuer: server.URL, ClientID: "client"}}
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "verified email",
+			claims: jwt.MapClaims{"email": "ann@example.com", "email_verified": true},
+			want:   "ann@example.com",
+		},
+		{
+			name:    "email_verified missing",
+			claims:  jwt.MapClaims{"email": "ann@example.com"},
+			wantErr: ErrOAuthNoVerifiedEmail,
+		},
+		{
+			name:    "email_verified false",
+			claims:  jwt.MapClaims{"email": "ann@example.com", "email_verified": false},
+			wantErr: ErrOAuthNoVerifiedEmail,
+		},
+		{
+			name:    "email_verified as a string",
+			claims:  jwt.MapClaims{"email": "ann@example.com", "email_verified": "true"},
+			wantErr: ErrOAuthNoVerifiedEmail,
+		},
+		{
+			name:    "only preferred_username",
+			claims:  jwt.MapClaims{"preferred_username": "ann@example.com", "email_verified": true},
+			wantErr: ErrOAuthNoVerifiedEmail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{
+				"iss":   server.URL,
+				"aud":   "client",
+				"sub":   "subject",
+				"exp":   time.Now().Add(time.Minute).Unix(),
+				"nonce": "nonce",
+			}
+			for name, value := range tt.claims {
+				claims[name] = value
+			}
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+			token.Header["kid"] = "test"
+			idToken, err := token.SignedString(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			profile, err := issuer.profile(context.Background(), OAuthToken{IDToken: idToken}, "nonce")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("profile() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("profile() error = %v", err)
+			}
+			if profile.Email != tt.want || !profile.EmailVerified {
+				t.Errorf("profile() = %q verified %v, want %q verified", profile.Email, profile.EmailVerified, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// requestIDHeader is the header carrying the request id, on incoming
+// requests, responses and outbound calls. It is set from the configuration
+// at startup.
+var requestIDHeader = "X-Request-ID"
+
+// SetRequestIDHeader sets the header carrying the request id.
+func SetRequestIDHeader(header string) {
+	requestIDHeader = header
+}
+
+// RequestIDHeader returns the header carrying the request id.
+func RequestIDHeader() string {
+	return requestIDHeader
+}
+
+type correlationKey struct{}
+
+// correlation identifies the incoming request that an outbound call is made
+// for.
+type correlation struct {
+	requestID   string
+	traceparent string
+}
+
+// WithCorrelation returns a copy of ctx carrying the request id and W3C
+// traceparent of the incoming request, for NewOutboundClient to forward.
+// traceparent may be empty.
+func WithCorrelation(ctx context.Context, requestID, traceparent string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, correlation{requestID: requestID, traceparent: traceparent})
+}
+
+// RequestIDFromContext returns the request id stored by WithCorrelation, or
+// "" if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	value, _ := ctx.Value(correlationKey{}).(correlation)
+	return value.requestID
+}
+
+// correlatingTransport adds the request id and traceparent from the request
+// context to every outbound request.
+type correlatingTransport struct {
+	next http.RoundTripper
+}
+
+func (t correlatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	value, ok := req.Context().Value(correlationKey{}).(correlation)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	if value.requestID != "" && req.Header.Get(requestIDHeader) == "" {
+		req.Header.Set(requestIDHeader, value.requestID)
+	}
+	if value.traceparent != "" && req.Header.Get("traceparent") == "" {
+		req.Header.Set("traceparent", value.traceparent)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// NewOutboundClient returns an HTTP client for calls to external services.
+// Requests made with a context from an incoming request carry its request id
+// and traceparent, so the logs of both sides can be correlated.
+func NewOutboundClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: correlatingTransport{next: http.DefaultTransport},
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOutboundClientForwardsCorrelation(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tests := []struct {
+		name            string
+		ctx             context.Context
+		wantID          string
+		wantTraceparent string
+	}{
+		{"incoming request", WithCorrelation(context.Background(), "req-42", traceparent), "req-42", traceparent},
+		{"without traceparent", WithCorrelation(context.Background(), "req-43", ""), "req-43", ""},
+		{"background job", context.Background(), "", ""},
+	}
+	client := NewOutboundClient(time.Second)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(tt.ctx, http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if id := got.Get(RequestIDHeader()); id != tt.wantID {
+				t.Errorf("%s = %q, want %q", RequestIDHeader(), id, tt.wantID)
+			}
+			if tp := got.Get("traceparent"); tp != tt.wantTraceparent {
+				t.Errorf("traceparent = %q, want %q", tp, tt.wantTraceparent)
+			}
+			if req.Header.Get(RequestIDHeader()) != "" {
+				t.Error("the caller's request was modified")
+			}
+		})
+	}
+}
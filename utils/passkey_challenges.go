@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTooManyPasskeyChallenges is returned by Create when the store is full.
+var ErrTooManyPasskeyChallenges = errors.New("too many passkey challenges in progress")
+
+// Purposes of a passkey challenge.
+const (
+	PasskeyRegistration = "registration"
+	PasskeyLogin        = "login"
+)
+
+type passkeyChallenge struct {
+	purpose string
+	userID  int
+	expires time.Time
+}
+
+// PasskeyChallengeStore keeps the challenges of passkey registrations and
+// logins in memory until they are answered or expire. Expired challenges
+// are forgotten whenever a new one is created.
+type PasskeyChallengeStore struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	max        int
+	challenges map[string]passkeyChallenge
+}
+
+// NewPasskeyChallengeStore returns a store whose challenges last ttl and
+// which holds at most max of them.
+func NewPasskeyChallengeStore(ttl time.Duration, max int) *PasskeyChallengeStore {
+	return &PasskeyChallengeStore{ttl: ttl, max: max, challenges: make(map[string]passkeyChallenge)}
+}
+
+// TTL returns the lifetime of a challenge.
+func (s *PasskeyChallengeStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Create returns a new challenge for the given purpose. userID is the user
+// registering a passkey, or the user expected to log in, 0 for anyone. While
+// the store is full, ErrTooManyPasskeyChallenges is returned instead; older
+// challenges are kept so that logins already started can complete.
+func (s *PasskeyChallengeStore) Create(purpose string, userID int) (string, error) {
+	challenge, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, existing := range s.challenges {
+		if now.After(existing.expires) {
+			delete(s.challenges, key)
+		}
+	}
+	if len(s.challenges) >= s.max {
+		return "", ErrTooManyPasskeyChallenges
+	}
+	s.challenges[challenge] = passkeyChallenge{purpose: purpose, userID: userID, expires: now.Add(s.ttl)}
+	return challenge, nil
+}
+
+// Consume forgets an unexpired challenge for the given purpose and returns
+// the user it was created for. Each challenge can be answered only once.
+func (s *PasskeyChallengeStore) Consume(purpose, challenge string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.challenges[challenge]
+	if !ok || existing.purpose != purpose {
+		return 0, false
+	}
+	delete(s.challenges, challenge)
+	if time.Now().After(existing.expires) {
+		return 0, false
+	}
+	return existing.userID, true
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPasskeyChallengeStoreFull(t *testing.T) {
+	store := NewPasskeyChallengeStore(time.Minute, 2)
+	first, err := store.Create(PasskeyLogin, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(PasskeyLogin, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(PasskeyLogin, 0); !errors.Is(err, ErrTooManyPasskeyChallenges) {
+		t.Fatalf("Create() on a full store error = %v, want %v", err, ErrTooManyPasskeyChallenges)
+	}
+
+	// Answering a challenge makes room for another
+	if _, ok := store.Consume(PasskeyLogin, first); !ok {
+		t.Fatal("Consume() of a pending challenge failed")
+	}
+	if _, err := store.Create(PasskeyLogin, 0); err != nil {
+		t.Fatalf("Create() after Consume() error = %v", err)
+	}
+}
+
+func TestPasskeyChallengeStoreForgetsExpired(t *testing.T) {
+	store := NewPasskeyChallengeStore(time.Millisecond, 1)
+	if _, err := store.Create(PasskeyLogin, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := store.Create(PasskeyLogin, 0); err != nil {
+		t.Fatalf("Create() after expiry error = %v", err)
+	}
+}
+
+func TestPasskeyChallengeStoreConsume(t *testing.T) {
+	store := NewPasskeyChallengeStore(time.Minute, 10)
+	challenge, err := store.Create(PasskeyRegistration, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Consume(PasskeyLogin, challenge); ok {
+		t.Error("Consume() accepted a challenge for another purpose")
+	}
+	if userID, ok := store.Consume(PasskeyRegistration, challenge); !ok || userID != 7 {
+		t.Errorf("Consume() = %d, %v, want 7, true", userID, ok)
+	}
+	if _, ok := store.Consume(PasskeyRegistration, challenge); ok {
+		t.Error("Consume() accepted a challenge twice")
+	}
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nbutton23/zxcvbn-go"
+)
+
+// maxPasswordBytes is the most bcrypt hashes; longer passwords are refused
+// rather than silently truncated.
+const maxPasswordBytes = 72
+
+// Character classes a password policy may require.
+const (
+	PasswordClassLower  = "lower"
+	PasswordClassUpper  = "upper"
+	PasswordClassDigit  = "digit"
+	PasswordClassSymbol = "symbol"
+)
+
+// commonPasswords are refused by every policy, compared case-insensitively.
+var commonPasswords = []string{
+	"123456", "123456789", "12345678", "1234567890", "password", "password1",
+	"password123", "qwerty", "qwerty123", "qwertyuiop", "abc123", "111111",
+	"123123", "1q2w3e4r", "1qaz2wsx", "admin", "admin123", "letmein",
+	"welcome", "welcome1", "iloveyou", "monkey", "dragon", "football",
+	"baseball", "sunshine", "princess", "master", "shadow", "superman",
+	"trustno1", "passw0rd", "p@ssw0rd", "p@ssword", "changeme", "secret",
+	"starwars", "whatever", "zaq12wsx", "asdfghjkl", "login", "default",
+}
+
+// PasswordPolicy describes the passwords users may choose.
+type PasswordPolicy struct {
+	// MinLength is counted in characters.
+	MinLength int
+	// RequiredClasses lists the character classes, e.g. PasswordClassDigit,
+	// of which a password needs at least one character each.
+	RequiredClasses []string
+	// MinScore is the lowest zxcvbn strength estimate accepted, from 0 to
+	// 4. Zero skips the estimate.
+	MinScore int
+
+	banned map[string]bool
+}
+
+// NewPasswordPolicy returns a policy refusing common passwords and the
+// given banned ones, compared case-insensitively.
+func NewPasswordPolicy(minLength int, requiredClasses []string, minScore int, banned []string) *PasswordPolicy {
+	p := &PasswordPolicy{
+		MinLength:       minLength,
+		RequiredClasses: requiredClasses,
+		MinScore:        minScore,
+		banned:          make(map[string]bool, len(commonPasswords)+len(banned)),
+	}
+	for _, list := range [][]string{commonPasswords, banned} {
+		for _, password := range list {
+			p.banned[strings.ToLower(password)] = true
+		}
+	}
+	return p
+}
+
+// PasswordPolicyError lists the ways a password breaks the policy.
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Violations, "; ")
+}
+
+// Validate checks password against the policy and returns a
+// *PasswordPolicyError if it breaks it. userInputs, such as the user's
+// name and email, make passwords built from them score lower.
+func (p *PasswordPolicy) Validate(password string, userInputs ...string) error {
+	var violations []string
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	if len(password) > maxPasswordBytes {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", maxPasswordBytes))
+	}
+
+	for _, name := range p.RequiredClasses {
+		if class, ok := passwordClasses[name]; ok && !strings.ContainsFunc(password, class.matches) {
+			violations = append(violations, "must contain "+class.description)
+		}
+	}
+
+	if p.banned[strings.ToLower(password)] {
+		violations = append(violations, "is too common")
+	} else if p.MinScore > 0 && password != "" && len(password) <= maxPasswordBytes {
+		if zxcvbn.PasswordStrength(password, userInputs).Score < p.MinScore {
+			violations = append(violations, "is too easy to guess")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// passwordClass is a character class a password may be required to use.
+type passwordClass struct {
+	description string
+	matches     func(rune) bool
+}
+
+var passwordClasses = map[string]passwordClass{
+	PasswordClassLower:  {"a lowercase letter", unicode.IsLower},
+	PasswordClassUpper:  {"an uppercase letter", unicode.IsUpper},
+	PasswordClassDigit:  {"a digit", unicode.IsDigit},
+	PasswordClassSymbol: {"a symbol", func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) }},
+}
@@ -0,0 +1,28 @@
+package utils
+
+import "strings"
+
+// NormalizePhone reduces a phone number to the E.164 style "+<digits>" so
+// that differently formatted copies of one number compare equal: spaces,
+// dashes, dots and parentheses are dropped and an international "00"
+// prefix becomes "+". Numbers without a country code keep their digits
+// only, since the country cannot be inferred.
+func NormalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	international := strings.HasPrefix(phone, "+")
+
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	normalized := digits.String()
+	if strings.HasPrefix(normalized, "00") && !international {
+		normalized, international = normalized[2:], true
+	}
+	if international && normalized != "" {
+		return "+" + normalized
+	}
+	return normalized
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiterPruneInterval is how often idle keys are forgotten.
+const rateLimiterPruneInterval = time.Minute
+
+// RateLimiter limits events per key, e.g. per client IP, with a token
+// bucket for each key. Keys whose bucket has refilled are forgotten
+// periodically, so memory is bounded by the recently active keys.
+type RateLimiter struct {
+	mu         sync.Mutex
+	rate       float64
+	burst      int
+	buckets    map[string]*tokenBucket
+	lastPruned time.Time
+}
+
+// NewRateLimiter allows each key ratePerMinute events, with bursts of up to
+// burst events.
+func NewRateLimiter(ratePerMinute float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:       ratePerMinute / 60,
+		burst:      burst,
+		buckets:    make(map[string]*tokenBucket),
+		lastPruned: time.Now(),
+	}
+}
+
+// Allow records an event for key and reports whether it is within the limit.
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	if time.Since(l.lastPruned) > rateLimiterPruneInterval {
+		for k, bucket := range l.buckets {
+			if bucket.full() {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastPruned = time.Now()
+	}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = newTokenBucket(l.rate, l.burst)
+		l.buckets[key] = bucket
+	}
+	l.mu.Unlock()
+
+	return bucket.take()
+}
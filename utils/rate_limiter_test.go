@@ -0,0 +1,18 @@
+package utils
+
+import "testing"
+
+func TestRateLimiterPerKey(t *testing.T) {
+	limiter := NewRateLimiter(1, 2)
+	for i := 0; i < 2; i++ {
+		if !limiter.Allow("192.0.2.1") {
+			t.Fatalf("event %d was limited within the burst", i+1)
+		}
+	}
+	if limiter.Allow("192.0.2.1") {
+		t.Error("event beyond the burst was allowed")
+	}
+	if !limiter.Allow("192.0.2.2") {
+		t.Error("another key was limited")
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// ResetSession holds a verified password reset token on the server, so the
+// token need not travel with the form that sets the new password.
+type ResetSession struct {
+	Token     string
+	CSRFToken string
+	Expires   time.Time
+}
+
+// ResetSessionStore keeps reset sessions in memory for a short time. Expired
+// sessions are forgotten whenever a new one is created.
+type ResetSessionStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]ResetSession
+}
+
+// NewResetSessionStore returns a store whose sessions last ttl.
+func NewResetSessionStore(ttl time.Duration) *ResetSessionStore {
+	return &ResetSessionStore{ttl: ttl, sessions: make(map[string]ResetSession)}
+}
+
+// TTL returns the lifetime of a session.
+func (s *ResetSessionStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Create stores a session for a verified reset token and returns its id,
+// to be kept in a cookie, with a fresh CSRF token for the form.
+func (s *ResetSessionStore) Create(resetToken string) (string, ResetSession, error) {
+	id, err := randomToken()
+	if err != nil {
+		return "", ResetSession{}, err
+	}
+	csrfToken, err := randomToken()
+	if err != nil {
+		return "", ResetSession{}, err
+	}
+	session := ResetSession{Token: resetToken, CSRFToken: csrfToken, Expires: time.Now().Add(s.ttl)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, existing := range s.sessions {
+		if now.After(existing.Expires) {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[id] = session
+	return id, session, nil
+}
+
+// Get returns the unexpired session with the given id.
+func (s *ResetSessionStore) Get(id string) (ResetSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || time.Now().After(session.Expires) {
+		return ResetSession{}, false
+	}
+	return session, true
+}
+
+// Delete ends a session, e.g. once its form has been used.
+func (s *ResetSessionStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/vikash-parashar/asset-locator/models"
+)
+
+// ErrSAMLInvalidResponse is returned, wrapped, for a SAML response that does
+// not verify or does not answer a request of this server.
+var ErrSAMLInvalidResponse = errors.New("SAML response is invalid")
+
+// The attributes IdPs commonly put the user's details in, by their LDAP
+// names, OIDs and the claim URIs of AD FS and Azure AD. They are tried in
+// order.
+var (
+	samlEmailAttributes = []string{
+		"email",
+		"mail",
+		"urn:oid:0.9.2342.19200300.100.1.3",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+	}
+	samlFirstNameAttributes = []string{
+		"givenName",
+		"firstName",
+		"urn:oid:2.5.4.42",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname",
+	}
+	samlLastNameAttributes = []string{
+		"sn",
+		"surname",
+		"lastName",
+		"urn:oid:2.5.4.4",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname",
+	}
+)
+
+// SAMLOptions configure this server as a SAML service provider.
+type SAMLOptions struct {
+	// BaseURL is the public URL of this server; the metadata is served at
+	// /saml/metadata and responses are posted to /saml/acs below it.
+	BaseURL string
+	// EntityID defaults to the metadata URL.
+	EntityID string
+	// The IdP is described by the metadata at IDPMetadataURL, fetched on
+	// first use, or by IDPMetadata.
+	IDPMetadataURL string
+	IDPMetadata    []byte
+	// Key and Certificate, if set, sign authentication requests and
+	// decrypt encrypted assertions.
+	Key         crypto.Signer
+	Certificate *x509.Certificate
+
+	// EmailAttribute names the attribute holding the user's email, by Name
+	// or FriendlyName. If it is empty the common email attributes are
+	// tried, then a NameID that is an email.
+	EmailAttribute string
+	// RoleAttribute names the attribute holding the user's roles or
+	// groups. Users with one of AdminRoles, compared case-insensitively,
+	// are registered as admins and all others as general users. Roles are left alone if
+	// RoleAttribute is empty.
+	RoleAttribute string
+	AdminRoles    []string
+}
+
+// SAMLServiceProvider signs users in with a SAML 2.0 identity provider.
+type SAMLServiceProvider struct {
+	options SAMLOptions
+
+	mu sync.Mutex
+	sp saml.ServiceProvider
+}
+
+// NewSAMLServiceProvider returns the service provider described by options.
+func NewSAMLServiceProvider(options SAMLOptions) (*SAMLServiceProvider, error) {
+	base, err := url.Parse(strings.TrimSuffix(options.BaseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	s := &SAMLServiceProvider{options: options}
+	s.sp = saml.ServiceProvider{
+		EntityID:    options.EntityID,
+		Key:         options.Key,
+		Certificate: options.Certificate,
+		MetadataURL: *base.JoinPath("saml", "metadata"),
+		AcsURL:      *base.JoinPath("saml", "acs"),
+		HTTPClient:  oauthClient,
+	}
+	if options.Key != nil {
+		s.sp.SignatureMethod = signatureMethod(options.Key)
+	}
+	if len(options.IDPMetadata) > 0 {
+		if s.sp.IDPMetadata, err = samlsp.ParseMetadata(options.IDPMetadata); err != nil {
+			return nil, fmt.Errorf("invalid SAML IdP metadata: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Metadata returns the service provider metadata to register with the IdP.
+func (s *SAMLServiceProvider) Metadata() ([]byte, error) {
+	metadata, err := xml.MarshalIndent(s.sp.Metadata(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), metadata...), nil
+}
+
+// AuthnRequestURL returns the IdP URL that starts a login, and the id of
+// the request, which the response must answer.
+func (s *SAMLServiceProvider) AuthnRequestURL(ctx context.Context) (redirectURL, requestID string, err error) {
+	sp, err := s.serviceProvider(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	req, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", "", err
+	}
+	u, err := req.Redirect("", sp)
+	if err != nil {
+		return "", "", err
+	}
+	return u.String(), req.ID, nil
+}
+
+// ParseResponse verifies the SAML response posted to the ACS, which must
+// answer the request with the given id, and maps its assertion onto a
+// profile. r's form must have been parsed.
+func (s *SAMLServiceProvider) ParseResponse(r *http.Request, requestID string) (OAuthProfile, error) {
+	sp, err := s.serviceProvider(r.Context())
+	if err != nil {
+		return OAuthProfile{}, err
+	}
+	assertion, err := sp.ParseResponse(r, []string{requestID})
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+		return OAuthProfile{}, fmt.Errorf("%w: %v", ErrSAMLInvalidResponse, err)
+	}
+
+	attributes := make(map[string][]string)
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			for _, value := range attribute.Values {
+				attributes[attribute.Name] = append(attributes[attribute.Name], value.Value)
+				if attribute.FriendlyName != "" {
+					attributes[attribute.FriendlyName] = append(attributes[attribute.FriendlyName], value.Value)
+				}
+			}
+		}
+	}
+	first := func(names ...string) string {
+		for _, name := range names {
+			if values := attributes[name]; len(values) > 0 && values[0] != "" {
+				return values[0]
+			}
+		}
+		return ""
+	}
+
+	profile := OAuthProfile{
+		FirstName: first(samlFirstNameAttributes...),
+		LastName:  first(samlLastNameAttributes...),
+	}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		profile.Subject = assertion.Subject.NameID.Value
+	}
+	if profile.Subject == "" {
+		return OAuthProfile{}, fmt.Errorf("%w: no NameID", ErrSAMLInvalidResponse)
+	}
+
+	if s.options.EmailAttribute != "" {
+		profile.Email = first(s.options.EmailAttribute)
+	} else {
+		profile.Email = first(samlEmailAttributes...)
+		if profile.Email == "" && strings.Contains(profile.Subject, "@") {
+			profile.Email = profile.Subject
+		}
+	}
+	if profile.Email == "" {
+		return OAuthProfile{}, ErrOAuthNoVerifiedEmail
+	}
+	// The assertion is signed by the IdP the admin configured, which
+	// manages its users' addresses
+	profile.EmailVerified = true
+
+	if s.options.RoleAttribute != "" {
+		profile.Role = models.UserRoleGeneral
+		for _, role := range attributes[s.options.RoleAttribute] {
+			for _, adminRole := range s.options.AdminRoles {
+				if strings.EqualFold(role, adminRole) {
+					profile.Role = models.UserRoleAdmin
+				}
+			}
+		}
+	}
+	return profile, nil
+}
+
+// serviceProvider returns the service provider once the IdP metadata is
+// known, fetching it on first use.
+func (s *SAMLServiceProvider) serviceProvider(ctx context.Context) (*saml.ServiceProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sp.IDPMetadata == nil {
+		metadataURL, err := url.Parse(s.options.IDPMetadataURL)
+		if err != nil {
+			return nil, err
+		}
+		metadata, err := samlsp.FetchMetadata(ctx, oauthClient, *metadataURL)
+		if err != nil {
+			return nil, fmt.Errorf("fetching the SAML IdP metadata failed: %w", err)
+		}
+		s.sp.IDPMetadata = metadata
+	}
+	return &s.sp, nil
+}
+
+// signatureMethod returns the SHA-256 signature method for key.
+func signatureMethod(key crypto.Signer) string {
+	if _, ok := key.Public().(*ecdsa.PublicKey); ok {
+		return "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	}
+	return "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+}
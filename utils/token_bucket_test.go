@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketLimitsBurst(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	bucket := newTokenBucket(2, 3)
+	bucket.last = start
+	bucket.now = func() time.Time { return now }
+	bucket.sleep = func(d time.Duration) { now = now.Add(d) }
+
+	// 3 sends use up the burst, the other 8 are spread out at 2 per second.
+	var delayed int
+	for i := 0; i < 11; i++ {
+		if bucket.Wait() > 0 {
+			delayed++
+		}
+	}
+	if delayed != 8 {
+		t.Errorf("%d sends delayed, want 8", delayed)
+	}
+	if elapsed := now.Sub(start); elapsed != 4*time.Second {
+		t.Errorf("11 sends took %s, want 4s", elapsed)
+	}
+	if bucket.full() {
+		t.Error("bucket is full right after a burst")
+	}
+
+	now = now.Add(2 * time.Second)
+	if !bucket.full() {
+		t.Error("bucket has not refilled after an idle period")
+	}
+	if !bucket.take() {
+		t.Error("take() failed on a full bucket")
+	}
+}
@@ -0,0 +1,299 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ugorji/go/codec"
+)
+
+// ErrPasskeyInvalid is returned, wrapped, for a passkey registration or
+// login response that does not verify.
+var ErrPasskeyInvalid = errors.New("passkey response is invalid")
+
+// COSE algorithms accepted for passkeys, in order of preference.
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+// PasskeyAlgorithms lists the COSE algorithms offered to authenticators.
+var PasskeyAlgorithms = []int{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+
+// Authenticator data flags.
+const (
+	authFlagUserPresent        = 0x01
+	authFlagUserVerified       = 0x04
+	authFlagAttestedCredential = 0x40
+)
+
+// RelyingParty identifies this server to WebAuthn authenticators. ID is the
+// domain passkeys are bound to, and Origins the origins browsers may report
+// for a ceremony.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// PasskeyCredential is a credential created by an authenticator during
+// registration.
+type PasskeyCredential struct {
+	ID []byte
+	// PublicKey is the COSE encoded credential public key.
+	PublicKey []byte
+	SignCount uint32
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+// PasskeyChallenge returns the challenge echoed in the clientDataJSON of a
+// registration or login response, to look up what it answers.
+func PasskeyChallenge(clientDataJSON []byte) (string, error) {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil || data.Challenge == "" {
+		return "", fmt.Errorf("%w: malformed client data", ErrPasskeyInvalid)
+	}
+	return data.Challenge, nil
+}
+
+// VerifyRegistration checks the response to a registration challenge and
+// returns the new credential. Attestation statements are not verified, as
+// "none" attestation is requested: the credential is trusted because the
+// signed-in user registered it, not because of its make.
+func (rp RelyingParty) VerifyRegistration(clientDataJSON, attestationObject []byte, challenge string) (PasskeyCredential, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return PasskeyCredential{}, err
+	}
+
+	var attestation struct {
+		Format   string `codec:"fmt"`
+		AuthData []byte `codec:"authData"`
+	}
+	if err := codec.NewDecoderBytes(attestationObject, new(codec.CborHandle)).Decode(&attestation); err != nil {
+		return PasskeyCredential{}, fmt.Errorf("%w: malformed attestation object", ErrPasskeyInvalid)
+	}
+	authData, err := parseAuthenticatorData(attestation.AuthData)
+	if err != nil {
+		return PasskeyCredential{}, err
+	}
+	if err := rp.verifyAuthenticatorData(authData); err != nil {
+		return PasskeyCredential{}, err
+	}
+	if authData.credentialID == nil {
+		return PasskeyCredential{}, fmt.Errorf("%w: no credential was created", ErrPasskeyInvalid)
+	}
+	if _, err := parseCOSEKey(authData.publicKey); err != nil {
+		return PasskeyCredential{}, err
+	}
+
+	return PasskeyCredential{
+		ID:        authData.credentialID,
+		PublicKey: authData.publicKey,
+		SignCount: authData.signCount,
+	}, nil
+}
+
+// VerifyAssertion checks the response to a login challenge against the
+// stored public key of the credential used, and returns the authenticator's
+// new signature counter.
+func (rp RelyingParty) VerifyAssertion(publicKey, clientDataJSON, rawAuthData, signature []byte, challenge string) (uint32, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.verifyAuthenticatorData(authData); err != nil {
+		return 0, err
+	}
+
+	key, err := parseCOSEKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	if !verifySignature(key, signed, signature) {
+		return 0, fmt.Errorf("%w: bad signature", ErrPasskeyInvalid)
+	}
+	return authData.signCount, nil
+}
+
+func (rp RelyingParty) verifyClientData(raw []byte, ceremony, challenge string) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("%w: malformed client data", ErrPasskeyInvalid)
+	}
+	if data.Type != ceremony {
+		return fmt.Errorf("%w: unexpected ceremony %q", ErrPasskeyInvalid, data.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrPasskeyInvalid)
+	}
+	for _, origin := range rp.Origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unexpected origin %q", ErrPasskeyInvalid, data.Origin)
+}
+
+// verifyAuthenticatorData checks that the authenticator signed for this
+// relying party and verified the user, as a passkey replaces the password.
+func (rp RelyingParty) verifyAuthenticatorData(authData authenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return fmt.Errorf("%w: relying party mismatch", ErrPasskeyInvalid)
+	}
+	if authData.flags&authFlagUserPresent == 0 || authData.flags&authFlagUserVerified == 0 {
+		return fmt.Errorf("%w: user was not verified", ErrPasskeyInvalid)
+	}
+	return nil
+}
+
+// parseAuthenticatorData splits the binary authenticator data. The attested
+// credential, present after registration, is followed by a single CBOR item
+// holding its public key.
+func parseAuthenticatorData(b []byte) (authenticatorData, error) {
+	malformed := fmt.Errorf("%w: malformed authenticator data", ErrPasskeyInvalid)
+	if len(b) < 37 {
+		return authenticatorData{}, malformed
+	}
+	authData := authenticatorData{
+		rpIDHash:  b[:32],
+		flags:     b[32],
+		signCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	if authData.flags&authFlagAttestedCredential == 0 {
+		return authData, nil
+	}
+
+	rest := b[37:]
+	if len(rest) < 18 {
+		return authenticatorData{}, malformed
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || len(rest) < idLen {
+		return authenticatorData{}, malformed
+	}
+	authData.credentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	var key map[int]interface{}
+	decoder := codec.NewDecoderBytes(rest, new(codec.CborHandle))
+	if err := decoder.Decode(&key); err != nil {
+		return authenticatorData{}, malformed
+	}
+	authData.publicKey = rest[:decoder.NumBytesRead()]
+	return authData, nil
+}
+
+// COSE key parameters.
+const (
+	coseKeyType    = 1
+	coseKeyAlg     = 3
+	coseKeyCrv     = -1
+	coseKeyX       = -2
+	coseKeyY       = -3
+	coseKeyRSAN    = -1
+	coseKeyRSAE    = -2
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+// parseCOSEKey decodes an ES256, EdDSA (Ed25519) or RS256 public key.
+func parseCOSEKey(raw []byte) (crypto.PublicKey, error) {
+	var params map[int]interface{}
+	if err := codec.NewDecoderBytes(raw, new(codec.CborHandle)).Decode(&params); err != nil {
+		return nil, fmt.Errorf("%w: malformed public key", ErrPasskeyInvalid)
+	}
+	kty, _ := coseInt(params[coseKeyType])
+	alg, _ := coseInt(params[coseKeyAlg])
+	crv, _ := coseInt(params[coseKeyCrv])
+	unsupported := fmt.Errorf("%w: unsupported key type %d with algorithm %d", ErrPasskeyInvalid, kty, alg)
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == COSEAlgES256 && crv == coseCrvP256:
+		x, _ := params[coseKeyX].([]byte)
+		y, _ := params[coseKeyY].([]byte)
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if len(x) != 32 || len(y) != 32 || !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("%w: invalid P-256 public key", ErrPasskeyInvalid)
+		}
+		return key, nil
+	case kty == coseKeyTypeOKP && alg == COSEAlgEdDSA && crv == coseCrvEd25519:
+		x, _ := params[coseKeyX].([]byte)
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 public key", ErrPasskeyInvalid)
+		}
+		return ed25519.PublicKey(x), nil
+	case kty == coseKeyTypeRSA && alg == COSEAlgRS256:
+		n, _ := params[coseKeyRSAN].([]byte)
+		e, _ := params[coseKeyRSAE].([]byte)
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) < 256 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("%w: invalid RSA public key", ErrPasskeyInvalid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	}
+	return nil, unsupported
+}
+
+// verifySignature checks a signature made with the private half of key.
+func verifySignature(key crypto.PublicKey, data, signature []byte) bool {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+	return false
+}
+
+// coseInt returns a CBOR integer, which decodes as int64 or uint64.
+func coseInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case uint64:
+		if n > 1<<63-1 {
+			return 0, false
+		}
+		return int64(n), true
+	}
+	return 0, false
+}